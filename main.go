@@ -4,18 +4,26 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"time"
 
 	nested "github.com/antonfisher/nested-logrus-formatter"
 	"github.com/cnrancher/cce-operator/pkg/controller"
 	ccev1 "github.com/cnrancher/cce-operator/pkg/generated/controllers/cce.pandaria.io"
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/leader"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
 	"github.com/cnrancher/cce-operator/pkg/utils"
+	"github.com/cnrancher/cce-operator/pkg/webhook"
 	"github.com/rancher/wrangler/v2/pkg/generated/controllers/core"
 	"github.com/rancher/wrangler/v2/pkg/kubeconfig"
 	"github.com/rancher/wrangler/v2/pkg/signals"
 	"github.com/rancher/wrangler/v2/pkg/start"
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
 var (
@@ -23,8 +31,24 @@ var (
 	kubeconfigFile string
 	version        bool
 	debug          bool
+	metricsAddress string
+	webhookAddress string
+	webhookCertDir string
+	huaweiAPIQPS   float64
+	workers        int
+	orphanGC       controller.OrphanGCOptions
+	instanceID     string
+	namespace      string
+
+	leaderElect            bool
+	leaderElectionNS       string
+	leaderElectionLease    time.Duration
+	leaderElectionDeadline time.Duration
+	leaderElectionRetry    time.Duration
 )
 
+const leaderElectionName = "cce-operator"
+
 func init() {
 	logrus.SetFormatter(&nested.Formatter{
 		HideKeys:        true,
@@ -37,7 +61,45 @@ func init() {
 		"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
 	flag.BoolVar(&version, "version", false, "Show version.")
 	flag.BoolVar(&debug, "debug", false, "Enable the debug output.")
+	flag.StringVar(&metricsAddress, "metrics-address", "",
+		"The address to serve the Prometheus metrics on, such as ':8080'. Metrics are disabled if empty.")
+	flag.StringVar(&webhookAddress, "webhook-address", "",
+		"The address to serve the admission webhooks on, such as ':9443'. Webhooks are disabled if empty.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "/etc/cce-operator/webhook",
+		"The directory containing the tls.crt and tls.key files of the admission webhooks.")
+	flag.IntVar(&workers, "workers", 2,
+		"The number of the workers of each controller, the configs are reconciled concurrently by the workers.")
+	flag.StringVar(&controller.CredentialFileDir, "credential-file-dir", "",
+		"The directory of the credential files referenced by the credential secrets, such as a mounted projected volume. "+
+			"The credential files are not allowed if empty.")
+	flag.StringVar(&instanceID, "instance-id", "",
+		"The ID of the operator installation tagged to the created resources. Defaults to the UID of the kube-system namespace.")
+	flag.StringVar(&namespace, "namespace", "cattle-system",
+		"The namespace the operator runs in, the state of the operator such as the credential regions is stored in it.")
+	flag.DurationVar(&orphanGC.Interval, "orphan-gc-interval", 0,
+		"The interval to scan the orphan Huawei Cloud resources created by the operator. The scan is disabled if zero.")
+	flag.DurationVar(&orphanGC.GracePeriod, "orphan-gc-grace-period", time.Hour,
+		"The duration the resource is found orphaned before it is deleted.")
+	flag.BoolVar(&orphanGC.DryRun, "orphan-gc-dry-run", true,
+		"Only report the orphan resources in the logs and metrics without deleting them.")
+	flag.Float64Var(&huaweiAPIQPS, "huawei-api-qps", float64(ratelimit.QPS),
+		"The max QPS of the Huawei Cloud API requests per account and region.")
+	flag.IntVar(&ratelimit.Burst, "huawei-api-burst", ratelimit.Burst,
+		"The max burst of the Huawei Cloud API requests per account and region.")
+	flag.IntVar(&ratelimit.MaxRetries, "huawei-api-max-retries", ratelimit.MaxRetries,
+		"The max number of the retries of the throttled or failed Huawei Cloud API requests.")
+	flag.BoolVar(&leaderElect, "leader-elect", false,
+		"Enable the Lease based leader election, required when running multiple replicas.")
+	flag.StringVar(&leaderElectionNS, "leader-election-namespace", "cattle-system",
+		"The namespace of the leader election Lease.")
+	flag.DurationVar(&leaderElectionLease, "leader-election-lease-duration", leader.DefaultLeaseDuration,
+		"The duration that non-leader candidates will wait before forcing to acquire the leadership.")
+	flag.DurationVar(&leaderElectionDeadline, "leader-election-renew-deadline", leader.DefaultRenewDeadline,
+		"The duration that the leader will retry refreshing the leadership before giving up.")
+	flag.DurationVar(&leaderElectionRetry, "leader-election-retry-period", leader.DefaultRetryPeriod,
+		"The duration the candidates should wait between tries of the leader election.")
 	flag.Parse()
+	ratelimit.QPS = float32(huaweiAPIQPS)
 
 	if debug {
 		logrus.SetLevel(logrus.DebugLevel)
@@ -75,13 +137,49 @@ func main() {
 	// The typical pattern is to build all your controller/clients then just pass to each handler
 	// the bare minimum of what they need.  This will eventually help with writing tests.  So
 	// don't pass in something like kubeClient, apps, or sample
-	controller.Register(ctx,
+	client := kubernetes.NewForConfigOrDie(cfg)
+	if instanceID == "" {
+		// The installations in the same cloud account are identified by the
+		// Kubernetes cluster they run in.
+		ns, err := client.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+		if err != nil {
+			logrus.Fatalf("Error getting the operator instance ID: %v", err)
+		}
+		instanceID = string(ns.UID)
+	}
+	h := controller.Register(ctx,
 		core.Core().V1().Secret(),
-		cce.Cce().V1().CCEClusterConfig())
+		cce.Cce().V1().CCEClusterConfig(),
+		client.CoreV1(),
+		client.CoreV1().ConfigMaps(namespace),
+		instanceID)
+
+	if metricsAddress != "" {
+		metrics.Serve(ctx, metricsAddress)
+	}
+	// Admission webhooks are served by all replicas.
+	if webhookAddress != "" {
+		webhook.Serve(ctx, webhookAddress, webhookCertDir)
+	}
 
 	// Start all the controllers
-	if err := start.All(ctx, 2, cce, core); err != nil {
-		logrus.Fatalf("Error starting cce controller: %v", err)
+	run := func(ctx context.Context) {
+		if err := start.All(ctx, workers, cce, core); err != nil {
+			logrus.Fatalf("Error starting cce controller: %v", err)
+		}
+		go h.RunOrphanGC(ctx, orphanGC)
+	}
+	if leaderElect {
+		// Only the leader starts the controllers, this blocks until ctx is done.
+		leader.RunOrDie(ctx, client, leader.Config{
+			Namespace:     leaderElectionNS,
+			Name:          leaderElectionName,
+			LeaseDuration: leaderElectionLease,
+			RenewDeadline: leaderElectionDeadline,
+			RetryPeriod:   leaderElectionRetry,
+		}, run)
+	} else {
+		run(ctx)
 	}
 
 	<-ctx.Done()
@@ -18,6 +18,7 @@ package v1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // +genclient
@@ -59,6 +60,36 @@ type CCEClusterConfigSpec struct {
 	ExtendParam            CCEClusterExtendParam `json:"extendParam,omitempty"`
 	NodePools              []CCENodePool         `json:"nodePools"`
 
+	// NodePoolDeletionProtection configures the safeguards of deleting the
+	// upstream node pools which are removed from the spec.
+	NodePoolDeletionProtection CCENodePoolDeletionProtection `json:"nodePoolDeletionProtection,omitempty"`
+
+	// Addons are the CCE addons installed and managed by the operator.
+	Addons []CCEAddon `json:"addons,omitempty"`
+
+	// ClusterAutoscaler is the cluster level settings of the autoscaler addon,
+	// the addon is installed if any node pool enables autoscaling.
+	ClusterAutoscaler CCEClusterAutoscaler `json:"clusterAutoscaler,omitempty"`
+
+	// NodeOperations are the operations requested on the individual nodes,
+	// each operation is executed once and its outcome is reported in status.
+	NodeOperations []CCENodeOperation `json:"nodeOperations,omitempty"`
+
+	// UpgradeStrategy configures how the cluster is upgraded when the
+	// version is changed.
+	UpgradeStrategy CCEUpgradeStrategy `json:"upgradeStrategy,omitempty"`
+
+	// MaintenanceWindows are the weekly time ranges to perform the disruptive
+	// operations, such as upgrading the cluster and deleting the nodes.
+	// The disruptive operations are performed at any time if empty.
+	MaintenanceWindows []CCEMaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// DeletionPolicy decides the Huawei Cloud resources to delete when the
+	// config is removed: Delete (default), Retain or RetainNetwork.
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+	// DeletionProtection refuses to remove the config and its resources.
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+
 	// CreatedNodePoolIDs is a temporary map to store nodePool ID by nodePool name
 	// and let cce-operator-controller (in Rancher) to know that some nodePools were
 	// created by cce-operator and update its ID to CCE cluster config in Rancher.
@@ -83,8 +114,287 @@ type CCEClusterConfigStatus struct {
 
 	ResizeClusterJobID   string `json:"resizeClusterJobID"`   // resize cluster job ID
 	UpgradeClusterTaskID string `json:"upgradeClusterTaskID"` // upgrade cluster task ID
+
+	UpgradePreCheck *CCEUpgradePreCheck      `json:"upgradePreCheck,omitempty"` // pre-upgrade check of the target version
+	Upgrade         *CCEClusterUpgradeStatus `json:"upgrade,omitempty"`         // progress of upgrading the cluster over several versions
+
+	NodePools []CCENodePoolStatus `json:"nodePools,omitempty"` // upstream node pool status
+
+	NodePoolReplacements []CCENodePoolReplacement `json:"nodePoolReplacements,omitempty"` // node pools being replaced
+
+	NodeOperations []CCENodeOperationStatus `json:"nodeOperations,omitempty"` // outcome of the node operations in spec
+
+	UnhealthyNodes   []CCEUnhealthyNode   `json:"unhealthyNodes,omitempty"`   // unhealthy nodes of the node pools enabling the health policy
+	NodeRemediations []CCENodeRemediation `json:"nodeRemediations,omitempty"` // recent remediations of the unhealthy nodes
+
+	Addons []CCEAddonStatus `json:"addons,omitempty"` // status of the addons managed by the operator
+
+	Plan []CCEClusterOperation `json:"plan,omitempty"` // operations planned in dry-run mode
+
+	Maintenance *CCEMaintenanceStatus `json:"maintenance,omitempty"` // disruptive operations deferred to the maintenance window
+
+	Deletion *CCEDeletionStatus `json:"deletion,omitempty"` // progress of deleting the cluster resources
+
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"` // last reconciled generation
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Condition types of the CCEClusterConfig status.
+const (
+	// ConditionNetworkReady is true when the EIP, VPC, subnet and NAT gateway
+	// required by the cluster are configured.
+	ConditionNetworkReady = "NetworkReady"
+	// ConditionClusterProvisioned is true when the CCE cluster is available.
+	ConditionClusterProvisioned = "ClusterProvisioned"
+	// ConditionNodePoolsSynced is true when the upstream node pools match the spec.
+	ConditionNodePoolsSynced = "NodePoolsSynced"
+	// ConditionUpgradeInProgress is true when the cluster upgrade task is running.
+	ConditionUpgradeInProgress = "UpgradeInProgress"
+	// ConditionResizeInProgress is true when the cluster flavor is being resized.
+	ConditionResizeInProgress = "ResizeInProgress"
+	// ConditionDeleting is true when the cluster resources are being deleted.
+	ConditionDeleting = "Deleting"
+	// ConditionNodePoolDeletionBlocked is true when the upstream node pools
+	// removed from the spec are not deleted by the deletion protection.
+	ConditionNodePoolDeletionBlocked = "NodePoolDeletionBlocked"
+	// ConditionAddonsSynced is true when the addons match the config spec.
+	ConditionAddonsSynced = "AddonsSynced"
+	// ConditionClusterAutoscalerReady is true when the node pool autoscaling
+	// is enabled and the autoscaler addon is running.
+	ConditionClusterAutoscalerReady = "ClusterAutoscalerReady"
+	// ConditionNodesHealthy is true when the nodes of the node pools enabling
+	// the health policy are healthy.
+	ConditionNodesHealthy = "NodesHealthy"
+	// ConditionOperationsDeferred is true when the disruptive operations are
+	// deferred until the maintenance window opens.
+	ConditionOperationsDeferred = "OperationsDeferred"
+)
+
+// Deletion policies of the CCEClusterConfig.
+const (
+	// DeletionPolicyDelete deletes the cluster and the created network resources.
+	DeletionPolicyDelete = "Delete"
+	// DeletionPolicyRetain keeps the cluster and the network resources.
+	DeletionPolicyRetain = "Retain"
+	// DeletionPolicyRetainNetwork deletes the cluster but keeps the created
+	// VPC, subnet, NAT gateway and EIPs.
+	DeletionPolicyRetainNetwork = "RetainNetwork"
+)
+
+// CCEAddon is the CCE addon managed by the operator.
+type CCEAddon struct {
+	Name    string                `json:"name"`             // addon template name, such as coredns
+	Version string                `json:"version"`          // addon version, the latest version is installed if empty
+	Values  *runtime.RawExtension `json:"values,omitempty"` // addon values, merged into the upstream values when updating
+}
+
+// CCEClusterAutoscaler is the cluster level settings of the autoscaler addon.
+type CCEClusterAutoscaler struct {
+	Version                       string `json:"version"`                       // autoscaler addon version, the latest version is installed if empty
+	ScaleDownDelayAfterAdd        int32  `json:"scaleDownDelayAfterAdd"`        // minutes to wait before scaling down after scaling up
+	ScaleDownUnneededTime         int32  `json:"scaleDownUnneededTime"`         // minutes the node should be unneeded before it is scaled down
+	ScaleDownUtilizationThreshold string `json:"scaleDownUtilizationThreshold"` // node utilization below which the node can be scaled down, such as "0.5"
+	Expander                      string `json:"expander"`                      // node pool selecting strategy when scaling up, such as priority or least-waste
+}
+
+// CCEUpgradeStrategy is the settings of the cluster upgrade.
+type CCEUpgradeStrategy struct {
+	Type          string     `json:"type,omitempty"`          // upgrade strategy type, default inPlaceRollingUpdate
+	BatchSize     int32      `json:"batchSize,omitempty"`     // nodes upgraded in a batch, 1 to 40, default 20
+	NodePoolOrder []string   `json:"nodePoolOrder,omitempty"` // names of the node pools upgraded first, in order
+	Addons        []CCEAddon `json:"addons,omitempty"`        // addons upgraded alongside the cluster, the version is required
+	SkipPreCheck  bool       `json:"skipPreCheck,omitempty"`  // upgrade the cluster without the pre-upgrade check
+}
+
+// CCEUpgradePreCheck is the CCE pre-upgrade check of the cluster.
+type CCEUpgradePreCheck struct {
+	TaskID        string      `json:"taskID"`
+	TargetVersion string      `json:"targetVersion"`
+	Phase         string      `json:"phase"`     // Init, Running, Success, Failed or Error
+	Message       string      `json:"message"`   // the failed check items
+	StartTime     metav1.Time `json:"startTime"` // the check is started again if failed for a while
+}
+
+// CCEClusterUpgradeStatus is the progress of upgrading the cluster to the spec
+// version, the cluster is upgraded to each hop of the upgrade path in order.
+type CCEClusterUpgradeStatus struct {
+	TargetVersion string   `json:"targetVersion"`           // spec version
+	CurrentHop    string   `json:"currentHop"`              // version the cluster is being upgraded to
+	RemainingHops []string `json:"remainingHops,omitempty"` // versions upgraded to after the current hop
+	TaskIDs       []string `json:"taskIDs,omitempty"`       // upgrade task IDs of the hops
+}
+
+// Upgrade strategy types of the cluster upgrade.
+const (
+	UpgradeStrategyInPlaceRollingUpdate = "inPlaceRollingUpdate"
+)
+
+// CCEAddonStatus is the upstream status of the addon managed by the operator.
+type CCEAddonStatus struct {
+	Name    string `json:"name"`
+	ID      string `json:"addonID"`
+	Version string `json:"version"`           // current upstream version
+	Status  string `json:"status"`            // CCE addon instance status, such as running
+	Message string `json:"message"`           // failure message of the addon instance
+	Drift   string `json:"drift"`             // differences between the upstream addon and the spec
+	Created bool   `json:"created,omitempty"` // the addon instance was installed by the operator
+}
+
+// CCENodePoolDeletionProtection is the safeguards of deleting the node pools.
+type CCENodePoolDeletionProtection struct {
+	Enabled                      bool     `json:"enabled"`                      // block the deletion of all node pools
+	ProtectedNodePools           []string `json:"protectedNodePools,omitempty"` // names or IDs of the node pools cannot be deleted
+	MaxDeletionsPerReconcile     int32    `json:"maxDeletionsPerReconcile"`     // maximum node pools deleted per reconcile, 0 is unlimited
+	SkipRunningNodesConfirmation bool     `json:"skipRunningNodesConfirmation"` // delete the node pools with running nodes without the confirmation annotation
+}
+
+// CCEMaintenanceWindow is a weekly time range to perform the disruptive
+// operations, the window ends on the next day if the end time is not after
+// the start time.
+type CCEMaintenanceWindow struct {
+	Days      []string `json:"days,omitempty"`     // weekdays the window starts, such as Sat or Saturday, every day if empty
+	StartTime string   `json:"startTime"`          // start time in HH:MM, such as 22:00
+	EndTime   string   `json:"endTime"`            // end time in HH:MM, such as 06:00
+	TimeZone  string   `json:"timeZone,omitempty"` // IANA time zone, such as Asia/Shanghai, default UTC
+}
+
+// CCEMaintenanceStatus is the maintenance window status of the cluster.
+type CCEMaintenanceStatus struct {
+	InWindow          bool                  `json:"inWindow"`                    // the maintenance window is open
+	NextWindow        metav1.Time           `json:"nextWindow"`                  // start time of the next maintenance window
+	PendingOperations []CCEClusterOperation `json:"pendingOperations,omitempty"` // disruptive operations deferred to the next window
+}
+
+// CCEDeletionStatus is the progress of deleting the cluster resources, the
+// deletion resumes from the stage after the operator restarts.
+type CCEDeletionStatus struct {
+	Stage              string      `json:"stage"`              // stage being deleted, such as DeletingCluster
+	StartTime          metav1.Time `json:"startTime"`          // time the deletion started
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"` // time the current stage started
+}
+
+// Stages of deleting the cluster resources, in order.
+const (
+	DeletionStageDrainingNodes      = "DrainingNodes"
+	DeletionStageDeletingCluster    = "DeletingCluster"
+	DeletionStageDeletingNatGateway = "DeletingNatGateway"
+	DeletionStageDeletingEIPs       = "DeletingEIPs"
+	DeletionStageDeletingSubnet     = "DeletingSubnet"
+	DeletionStageDeletingVpcep      = "DeletingVpcEndpointService"
+	DeletionStageDeletingVPC        = "DeletingVPC"
+)
+
+// CCEClusterOperation is a Huawei Cloud operation planned in dry-run mode.
+type CCEClusterOperation struct {
+	Operation string `json:"operation"` // Huawei Cloud API, such as CreateNodePool
+	Resource  string `json:"resource"`  // cluster, nodePool, vpc, subnet, publicIP, natGateway or snatRule
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	Current   string `json:"current"` // current upstream value
+	Desired   string `json:"desired"` // desired value in spec
+}
+
+// CCENodePoolStatus is the status of the node pool in CCE cluster.
+type CCENodePoolStatus struct {
+	Name         string   `json:"name"`
+	ID           string   `json:"nodePoolID"`
+	Phase        string   `json:"phase"`           // CCE node pool phase, empty if the node pool is available
+	CurrentNodes int32    `json:"currentNodes"`    // node count excluding the deleting nodes
+	DesiredNodes int32    `json:"desiredNodes"`    // target node count of the node pool
+	JobID        string   `json:"jobID"`           // job ID of the latest node pool operation
+	LastError    string   `json:"lastError"`       // latest error reported by the node pool conditions
+	Drift        string   `json:"drift,omitempty"` // immutable node template fields different from the spec
+	Nodes        []string `json:"nodes,omitempty"`
+}
+
+// CCENodePoolReplacement is the progress of the blue/green replacement of a
+// node pool whose immutable node template fields were changed.
+type CCENodePoolReplacement struct {
+	Name    string `json:"name"`                    // node pool name in spec
+	OldID   string `json:"oldNodePoolID"`           // ID of the node pool being replaced
+	NewName string `json:"newName,omitempty"`       // name of the successor node pool
+	NewID   string `json:"newNodePoolID,omitempty"` // ID of the successor node pool
+	Phase   string `json:"phase"`                   // Blocked, Creating, Draining, Deleting or Completed
+	Reason  string `json:"reason,omitempty"`        // changed immutable fields
+	Message string `json:"message,omitempty"`       // latest progress of the replacement
+}
+
+// Phases of the node pool replacement.
+const (
+	NodePoolReplacementBlocked   = "Blocked"
+	NodePoolReplacementCreating  = "Creating"
+	NodePoolReplacementDraining  = "Draining"
+	NodePoolReplacementDeleting  = "Deleting"
+	NodePoolReplacementCompleted = "Completed"
+)
+
+// CCENodeOperation is an operation requested on a node of the cluster.
+type CCENodeOperation struct {
+	Node   string `json:"node"`   // name, ID or private IP of the CCE node
+	Action string `json:"action"` // Replace, Delete or Reboot
+}
+
+// CCENodeOperationStatus is the progress of the node operation.
+type CCENodeOperationStatus struct {
+	Node       string `json:"node"`                 // node in the operation spec
+	Action     string `json:"action"`               // Replace, Delete or Reboot
+	NodeID     string `json:"nodeID,omitempty"`     // ID of the CCE node
+	NodePoolID string `json:"nodePoolID,omitempty"` // ID of the node pool of the node
+	JobID      string `json:"jobID,omitempty"`      // job ID of the server reboot
+	Phase      string `json:"phase"`                // Draining, Deleting, Recreating, Rebooting, Succeeded or Failed
+	Message    string `json:"message,omitempty"`    // latest progress or failure of the operation
+}
+
+// Actions of the node operation.
+const (
+	// NodeActionReplace drains and deletes the node, the node pool creates a
+	// new node to keep the node count.
+	NodeActionReplace = "Replace"
+	// NodeActionDelete drains and deletes the node.
+	NodeActionDelete = "Delete"
+	// NodeActionReboot drains and reboots the server of the node, the node
+	// is uncordoned after the reboot.
+	NodeActionReboot = "Reboot"
+)
+
+// Phases of the node operation.
+const (
+	NodeOperationDraining   = "Draining"
+	NodeOperationDeleting   = "Deleting"
+	NodeOperationRecreating = "Recreating"
+	NodeOperationRebooting  = "Rebooting"
+	NodeOperationSucceeded  = "Succeeded"
+	NodeOperationFailed     = "Failed"
+)
+
+// CCEUnhealthyNode is a node found unhealthy by the node health policy.
+type CCEUnhealthyNode struct {
+	NodeID     string      `json:"nodeID"`
+	Name       string      `json:"name"`
+	NodePoolID string      `json:"nodePoolID"`
+	Reason     string      `json:"reason"` // CCE node phase or Kubernetes node condition
+	Since      metav1.Time `json:"since"`  // time the node was first found unhealthy
 }
 
+// CCENodeRemediation is the remediation of an unhealthy node, the node is
+// drained and deleted and the node pool creates a new node.
+type CCENodeRemediation struct {
+	NodeID     string      `json:"nodeID"`
+	Name       string      `json:"name"`
+	NodePoolID string      `json:"nodePoolID"`
+	Reason     string      `json:"reason"`            // reason the node was unhealthy
+	Phase      string      `json:"phase"`             // Draining, Deleting or Succeeded
+	StartTime  metav1.Time `json:"startTime"`         // time the remediation started
+	Message    string      `json:"message,omitempty"` // latest progress of the remediation
+}
+
+// Phases of the node remediation.
+const (
+	NodeRemediationDraining  = "Draining"
+	NodeRemediationDeleting  = "Deleting"
+	NodeRemediationSucceeded = "Succeeded"
+)
+
 type CCEHostNetwork struct {
 	VpcID         string `json:"vpcID"`
 	SubnetID      string `json:"subnetID"`
@@ -121,19 +431,42 @@ type CCENodePool struct {
 	Autoscaling          CCENodePoolNodeAutoscaling `json:"autoscaling"`
 	PodSecurityGroups    []string                   `json:"podSecurityGroups"`
 	CustomSecurityGroups []string                   `json:"customSecurityGroups"` // 节点池自定义安全组相关配置，未指定安全组ID，新建节点将添加 Node 节点默认安全组。
+
+	// HealthPolicy enables the remediation of the unhealthy nodes.
+	HealthPolicy CCENodeHealthPolicy `json:"healthPolicy,omitempty"`
+}
+
+// CCENodeHealthPolicy configures the automatic remediation of the unhealthy
+// nodes of the node pool.
+type CCENodeHealthPolicy struct {
+	Enabled                bool  `json:"enabled"`
+	UnhealthyTimeout       int32 `json:"unhealthyTimeout"`       // minutes the node stays unhealthy before it is remediated, default 10
+	MaxUnhealthyPercentage int32 `json:"maxUnhealthyPercentage"` // remediation is paused if more nodes of the node pool are unhealthy, 0 is unlimited
 }
 
 type CCENodeTemplate struct {
-	Flavor          string             `json:"flavor"`          // 节点池规格
-	AvailableZone   string             `json:"availableZone"`   // 可用区
-	OperatingSystem string             `json:"operatingSystem"` // 节点操作系统
-	SSHKey          string             `json:"sshKey"`          // SSH 密钥名称（不支持帐号密码登录）
-	RootVolume      CCENodeVolume      `json:"rootVolume"`      // 节点的系统盘
-	DataVolumes     []CCENodeVolume    `json:"dataVolumes"`     // 节点的数据盘
-	PublicIP        CCENodePublicIP    `json:"publicIP"`        // 节点公网IP
-	BillingMode     int32              `json:"billingMode"`     // 节点计费模式
-	Runtime         string             `json:"runtime"`         // 容器运行时，docker 或 containerd
-	ExtendParam     CCENodeExtendParam `json:"extendParam"`     // 节点扩展参数
+	Flavor          string             `json:"flavor"`                // 节点池规格
+	AvailableZone   string             `json:"availableZone"`         // 可用区
+	OperatingSystem string             `json:"operatingSystem"`       // 节点操作系统
+	SSHKey          string             `json:"sshKey"`                // SSH 密钥名称（不支持帐号密码登录）
+	RootVolume      CCENodeVolume      `json:"rootVolume"`            // 节点的系统盘
+	DataVolumes     []CCENodeVolume    `json:"dataVolumes"`           // 节点的数据盘
+	PublicIP        CCENodePublicIP    `json:"publicIP"`              // 节点公网IP
+	BillingMode     int32              `json:"billingMode"`           // 节点计费模式
+	Runtime         string             `json:"runtime"`               // 容器运行时，docker 或 containerd
+	ExtendParam     CCENodeExtendParam `json:"extendParam"`           // 节点扩展参数
+	Taints          *[]CCENodeTaint    `json:"taints,omitempty"`      // 节点污点，可在节点池创建后更新，未设置时不管理
+	K8sTags         *map[string]string `json:"k8sTags,omitempty"`     // 节点的 Kubernetes 标签，可在节点池创建后更新，未设置时不管理
+	UserTags        *map[string]string `json:"userTags,omitempty"`    // 云服务器标签，可在节点池创建后更新，未设置时不管理
+	PreInstall      string             `json:"preInstall,omitempty"`  // 安装前执行脚本，节点池创建后不可修改
+	PostInstall     string             `json:"postInstall,omitempty"` // 安装后执行脚本，节点池创建后不可修改
+	MaxPods         int32              `json:"maxPods,omitempty"`     // 节点允许创建的最大 Pod 数，节点池创建后不可修改
+}
+
+type CCENodeTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"` // NoSchedule, PreferNoSchedule 或 NoExecute
 }
 
 type CCENodePoolNodeAutoscaling struct {
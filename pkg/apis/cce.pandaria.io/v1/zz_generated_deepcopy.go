@@ -22,6 +22,7 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -41,6 +42,43 @@ func (in *CCEAuthenticatingProxy) DeepCopy() *CCEAuthenticatingProxy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEAddon) DeepCopyInto(out *CCEAddon) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEAddon.
+func (in *CCEAddon) DeepCopy() *CCEAddon {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEAddon)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEAddonStatus) DeepCopyInto(out *CCEAddonStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEAddonStatus.
+func (in *CCEAddonStatus) DeepCopy() *CCEAddonStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEAddonStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CCEAuthentication) DeepCopyInto(out *CCEAuthentication) {
 	*out = *in
@@ -58,6 +96,22 @@ func (in *CCEAuthentication) DeepCopy() *CCEAuthentication {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEClusterAutoscaler) DeepCopyInto(out *CCEClusterAutoscaler) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEClusterAutoscaler.
+func (in *CCEClusterAutoscaler) DeepCopy() *CCEClusterAutoscaler {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEClusterAutoscaler)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CCEClusterConfig) DeepCopyInto(out *CCEClusterConfig) {
 	*out = *in
@@ -150,6 +204,28 @@ func (in *CCEClusterConfigSpec) DeepCopyInto(out *CCEClusterConfigSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	in.NodePoolDeletionProtection.DeepCopyInto(&out.NodePoolDeletionProtection)
+	if in.Addons != nil {
+		in, out := &in.Addons, &out.Addons
+		*out = make([]CCEAddon, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.ClusterAutoscaler = in.ClusterAutoscaler
+	if in.NodeOperations != nil {
+		in, out := &in.NodeOperations, &out.NodeOperations
+		*out = make([]CCENodeOperation, len(*in))
+		copy(*out, *in)
+	}
+	in.UpgradeStrategy.DeepCopyInto(&out.UpgradeStrategy)
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]CCEMaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.CreatedNodePoolIDs != nil {
 		in, out := &in.CreatedNodePoolIDs, &out.CreatedNodePoolIDs
 		*out = make(map[string]string, len(*in))
@@ -178,6 +254,74 @@ func (in *CCEClusterConfigStatus) DeepCopyInto(out *CCEClusterConfigStatus) {
 		*out = make([]CCEClusterEndpoints, len(*in))
 		copy(*out, *in)
 	}
+	if in.UpgradePreCheck != nil {
+		in, out := &in.UpgradePreCheck, &out.UpgradePreCheck
+		*out = new(CCEUpgradePreCheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(CCEClusterUpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodePools != nil {
+		in, out := &in.NodePools, &out.NodePools
+		*out = make([]CCENodePoolStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodePoolReplacements != nil {
+		in, out := &in.NodePoolReplacements, &out.NodePoolReplacements
+		*out = make([]CCENodePoolReplacement, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeOperations != nil {
+		in, out := &in.NodeOperations, &out.NodeOperations
+		*out = make([]CCENodeOperationStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.UnhealthyNodes != nil {
+		in, out := &in.UnhealthyNodes, &out.UnhealthyNodes
+		*out = make([]CCEUnhealthyNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeRemediations != nil {
+		in, out := &in.NodeRemediations, &out.NodeRemediations
+		*out = make([]CCENodeRemediation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Addons != nil {
+		in, out := &in.Addons, &out.Addons
+		*out = make([]CCEAddonStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = make([]CCEClusterOperation, len(*in))
+		copy(*out, *in)
+	}
+	if in.Maintenance != nil {
+		in, out := &in.Maintenance, &out.Maintenance
+		*out = new(CCEMaintenanceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Deletion != nil {
+		in, out := &in.Deletion, &out.Deletion
+		*out = new(CCEDeletionStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -223,6 +367,22 @@ func (in *CCEClusterExtendParam) DeepCopy() *CCEClusterExtendParam {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEClusterOperation) DeepCopyInto(out *CCEClusterOperation) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEClusterOperation.
+func (in *CCEClusterOperation) DeepCopy() *CCEClusterOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEClusterOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CCEClusterPublicIP) DeepCopyInto(out *CCEClusterPublicIP) {
 	*out = *in
@@ -240,6 +400,32 @@ func (in *CCEClusterPublicIP) DeepCopy() *CCEClusterPublicIP {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEClusterUpgradeStatus) DeepCopyInto(out *CCEClusterUpgradeStatus) {
+	*out = *in
+	if in.RemainingHops != nil {
+		in, out := &in.RemainingHops, &out.RemainingHops
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TaskIDs != nil {
+		in, out := &in.TaskIDs, &out.TaskIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEClusterUpgradeStatus.
+func (in *CCEClusterUpgradeStatus) DeepCopy() *CCEClusterUpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEClusterUpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CCEContainerNetwork) DeepCopyInto(out *CCEContainerNetwork) {
 	*out = *in
@@ -256,6 +442,24 @@ func (in *CCEContainerNetwork) DeepCopy() *CCEContainerNetwork {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEDeletionStatus) DeepCopyInto(out *CCEDeletionStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEDeletionStatus.
+func (in *CCEDeletionStatus) DeepCopy() *CCEDeletionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEDeletionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CCEEip) DeepCopyInto(out *CCEEip) {
 	*out = *in
@@ -326,6 +530,49 @@ func (in *CCEHostNetwork) DeepCopy() *CCEHostNetwork {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEMaintenanceStatus) DeepCopyInto(out *CCEMaintenanceStatus) {
+	*out = *in
+	in.NextWindow.DeepCopyInto(&out.NextWindow)
+	if in.PendingOperations != nil {
+		in, out := &in.PendingOperations, &out.PendingOperations
+		*out = make([]CCEClusterOperation, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEMaintenanceStatus.
+func (in *CCEMaintenanceStatus) DeepCopy() *CCEMaintenanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEMaintenanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEMaintenanceWindow) DeepCopyInto(out *CCEMaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEMaintenanceWindow.
+func (in *CCEMaintenanceWindow) DeepCopy() *CCEMaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEMaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CCENatGateway) DeepCopyInto(out *CCENatGateway) {
 	*out = *in
@@ -359,6 +606,54 @@ func (in *CCENodeExtendParam) DeepCopy() *CCENodeExtendParam {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCENodeHealthPolicy) DeepCopyInto(out *CCENodeHealthPolicy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCENodeHealthPolicy.
+func (in *CCENodeHealthPolicy) DeepCopy() *CCENodeHealthPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CCENodeHealthPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCENodeOperation) DeepCopyInto(out *CCENodeOperation) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCENodeOperation.
+func (in *CCENodeOperation) DeepCopy() *CCENodeOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(CCENodeOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCENodeOperationStatus) DeepCopyInto(out *CCENodeOperationStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCENodeOperationStatus.
+func (in *CCENodeOperationStatus) DeepCopy() *CCENodeOperationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CCENodeOperationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CCENodePool) DeepCopyInto(out *CCENodePool) {
 	*out = *in
@@ -374,6 +669,7 @@ func (in *CCENodePool) DeepCopyInto(out *CCENodePool) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	out.HealthPolicy = in.HealthPolicy
 	return
 }
 
@@ -387,6 +683,27 @@ func (in *CCENodePool) DeepCopy() *CCENodePool {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCENodePoolDeletionProtection) DeepCopyInto(out *CCENodePoolDeletionProtection) {
+	*out = *in
+	if in.ProtectedNodePools != nil {
+		in, out := &in.ProtectedNodePools, &out.ProtectedNodePools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCENodePoolDeletionProtection.
+func (in *CCENodePoolDeletionProtection) DeepCopy() *CCENodePoolDeletionProtection {
+	if in == nil {
+		return nil
+	}
+	out := new(CCENodePoolDeletionProtection)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CCENodePoolNodeAutoscaling) DeepCopyInto(out *CCENodePoolNodeAutoscaling) {
 	*out = *in
@@ -425,6 +742,76 @@ func (in *CCENodePublicIP) DeepCopy() *CCENodePublicIP {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCENodePoolReplacement) DeepCopyInto(out *CCENodePoolReplacement) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCENodePoolReplacement.
+func (in *CCENodePoolReplacement) DeepCopy() *CCENodePoolReplacement {
+	if in == nil {
+		return nil
+	}
+	out := new(CCENodePoolReplacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCENodePoolStatus) DeepCopyInto(out *CCENodePoolStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCENodePoolStatus.
+func (in *CCENodePoolStatus) DeepCopy() *CCENodePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CCENodePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCENodeRemediation) DeepCopyInto(out *CCENodeRemediation) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCENodeRemediation.
+func (in *CCENodeRemediation) DeepCopy() *CCENodeRemediation {
+	if in == nil {
+		return nil
+	}
+	out := new(CCENodeRemediation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCENodeTaint) DeepCopyInto(out *CCENodeTaint) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCENodeTaint.
+func (in *CCENodeTaint) DeepCopy() *CCENodeTaint {
+	if in == nil {
+		return nil
+	}
+	out := new(CCENodeTaint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CCENodeTemplate) DeepCopyInto(out *CCENodeTemplate) {
 	*out = *in
@@ -436,6 +823,37 @@ func (in *CCENodeTemplate) DeepCopyInto(out *CCENodeTemplate) {
 	}
 	in.PublicIP.DeepCopyInto(&out.PublicIP)
 	out.ExtendParam = in.ExtendParam
+	if in.Taints != nil {
+		in, out := &in.Taints, &out.Taints
+		*out = new([]CCENodeTaint)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]CCENodeTaint, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.K8sTags != nil {
+		in, out := &in.K8sTags, &out.K8sTags
+		*out = new(map[string]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make(map[string]string, len(*in))
+			for key, val := range *in {
+				(*out)[key] = val
+			}
+		}
+	}
+	if in.UserTags != nil {
+		in, out := &in.UserTags, &out.UserTags
+		*out = new(map[string]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make(map[string]string, len(*in))
+			for key, val := range *in {
+				(*out)[key] = val
+			}
+		}
+	}
 	return
 }
 
@@ -464,3 +882,65 @@ func (in *CCENodeVolume) DeepCopy() *CCENodeVolume {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEUnhealthyNode) DeepCopyInto(out *CCEUnhealthyNode) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEUnhealthyNode.
+func (in *CCEUnhealthyNode) DeepCopy() *CCEUnhealthyNode {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEUnhealthyNode)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEUpgradePreCheck) DeepCopyInto(out *CCEUpgradePreCheck) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEUpgradePreCheck.
+func (in *CCEUpgradePreCheck) DeepCopy() *CCEUpgradePreCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEUpgradePreCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CCEUpgradeStrategy) DeepCopyInto(out *CCEUpgradeStrategy) {
+	*out = *in
+	if in.NodePoolOrder != nil {
+		in, out := &in.NodePoolOrder, &out.NodePoolOrder
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Addons != nil {
+		in, out := &in.Addons, &out.Addons
+		*out = make([]CCEAddon, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CCEUpgradeStrategy.
+func (in *CCEUpgradeStrategy) DeepCopy() *CCEUpgradeStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(CCEUpgradeStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,490 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// addonChange is the operation to make the upstream addon match the spec.
+type addonChange struct {
+	operation string                   // planCreateAddon, planUpdateAddon or planDeleteAddon
+	addon     *ccev1.CCEAddon          // nil when deleting the addon
+	instance  *cce_model.AddonInstance // nil when creating the addon
+	version   string                   // version to update
+	values    map[string]any           // full values to update
+	drift     []string
+}
+
+// addonChanges compares the upstream addon instances with the desired addons,
+// returns the changes to request and the status of the managed addons.
+// The addons removed from the spec are deleted only if they were installed by
+// the operator, so the addons installed outside of the operator are kept.
+func addonChanges(
+	config *ccev1.CCEClusterConfig, desired []ccev1.CCEAddon, instances []cce_model.AddonInstance,
+) ([]addonChange, []ccev1.CCEAddonStatus, error) {
+	upstream := map[string]*cce_model.AddonInstance{}
+	for i := range instances {
+		if instances[i].Spec == nil {
+			continue
+		}
+		upstream[instances[i].Spec.AddonTemplateName] = &instances[i]
+	}
+
+	previous := map[string]ccev1.CCEAddonStatus{}
+	for _, s := range config.Status.Addons {
+		previous[s.Name] = s
+	}
+
+	var (
+		changes []addonChange
+		status  []ccev1.CCEAddonStatus
+		managed = map[string]bool{}
+	)
+	for i := range desired {
+		addon := &desired[i]
+		managed[addon.Name] = true
+		values, err := cce.AddonValues(addon)
+		if err != nil {
+			return nil, nil, err
+		}
+		instance, ok := upstream[addon.Name]
+		if !ok {
+			changes = append(changes, addonChange{
+				operation: planCreateAddon,
+				addon:     addon,
+				values:    values,
+			})
+			status = append(status, ccev1.CCEAddonStatus{Name: addon.Name, Created: true})
+			continue
+		}
+		s := addonStatus(instance)
+		s.Created = addonCreated(previous[addon.Name], instance)
+		drift := addonDrift(addon, values, instance)
+		s.Drift = strings.Join(drift, "; ")
+		status = append(status, s)
+		if len(drift) == 0 || !addonUpdatable(instance) {
+			continue
+		}
+		version := addon.Version
+		if version == "" {
+			version = instance.Spec.Version
+		}
+		changes = append(changes, addonChange{
+			operation: planUpdateAddon,
+			addon:     addon,
+			instance:  instance,
+			version:   version,
+			values:    mergeAddonValues(instance.Spec.Values, values),
+			drift:     drift,
+		})
+	}
+	for _, s := range config.Status.Addons {
+		instance, ok := upstream[s.Name]
+		if managed[s.Name] || !ok || !addonCreated(s, instance) {
+			continue
+		}
+		removed := addonStatus(instance)
+		removed.Created = true
+		status = append(status, removed)
+		if instance.Status != nil &&
+			instance.Status.Status == cce_model.GetAddonInstanceStatusStatusEnum().DELETING {
+			continue
+		}
+		changes = append(changes, addonChange{
+			operation: planDeleteAddon,
+			instance:  instance,
+		})
+	}
+	return changes, status, nil
+}
+
+// addonCreated returns true if the addon instance was installed by the
+// operator according to the previous status.
+func addonCreated(previous ccev1.CCEAddonStatus, instance *cce_model.AddonInstance) bool {
+	if !previous.Created {
+		return false
+	}
+	// The ID is unknown if the create response does not contain it.
+	return previous.ID == "" || instance.Metadata == nil || previous.ID == utils.Value(instance.Metadata.Uid)
+}
+
+func addonStatus(instance *cce_model.AddonInstance) ccev1.CCEAddonStatus {
+	s := ccev1.CCEAddonStatus{
+		Name: instance.Spec.AddonTemplateName,
+	}
+	if instance.Metadata != nil {
+		s.ID = utils.Value(instance.Metadata.Uid)
+	}
+	if instance.Status != nil {
+		s.Status = instance.Status.Status.Value()
+		s.Message = instance.Status.Message
+		if s.Message == "" {
+			s.Message = instance.Status.Reason
+		}
+		if instance.Status.CurrentVersion != nil {
+			s.Version = instance.Status.CurrentVersion.Version
+		}
+	}
+	if s.Version == "" {
+		s.Version = instance.Spec.Version
+	}
+	return s
+}
+
+// addonUpdatable returns true if the addon instance accepts the upgrade
+// request, the addons in progress or failed to install are not updated.
+func addonUpdatable(instance *cce_model.AddonInstance) bool {
+	if instance.Status == nil {
+		return false
+	}
+	switch instance.Status.Status {
+	case cce_model.GetAddonInstanceStatusStatusEnum().RUNNING,
+		cce_model.GetAddonInstanceStatusStatusEnum().AVAILABLE,
+		cce_model.GetAddonInstanceStatusStatusEnum().ABNORMAL,
+		cce_model.GetAddonInstanceStatusStatusEnum().UPGRADE_FAILED:
+		return true
+	}
+	return false
+}
+
+// addonInProgress returns true if the addon instance is being installed,
+// upgraded, rolled back or deleted.
+func addonInProgress(status string) bool {
+	switch status {
+	case "",
+		cce_model.GetAddonInstanceStatusStatusEnum().INSTALLING.Value(),
+		cce_model.GetAddonInstanceStatusStatusEnum().UPGRADING.Value(),
+		cce_model.GetAddonInstanceStatusStatusEnum().ROLLBACKING.Value(),
+		cce_model.GetAddonInstanceStatusStatusEnum().DELETING.Value():
+		return true
+	}
+	return false
+}
+
+// addonFailed returns true if the addon instance requires manual operation.
+func addonFailed(status string) bool {
+	switch status {
+	case cce_model.GetAddonInstanceStatusStatusEnum().INSTALL_FAILED.Value(),
+		cce_model.GetAddonInstanceStatusStatusEnum().UPGRADE_FAILED.Value(),
+		cce_model.GetAddonInstanceStatusStatusEnum().DELETE_FAILED.Value(),
+		cce_model.GetAddonInstanceStatusStatusEnum().ROLLBACK_FAILED.Value():
+		return true
+	}
+	return false
+}
+
+// addonDrift returns the differences between the upstream addon instance and
+// the spec, only the values configured in the spec are compared.
+func addonDrift(addon *ccev1.CCEAddon, values map[string]any, instance *cce_model.AddonInstance) []string {
+	var drift []string
+	upstreamVersion := instance.Spec.Version
+	if addon.Version != "" && addon.Version != upstreamVersion {
+		drift = append(drift, fmt.Sprintf("version [%s] -> [%s]", upstreamVersion, addon.Version))
+	}
+	if keys := addonValuesDrift("", values, instance.Spec.Values); len(keys) != 0 {
+		drift = append(drift, fmt.Sprintf("values [%s]", strings.Join(keys, ", ")))
+	}
+	return drift
+}
+
+// addonValuesDrift returns the keys of the desired values not matching the
+// upstream values.
+func addonValuesDrift(prefix string, desired, upstream map[string]any) []string {
+	var keys []string
+	for _, k := range sortedMapKeys(desired) {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		d, u := desired[k], upstream[k]
+		dm, dok := d.(map[string]any)
+		um, uok := u.(map[string]any)
+		if dok && uok {
+			keys = append(keys, addonValuesDrift(key, dm, um)...)
+			continue
+		}
+		// Compare the JSON encoding since the upstream numbers are decoded
+		// as json.Number by the SDK.
+		dj, _ := json.Marshal(d)
+		uj, _ := json.Marshal(u)
+		if !bytes.Equal(dj, uj) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// mergeAddonValues merges the desired values into a copy of the upstream
+// values, the CCE API requires the full values when updating the addon.
+func mergeAddonValues(upstream, desired map[string]any) map[string]any {
+	merged := make(map[string]any, len(upstream))
+	for k, v := range upstream {
+		merged[k] = v
+	}
+	for k, v := range desired {
+		dm, dok := v.(map[string]any)
+		um, uok := merged[k].(map[string]any)
+		if dok && uok {
+			merged[k] = mergeAddonValues(um, dm)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func sortedMapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// listAddonInstances returns the addon instances of the cluster.
+func (h *Handler) listAddonInstances(config *ccev1.CCEClusterConfig) ([]cce_model.AddonInstance, error) {
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return nil, err
+	}
+	res, err := cce.ListAddonInstances(driver.CCE, config.Spec.ClusterID, "")
+	if err != nil {
+		return nil, err
+	}
+	if res == nil || res.Items == nil {
+		return nil, fmt.Errorf("ListAddonInstances returns invalid data")
+	}
+	return *res.Items, nil
+}
+
+// planAddons returns the addon operations syncAddons would request.
+func (h *Handler) planAddons(config *ccev1.CCEClusterConfig) ([]ccev1.CCEClusterOperation, error) {
+	desired, err := desiredAddons(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(desired) == 0 && len(config.Status.Addons) == 0 {
+		return nil, nil
+	}
+	instances, err := h.listAddonInstances(config)
+	if err != nil {
+		return nil, err
+	}
+	changes, _, err := addonChanges(config, desired, instances)
+	if err != nil {
+		return nil, err
+	}
+	var plan []ccev1.CCEClusterOperation
+	for _, c := range changes {
+		op := ccev1.CCEClusterOperation{
+			Operation: c.operation,
+			Resource:  planResourceAddon,
+		}
+		switch c.operation {
+		case planCreateAddon:
+			op.Name = c.addon.Name
+			op.Desired = c.addon.Version
+		case planUpdateAddon:
+			op.Name = c.addon.Name
+			op.ID = utils.Value(c.instance.Metadata.Uid)
+			op.Current = c.instance.Spec.Version
+			op.Desired = strings.Join(c.drift, "; ")
+		case planDeleteAddon:
+			op.Name = c.instance.Spec.AddonTemplateName
+			op.ID = utils.Value(c.instance.Metadata.Uid)
+			op.Current = c.instance.Spec.Version
+		}
+		plan = append(plan, op)
+	}
+	return plan, nil
+}
+
+// recordCreatedAddon adds the addon installed by the operator to the addon
+// status.
+func (h *Handler) recordCreatedAddon(
+	config *ccev1.CCEClusterConfig, created ccev1.CCEAddonStatus,
+) (*ccev1.CCEClusterConfig, error) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.Addons = nil
+		for _, s := range config.Status.Addons {
+			if s.Name != created.Name {
+				configUpdate.Status.Addons = append(configUpdate.Status.Addons, s)
+			}
+		}
+		configUpdate.Status.Addons = append(configUpdate.Status.Addons, created)
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	return config, err
+}
+
+// syncAddons installs, updates and uninstalls the addons to match the spec,
+// then updates the addon status, the AddonsSynced and ClusterAutoscalerReady
+// conditions.
+func (h *Handler) syncAddons(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
+	desired, err := desiredAddons(config)
+	if err != nil {
+		return config, err
+	}
+	if len(desired) == 0 && len(config.Status.Addons) == 0 &&
+		meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionClusterAutoscalerReady) == nil {
+		return config, nil
+	}
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, err
+	}
+	instances, err := h.listAddonInstances(config)
+	if err != nil {
+		return config, err
+	}
+	changes, status, err := addonChanges(config, desired, instances)
+	if err != nil {
+		return config, err
+	}
+
+	for _, c := range changes {
+		switch c.operation {
+		case planCreateAddon:
+			res, err := cce.CreateAddonInstance(driver.CCE, config.Spec.ClusterID, c.addon)
+			if err != nil {
+				return config, err
+			}
+			var id string
+			if res.Metadata != nil {
+				id = utils.Value(res.Metadata.Uid)
+			}
+			var created ccev1.CCEAddonStatus
+			for i := range status {
+				if status[i].Name == c.addon.Name {
+					status[i].ID = id
+					created = status[i]
+				}
+			}
+			// Record the created addon at once, otherwise it would be taken
+			// as installed outside of the operator if the reconcile fails.
+			if config, err = h.recordCreatedAddon(config, created); err != nil {
+				return config, err
+			}
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("request to install addon [%s] ID [%s]", c.addon.Name, id)
+			h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreating,
+				"request to install addon [%s] ID [%s]", c.addon.Name, id)
+		case planUpdateAddon:
+			id := utils.Value(c.instance.Metadata.Uid)
+			_, err := cce.UpdateAddonInstance(driver.CCE, config.Spec.ClusterID, id,
+				c.addon.Name, c.version, c.values)
+			if err != nil {
+				return config, err
+			}
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("request to update addon [%s] ID [%s]: %s", c.addon.Name, id, strings.Join(c.drift, "; "))
+			h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonUpdating,
+				"request to update addon [%s] ID [%s]: %s", c.addon.Name, id, strings.Join(c.drift, "; "))
+		case planDeleteAddon:
+			id := utils.Value(c.instance.Metadata.Uid)
+			if _, err := cce.DeleteAddonInstance(driver.CCE, config.Spec.ClusterID, id); err != nil {
+				return config, err
+			}
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("request to uninstall addon [%s] ID [%s]", c.instance.Spec.AddonTemplateName, id)
+			h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+				"request to uninstall addon [%s] ID [%s]", c.instance.Spec.AddonTemplateName, id)
+		}
+	}
+
+	var (
+		inProgress = len(changes) != 0
+		pending    []string
+		failed     []string
+	)
+	for _, s := range status {
+		switch {
+		case addonFailed(s.Status):
+			failed = append(failed, fmt.Sprintf("addon [%s] status [%s]: %s", s.Name, s.Status, s.Message))
+		case addonInProgress(s.Status) || s.Drift != "":
+			inProgress = true
+			pending = append(pending, s.Name)
+		}
+	}
+	conditionStatus, reason := metav1.ConditionTrue, reasonAddonsSynced
+	message := fmt.Sprintf("%d addons are synced", len(desired))
+	switch {
+	case len(failed) != 0:
+		conditionStatus, reason, message = metav1.ConditionFalse, reasonAddonFailed, strings.Join(failed, "; ")
+	case inProgress:
+		conditionStatus, reason = metav1.ConditionFalse, reasonAddonsUpdating
+		message = fmt.Sprintf("waiting for addons %v", pending)
+		if len(changes) != 0 {
+			message = fmt.Sprintf("request to change %d addons", len(changes))
+		}
+	}
+
+	autoscalerStatus, autoscalerReason, autoscalerMessage, autoscaling := autoscalerCondition(config, status)
+	autoscalerChanged := false
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.Addons = status
+		changed := setCondition(configUpdate, ccev1.ConditionAddonsSynced, conditionStatus, reason, message)
+		if autoscaling {
+			autoscalerChanged = setCondition(configUpdate, ccev1.ConditionClusterAutoscalerReady,
+				autoscalerStatus, autoscalerReason, autoscalerMessage)
+		} else {
+			autoscalerChanged = meta.FindStatusCondition(configUpdate.Status.Conditions,
+				ccev1.ConditionClusterAutoscalerReady) != nil
+			meta.RemoveStatusCondition(&configUpdate.Status.Conditions, ccev1.ConditionClusterAutoscalerReady)
+		}
+		if !changed && !autoscalerChanged && reflect.DeepEqual(config.Status.Addons, status) {
+			return nil
+		}
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	if err != nil {
+		return config, err
+	}
+	if autoscalerChanged && autoscaling && autoscalerStatus == metav1.ConditionFalse &&
+		autoscalerReason != reasonAddonsUpdating {
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Warn(autoscalerMessage)
+		h.recorder.Event(config, corev1.EventTypeWarning, eventReasonNotReady, autoscalerMessage)
+	}
+	if inProgress {
+		h.cceEnqueueAfter(config.Namespace, config.Name, 30*time.Second)
+	}
+	return config, nil
+}
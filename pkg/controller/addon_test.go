@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newMockAddon(
+	name, version string, status cce_model.AddonInstanceStatusStatus, values map[string]any,
+) cce_model.AddonInstance {
+	return cce_model.AddonInstance{
+		Metadata: &cce_model.AddonMetadata{
+			Uid:  utils.Pointer("mock-" + name + "-id"),
+			Name: utils.Pointer(name),
+		},
+		Spec: &cce_model.InstanceSpec{
+			ClusterID:         "mock-cluster-id",
+			Version:           version,
+			AddonTemplateName: name,
+			Values:            values,
+		},
+		Status: &cce_model.AddonInstanceStatus{
+			Status:         status,
+			CurrentVersion: &cce_model.Versions{Version: version},
+		},
+	}
+}
+
+func Test_addonChanges(t *testing.T) {
+	assert := assert.New(t)
+	running := cce_model.GetAddonInstanceStatusStatusEnum().RUNNING
+	config := newMockConfig(cceConfigActivePhase)
+	config.Spec.Addons = []ccev1.CCEAddon{
+		{Name: "coredns", Values: &runtime.RawExtension{Raw: []byte(`{"custom":{"stub_domains":{}}}`)}},
+		{Name: "everest", Version: "2.1.0"},
+		{Name: "metrics-server"},
+		{Name: "autoscaler", Version: "1.25.7"},
+	}
+	config.Status.Addons = []ccev1.CCEAddonStatus{
+		{Name: "npd", ID: "mock-npd-id", Created: true},
+		{Name: "volcano", ID: "mock-volcano-id"},
+	}
+	instances := []cce_model.AddonInstance{
+		newMockAddon("coredns", "1.25.1", running, map[string]any{
+			"basic":  map[string]any{"swr_addr": "swr.io"},
+			"custom": map[string]any{"stub_domains": map[string]any{}},
+		}),
+		newMockAddon("everest", "2.0.9", running, nil),
+		newMockAddon("autoscaler", "1.25.6", cce_model.GetAddonInstanceStatusStatusEnum().INSTALLING, nil),
+		newMockAddon("npd", "1.18.10", running, nil),
+		newMockAddon("volcano", "1.9.1", running, nil),
+	}
+
+	changes, status, err := addonChanges(config, config.Spec.Addons, instances)
+	if !assert.Nil(err) {
+		return
+	}
+	if assert.Len(changes, 3) {
+		assert.Equal(planUpdateAddon, changes[0].operation)
+		assert.Equal("everest", changes[0].addon.Name)
+		assert.Equal("2.1.0", changes[0].version)
+		assert.Equal([]string{"version [2.0.9] -> [2.1.0]"}, changes[0].drift)
+		assert.Equal(planCreateAddon, changes[1].operation)
+		assert.Equal("metrics-server", changes[1].addon.Name)
+		assert.Equal(planDeleteAddon, changes[2].operation)
+		assert.Equal("npd", changes[2].instance.Spec.AddonTemplateName)
+	}
+	// The addon in progress is not updated, the addons not installed by the
+	// operator are not deleted.
+	var names []string
+	for _, s := range status {
+		names = append(names, s.Name)
+	}
+	assert.Equal([]string{"coredns", "everest", "metrics-server", "autoscaler", "npd"}, names)
+	assert.Equal("installing", status[3].Status)
+	assert.Equal("version [1.25.6] -> [1.25.7]", status[3].Drift)
+	assert.True(status[2].Created)
+	assert.False(status[3].Created)
+
+	// The addon reinstalled out of the operator is not deleted.
+	config.Status.Addons[0].ID = "other-npd-id"
+	changes, status, err = addonChanges(config, config.Spec.Addons, instances)
+	if assert.Nil(err) {
+		assert.Len(changes, 2)
+		assert.Len(status, 4)
+	}
+
+	config.Spec.Addons[0].Values = &runtime.RawExtension{Raw: []byte(`[]`)}
+	_, _, err = addonChanges(config, config.Spec.Addons, instances)
+	assert.ErrorContains(err, "failed to decode values of addon [coredns]")
+}
+
+func Test_mergeAddonValues(t *testing.T) {
+	assert := assert.New(t)
+	upstream := map[string]any{
+		"basic":  map[string]any{"swr_addr": "swr.io", "rbac_enabled": true},
+		"flavor": map[string]any{"replicas": 2},
+	}
+	merged := mergeAddonValues(upstream, map[string]any{
+		"basic":  map[string]any{"rbac_enabled": false},
+		"custom": map[string]any{"parallelism": 10},
+	})
+	assert.Equal(map[string]any{
+		"basic":  map[string]any{"swr_addr": "swr.io", "rbac_enabled": false},
+		"flavor": map[string]any{"replicas": 2},
+		"custom": map[string]any{"parallelism": 10},
+	}, merged)
+	// The upstream values are not modified.
+	assert.Equal(true, upstream["basic"].(map[string]any)["rbac_enabled"])
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// autoscalerAddonName is the template name of the CCE autoscaler addon,
+// the node pool autoscaling only works if the addon is installed.
+const autoscalerAddonName = "autoscaler"
+
+// autoscalingEnabled returns true if any node pool enables autoscaling.
+func autoscalingEnabled(config *ccev1.CCEClusterConfig) bool {
+	for _, np := range config.Spec.NodePools {
+		if np.Autoscaling.Enable {
+			return true
+		}
+	}
+	return false
+}
+
+// desiredAddons returns the addons in spec and the autoscaler addon if the
+// node pool autoscaling is enabled. The values of the autoscaler addon in
+// spec take precedence over the clusterAutoscaler settings.
+func desiredAddons(config *ccev1.CCEClusterConfig) ([]ccev1.CCEAddon, error) {
+	if !autoscalingEnabled(config) {
+		// The autoscaler left out is uninstalled by addonChanges only if it
+		// was installed by the operator, the adopted one is kept.
+		return config.Spec.Addons, nil
+	}
+	autoscaler, err := autoscalerAddon(config)
+	if err != nil {
+		return nil, err
+	}
+	addons := make([]ccev1.CCEAddon, 0, len(config.Spec.Addons)+1)
+	found := false
+	for _, addon := range config.Spec.Addons {
+		if addon.Name != autoscalerAddonName {
+			addons = append(addons, addon)
+			continue
+		}
+		found = true
+		values, err := cce.AddonValues(&addon)
+		if err != nil {
+			return nil, err
+		}
+		defaults, err := cce.AddonValues(&autoscaler)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(mergeAddonValues(defaults, values))
+		if err != nil {
+			return nil, err
+		}
+		if addon.Version == "" {
+			addon.Version = autoscaler.Version
+		}
+		addon.Values = &runtime.RawExtension{Raw: raw}
+		addons = append(addons, addon)
+	}
+	if !found {
+		addons = append(addons, autoscaler)
+	}
+	return addons, nil
+}
+
+// autoscalerAddon builds the autoscaler addon from the clusterAutoscaler
+// settings, the settings not configured use the addon template defaults.
+func autoscalerAddon(config *ccev1.CCEClusterConfig) (ccev1.CCEAddon, error) {
+	settings := config.Spec.ClusterAutoscaler
+	custom := map[string]any{
+		"cluster_id":       config.Spec.ClusterID,
+		"scaleDownEnabled": true,
+	}
+	if settings.ScaleDownDelayAfterAdd > 0 {
+		custom["scaleDownDelayAfterAdd"] = settings.ScaleDownDelayAfterAdd
+	}
+	if settings.ScaleDownUnneededTime > 0 {
+		custom["scaleDownUnneededTime"] = settings.ScaleDownUnneededTime
+	}
+	if settings.ScaleDownUtilizationThreshold != "" {
+		threshold, err := strconv.ParseFloat(settings.ScaleDownUtilizationThreshold, 64)
+		if err != nil {
+			return ccev1.CCEAddon{}, fmt.Errorf("invalid clusterAutoscaler.scaleDownUtilizationThreshold [%s]: %w",
+				settings.ScaleDownUtilizationThreshold, err)
+		}
+		custom["scaleDownUtilizationThreshold"] = threshold
+	}
+	if settings.Expander != "" {
+		custom["expander"] = settings.Expander
+	}
+	raw, err := json.Marshal(map[string]any{"custom": custom})
+	if err != nil {
+		return ccev1.CCEAddon{}, err
+	}
+	return ccev1.CCEAddon{
+		Name:    autoscalerAddonName,
+		Version: settings.Version,
+		Values:  &runtime.RawExtension{Raw: raw},
+	}, nil
+}
+
+// autoscalerCondition returns the ClusterAutoscalerReady condition built
+// from the autoscaler addon status, returns false if autoscaling is disabled.
+func autoscalerCondition(
+	config *ccev1.CCEClusterConfig, status []ccev1.CCEAddonStatus,
+) (metav1.ConditionStatus, string, string, bool) {
+	if !autoscalingEnabled(config) {
+		return "", "", "", false
+	}
+	for _, s := range status {
+		if s.Name != autoscalerAddonName {
+			continue
+		}
+		switch {
+		case s.Status == cce_model.GetAddonInstanceStatusStatusEnum().RUNNING.Value(),
+			s.Status == cce_model.GetAddonInstanceStatusStatusEnum().AVAILABLE.Value():
+			return metav1.ConditionTrue, reasonAutoscalerRunning,
+				fmt.Sprintf("autoscaler addon [%s] version [%s] is %s", s.ID, s.Version, s.Status), true
+		case addonInProgress(s.Status):
+			return metav1.ConditionFalse, reasonAddonsUpdating,
+				fmt.Sprintf("waiting for autoscaler addon [%s] to be running", s.ID), true
+		default:
+			message := fmt.Sprintf("node pool autoscaling is enabled but the autoscaler addon [%s] is %s",
+				s.ID, s.Status)
+			if s.Message != "" {
+				message += ": " + s.Message
+			}
+			return metav1.ConditionFalse, reasonAutoscalerUnhealthy, message, true
+		}
+	}
+	return metav1.ConditionFalse, reasonAutoscalerMissing,
+		"node pool autoscaling is enabled but the autoscaler addon is not installed", true
+}
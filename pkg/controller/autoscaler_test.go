@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_desiredAddons(t *testing.T) {
+	assert := assert.New(t)
+	config := newMockConfig(cceConfigActivePhase)
+	config.Spec.Addons = []ccev1.CCEAddon{{Name: "coredns"}}
+
+	addons, err := desiredAddons(config)
+	assert.Nil(err)
+	assert.Equal(config.Spec.Addons, addons)
+
+	config.Spec.NodePools[0].Autoscaling.Enable = true
+	config.Spec.ClusterAutoscaler = ccev1.CCEClusterAutoscaler{
+		Version:                       "1.25.21",
+		ScaleDownDelayAfterAdd:        15,
+		ScaleDownUtilizationThreshold: "0.6",
+		Expander:                      "least-waste",
+	}
+	addons, err = desiredAddons(config)
+	if assert.Nil(err) && assert.Len(addons, 2) {
+		assert.Equal("coredns", addons[0].Name)
+		assert.Equal(autoscalerAddonName, addons[1].Name)
+		assert.Equal("1.25.21", addons[1].Version)
+		assert.JSONEq(`{"custom":{
+			"cluster_id":"mock-cluster-id",
+			"scaleDownEnabled":true,
+			"scaleDownDelayAfterAdd":15,
+			"scaleDownUtilizationThreshold":0.6,
+			"expander":"least-waste"
+		}}`, string(addons[1].Values.Raw))
+	}
+
+	// The autoscaler values in spec take precedence over the settings.
+	config.Spec.Addons = append(config.Spec.Addons, ccev1.CCEAddon{
+		Name:   autoscalerAddonName,
+		Values: &runtime.RawExtension{Raw: []byte(`{"custom":{"expander":"priority","coresTotal":32000}}`)},
+	})
+	addons, err = desiredAddons(config)
+	if assert.Nil(err) && assert.Len(addons, 2) {
+		assert.Equal("1.25.21", addons[1].Version)
+		assert.JSONEq(`{"custom":{
+			"cluster_id":"mock-cluster-id",
+			"scaleDownEnabled":true,
+			"scaleDownDelayAfterAdd":15,
+			"scaleDownUtilizationThreshold":0.6,
+			"expander":"priority",
+			"coresTotal":32000
+		}}`, string(addons[1].Values.Raw))
+	}
+	// The spec is not modified.
+	assert.Empty(config.Spec.Addons[1].Version)
+
+	config.Spec.ClusterAutoscaler.ScaleDownUtilizationThreshold = "half"
+	_, err = desiredAddons(config)
+	assert.ErrorContains(err, "invalid clusterAutoscaler.scaleDownUtilizationThreshold")
+}
+
+func Test_autoscalerCondition(t *testing.T) {
+	assert := assert.New(t)
+	config := newMockConfig(cceConfigActivePhase)
+	_, _, _, required := autoscalerCondition(config, nil)
+	assert.False(required)
+
+	config.Spec.NodePools[0].Autoscaling.Enable = true
+	status, reason, message, required := autoscalerCondition(config, nil)
+	assert.True(required)
+	assert.Equal(metav1.ConditionFalse, status)
+	assert.Equal(reasonAutoscalerMissing, reason)
+	assert.Contains(message, "not installed")
+
+	addon := ccev1.CCEAddonStatus{Name: autoscalerAddonName, ID: "mock-autoscaler-id", Version: "1.25.21"}
+	for _, c := range []struct {
+		status string
+		want   metav1.ConditionStatus
+		reason string
+	}{
+		{"", metav1.ConditionFalse, reasonAddonsUpdating},
+		{"installing", metav1.ConditionFalse, reasonAddonsUpdating},
+		{"running", metav1.ConditionTrue, reasonAutoscalerRunning},
+		{"abnormal", metav1.ConditionFalse, reasonAutoscalerUnhealthy},
+		{"installFailed", metav1.ConditionFalse, reasonAutoscalerUnhealthy},
+	} {
+		addon.Status = c.status
+		status, reason, _, _ = autoscalerCondition(config, []ccev1.CCEAddonStatus{addon})
+		assert.Equal(c.want, status, c.status)
+		assert.Equal(c.reason, reason, c.status)
+	}
+}
@@ -2,11 +2,13 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"reflect"
+	"strings"
 	"time"
 
-	"github.com/Masterminds/semver/v3"
 	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
 	ccecontrollers "github.com/cnrancher/cce-operator/pkg/generated/controllers/cce.pandaria.io/v1"
 	"github.com/cnrancher/cce-operator/pkg/huawei"
@@ -16,18 +18,27 @@ import (
 	"github.com/cnrancher/cce-operator/pkg/huawei/eip"
 	"github.com/cnrancher/cce-operator/pkg/huawei/nat"
 	"github.com/cnrancher/cce-operator/pkg/huawei/vpc"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
 	"github.com/cnrancher/cce-operator/pkg/utils"
 	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	vpc_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
 	wranglerv1 "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v2/pkg/generic"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 )
 
 const (
 	controllerName           = "cce-operator"
 	controllerRemoveName     = "cce-operator-remove"
+	controllerSecretName     = "cce-operator-credential"
 	cceConfigCreatingPhase   = "creating"
 	cceConfigNotCreatedPhase = ""
 	cceConfigActivePhase     = "active"
@@ -36,32 +47,96 @@ const (
 	cceClusterConfigKind     = "CCEClusterConfig"
 )
 
+// subnetWaitInterval is the interval to check the subnet is active before
+// creating the NAT Gateway.
+var subnetWaitInterval = 5 * time.Second
+
 type Handler struct {
 	cceCC           ccecontrollers.CCEClusterConfigClient
 	cceEnqueueAfter func(namespace, name string, duration time.Duration)
 	cceEnqueue      func(namespace, name string)
 	secrets         wranglerv1.SecretClient
 	secretsCache    wranglerv1.SecretCache
-	drivers         map[string]*HuaweiDriver
+	drivers         *driverCache
+	recorder        record.EventRecorder
+	// configMaps is the client of the ConfigMaps in the operator namespace.
+	configMaps typedcorev1.ConfigMapInterface
+	// instanceID identifies the operator installation in the resource tags.
+	instanceID string
+
+	// overrides is set by the handler options in the tests.
+	overrides handlerOverrides
+}
+
+// handlerOverrides overrides the clients of the Handler, the zero value uses
+// the Huawei Cloud APIs.
+type handlerOverrides struct {
+	// endpoint overrides the Huawei Cloud API endpoint if not empty.
+	endpoint string
+	// clusterClient overrides the client of the CCE cluster if not nil.
+	clusterClient func(config *ccev1.CCEClusterConfig) (kubernetes.Interface, error)
+}
+
+// handlerOption overrides the clients of the Handler.
+type handlerOption func(o *handlerOverrides)
+
+// withEndpoint sends the Huawei Cloud API requests to the endpoint.
+func withEndpoint(endpoint string) handlerOption {
+	return func(o *handlerOverrides) {
+		o.endpoint = endpoint
+	}
+}
+
+// withClusterClient uses the function to get the client of the CCE cluster.
+func withClusterClient(
+	clusterClient func(config *ccev1.CCEClusterConfig) (kubernetes.Interface, error),
+) handlerOption {
+	return func(o *handlerOverrides) {
+		o.clusterClient = clusterClient
+	}
+}
+
+// apply applies the options to the Handler.
+func (h *Handler) apply(opts ...handlerOption) *Handler {
+	for _, opt := range opts {
+		opt(&h.overrides)
+	}
+	return h
 }
 
 func Register(
 	ctx context.Context,
 	secrets wranglerv1.SecretController,
 	cce ccecontrollers.CCEClusterConfigController,
-) {
+	events typedcorev1.EventsGetter,
+	configMaps typedcorev1.ConfigMapInterface,
+	instanceID string,
+) *Handler {
 	h := &Handler{
 		cceCC:           cce,
 		cceEnqueue:      cce.Enqueue,
 		cceEnqueueAfter: cce.EnqueueAfter,
 		secretsCache:    secrets.Cache(),
 		secrets:         secrets,
-		drivers:         make(map[string]*HuaweiDriver),
+		drivers:         newDriverCache(driverCacheTTL),
+		recorder:        newEventRecorder(ctx, events),
+		configMaps:      configMaps,
+		instanceID:      instanceID,
 	}
 
 	// Register handlers
 	cce.OnChange(ctx, controllerName, h.recordError(h.OnCCEConfigChanged))
-	cce.OnRemove(ctx, controllerRemoveName, h.OnCCEConfigRemoved)
+	secrets.OnChange(ctx, controllerSecretName, h.OnSecretChanged)
+	cce.OnRemove(ctx, controllerRemoveName, func(key string, config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
+		return observeReconcile("OnCCEConfigRemoved", func(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
+			return h.OnCCEConfigRemoved(key, config)
+		}, config)
+	})
+
+	metrics.RegisterClusterPhases(func() map[string]int {
+		return countClusterPhases(cce.Cache())
+	})
+	return h
 }
 
 func (h *Handler) OnCCEConfigChanged(_ string, config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
@@ -72,25 +147,79 @@ func (h *Handler) OnCCEConfigChanged(_ string, config *ccev1.CCEClusterConfig) (
 		return config, nil
 	}
 
-	// Ensure the driver in h.drivers map exists.
-	if err := h.setupHuaweiDriver(&config.Spec); err != nil {
+	// Ensure the driver of the credential and region is cached.
+	if _, err := h.setupHuaweiDriver(&config.Spec); err != nil {
 		return config, err
 	}
+	h.requeueCredentialRefresh(config)
 
 	switch config.Status.Phase {
 	case cceConfigImportingPhase:
-		return h.importCluster(config)
+		return observeReconcile("importCluster", h.importCluster, config)
 	case cceConfigNotCreatedPhase:
-		return h.create(config)
+		return observeReconcile("create", h.create, config)
 	case cceConfigCreatingPhase:
-		return h.waitForCreationComplete(config)
+		return observeReconcile("waitForCreationComplete", h.waitForCreationComplete, config)
 	case cceConfigActivePhase, cceConfigUpdatingPhase:
-		return h.checkAndUpdate(config)
+		return observeReconcile("checkAndUpdate", h.checkAndUpdate, config)
 	}
 
 	return config, nil
 }
 
+// observeReconcile calls the phase handler and records the reconcile metrics.
+func observeReconcile(
+	handler string,
+	reconcile func(*ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error),
+	config *ccev1.CCEClusterConfig,
+) (*ccev1.CCEClusterConfig, error) {
+	start := time.Now()
+	config, err := reconcile(config)
+	observed := err
+	if errors.Is(err, generic.ErrSkip) {
+		// The config is requeued to wait for the cloud resources.
+		observed = nil
+	}
+	metrics.ObserveReconcile(handler, time.Since(start), observed)
+	return config, err
+}
+
+// requeueError is returned to reconcile the config again after the delay when
+// waiting for the cloud resources, it is not recorded as the failure.
+type requeueError struct {
+	after   time.Duration
+	message string
+}
+
+func (e *requeueError) Error() string {
+	return e.message
+}
+
+func requeueAfter(after time.Duration, format string, args ...any) error {
+	return &requeueError{
+		after:   after,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+// countClusterPhases returns the number of the configs in each phase.
+func countClusterPhases(cache ccecontrollers.CCEClusterConfigCache) map[string]int {
+	configs, err := cache.List("", labels.Everything())
+	if err != nil {
+		logrus.Warnf("failed to list cce cluster configs: %v", err)
+		return nil
+	}
+	phases := map[string]int{}
+	for _, config := range configs {
+		phase := config.Status.Phase
+		if phase == cceConfigNotCreatedPhase {
+			phase = "pending"
+		}
+		phases[phase]++
+	}
+	return phases
+}
+
 // recordError writes the error return by onChange to the failureMessage field on status. If there is no error, then
 // empty string will be written to status
 func (h *Handler) recordError(
@@ -104,6 +233,15 @@ func (h *Handler) recordError(
 			// CCE config is likely deleting
 			return config, err
 		}
+		var requeue *requeueError
+		if errors.As(err, &requeue) {
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("%v, reconcile again in %v", requeue, requeue.after)
+			h.cceEnqueueAfter(config.Namespace, config.Name, requeue.after)
+			err = nil
+		}
 		if err != nil {
 			logrus.Warnf("%v", err)
 			if huawei.IsHuaweiError(err) {
@@ -113,6 +251,9 @@ func (h *Handler) recordError(
 			} else {
 				message = err.Error()
 			}
+			if config.Name != "" {
+				h.recorder.Event(config, corev1.EventTypeWarning, eventReasonFailed, message)
+			}
 		}
 
 		if config.Name == "" {
@@ -120,10 +261,7 @@ func (h *Handler) recordError(
 		}
 
 		if config.Status.FailureMessage == message {
-			// Avoid trigger the HWCloud API rate limit.
-			if message != "" {
-				time.Sleep(time.Second * 5)
-			}
+			// The failed config is requeued with the exponential backoff.
 			return config, err
 		}
 
@@ -146,8 +284,10 @@ func (h *Handler) recordError(
 }
 
 func (h *Handler) create(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
-	var err error
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, err
+	}
 	if config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{}); err != nil {
 		return config, err
 	}
@@ -158,8 +298,18 @@ func (h *Handler) create(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfi
 	if config.Spec.Imported {
 		config = config.DeepCopy()
 		config.Status.Phase = cceConfigImportingPhase
+		setCondition(config, ccev1.ConditionClusterProvisioned, metav1.ConditionFalse, reasonClusterImporting,
+			fmt.Sprintf("importing cluster ID [%s]", config.Spec.ClusterID))
 		return h.cceCC.UpdateStatus(config)
 	}
+	if isDryRun(config) {
+		return h.updatePlan(config, planCreate(config))
+	}
+	if len(config.Status.Plan) > 0 {
+		if config, err = h.updatePlan(config, nil); err != nil {
+			return config, err
+		}
+	}
 
 	if config, err = h.generateAndSetNetworking(config); err != nil {
 		return config, err
@@ -174,6 +324,8 @@ func (h *Handler) create(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfi
 				config.Spec.Name, config.Spec.ClusterID)
 			config = config.DeepCopy()
 			config.Status.Phase = cceConfigCreatingPhase
+			setCondition(config, ccev1.ConditionClusterProvisioned, metav1.ConditionFalse, reasonClusterCreating,
+				fmt.Sprintf("creating cluster [%s] ID [%s]", config.Spec.Name, config.Spec.ClusterID))
 			return h.cceCC.UpdateStatus(config)
 		}
 	}
@@ -209,6 +361,8 @@ func (h *Handler) create(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfi
 		config = config.DeepCopy()
 		config.Status.Phase = cceConfigCreatingPhase
 		config.Status.FailureMessage = ""
+		setCondition(config, ccev1.ConditionClusterProvisioned, metav1.ConditionFalse, reasonClusterCreating,
+			fmt.Sprintf("creating cluster [%s] ID [%s]", config.Spec.Name, config.Spec.ClusterID))
 		config, err = h.cceCC.UpdateStatus(config)
 		return err
 	}); err != nil {
@@ -219,14 +373,22 @@ func (h *Handler) create(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfi
 		"phase":   "create",
 	}).Infof("request to create cluster name [%s] ID [%s]",
 		config.Spec.Name, config.Spec.ClusterID)
+	h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreating,
+		"request to create cluster name [%s] ID [%s]", config.Spec.Name, config.Spec.ClusterID)
 	return config, err
 }
 
 func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
-	var err error
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, err
+	}
 	// Create Cluster PublicIP.
 	if config.Spec.PublicAccess && config.Spec.PublicIP.CreateEIP && config.Status.ClusterExternalIP == "" {
+		if config, err = h.updateCondition(config, ccev1.ConditionNetworkReady, metav1.ConditionFalse,
+			reasonCreatingEIP, "creating cluster public IP"); err != nil {
+			return config, err
+		}
 		res, err := eip.CreatePublicIP(driver.EIP, &config.Spec.PublicIP.Eip)
 		if err != nil {
 			return config, err
@@ -239,6 +401,9 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			"phase":   "create",
 		}).Infof("created cluster public IP [%s] address [%s]",
 			utils.Value(res.Publicip.Alias), utils.Value(res.Publicip.PublicIpAddress))
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreated,
+			"created cluster public IP [%s] address [%s]",
+			utils.Value(res.Publicip.Alias), utils.Value(res.Publicip.PublicIpAddress))
 		// Use the RetryOnConflict to prevent repeated creation of EIP.
 		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
@@ -276,10 +441,15 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			"cluster": config.Name,
 			"phase":   "create",
 		}).Infof("VPC ID not provided, will create VPC and subnet")
+		if config, err = h.updateCondition(config, ccev1.ConditionNetworkReady, metav1.ConditionFalse,
+			reasonCreatingVPC, "creating VPC and subnet"); err != nil {
+			return config, err
+		}
 		vpcRes, err := vpc.CreateVPC(
 			driver.VPC,
 			common.GenResourceName("vpc"),
 			vpc.DefaultVpcCIDR,
+			h.resourceOwner(config),
 		)
 		if err != nil {
 			return config, err
@@ -291,6 +461,8 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			"cluster": config.Name,
 			"phase":   "create",
 		}).Infof("created VPC name [%s] ID [%s]", vpcRes.Vpc.Name, vpcRes.Vpc.Id)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreated,
+			"created VPC name [%s] ID [%s]", vpcRes.Vpc.Name, vpcRes.Vpc.Id)
 		dnsServers, err := dns.ListNameServers(driver.DNS, config.Spec.RegionID)
 		if err != nil {
 			return config, err
@@ -319,6 +491,7 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			vpcRes.Vpc.Id,
 			dnsRecords[0],
 			dnsRecords[1],
+			h.resourceOwner(config),
 		)
 		if err != nil {
 			return config, err
@@ -331,6 +504,9 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			"phase":   "create",
 		}).Infof("created subnet for VPC [%s]: name [%s] ID [%s]",
 			vpcRes.Vpc.Name, subnetRes.Subnet.Name, subnetRes.Subnet.Id)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreated,
+			"created subnet for VPC [%s]: name [%s] ID [%s]",
+			vpcRes.Vpc.Name, subnetRes.Subnet.Name, subnetRes.Subnet.Id)
 		// Update status.
 		// Use the RetryOnConflict to prevent repeated creation of VPC & Subnet.
 		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -364,6 +540,10 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 		// VPC ID provided but subnet ID not provided.
 		// Create a subnet based on the provided VPC.
 		// Ensure provided VPC exists first.
+		if config, err = h.updateCondition(config, ccev1.ConditionNetworkReady, metav1.ConditionFalse,
+			reasonCreatingSubnet, fmt.Sprintf("creating subnet for VPC [%s]", config.Spec.HostNetwork.VpcID)); err != nil {
+			return config, err
+		}
 		vpcRes, err := vpc.ShowVPC(driver.VPC, config.Spec.HostNetwork.VpcID)
 		if err != nil {
 			return config, err
@@ -408,6 +588,7 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			config.Spec.HostNetwork.VpcID,
 			dnsRecords[0],
 			dnsRecords[1],
+			h.resourceOwner(config),
 		)
 		if err != nil {
 			return config, err
@@ -420,6 +601,9 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			"phase":   "create",
 		}).Infof("created subnet for VPC [%s]: name [%s] ID [%s]",
 			vpcRes.Vpc.Name, subnetRes.Subnet.Name, subnetRes.Subnet.Id)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreated,
+			"created subnet for VPC [%s]: name [%s] ID [%s]",
+			vpcRes.Vpc.Name, subnetRes.Subnet.Name, subnetRes.Subnet.Id)
 		// Update status.
 		// Use the RetryOnConflict to prevent repeated creation of subnet.
 		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -450,6 +634,13 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 	} else {
 		// Both VPC ID and subnet ID are provided.
 		// Ensure provided VPC and subnet exists.
+		if !meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNetworkReady) {
+			if config, err = h.updateCondition(config, ccev1.ConditionNetworkReady, metav1.ConditionFalse,
+				reasonCheckingNetwork, fmt.Sprintf("checking VPC [%s] and subnet [%s]",
+					config.Spec.HostNetwork.VpcID, config.Spec.HostNetwork.SubnetID)); err != nil {
+				return config, err
+			}
+		}
 		_, err = vpc.ShowVPC(driver.VPC, config.Spec.HostNetwork.VpcID)
 		if err != nil {
 			return config, err
@@ -465,11 +656,21 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			config.Spec.HostNetwork.VpcID, config.Spec.HostNetwork.SubnetID)
 	}
 
-	// Add timeout to avoid huawei "The virSubnet is not in the vpc" error.
-	time.Sleep(time.Second)
-
 	// Configure NAT Gateway.
 	if config.Spec.NatGateway.Enabled && config.Status.CreatedNatGatewayID == "" {
+		// Wait for the subnet to avoid huawei "The virSubnet is not in the vpc" error.
+		subnet, err := vpc.ShowSubnet(driver.VPC, config.Spec.HostNetwork.SubnetID)
+		if err != nil {
+			return config, err
+		}
+		if subnet.Subnet == nil || subnet.Subnet.Status != vpc_model.GetSubnetStatusEnum().ACTIVE {
+			return config, requeueAfter(subnetWaitInterval, "waiting for subnet [%s] to be active",
+				config.Spec.HostNetwork.SubnetID)
+		}
+		if config, err = h.updateCondition(config, ccev1.ConditionNetworkReady, metav1.ConditionFalse,
+			reasonCreatingNatGateway, "creating NAT Gateway"); err != nil {
+			return config, err
+		}
 		natRes, err := nat.CreateNatGateway(driver.NAT, common.GenResourceName("nat"), &config.Spec)
 		if err != nil {
 			return config, err
@@ -482,6 +683,8 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			"phase":   "create",
 		}).Infof("created NAT Gateway [%s] ID [%s]",
 			natRes.NatGateway.Name, natRes.NatGateway.Id)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreated,
+			"created NAT Gateway [%s] ID [%s]", natRes.NatGateway.Name, natRes.NatGateway.Id)
 		// Use the RetryOnConflict to prevent repeated creation of NAT Gateway.
 		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
@@ -498,6 +701,11 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 	}
 	// Configure SNAT Rule for NAT Gateway.
 	if config.Spec.NatGateway.Enabled && config.Status.CreatedSNATRuleID == "" {
+		if config, err = h.updateCondition(config, ccev1.ConditionNetworkReady, metav1.ConditionFalse,
+			reasonCreatingSNATRule, fmt.Sprintf("creating SNAT Rule for NAT Gateway [%s]",
+				config.Status.CreatedNatGatewayID)); err != nil {
+			return config, err
+		}
 		// Configure EIP for SNAT Rule.
 		var snatEipID string
 		if config.Spec.NatGateway.ExistingEIPID != "" {
@@ -524,6 +732,9 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 				"phase":   "create",
 			}).Infof("created public IP [%s] address [%s] for SNAT Rule",
 				utils.Value(eipRes.Publicip.Alias), utils.Value(eipRes.Publicip.PublicIpAddress))
+			h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreated,
+				"created public IP [%s] address [%s] for SNAT Rule",
+				utils.Value(eipRes.Publicip.Alias), utils.Value(eipRes.Publicip.PublicIpAddress))
 			snatEipID = utils.Value(eipRes.Publicip.Id)
 			// Use the RetryOnConflict to prevent repeated creation of EIP used by SNAT Rule.
 			if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -559,6 +770,8 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 			"cluster": config.Name,
 			"phase":   "create",
 		}).Infof("created SNAT Rule [%s]", snatRuleRes.SnatRule.Id)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreated,
+			"created SNAT Rule [%s]", snatRuleRes.SnatRule.Id)
 		// Use the RetryOnConflict to prevent repeated creation of SNAT Rule.
 		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
@@ -574,11 +787,20 @@ func (h *Handler) generateAndSetNetworking(config *ccev1.CCEClusterConfig) (*cce
 		}
 	}
 
-	return config, nil
+	if err = h.tagCreatedResources(config); err != nil {
+		return config, err
+	}
+
+	return h.updateCondition(config, ccev1.ConditionNetworkReady, metav1.ConditionTrue,
+		reasonNetworkConfigured, fmt.Sprintf("VPC [%s] and subnet [%s] are configured",
+			config.Spec.HostNetwork.VpcID, config.Spec.HostNetwork.SubnetID))
 }
 
 func (h *Handler) waitForCreationComplete(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, err
+	}
 	cluster, err := cce.ShowCluster(driver.CCE, config.Spec.ClusterID)
 	if err != nil {
 		return config, err
@@ -587,6 +809,10 @@ func (h *Handler) waitForCreationComplete(config *ccev1.CCEClusterConfig) (*ccev
 		return config, fmt.Errorf("cce.GetCluster returns invalid data")
 	}
 	if utils.Value(cluster.Status.Phase) == cce.ClusterStatusUnavailable {
+		if config, err = h.updateCondition(config, ccev1.ConditionClusterProvisioned, metav1.ConditionFalse,
+			reasonClusterFailed, utils.Value(cluster.Status.Reason)); err != nil {
+			return config, err
+		}
 		return config, fmt.Errorf("creation failed for cluster %q: %v",
 			cluster.Metadata.Name, utils.Value(cluster.Status.Reason))
 	}
@@ -601,6 +827,7 @@ func (h *Handler) waitForCreationComplete(config *ccev1.CCEClusterConfig) (*ccev
 			config.Spec.Name, config.Spec.ClusterID)
 		config = config.DeepCopy()
 		config.Status.Phase = cceConfigUpdatingPhase
+		setClusterProvisioned(config)
 		config, err = h.cceCC.UpdateStatus(config)
 		if err != nil {
 			return config, err
@@ -612,14 +839,22 @@ func (h *Handler) waitForCreationComplete(config *ccev1.CCEClusterConfig) (*ccev
 		"phase":   config.Status.Phase,
 	}).Infof("waiting for cluster [%s] status [%s]",
 		config.Spec.Name, utils.Value(cluster.Status.Phase))
+	if config, err = h.updateCondition(config, ccev1.ConditionClusterProvisioned, metav1.ConditionFalse,
+		reasonClusterCreating, fmt.Sprintf("waiting for cluster status [%s]",
+			utils.Value(cluster.Status.Phase))); err != nil {
+		return config, err
+	}
 	h.cceEnqueueAfter(config.Namespace, config.Name, 30*time.Second)
 
 	return config, nil
 }
 
 func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
-	if err := validateUpdate(config); err != nil {
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, err
+	}
+	if err := ValidateUpdate(config); err != nil {
 		// validation failed, will be considered a failing update until resolved
 		config = config.DeepCopy()
 		config.Status.Phase = cceConfigUpdatingPhase
@@ -651,6 +886,10 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 			// the upstream cluster version.
 			config = config.DeepCopy()
 			config.Status.UpgradeClusterTaskID = ""
+			config.Status.UpgradePreCheck = nil
+			config.Status.Upgrade = nil
+			setCondition(config, ccev1.ConditionUpgradeInProgress, metav1.ConditionFalse, reasonClusterVersionSynced,
+				fmt.Sprintf("cluster version is [%s]", utils.Value(cluster.Spec.Version)))
 			return h.cceCC.UpdateStatus(config)
 		}
 
@@ -659,6 +898,8 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 			hwerr, _ := huawei.NewHuaweiError(err)
 			if hwerr.StatusCode == 404 {
 				config = config.DeepCopy()
+				setCondition(config, ccev1.ConditionUpgradeInProgress, metav1.ConditionFalse, reasonUpgradeTaskNotFound,
+					fmt.Sprintf("upgrade task [%s] not found", config.Status.UpgradeClusterTaskID))
 				config.Status.UpgradeClusterTaskID = ""
 				return h.cceCC.UpdateStatus(config)
 			} else {
@@ -675,8 +916,22 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 					config.Spec.Name, utils.Value(cluster.Spec.Version))
 				config = config.DeepCopy()
 				config.Status.UpgradeClusterTaskID = ""
+				if config.Status.Upgrade != nil && len(config.Status.Upgrade.RemainingHops) != 0 {
+					// The next hop is upgraded in the following reconcile.
+					setCondition(config, ccev1.ConditionUpgradeInProgress, metav1.ConditionTrue, reasonUpgrading,
+						fmt.Sprintf("cluster upgraded to [%s], continuing to [%s]",
+							utils.Value(cluster.Spec.Version), config.Status.Upgrade.RemainingHops[0]))
+					return h.cceCC.UpdateStatus(config)
+				}
+				setCondition(config, ccev1.ConditionUpgradeInProgress, metav1.ConditionFalse, reasonUpgradeSucceeded,
+					fmt.Sprintf("cluster upgraded to [%s]", utils.Value(cluster.Spec.Version)))
 				return h.cceCC.UpdateStatus(config)
 			case "Failed":
+				if config, err = h.updateCondition(config, ccev1.ConditionUpgradeInProgress, metav1.ConditionFalse,
+					reasonUpgradeFailed, fmt.Sprintf("upgrade task [%s] failed",
+						config.Status.UpgradeClusterTaskID)); err != nil {
+					return config, err
+				}
 				return config, fmt.Errorf("failed to upgrade cluster [%s] to %v, status [%s]",
 					config.Spec.Name, config.Spec.Version, utils.Value(res.Status.Phase))
 			default:
@@ -685,6 +940,11 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 					"phase":   config.Status.Phase,
 				}).Infof("waiting for cluster [%s] upgrade task status [%s]",
 					config.Spec.Name, utils.Value(res.Status.Phase))
+				if config, err = h.updateCondition(config, ccev1.ConditionUpgradeInProgress, metav1.ConditionTrue,
+					reasonUpgrading, fmt.Sprintf("waiting for upgrade task [%s] status [%s]",
+						config.Status.UpgradeClusterTaskID, utils.Value(res.Status.Phase))); err != nil {
+					return config, err
+				}
 			}
 			h.cceEnqueueAfter(config.Namespace, config.Name, 30*time.Second)
 			return config, nil
@@ -701,9 +961,19 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 			"phase":   config.Status.Phase,
 		}).Infof("waiting for cluster [%s] finish status [%s]",
 			config.Spec.Name, utils.Value(cluster.Status.Phase))
-		if config.Status.Phase != cceConfigUpdatingPhase {
-			configUpdate := config.DeepCopy()
-			configUpdate.Status.Phase = cceConfigUpdatingPhase
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.Phase = cceConfigUpdatingPhase
+		var updateStatus = config.Status.Phase != cceConfigUpdatingPhase
+		message := fmt.Sprintf("waiting for cluster status [%s]", utils.Value(cluster.Status.Phase))
+		switch utils.Value(cluster.Status.Phase) {
+		case cce.ClusterStatusResizing:
+			updateStatus = setCondition(configUpdate, ccev1.ConditionResizeInProgress, metav1.ConditionTrue,
+				reasonResizing, message) || updateStatus
+		case cce.ClusterStatusUpgrading:
+			updateStatus = setCondition(configUpdate, ccev1.ConditionUpgradeInProgress, metav1.ConditionTrue,
+				reasonUpgrading, message) || updateStatus
+		}
+		if updateStatus {
 			if config, err = h.cceCC.UpdateStatus(configUpdate); err != nil {
 				return config, err
 			}
@@ -713,6 +983,14 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 	}
 	configUpdate := config.DeepCopy()
 	var updateStatus = false
+	if utils.Value(cluster.Status.Phase) == cce.ClusterStatusAvailable && setClusterProvisioned(configUpdate) {
+		updateStatus = true
+	}
+	if meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionResizeInProgress) {
+		setCondition(configUpdate, ccev1.ConditionResizeInProgress, metav1.ConditionFalse, reasonResizeCompleted,
+			fmt.Sprintf("cluster flavor is [%s]", cluster.Spec.Flavor))
+		updateStatus = true
+	}
 	if config.Status.AvailableZone != utils.Value(cluster.Spec.Az) {
 		configUpdate.Status.AvailableZone = utils.Value(cluster.Spec.Az)
 		updateStatus = true
@@ -755,6 +1033,10 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 			"phase":   config.Status.Phase,
 		}).Debugf("waiting for cce-operator-controller (Rancher) to update nodePool ID: %v",
 			utils.PrintObject(config.Spec.CreatedNodePoolIDs))
+		if config, err = h.updateCondition(config, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse,
+			reasonWaitingNodePoolIDs, "waiting for Rancher to update the created node pool IDs"); err != nil {
+			return config, err
+		}
 		h.cceEnqueueAfter(config.Namespace, config.Name, 10*time.Second)
 		return config, nil
 	}
@@ -767,6 +1049,23 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 	if nodePools == nil || nodePools.Items == nil {
 		return config, fmt.Errorf("checkAndUpdate: failed to get cluster nodePools: Items is nil")
 	}
+	nodes, err := cce.ListNodes(driver.CCE, config.Spec.ClusterID)
+	if err != nil {
+		return config, err
+	}
+	// Update the node pool status before waiting for the node pools,
+	// so the node pool errors can be found in the config status.
+	nodePoolStatus := BuildNodePoolStatus(nodePools, nodes)
+	if upstreamNodePools, err := BuildUpstreamNodePoolConfigs(nodePools); err == nil {
+		setNodePoolDrift(nodePoolStatus, config.Spec.NodePools, upstreamNodePools)
+	}
+	if !reflect.DeepEqual(nodePoolStatus, config.Status.NodePools) {
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.NodePools = nodePoolStatus
+		if config, err = h.cceCC.UpdateStatus(configUpdate); err != nil {
+			return config, err
+		}
+	}
 	if len(*nodePools.Items) == 0 {
 		logrus.WithFields(logrus.Fields{
 			"cluster": config.Name,
@@ -788,10 +1087,13 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 				"phase":   config.Status.Phase,
 			}).Infof("waiting for nodepool %q %q status: %q",
 				np.Metadata.Name, utils.Value(np.Metadata.Uid), np.Status.Phase.Value())
-			if config.Status.Phase != cceConfigUpdatingPhase {
-				config = config.DeepCopy()
-				config.Status.Phase = cceConfigUpdatingPhase
-				if config, err = h.cceCC.UpdateStatus(config); err != nil {
+			configUpdate := config.DeepCopy()
+			configUpdate.Status.Phase = cceConfigUpdatingPhase
+			if setCondition(configUpdate, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse, reasonNodePoolNotReady,
+				fmt.Sprintf("waiting for nodePool [%s] ID [%s] status [%s]",
+					np.Metadata.Name, utils.Value(np.Metadata.Uid), np.Status.Phase.Value())) ||
+				config.Status.Phase != cceConfigUpdatingPhase {
+				if config, err = h.cceCC.UpdateStatus(configUpdate); err != nil {
 					return config, err
 				}
 			}
@@ -815,16 +1117,20 @@ func (h *Handler) checkAndUpdate(config *ccev1.CCEClusterConfig) (*ccev1.CCEClus
 func (h *Handler) updateUpstreamClusterState(
 	upstreamSpec *ccev1.CCEClusterConfigSpec, config *ccev1.CCEClusterConfig,
 ) (*ccev1.CCEClusterConfig, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
-	var err error
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, err
+	}
 	if config.Spec.Imported {
-		if config.Status.Phase != cceConfigActivePhase {
+		if config.Status.Phase != cceConfigActivePhase ||
+			config.Status.ObservedGeneration != config.Generation {
 			logrus.WithFields(logrus.Fields{
 				"cluster": config.Name,
 				"phase":   config.Status.Phase,
 			}).Infof("cluster [%s] finished updating", config.Spec.Name)
 			config = config.DeepCopy()
 			config.Status.Phase = cceConfigActivePhase
+			config.Status.ObservedGeneration = config.Generation
 			config, err = h.cceCC.UpdateStatus(config)
 			if err != nil {
 				return config, err
@@ -833,6 +1139,22 @@ func (h *Handler) updateUpstreamClusterState(
 		}
 		return config, nil
 	}
+	if isDryRun(config) {
+		plan, err := planUpstreamClusterState(upstreamSpec, config)
+		if err != nil {
+			return config, err
+		}
+		addonPlan, err := h.planAddons(config)
+		if err != nil {
+			return config, err
+		}
+		return h.updatePlan(config, append(plan, addonPlan...))
+	}
+	if len(config.Status.Plan) > 0 {
+		if config, err = h.updatePlan(config, nil); err != nil {
+			return config, err
+		}
+	}
 
 	// Init security group ID for created cluster if the security group wasn't
 	// provided when creating the cluster.
@@ -846,25 +1168,32 @@ func (h *Handler) updateUpstreamClusterState(
 		}
 	}
 
+	// Defer the disruptive operations until the maintenance window opens.
+	if config, err = h.syncMaintenance(config, upstreamSpec); err != nil {
+		return config, err
+	}
+
 	// Check kubernetes version for upgrade cluster.
 	if ok, err := clusterUpgradeable(config.Spec.Version, upstreamSpec.Version); err != nil {
 		return config, err
 	} else if ok {
-		return h.upgradeCluster(config)
-	}
-	// Check cluster flavor is resizable.
-	var clusterResizable = false
-	if config.Spec.Flavor != "" && config.Spec.Flavor != upstreamSpec.Flavor {
-		cv, err := semver.NewVersion(config.Spec.Version)
-		if err != nil {
-			return config, err
+		if !maintenanceDeferred(config) {
+			var upgrading bool
+			if config, upgrading, err = h.syncClusterUpgrade(config, upstreamSpec.Version); err != nil || upgrading {
+				return config, err
+			}
 		}
-		minVersion := semver.New(1, 15, 0, "", "")
-		if cv.Compare(minVersion) >= 0 {
-			clusterResizable = true
+		// The upgrade is blocked until the pre-upgrade check passes or the
+		// maintenance window opens, continue to update the other settings.
+	} else if config.Status.UpgradePreCheck != nil || config.Status.Upgrade != nil {
+		if config, err = h.clearUpgradeStatus(config, upstreamSpec.Version); err != nil {
+			return config, err
 		}
 	}
-	if clusterResizable {
+	// Check cluster flavor is resizable.
+	if ok, err := clusterResizable(config, upstreamSpec); err != nil {
+		return config, err
+	} else if ok && !maintenanceDeferred(config) {
 		logrus.WithFields(logrus.Fields{
 			"cluster": config.Name,
 			"phase":   config.Status.Phase,
@@ -888,6 +1217,8 @@ func (h *Handler) updateUpstreamClusterState(
 			"phase":   config.Status.Phase,
 		}).Infof("start resize cluster [%s] job ID %q",
 			config.Spec.Name, utils.Value(res.JobID))
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonResizing,
+			"start resize cluster [%s] job ID %q", config.Spec.Name, utils.Value(res.JobID))
 		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
 			if err != nil {
@@ -895,6 +1226,8 @@ func (h *Handler) updateUpstreamClusterState(
 			}
 			configUpdate := config.DeepCopy()
 			configUpdate.Status.ResizeClusterJobID = utils.Value(res.JobID)
+			setCondition(configUpdate, ccev1.ConditionResizeInProgress, metav1.ConditionTrue, reasonResizing,
+				fmt.Sprintf("resizing cluster flavor to [%s], job ID [%s]", config.Spec.Flavor, utils.Value(res.JobID)))
 			config, err = h.cceCC.UpdateStatus(configUpdate)
 			return err
 		})
@@ -908,52 +1241,50 @@ func (h *Handler) updateUpstreamClusterState(
 	if _, err = cce.UpdateCluster(driver.CCE, config); err != nil {
 		return config, err
 	}
+	// Drain and delete the nodes before scaling the nodePools.
+	config, operating, err := h.syncNodeOperations(config)
+	if err != nil || operating {
+		return config, err
+	}
+	// Remediate the unhealthy nodes of the nodePools enabling the health policy.
+	if config, err = h.syncNodeHealth(config); err != nil {
+		return config, err
+	}
 	// Update nodePool infos.
+	upstreamNodePools := make(map[string]*ccev1.CCENodePool, len(upstreamSpec.NodePools))
+	for i := range upstreamSpec.NodePools {
+		upstreamNodePools[upstreamSpec.NodePools[i].ID] = &upstreamSpec.NodePools[i]
+	}
 	for _, np := range config.Spec.NodePools {
-		if np.ID == "" {
+		// The replaced node pools may be deleted before Rancher updates the ID.
+		upstream, ok := upstreamNodePools[np.ID]
+		if np.ID == "" || !ok {
 			continue
 		}
-		_, err := cce.UpdateNodePool(driver.CCE, config.Spec.ClusterID, &np)
+		_, err := cce.UpdateNodePool(driver.CCE, config.Spec.ClusterID, &np, &upstream.NodeTemplate)
 		if err != nil {
 			return config, err
 		}
 	}
+	// Replace the nodePools whose immutable fields were changed.
+	config, replacing, err := h.syncNodePoolReplacements(config, upstreamSpec)
+	if err != nil || replacing {
+		return config, err
+	}
 
 	// Compare nodePools between upstream & config spec.
 	enqueueNodePool := false
-	upstreamNodePoolIDs := make(map[string]bool, len(upstreamSpec.NodePools))
-	upstreamNodePoolNames := make(map[string]bool, len(upstreamSpec.NodePools))
-	specNodePoolIDs := make(map[string]bool, len(config.Spec.NodePools))
-	specNodePoolNames := make(map[string]bool, len(config.Spec.NodePools))
-	for _, np := range upstreamSpec.NodePools {
-		upstreamNodePoolIDs[np.ID] = true
-		upstreamNodePoolNames[np.Name] = true
+	toCreate, toDelete := nodePoolChanges(config, upstreamSpec)
+	toDelete, blocked, err := protectNodePoolDeletion(driver.CCE, config, toDelete)
+	if err != nil {
+		return config, err
+	}
+	var deferred []ccev1.CCENodePool
+	if maintenanceDeferred(config) {
+		toDelete, deferred = nil, toDelete
 	}
 	createdNodePoolIDs := map[string]string{}
-	for i := 0; i < len(config.Spec.NodePools); i++ {
-		// This for loop create nodePool exists in spec but not exists in upstream.
-		np := &config.Spec.NodePools[i]
-		if np.ID != "" {
-			specNodePoolIDs[np.ID] = true
-			specNodePoolNames[np.Name] = true
-		}
-		if upstreamNodePoolIDs[np.ID] {
-			logrus.WithFields(logrus.Fields{
-				"cluster": config.Name,
-				"phase":   config.Status.Phase,
-			}).Debugf("found nodePool [%s] ID [%s] exists in cce cluster [%s]",
-				np.Name, np.ID, config.Spec.Name)
-			continue
-		}
-		if upstreamNodePoolNames[np.Name] {
-			// Prevent creation of node pools with the same name.
-			logrus.WithFields(logrus.Fields{
-				"cluster": config.Name,
-				"phase":   config.Status.Phase,
-			}).Debugf("nodePool [%s] exists in cce cluster [%s], skip creation",
-				np.Name, config.Spec.Name)
-			continue
-		}
+	for _, np := range toCreate {
 		// Create nodePool if not found in upstream spec.
 		res, err := cce.CreateNodePool(driver.CCE, config.Spec.ClusterID, np)
 		if err != nil {
@@ -968,6 +1299,8 @@ func (h *Handler) updateUpstreamClusterState(
 			"phase":   config.Status.Phase,
 		}).Infof("request to create nodePool [%s] ID [%s]",
 			res.Metadata.Name, utils.Value(res.Metadata.Uid))
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreating,
+			"request to create nodePool [%s] ID [%s]", res.Metadata.Name, utils.Value(res.Metadata.Uid))
 		createdNodePoolIDs[res.Metadata.Name] = utils.Value(res.Metadata.Uid)
 	}
 	if len(createdNodePoolIDs) != 0 {
@@ -987,16 +1320,7 @@ func (h *Handler) updateUpstreamClusterState(
 		}
 	}
 
-	for _, np := range upstreamSpec.NodePools {
-		// This for loop deletes nodePool exists in upstream but not exists in spec.
-		if specNodePoolIDs[np.ID] {
-			continue
-		}
-		logrus.WithFields(logrus.Fields{
-			"cluster": config.Name,
-			"phase":   config.Status.Phase,
-		}).Debugf("nodePool [%s] ID [%s] exists in upstream but not exists in config spec",
-			np.Name, np.ID)
+	for _, np := range toDelete {
 		// Delete nodePool.
 		if _, err := cce.DeleteNodePool(driver.CCE, config.Spec.ClusterID, np.ID); err != nil {
 			return config, err
@@ -1006,32 +1330,80 @@ func (h *Handler) updateUpstreamClusterState(
 			"cluster": config.Name,
 			"phase":   config.Status.Phase,
 		}).Infof("request to delete nodePool [%s] ID [%s]", np.Name, np.ID)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+			"request to delete nodePool [%s] ID [%s]", np.Name, np.ID)
+	}
+	if config, err = h.removeDeletionConfirmations(config, toDelete); err != nil {
+		return config, err
 	}
 	if enqueueNodePool {
-		if config.Status.Phase != cceConfigUpdatingPhase {
-			if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
-				if err != nil {
-					return err
-				}
-				config = config.DeepCopy()
-				config.Status.Phase = cceConfigUpdatingPhase
-				config, err = h.cceCC.UpdateStatus(config)
+		message := fmt.Sprintf("request to create %d and delete %d nodePools",
+			len(createdNodePoolIDs), len(toDelete))
+		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+			if err != nil {
 				return err
-			}); err != nil {
-				return config, err
 			}
+			configUpdate := config.DeepCopy()
+			configUpdate.Status.Phase = cceConfigUpdatingPhase
+			if !setCondition(configUpdate, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse,
+				reasonNodePoolsUpdating, message) && config.Status.Phase == cceConfigUpdatingPhase {
+				return nil
+			}
+			config, err = h.cceCC.UpdateStatus(configUpdate)
+			return err
+		}); err != nil {
+			return config, err
 		}
 		h.cceEnqueueAfter(config.Namespace, config.Name, 10*time.Second)
 		return config, nil
 	}
 
-	if config.Status.Phase != cceConfigActivePhase {
+	// Sync the addons after the node pools are synced, so the addon
+	// workloads can be scheduled to the nodes.
+	if config, err = h.syncAddons(config); err != nil {
+		return config, err
+	}
+
+	configUpdate := config.DeepCopy()
+	configUpdate.Status.ObservedGeneration = config.Generation
+	changed := false
+	if len(blocked) != 0 {
+		// The blocked node pools are kept until the protection is changed.
+		message := strings.Join(blocked, "; ")
+		if setCondition(configUpdate, ccev1.ConditionNodePoolDeletionBlocked, metav1.ConditionTrue,
+			reasonDeletionBlocked, message) {
+			changed = true
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Warnf("nodePool deletion blocked: %s", message)
+			h.recorder.Event(config, corev1.EventTypeWarning, eventReasonBlocked, message)
+		}
+		changed = setCondition(configUpdate, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse,
+			reasonDeletionBlocked, fmt.Sprintf("deletion of %d nodePools is blocked", len(blocked))) || changed
+	} else {
+		if meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNodePoolDeletionBlocked) {
+			changed = setCondition(configUpdate, ccev1.ConditionNodePoolDeletionBlocked, metav1.ConditionFalse,
+				reasonDeletionNotBlocked, "no nodePool deletion is blocked")
+		}
+		if len(deferred) != 0 {
+			changed = setCondition(configUpdate, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse,
+				reasonOutsideMaintenanceWindow, fmt.Sprintf("deletion of %d nodePools is deferred to the maintenance window",
+					len(deferred))) || changed
+		} else {
+			changed = setCondition(configUpdate, ccev1.ConditionNodePoolsSynced, metav1.ConditionTrue, reasonNodePoolsSynced,
+				fmt.Sprintf("%d nodePools are synced", len(config.Spec.NodePools))) || changed
+		}
+	}
+	if changed ||
+		config.Status.Phase != cceConfigActivePhase ||
+		config.Status.ObservedGeneration != config.Generation {
 		logrus.WithFields(logrus.Fields{
 			"cluster": config.Name,
 			"phase":   config.Status.Phase,
 		}).Infof("cluster [%s] finished updating", config.Spec.Name)
-		config = config.DeepCopy()
+		config = configUpdate
 		config.Status.Phase = cceConfigActivePhase
 		config, err = h.cceCC.UpdateStatus(config)
 		if err != nil {
@@ -1043,7 +1415,10 @@ func (h *Handler) updateUpstreamClusterState(
 }
 
 func (h *Handler) importCluster(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, err
+	}
 	cluster, err := cce.ShowCluster(driver.CCE, config.Spec.ClusterID)
 	if err != nil {
 		return config, err
@@ -1064,6 +1439,11 @@ func (h *Handler) importCluster(config *ccev1.CCEClusterConfig) (*ccev1.CCEClust
 			"phase":   config.Status.Phase,
 		}).Infof("waiting for cluster [%s] finish status [%s]",
 			config.Spec.Name, utils.Value(cluster.Status.Phase))
+		if config, err = h.updateCondition(config, ccev1.ConditionClusterProvisioned, metav1.ConditionFalse,
+			reasonClusterImporting, fmt.Sprintf("waiting for cluster status [%s]",
+				utils.Value(cluster.Status.Phase))); err != nil {
+			return config, err
+		}
 		h.cceEnqueueAfter(config.Namespace, config.Name, 15*time.Second)
 		return config, nil
 	}
@@ -1115,6 +1495,8 @@ func (h *Handler) importCluster(config *ccev1.CCEClusterConfig) (*ccev1.CCEClust
 		}
 		configUpdate := config.DeepCopy()
 		configUpdate.Status.Phase = cceConfigActivePhase
+		configUpdate.Status.ObservedGeneration = config.Generation
+		setClusterProvisioned(configUpdate)
 		config, err = h.cceCC.UpdateStatus(configUpdate)
 		return err
 	})
@@ -1124,7 +1506,10 @@ func (h *Handler) importCluster(config *ccev1.CCEClusterConfig) (*ccev1.CCEClust
 
 // createCASecret creates a secret containing a CA and endpoint for use in generating a kubeconfig file.
 func (h *Handler) createCASecret(config *ccev1.CCEClusterConfig) error {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return err
+	}
 	certs, err := cce.GetClusterCert(driver.CCE, config.Spec.ClusterID, 0)
 	if err != nil {
 		return err
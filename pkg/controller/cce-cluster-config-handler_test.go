@@ -0,0 +1,2371 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
+	"github.com/cnrancher/cce-operator/pkg/huawei/common"
+	"github.com/cnrancher/cce-operator/pkg/huawei/fake"
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/rancher/wrangler/v2/pkg/generic"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	testNamespace        = "cattle-global-data"
+	testCredentialSecret = "cattle-global-data:cc-test"
+	testInstanceID       = "test-instance"
+)
+
+type testEnv struct {
+	server  *fake.Server
+	handler *Handler
+	configs *fakeStore[*ccev1.CCEClusterConfig, *ccev1.CCEClusterConfigList]
+	secrets *fakeStore[*corev1.Secret, *corev1.SecretList]
+	queue   *fakeQueue
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	ratelimit.QPS = 1000
+	ratelimit.Burst = 1000
+	removeNodeWaitInterval = 0
+	removeClusterWaitInterval = 0
+	removeNetworkWaitInterval = 0
+
+	server := fake.NewServer()
+	t.Cleanup(server.Close)
+	e := &testEnv{
+		server:  server,
+		configs: newFakeCCEClusterConfigStore(),
+		secrets: newFakeSecretStore(),
+		queue:   &fakeQueue{},
+	}
+	e.handler = &Handler{
+		cceCC:           e.configs,
+		cceEnqueue:      e.queue.enqueue,
+		cceEnqueueAfter: e.queue.enqueueAfter,
+		secrets:         e.secrets,
+		secretsCache:    e.secrets.cache(),
+		drivers:         newDriverCache(driverCacheTTL),
+		recorder:        record.NewFakeRecorder(1000),
+		configMaps:      k8sfake.NewSimpleClientset().CoreV1().ConfigMaps("cattle-system"),
+		instanceID:      testInstanceID,
+	}
+	e.handler.apply(withEndpoint(server.URL))
+	_, err := e.secrets.Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cc-test",
+			Namespace: testNamespace,
+		},
+		Data: map[string][]byte{
+			"huaweicredentialConfig-accessKey": []byte("fake-ak"),
+			"huaweicredentialConfig-secretKey": []byte("fake-sk"),
+			"huaweicredentialConfig-projectID": []byte(server.ProjectID),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// remove calls OnCCEConfigRemoved with the latest config until the config is
+// not requeued to wait for the resources to be deleted.
+func (e *testEnv) remove(t *testing.T, config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
+	t.Helper()
+
+	for i := 0; i < 20; i++ {
+		removed, err := e.handler.OnCCEConfigRemoved("", config)
+		if !errors.Is(err, generic.ErrSkip) {
+			return removed, err
+		}
+		if config, err = e.configs.Get(config.Namespace, config.Name, metav1.GetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Fatalf("config %q is not removed", config.Name)
+	return config, nil
+}
+
+// reconcile calls OnCCEConfigChanged with the latest config until the phase
+// of config matches the expected phase.
+func (e *testEnv) reconcile(t *testing.T, name, phase string) *ccev1.CCEClusterConfig {
+	t.Helper()
+
+	for i := 0; i < 20; i++ {
+		config, err := e.configs.Get(testNamespace, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Status.Phase == phase {
+			return config
+		}
+		if _, err = e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatalf("reconcile config in phase %q: %v", config.Status.Phase, err)
+		}
+	}
+	t.Fatalf("config %q does not reach phase %q", name, phase)
+	return nil
+}
+
+func newTestConfig(name string) *ccev1.CCEClusterConfig {
+	return &ccev1.CCEClusterConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+		Spec: ccev1.CCEClusterConfigSpec{
+			HuaweiCredentialSecret: testCredentialSecret,
+			Category:               "CCE",
+			RegionID:               fake.DefaultRegion,
+			Name:                   name,
+			Type:                   "VirtualMachine",
+			Flavor:                 "cce.s1.small",
+			Version:                "v1.25",
+			ContainerNetwork: ccev1.CCEContainerNetwork{
+				Mode: "vpc-router",
+				CIDR: "172.16.0.0/16",
+			},
+			KubernetesSvcIPRange: "10.247.0.0/16",
+			KubeProxyMode:        "iptables",
+			PublicAccess:         true,
+			PublicIP: ccev1.CCEClusterPublicIP{
+				CreateEIP: true,
+				Eip: ccev1.CCEEip{
+					Iptype: "5_bgp",
+					Bandwidth: ccev1.CCEEipBandwidth{
+						ChargeMode: "traffic",
+						Size:       5,
+						ShareType:  "PER",
+					},
+				},
+			},
+			NatGateway: ccev1.CCENatGateway{
+				Enabled: true,
+				SNatRuleEIP: ccev1.CCEEip{
+					Iptype: "5_bgp",
+					Bandwidth: ccev1.CCEEipBandwidth{
+						ChargeMode: "traffic",
+						Size:       5,
+						ShareType:  "PER",
+					},
+				},
+			},
+			NodePools: []ccev1.CCENodePool{
+				{
+					Name: "nodepool-1",
+					Type: "vm",
+					NodeTemplate: ccev1.CCENodeTemplate{
+						Flavor:          "c6.large.2",
+						AvailableZone:   "cn-north-4a",
+						OperatingSystem: "EulerOS 2.9",
+						SSHKey:          "ssh-key",
+						RootVolume:      ccev1.CCENodeVolume{Size: 50, Type: "SSD"},
+						DataVolumes:     []ccev1.CCENodeVolume{{Size: 100, Type: "SSD"}},
+						Runtime:         "containerd",
+					},
+					InitialNodeCount: 2,
+				},
+			},
+		},
+	}
+}
+
+// syncCreatedNodePoolIDs updates the created node pool IDs to the spec
+// like the cce-operator-controller in Rancher does.
+func (e *testEnv) syncCreatedNodePoolIDs(t *testing.T, name string) {
+	t.Helper()
+
+	config, err := e.configs.Get(testNamespace, name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Spec.CreatedNodePoolIDs) == 0 {
+		return
+	}
+	for i := range config.Spec.NodePools {
+		np := &config.Spec.NodePools[i]
+		if id, ok := config.Spec.CreatedNodePoolIDs[np.Name]; ok {
+			np.ID = id
+		}
+	}
+	config.Spec.CreatedNodePoolIDs = nil
+	if _, err = e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_CCEClusterConfig_Lifecycle(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+	e.server.Delay = 2
+
+	_, err := e.configs.Create(newTestConfig("cce-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	assert.NotEmpty(config.Spec.ClusterID)
+	assert.NotEmpty(config.Spec.HostNetwork.VpcID)
+	assert.NotEmpty(config.Spec.HostNetwork.SubnetID)
+	assert.Equal(config.Spec.HostNetwork.VpcID, config.Status.CreatedVpcID)
+	assert.Equal(config.Spec.HostNetwork.SubnetID, config.Status.CreatedSubnetID)
+	assert.NotEmpty(config.Status.CreatedClusterEIPID)
+	assert.NotEmpty(config.Status.ClusterExternalIP)
+	assert.NotEmpty(config.Status.CreatedNatGatewayID)
+	assert.NotEmpty(config.Status.CreatedSNatRuleEIPID)
+	assert.NotEmpty(config.Status.CreatedSNATRuleID)
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNetworkReady))
+	assert.True(meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionClusterProvisioned))
+	events := recordedEvents(e.handler.recorder)
+	if assert.Len(events, 7) {
+		assert.Contains(events[0], "Normal Created created cluster public IP")
+		assert.Contains(events[1], "Normal Created created VPC")
+		assert.Contains(events[6], "Normal Creating request to create cluster")
+	}
+
+	config = e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	_, err = e.secrets.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	assert.Nil(err)
+
+	// Create the node pool and wait for Rancher to update the node pool ID.
+	_, err = e.handler.OnCCEConfigChanged("", config)
+	assert.Nil(err)
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	assert.Len(config.Spec.CreatedNodePoolIDs, 1)
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	assert.NotEmpty(config.Spec.NodePools[0].ID)
+	assert.NotEmpty(config.Spec.HostNetwork.SecurityGroup)
+	assert.Len(config.Status.Endpoints, 2)
+	assert.NotEmpty(config.Status.AvailableZone)
+	assert.Equal(1, e.server.Resources()[fake.KindNodePool])
+	assert.Equal(2, e.server.Resources()[fake.KindNode])
+	assert.Empty(config.Status.FailureMessage)
+	if assert.Len(config.Status.NodePools, 1) {
+		assert.Equal(config.Spec.NodePools[0].ID, config.Status.NodePools[0].ID)
+		assert.Equal(int32(2), config.Status.NodePools[0].DesiredNodes)
+		assert.Equal(int32(2), config.Status.NodePools[0].CurrentNodes)
+		assert.Len(config.Status.NodePools[0].Nodes, 2)
+	}
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionClusterProvisioned))
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNodePoolsSynced))
+	assert.Equal(config.Generation, config.Status.ObservedGeneration)
+	events = recordedEvents(e.handler.recorder)
+	if assert.Len(events, 1) {
+		assert.Contains(events[0], "Normal Creating request to create nodePool [nodepool-1]")
+	}
+
+	config, err = e.remove(t, config)
+	assert.Nil(err)
+	assert.Empty(config.Spec.ClusterID)
+	for _, event := range recordedEvents(e.handler.recorder) {
+		assert.Contains(event, "Normal Deleting")
+	}
+	for kind, count := range e.server.Resources() {
+		assert.Zerof(count, "%s resources were not deleted", kind)
+	}
+	assert.Empty(config.Status.CreatedVpcID)
+	assert.Empty(config.Status.CreatedSubnetID)
+	assert.Empty(config.Status.CreatedNatGatewayID)
+	assert.Empty(config.Status.CreatedClusterEIPID)
+	assert.Empty(config.Status.CreatedSNatRuleEIPID)
+	c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionDeleting)
+	if assert.NotNil(c) {
+		assert.Equal(reasonDeletingNetwork, c.Reason)
+	}
+	if assert.NotNil(config.Status.Deletion) {
+		assert.Equal(ccev1.DeletionStageDeletingVPC, config.Status.Deletion.Stage)
+	}
+}
+
+func Test_CCEClusterConfig_ExistingNetwork(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	// Create the cluster in the VPC & subnet created by another config.
+	_, err := e.configs.Create(newTestConfig("cce-test-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1 := e.reconcile(t, "cce-test-1", cceConfigCreatingPhase)
+
+	c2 := newTestConfig("cce-test-2")
+	c2.Spec.HostNetwork.VpcID = c1.Spec.HostNetwork.VpcID
+	c2.Spec.HostNetwork.SubnetID = c1.Spec.HostNetwork.SubnetID
+	c2.Spec.PublicAccess = false
+	c2.Spec.PublicIP.CreateEIP = false
+	c2.Spec.NatGateway.Enabled = false
+	if _, err = e.configs.Create(c2); err != nil {
+		t.Fatal(err)
+	}
+	c2 = e.reconcile(t, "cce-test-2", cceConfigCreatingPhase)
+	assert.Empty(c2.Status.CreatedVpcID)
+	assert.Empty(c2.Status.CreatedSubnetID)
+	assert.Empty(c2.Status.CreatedNatGatewayID)
+	assert.Empty(c2.Status.ClusterExternalIP)
+
+	// The VPC & subnet provided by user should not be deleted.
+	c2 = e.reconcile(t, "cce-test-2", cceConfigUpdatingPhase)
+	_, err = e.remove(t, c2)
+	assert.Nil(err)
+	assert.Equal(1, e.server.Resources()[fake.KindCluster])
+	assert.Equal(1, e.server.Resources()[fake.KindVPC])
+	assert.Equal(1, e.server.Resources()[fake.KindSubnet])
+}
+
+func Test_CCEClusterConfig_DeletionPolicy(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	_, err := e.configs.Create(newTestConfig("cce-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	resources := e.server.Resources()
+	recordedEvents(e.handler.recorder)
+
+	config.Spec.DeletionProtection = true
+	if config, err = e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	config, err = e.remove(t, config)
+	assert.ErrorContains(err, "deletionProtection")
+	assert.Equal(resources, e.server.Resources())
+	c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionDeleting)
+	if assert.NotNil(c) {
+		assert.Equal(metav1.ConditionFalse, c.Status)
+		assert.Equal(reasonDeletionProtected, c.Reason)
+	}
+	events := recordedEvents(e.handler.recorder)
+	if assert.Len(events, 1) {
+		assert.Contains(events[0], "Warning DeletionBlocked")
+	}
+
+	config.Spec.DeletionProtection = false
+	config.Spec.DeletionPolicy = ccev1.DeletionPolicyRetain
+	if config, err = e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	config, err = e.remove(t, config)
+	assert.Nil(err)
+	assert.Equal(resources, e.server.Resources())
+
+	config.Spec.DeletionPolicy = ccev1.DeletionPolicyRetainNetwork
+	if config, err = e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	config, err = e.remove(t, config)
+	assert.Nil(err)
+	assert.Empty(config.Spec.ClusterID)
+	assert.Zero(e.server.Resources()[fake.KindCluster])
+	assert.Equal(1, e.server.Resources()[fake.KindVPC])
+	assert.Equal(1, e.server.Resources()[fake.KindSubnet])
+	assert.Equal(1, e.server.Resources()[fake.KindNatGateway])
+	assert.NotEmpty(config.Status.CreatedVpcID)
+	assert.NotEmpty(config.Status.CreatedNatGatewayID)
+	// The retained resources are not collected as orphans.
+	for _, id := range []string{
+		config.Status.CreatedVpcID,
+		config.Status.CreatedSubnetID,
+		config.Status.CreatedNatGatewayID,
+		config.Status.CreatedClusterEIPID,
+		config.Status.CreatedSNatRuleEIPID,
+	} {
+		assert.Equal("true", e.server.Tags(id)[common.TagRetained])
+	}
+}
+
+func Test_CCEClusterConfig_DeletionStages(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	_, err := e.configs.Create(newTestConfig("cce-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	recordedEvents(e.handler.recorder)
+	e.queue.pop()
+
+	// The config is requeued to wait for the cluster deleted instead of
+	// blocking the worker.
+	_, err = e.handler.OnCCEConfigRemoved("", config)
+	assert.ErrorIs(err, generic.ErrSkip)
+	assert.Equal([]string{fakeStoreKey(testNamespace, "cce-test")}, e.queue.pop())
+	if config, err = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(config.Status.Deletion) {
+		assert.Equal(ccev1.DeletionStageDeletingCluster, config.Status.Deletion.Stage)
+		assert.False(config.Status.Deletion.StartTime.IsZero())
+	}
+	startTime := config.Status.Deletion.StartTime
+	c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionDeleting)
+	if assert.NotNil(c) {
+		assert.Equal(reasonDeletingCluster, c.Reason)
+	}
+
+	// The deletion resumes from the recorded stage.
+	config, err = e.remove(t, config)
+	assert.Nil(err)
+	assert.Equal(ccev1.DeletionStageDeletingVPC, config.Status.Deletion.Stage)
+	assert.Equal(startTime, config.Status.Deletion.StartTime)
+	for kind, count := range e.server.Resources() {
+		assert.Zerof(count, "%s resources were not deleted", kind)
+	}
+	started := 0
+	for _, event := range recordedEvents(e.handler.recorder) {
+		if strings.Contains(event, "start deleting") {
+			started++
+		}
+	}
+	assert.Equal(1, started)
+}
+
+func Test_CCEClusterConfig_Addons(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	_, err := e.configs.Create(newTestConfig("cce-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err = e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	recordedEvents(e.handler.recorder)
+
+	// reconcile updates the spec and calls OnCCEConfigChanged.
+	reconcile := func(update func(spec *ccev1.CCEClusterConfigSpec)) *ccev1.CCEClusterConfig {
+		t.Helper()
+		config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		if update != nil {
+			update(&config.Spec)
+			if config, err = e.configs.Update(config); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err = e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatal(err)
+		}
+		config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		return config
+	}
+
+	// Install the addon.
+	config = reconcile(func(spec *ccev1.CCEClusterConfigSpec) {
+		spec.Addons = []ccev1.CCEAddon{{
+			Name:    "metrics-server",
+			Version: "1.3.2",
+			Values:  &runtime.RawExtension{Raw: []byte(`{"basic":{"swr_addr":"swr.io"},"custom":{"replicas":1}}`)},
+		}}
+	})
+	assert.Equal(1, e.server.Resources()[fake.KindAddon])
+	assert.True(meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionAddonsSynced))
+	if assert.Len(config.Status.Addons, 1) {
+		assert.Equal("metrics-server", config.Status.Addons[0].Name)
+		assert.NotEmpty(config.Status.Addons[0].ID)
+	}
+	events := recordedEvents(e.handler.recorder)
+	if assert.Len(events, 1) {
+		assert.Contains(events[0], "Normal Creating request to install addon [metrics-server]")
+	}
+	config = reconcile(nil)
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionAddonsSynced))
+	assert.Equal([]ccev1.CCEAddonStatus{{
+		Name:    "metrics-server",
+		ID:      config.Status.Addons[0].ID,
+		Version: "1.3.2",
+		Status:  "running",
+		Created: true,
+	}}, config.Status.Addons)
+
+	// Upgrade and reconfigure the addon, the values not in spec are kept.
+	config = reconcile(func(spec *ccev1.CCEClusterConfigSpec) {
+		spec.Addons[0].Version = "1.3.6"
+		spec.Addons[0].Values = &runtime.RawExtension{Raw: []byte(`{"custom":{"replicas":2}}`)}
+	})
+	if assert.Len(config.Status.Addons, 1) {
+		assert.Equal("version [1.3.2] -> [1.3.6]; values [custom.replicas]", config.Status.Addons[0].Drift)
+	}
+	events = recordedEvents(e.handler.recorder)
+	if assert.Len(events, 1) {
+		assert.Contains(events[0], "Normal Updating request to update addon [metrics-server]")
+	}
+	config = reconcile(nil)
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionAddonsSynced))
+	if assert.Len(config.Status.Addons, 1) {
+		assert.Equal("1.3.6", config.Status.Addons[0].Version)
+		assert.Empty(config.Status.Addons[0].Drift)
+	}
+	instances, err := e.handler.listAddonInstances(config)
+	if assert.Nil(err) && assert.Len(instances, 1) {
+		values, _ := json.Marshal(instances[0].Spec.Values)
+		assert.JSONEq(`{"basic":{"swr_addr":"swr.io"},"custom":{"replicas":2}}`, string(values))
+	}
+
+	// Uninstall the addon removed from spec.
+	config = reconcile(func(spec *ccev1.CCEClusterConfigSpec) {
+		spec.Addons = nil
+	})
+	assert.Len(config.Status.Addons, 1)
+	events = recordedEvents(e.handler.recorder)
+	if assert.Len(events, 1) {
+		assert.Contains(events[0], "Normal Deleting request to uninstall addon [metrics-server]")
+	}
+	config = reconcile(nil)
+	assert.Empty(config.Status.Addons)
+	assert.Zero(e.server.Resources()[fake.KindAddon])
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionAddonsSynced))
+
+	// The addon installed out of the operator is adopted and kept after
+	// removed from spec.
+	driver, _ := e.handler.drivers.get(&config.Spec)
+	npd := &ccev1.CCEAddon{Name: "npd", Version: "1.18.10"}
+	res, err := cce.CreateAddonInstance(driver.CCE, config.Spec.ClusterID, npd)
+	if !assert.Nil(err) {
+		return
+	}
+	config = reconcile(func(spec *ccev1.CCEClusterConfigSpec) {
+		spec.Addons = []ccev1.CCEAddon{*npd}
+	})
+	if assert.Len(config.Status.Addons, 1) {
+		assert.False(config.Status.Addons[0].Created)
+	}
+	config = reconcile(func(spec *ccev1.CCEClusterConfigSpec) {
+		spec.Addons = nil
+	})
+	assert.Empty(config.Status.Addons)
+	assert.Equal(1, e.server.Resources()[fake.KindAddon])
+	_, err = cce.DeleteAddonInstance(driver.CCE, config.Spec.ClusterID, utils.Value(res.Metadata.Uid))
+	assert.Nil(err)
+	_, err = e.handler.listAddonInstances(config)
+	assert.Nil(err)
+	assert.Zero(e.server.Resources()[fake.KindAddon])
+
+	// The autoscaler addon is installed when the node pool autoscaling is enabled.
+	config = reconcile(func(spec *ccev1.CCEClusterConfigSpec) {
+		spec.NodePools[0].Autoscaling.Enable = true
+		spec.ClusterAutoscaler.Expander = "least-waste"
+	})
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = reconcile(nil)
+	assert.Equal(1, e.server.Resources()[fake.KindAddon])
+	if assert.Len(config.Status.Addons, 1) {
+		assert.Equal(autoscalerAddonName, config.Status.Addons[0].Name)
+	}
+	config = reconcile(nil)
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionClusterAutoscalerReady))
+	instances, err = e.handler.listAddonInstances(config)
+	if assert.Nil(err) && assert.Len(instances, 1) {
+		values, _ := json.Marshal(instances[0].Spec.Values)
+		assert.JSONEq(`{"custom":{"cluster_id":"`+config.Spec.ClusterID+
+			`","scaleDownEnabled":true,"expander":"least-waste"}}`, string(values))
+	}
+
+	// The condition is removed and the autoscaler installed by the operator
+	// is uninstalled when the autoscaling is disabled.
+	config = reconcile(func(spec *ccev1.CCEClusterConfigSpec) {
+		spec.NodePools[0].Autoscaling.Enable = false
+	})
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = reconcile(nil)
+	assert.Nil(meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionClusterAutoscalerReady))
+	assert.Empty(config.Status.Addons)
+	assert.Zero(e.server.Resources()[fake.KindAddon])
+
+	// The autoscaler installed before the autoscaling is enabled is adopted
+	// and kept after the autoscaling is disabled.
+	res, err = cce.CreateAddonInstance(driver.CCE, config.Spec.ClusterID,
+		&ccev1.CCEAddon{Name: autoscalerAddonName, Version: "1.25.21"})
+	if !assert.Nil(err) {
+		return
+	}
+	config = reconcile(func(spec *ccev1.CCEClusterConfigSpec) {
+		spec.NodePools[0].Autoscaling.Enable = true
+	})
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = reconcile(nil)
+	if assert.Len(config.Status.Addons, 1) {
+		assert.Equal(utils.Value(res.Metadata.Uid), config.Status.Addons[0].ID)
+		assert.False(config.Status.Addons[0].Created)
+	}
+	recordedEvents(e.handler.recorder)
+	config = reconcile(func(spec *ccev1.CCEClusterConfigSpec) {
+		spec.NodePools[0].Autoscaling.Enable = false
+	})
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = reconcile(nil)
+	assert.Empty(config.Status.Addons)
+	assert.Empty(recordedEvents(e.handler.recorder))
+	assert.Equal(1, e.server.Resources()[fake.KindAddon])
+}
+
+func Test_CCEClusterConfig_NodeTemplate(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	c := newTestConfig("cce-test")
+	template := &c.Spec.NodePools[0].NodeTemplate
+	template.Taints = &[]ccev1.CCENodeTaint{{Key: "dedicated", Value: "gpu", Effect: "NoSchedule"}}
+	template.K8sTags = &map[string]string{"workload": "gpu"}
+	template.UserTags = &map[string]string{"team": "infra"}
+	template.PreInstall = "#!/bin/bash\necho pre"
+	template.PostInstall = "#!/bin/bash\necho post"
+	template.MaxPods = 64
+	if _, err := e.configs.Create(c); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+
+	// upstreamNodeTemplate reads the node template back from the upstream.
+	upstreamNodeTemplate := func() ccev1.CCENodeTemplate {
+		t.Helper()
+		driver, _ := e.handler.drivers.get(&config.Spec)
+		res, err := cce.ListNodePools(driver.CCE, config.Spec.ClusterID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodePools, err := BuildUpstreamNodePoolConfigs(res)
+		if err != nil || len(nodePools) != 1 {
+			t.Fatalf("unexpected upstream nodePools %v: %v", nodePools, err)
+		}
+		return nodePools[0].NodeTemplate
+	}
+	upstream := upstreamNodeTemplate()
+	assert.Equal(template.Taints, upstream.Taints)
+	assert.Equal(template.K8sTags, upstream.K8sTags)
+	assert.Equal(template.UserTags, upstream.UserTags)
+	assert.Equal(template.PreInstall, upstream.PreInstall)
+	assert.Equal(template.PostInstall, upstream.PostInstall)
+	assert.Equal(int32(64), upstream.MaxPods)
+
+	// update applies the node template to the spec and reconciles.
+	update := func(apply func(template *ccev1.CCENodeTemplate)) {
+		t.Helper()
+		config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		apply(&config.Spec.NodePools[0].NodeTemplate)
+		if config, err := e.configs.Update(config); err != nil {
+			t.Fatal(err)
+		} else if _, err = e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The taints, labels and user tags are updated in place.
+	update(func(template *ccev1.CCENodeTemplate) {
+		template.Taints = &[]ccev1.CCENodeTaint{}
+		template.K8sTags = &map[string]string{"workload": "cpu"}
+		template.UserTags = &map[string]string{"team": "infra", "env": "prod"}
+	})
+	upstream = upstreamNodeTemplate()
+	assert.Nil(upstream.Taints)
+	assert.Equal(&map[string]string{"workload": "cpu"}, upstream.K8sTags)
+	assert.Equal(&map[string]string{"team": "infra", "env": "prod"}, upstream.UserTags)
+	assert.Equal(template.PreInstall, upstream.PreInstall)
+
+	// The taints, labels and user tags not set in spec are not managed, the
+	// upstream ones are not cleared.
+	update(func(template *ccev1.CCENodeTemplate) {
+		template.Taints = &[]ccev1.CCENodeTaint{{Key: "dedicated", Value: "gpu", Effect: "NoSchedule"}}
+	})
+	update(func(template *ccev1.CCENodeTemplate) {
+		template.Taints = nil
+		template.K8sTags = nil
+		template.UserTags = nil
+	})
+	upstream = upstreamNodeTemplate()
+	assert.Equal(&[]ccev1.CCENodeTaint{{Key: "dedicated", Value: "gpu", Effect: "NoSchedule"}}, upstream.Taints)
+	assert.Equal(&map[string]string{"workload": "cpu"}, upstream.K8sTags)
+	assert.Equal(&map[string]string{"team": "infra", "env": "prod"}, upstream.UserTags)
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	assert.False(nodeTemplateUpdated(&config.Spec.NodePools[0].NodeTemplate, &upstream))
+}
+
+func Test_CCEClusterConfig_NodePoolReplacement(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	if _, err := e.configs.Create(newTestConfig("cce-test")); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	oldID := config.Spec.NodePools[0].ID
+	driver, _ := e.handler.drivers.get(&config.Spec)
+
+	// Register the CCE nodes and the pods running on them to the cluster.
+	nodes, err := cce.ListNodes(driver.CCE, config.Spec.ClusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := k8sfake.NewSimpleClientset()
+	var oldNodes []string
+	for _, n := range *nodes.Items {
+		name := utils.Value(n.Status.PrivateIP)
+		oldNodes = append(oldNodes, name)
+		objects := []runtime.Object{
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx-" + name, Namespace: "default"},
+				Spec:       corev1.PodSpec{NodeName: name},
+			},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "node-exporter-" + name,
+					Namespace:       "default",
+					OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "node-exporter"}},
+				},
+				Spec: corev1.PodSpec{NodeName: name},
+			},
+		}
+		for _, o := range objects {
+			if err := client.Tracker().Add(o); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var evicted []string
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		evicted = append(evicted, eviction.Name)
+		return true, nil, client.Tracker().Delete(
+			corev1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace, eviction.Name)
+	})
+	e.handler.apply(withClusterClient(func(*ccev1.CCEClusterConfig) (kubernetes.Interface, error) {
+		return client, nil
+	}))
+
+	// reconcileUntil calls OnCCEConfigChanged until the replacement reaches the phase.
+	reconcileUntil := func(phase string) *ccev1.CCENodePoolReplacement {
+		t.Helper()
+		for i := 0; i < 20; i++ {
+			config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+			if len(config.Status.NodePoolReplacements) == 1 &&
+				config.Status.NodePoolReplacements[0].Phase == phase {
+				return &config.Status.NodePoolReplacements[0]
+			}
+			if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Fatalf("nodePool replacement does not reach phase %q", phase)
+		return nil
+	}
+
+	// Changing the flavor creates the successor node pool.
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	config.Spec.NodePools[0].NodeTemplate.Flavor = "c7.xlarge.2"
+	if _, err = e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	r := reconcileUntil(ccev1.NodePoolReplacementCreating)
+	assert.Equal("nodepool-1", r.Name)
+	assert.Equal(oldID, r.OldID)
+	assert.Equal("immutable fields changed: flavor", r.Reason)
+	assert.Equal(2, e.server.Resources()[fake.KindNodePool])
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	assert.Equal(cceConfigUpdatingPhase, config.Status.Phase)
+	assert.True(meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionNodePoolsSynced))
+
+	// The old nodes are cordoned and drained, the DaemonSet pods are kept.
+	r = reconcileUntil(ccev1.NodePoolReplacementDeleting)
+	newID := r.NewID
+	assert.ElementsMatch([]string{"nginx-" + oldNodes[0], "nginx-" + oldNodes[1]}, evicted)
+	for _, name := range oldNodes {
+		node, err := client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+		if assert.Nil(err) {
+			assert.True(node.Spec.Unschedulable)
+		}
+	}
+	pods, _ := client.CoreV1().Pods("default").List(context.TODO(), metav1.ListOptions{})
+	assert.Len(pods.Items, 2)
+
+	// The old node pool is deleted and the successor replaces it in spec.
+	reconcileUntil(ccev1.NodePoolReplacementCompleted)
+	assert.Equal(1, e.server.Resources()[fake.KindNodePool])
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	assert.Equal(map[string]string{"nodepool-1": newID}, config.Spec.CreatedNodePoolIDs)
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	assert.Equal(newID, config.Spec.NodePools[0].ID)
+	assert.Empty(config.Status.NodePoolReplacements)
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNodePoolsSynced))
+
+	res, err := cce.ListNodePools(driver.CCE, config.Spec.ClusterID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodePools, _ := BuildUpstreamNodePoolConfigs(res)
+	if assert.Len(nodePools, 1) {
+		assert.Equal("nodepool-1", nodePools[0].Name)
+		assert.Equal("c7.xlarge.2", nodePools[0].NodeTemplate.Flavor)
+	}
+	events := recordedEvents(e.handler.recorder)
+	assert.Contains(strings.Join(events, "\n"), "Normal Replaced nodePool [nodepool-1] ID ["+oldID+"] is replaced")
+}
+
+func Test_CCEClusterConfig_NodePoolReplacementProtected(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	if _, err := e.configs.Create(newTestConfig("cce-test")); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	oldID := config.Spec.NodePools[0].ID
+	e.handler.apply(withClusterClient(func(*ccev1.CCEClusterConfig) (kubernetes.Interface, error) {
+		return k8sfake.NewSimpleClientset(), nil
+	}))
+	recordedEvents(e.handler.recorder)
+	sync := func() *ccev1.CCEClusterConfig {
+		t.Helper()
+		config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatal(err)
+		}
+		config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		return config
+	}
+
+	// The protected node pool is not replaced.
+	config.Spec.NodePoolDeletionProtection.ProtectedNodePools = []string{"nodepool-1"}
+	config.Spec.NodePools[0].NodeTemplate.Flavor = "c7.xlarge.2"
+	if _, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	sync()
+	config = sync()
+	assert.Equal(cceConfigActivePhase, config.Status.Phase)
+	if assert.Len(config.Status.NodePoolReplacements, 1) {
+		r := config.Status.NodePoolReplacements[0]
+		assert.Equal(ccev1.NodePoolReplacementBlocked, r.Phase)
+		assert.Equal(oldID, r.OldID)
+		assert.Equal("immutable fields changed: flavor", r.Reason)
+		assert.Equal("replacement blocked, nodePool [nodepool-1] ID ["+oldID+"] is protected", r.Message)
+	}
+	assert.Equal(1, e.server.Resources()[fake.KindNodePool])
+	assert.Equal([]string{
+		"Warning DeletionBlocked nodePool [nodepool-1] ID [" + oldID +
+			"] is not replaced, replacement blocked, nodePool [nodepool-1] ID [" + oldID + "] is protected",
+	}, recordedEvents(e.handler.recorder))
+
+	// The replacement starts after the protection is removed, and waits
+	// before deleting the drained node pool if protected again.
+	config.Spec.NodePoolDeletionProtection.ProtectedNodePools = nil
+	if _, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	config = sync()
+	if assert.Len(config.Status.NodePoolReplacements, 1) {
+		assert.Equal(ccev1.NodePoolReplacementCreating, config.Status.NodePoolReplacements[0].Phase)
+	}
+	config.Spec.NodePoolDeletionProtection.Enabled = true
+	if _, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		config = sync()
+	}
+	if assert.Len(config.Status.NodePoolReplacements, 1) {
+		r := config.Status.NodePoolReplacements[0]
+		assert.Equal(ccev1.NodePoolReplacementDraining, r.Phase)
+		assert.Contains(r.Message, "is protected by the cluster deletion protection")
+	}
+	assert.Equal(2, e.server.Resources()[fake.KindNodePool])
+}
+
+func Test_CCEClusterConfig_NodePoolReplacementAutoscaling(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	config := newTestConfig("cce-test")
+	config.Spec.NodePools[0].Autoscaling = ccev1.CCENodePoolNodeAutoscaling{
+		Enable:       true,
+		MinNodeCount: 1,
+		MaxNodeCount: 3,
+	}
+	if _, err := e.configs.Create(config); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config = e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	driver, _ := e.handler.drivers.get(&config.Spec)
+	e.handler.apply(withClusterClient(func(*ccev1.CCEClusterConfig) (kubernetes.Interface, error) {
+		return k8sfake.NewSimpleClientset(), nil
+	}))
+	sync := func() *ccev1.CCEClusterConfig {
+		t.Helper()
+		config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatal(err)
+		}
+		config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		return config
+	}
+
+	config.Spec.NodePools[0].NodeTemplate.Flavor = "c7.xlarge.2"
+	if _, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	config = sync()
+	if !assert.Len(config.Status.NodePoolReplacements, 1) {
+		return
+	}
+	newID := config.Status.NodePoolReplacements[0].NewID
+	assert.Equal(ccev1.NodePoolReplacementCreating, config.Status.NodePoolReplacements[0].Phase)
+
+	// Only the min node count of the autoscaling successor is running.
+	var successor []string
+	for i := 0; i < 5; i++ {
+		if _, err := cce.ListNodePools(driver.CCE, config.Spec.ClusterID, false); err != nil {
+			t.Fatal(err)
+		}
+		nodes, err := cce.ListNodes(driver.CCE, config.Spec.ClusterID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		successor = nil
+		for _, n := range *nodes.Items {
+			if n.Metadata.Annotations[cce.NodePoolIDAnnotationKey] == newID {
+				successor = append(successor, utils.Value(n.Metadata.Uid))
+			}
+		}
+	}
+	if !assert.Len(successor, 2) {
+		return
+	}
+	e.server.SetNodePhase(successor[0], cce_model.GetNodeStatusPhaseEnum().INSTALLING)
+	config = sync()
+	if assert.Len(config.Status.NodePoolReplacements, 1) {
+		assert.Equal(ccev1.NodePoolReplacementDraining, config.Status.NodePoolReplacements[0].Phase)
+	}
+}
+
+func Test_CCEClusterConfig_NodePoolReplacementRecorded(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	if _, err := e.configs.Create(newTestConfig("cce-test")); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+
+	// The successor node pool is recorded although the status update after
+	// creating it fails, and is not created again.
+	e.handler.cceCC = &failingConfigClient{
+		CCEClusterConfigClient: e.configs,
+		fail: func(config *ccev1.CCEClusterConfig) bool {
+			return config.Status.Phase == cceConfigUpdatingPhase
+		},
+	}
+	config.Spec.NodePools[0].NodeTemplate.Flavor = "c7.xlarge.2"
+	if config, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	} else if _, err = e.handler.OnCCEConfigChanged("", config); err == nil {
+		t.Fatal("expect the status update to fail")
+	}
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	if !assert.Len(config.Status.NodePoolReplacements, 1) {
+		return
+	}
+	r := config.Status.NodePoolReplacements[0]
+	assert.Equal(ccev1.NodePoolReplacementCreating, r.Phase)
+	assert.NotEmpty(r.NewID)
+	assert.Equal(2, e.server.Resources()[fake.KindNodePool])
+
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	assert.Equal(cceConfigUpdatingPhase, config.Status.Phase)
+	assert.Equal(2, e.server.Resources()[fake.KindNodePool])
+	if assert.Len(config.Status.NodePoolReplacements, 1) {
+		assert.Equal(r.NewID, config.Status.NodePoolReplacements[0].NewID)
+	}
+}
+
+func Test_CCEClusterConfig_NodeOperations(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	if _, err := e.configs.Create(newTestConfig("cce-test")); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	driver, _ := e.handler.drivers.get(&config.Spec)
+
+	// Register the CCE nodes and the pods running on them to the cluster.
+	nodes, err := cce.ListNodes(driver.CCE, config.Spec.ClusterID)
+	if err != nil || len(*nodes.Items) != 2 {
+		t.Fatalf("unexpected nodes: %v", err)
+	}
+	client := k8sfake.NewSimpleClientset()
+	for _, n := range *nodes.Items {
+		name := utils.Value(n.Status.PrivateIP)
+		objects := []runtime.Object{
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx-" + name, Namespace: "default"},
+				Spec:       corev1.PodSpec{NodeName: name},
+			},
+		}
+		for _, o := range objects {
+			if err := client.Tracker().Add(o); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// The evictions are rejected by the PodDisruptionBudget until unblocked.
+	blocked := true
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		if blocked {
+			return true, nil, fmt.Errorf("cannot evict pod as it would violate the pod's disruption budget")
+		}
+		return true, nil, client.Tracker().Delete(
+			corev1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace, eviction.Name)
+	})
+	e.handler.apply(withClusterClient(func(*ccev1.CCEClusterConfig) (kubernetes.Interface, error) {
+		return client, nil
+	}))
+
+	// reconcileUntil calls OnCCEConfigChanged until the operation of the node
+	// reaches the phase.
+	reconcileUntil := func(node, phase string) *ccev1.CCENodeOperationStatus {
+		t.Helper()
+		for i := 0; i < 20; i++ {
+			config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+			for _, op := range config.Status.NodeOperations {
+				if op.Node == node && op.Phase == phase {
+					return &op
+				}
+			}
+			if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Fatalf("operation of node [%s] does not reach phase %q", node, phase)
+		return nil
+	}
+	setOperations := func(operations ...ccev1.CCENodeOperation) {
+		t.Helper()
+		config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		config.Spec.NodeOperations = operations
+		if _, err := e.configs.Update(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reboot the first node after the pods are evicted.
+	first, second := (*nodes.Items)[0], (*nodes.Items)[1]
+	rebootNode := utils.Value(first.Status.PrivateIP)
+	setOperations(ccev1.CCENodeOperation{Node: rebootNode, Action: ccev1.NodeActionReboot})
+	op := reconcileUntil(rebootNode, ccev1.NodeOperationDraining)
+	assert.Equal(utils.Value(first.Metadata.Uid), op.NodeID)
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	assert.Contains(config.Status.NodeOperations[0].Message, "disruption budget")
+	assert.Equal(cceConfigUpdatingPhase, config.Status.Phase)
+	assert.True(meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionNodePoolsSynced))
+	assert.Empty(e.server.Reboots())
+	node, _ := client.CoreV1().Nodes().Get(context.TODO(), rebootNode, metav1.GetOptions{})
+	assert.True(node.Spec.Unschedulable)
+
+	blocked = false
+	reconcileUntil(rebootNode, ccev1.NodeOperationSucceeded)
+	assert.Equal([]string{utils.Value(first.Status.ServerId)}, e.server.Reboots())
+	node, _ = client.CoreV1().Nodes().Get(context.TODO(), rebootNode, metav1.GetOptions{})
+	assert.False(node.Spec.Unschedulable)
+	assert.Equal(2, e.server.Resources()[fake.KindNode])
+
+	// Replace the second node, the node pool creates a new node.
+	replaceNode := utils.Value(second.Metadata.Uid)
+	setOperations(
+		ccev1.CCENodeOperation{Node: rebootNode, Action: ccev1.NodeActionReboot},
+		ccev1.CCENodeOperation{Node: replaceNode, Action: ccev1.NodeActionReplace},
+		ccev1.CCENodeOperation{Node: "10.0.0.1", Action: ccev1.NodeActionDelete},
+	)
+	reconcileUntil(replaceNode, ccev1.NodeOperationRecreating)
+	op = reconcileUntil(replaceNode, ccev1.NodeOperationSucceeded)
+	assert.Contains(op.Message, "is replaced")
+	nodes, _ = cce.ListNodes(driver.CCE, config.Spec.ClusterID)
+	if assert.Len(*nodes.Items, 2) {
+		for _, n := range *nodes.Items {
+			assert.NotEqual(replaceNode, utils.Value(n.Metadata.Uid))
+		}
+	}
+	// The reboot is not executed again and the unknown node fails.
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	assert.Len(e.server.Reboots(), 1)
+	if assert.Len(config.Status.NodeOperations, 3) {
+		assert.Equal(ccev1.NodeOperationFailed, config.Status.NodeOperations[2].Phase)
+		assert.Equal("node [10.0.0.1] not found", config.Status.NodeOperations[2].Message)
+	}
+	pods, _ := client.CoreV1().Pods("default").List(context.TODO(), metav1.ListOptions{})
+	assert.Empty(pods.Items)
+
+	// Deleting the first node decreases the node count of the node pool, the
+	// node pool is not scaled up to the node count in spec again.
+	deleteNode := utils.Value(first.Metadata.Uid)
+	setOperations(
+		ccev1.CCENodeOperation{Node: rebootNode, Action: ccev1.NodeActionReboot},
+		ccev1.CCENodeOperation{Node: replaceNode, Action: ccev1.NodeActionReplace},
+		ccev1.CCENodeOperation{Node: "10.0.0.1", Action: ccev1.NodeActionDelete},
+		ccev1.CCENodeOperation{Node: deleteNode, Action: ccev1.NodeActionDelete},
+	)
+	op = reconcileUntil(deleteNode, ccev1.NodeOperationSucceeded)
+	assert.Contains(op.Message, "is deleted")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	for i := 0; i < 3; i++ {
+		if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatal(err)
+		}
+		config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	}
+	assert.Equal(int32(1), config.Spec.NodePools[0].InitialNodeCount)
+	res, err := cce.ShowNodePool(driver.CCE, config.Spec.ClusterID, config.Spec.NodePools[0].ID)
+	if assert.Nil(err) {
+		assert.Equal(int32(1), utils.Value(res.Spec.InitialNodeCount))
+	}
+	nodes, _ = cce.ListNodes(driver.CCE, config.Spec.ClusterID)
+	if assert.Len(*nodes.Items, 1) {
+		assert.NotEqual(deleteNode, utils.Value((*nodes.Items)[0].Metadata.Uid))
+	}
+
+	// The status is removed with the operations in spec.
+	setOperations()
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	assert.Empty(config.Status.NodeOperations)
+
+	events := strings.Join(recordedEvents(e.handler.recorder), "\n")
+	assert.Contains(events, "Normal Rebooting request to reboot node ["+rebootNode+"]")
+	assert.Contains(events, "Normal Succeeded node operation Replace ["+replaceNode+"] succeeded")
+	assert.Contains(events, "Warning Failed node operation Delete [10.0.0.1] failed")
+}
+
+func Test_CCEClusterConfig_NodeHealth(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	c := newTestConfig("cce-test")
+	c.Spec.NodePools[0].HealthPolicy = ccev1.CCENodeHealthPolicy{
+		Enabled:                true,
+		MaxUnhealthyPercentage: 50,
+	}
+	if _, err := e.configs.Create(c); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNodesHealthy))
+	driver, _ := e.handler.drivers.get(&config.Spec)
+
+	// Register the CCE nodes and the pods running on them to the cluster.
+	nodes, err := cce.ListNodes(driver.CCE, config.Spec.ClusterID)
+	if err != nil || len(*nodes.Items) != 2 {
+		t.Fatalf("unexpected nodes: %v", err)
+	}
+	client := k8sfake.NewSimpleClientset()
+	setReady := func(name string, status corev1.ConditionStatus) {
+		t.Helper()
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+			},
+		}
+		if _, err := client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+			_, err = client.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{})
+		} else {
+			_, err = client.CoreV1().Nodes().UpdateStatus(context.TODO(), node, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := (*nodes.Items)[0], (*nodes.Items)[1]
+	for _, n := range *nodes.Items {
+		name := utils.Value(n.Status.PrivateIP)
+		setReady(name, corev1.ConditionTrue)
+		if err := client.Tracker().Add(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "nginx-" + name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: name},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		return true, nil, client.Tracker().Delete(
+			corev1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace, eviction.Name)
+	})
+	e.handler.apply(withClusterClient(func(*ccev1.CCEClusterConfig) (kubernetes.Interface, error) {
+		return client, nil
+	}))
+	reconcileOnce := func() *ccev1.CCEClusterConfig {
+		t.Helper()
+		config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatal(err)
+		}
+		config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		return config
+	}
+	// expire makes the unhealthy nodes exceed the unhealthy timeout.
+	expire := func() {
+		t.Helper()
+		config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		for i := range config.Status.UnhealthyNodes {
+			config.Status.UnhealthyNodes[i].Since = metav1.NewTime(time.Now().Add(-11 * time.Minute))
+		}
+		if _, err := e.configs.UpdateStatus(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The unhealthy nodes are not remediated before the timeout.
+	e.server.SetNodePhase(utils.Value(first.Metadata.Uid), cce_model.GetNodeStatusPhaseEnum().ABNORMAL)
+	setReady(utils.Value(second.Status.PrivateIP), corev1.ConditionFalse)
+	config = reconcileOnce()
+	reasons := map[string]string{}
+	for _, u := range config.Status.UnhealthyNodes {
+		reasons[u.NodeID] = u.Reason
+	}
+	assert.Equal(map[string]string{
+		utils.Value(first.Metadata.Uid):  "CCE node phase is Abnormal",
+		utils.Value(second.Metadata.Uid): "Kubernetes node Ready condition is False",
+	}, reasons)
+	assert.Empty(config.Status.NodeRemediations)
+	assert.True(meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionNodesHealthy))
+
+	// The remediation is paused if more than 50% nodes are unhealthy.
+	expire()
+	config = reconcileOnce()
+	assert.Empty(config.Status.NodeRemediations)
+	condition := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionNodesHealthy)
+	if assert.NotNil(condition) {
+		assert.Equal(reasonRemediationPaused, condition.Reason)
+		assert.Contains(condition.Message, "2/2 nodes of nodePool [nodepool-1] are unhealthy")
+	}
+
+	// The unhealthy node is drained and deleted, then recreated by the node pool.
+	setReady(utils.Value(second.Status.PrivateIP), corev1.ConditionTrue)
+	config = reconcileOnce()
+	if assert.Len(config.Status.NodeRemediations, 1) {
+		r := config.Status.NodeRemediations[0]
+		assert.Equal(utils.Value(first.Metadata.Uid), r.NodeID)
+		assert.Equal("CCE node phase is Abnormal", r.Reason)
+		assert.Equal(ccev1.NodeRemediationDraining, r.Phase)
+	}
+	pods, _ := client.CoreV1().Pods("default").List(context.TODO(), metav1.ListOptions{})
+	if assert.Len(pods.Items, 1) {
+		assert.Equal(utils.Value(second.Status.PrivateIP), pods.Items[0].Spec.NodeName)
+	}
+	config = reconcileOnce()
+	assert.Equal(ccev1.NodeRemediationDeleting, config.Status.NodeRemediations[0].Phase)
+	for i := 0; i < 20; i++ {
+		config = reconcileOnce()
+		if config.Status.NodeRemediations[0].Phase == ccev1.NodeRemediationSucceeded &&
+			config.Status.Phase == cceConfigActivePhase && e.server.Resources()[fake.KindNode] == 2 {
+			break
+		}
+	}
+	assert.Equal(ccev1.NodeRemediationSucceeded, config.Status.NodeRemediations[0].Phase)
+	assert.Equal(2, e.server.Resources()[fake.KindNode])
+	config = reconcileOnce()
+	assert.Empty(config.Status.UnhealthyNodes)
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNodesHealthy))
+
+	events := strings.Join(recordedEvents(e.handler.recorder), "\n")
+	assert.Contains(events, "Warning Unhealthy remediation is paused")
+	assert.Contains(events, "Warning Unhealthy start remediating node ["+utils.Value(first.Metadata.Name)+"]")
+	assert.Contains(events, "Normal Remediated node ["+utils.Value(first.Metadata.Name)+"]")
+}
+
+func Test_CCEClusterConfig_Upgrade(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+	defer func(interval time.Duration) { upgradePreCheckRetryInterval = interval }(upgradePreCheckRetryInterval)
+
+	if _, err := e.configs.Create(newTestConfig("cce-test")); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	recordedEvents(e.handler.recorder)
+
+	reconcileOnce := func() *ccev1.CCEClusterConfig {
+		t.Helper()
+		config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatal(err)
+		}
+		config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		return config
+	}
+
+	// The upgrade is blocked if the pre-upgrade check failed.
+	e.server.SetPreCheckFailures(cce_model.PreCheckItemStatus{
+		Name:    utils.Pointer("NodeCheck"),
+		Level:   utils.Pointer("Fatal"),
+		Phase:   utils.Pointer(cce.PreCheckPhaseFailed),
+		Message: utils.Pointer("node is not ready"),
+	})
+	config = config.DeepCopy()
+	config.Spec.Version = "v1.27"
+	config.Spec.UpgradeStrategy.BatchSize = 10
+	if _, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	config = reconcileOnce()
+	assert.Equal(cceConfigUpdatingPhase, config.Status.Phase)
+	if assert.NotNil(config.Status.UpgradePreCheck) {
+		assert.Equal("v1.27", config.Status.UpgradePreCheck.TargetVersion)
+	}
+	config = reconcileOnce()
+	assert.Equal(cceConfigActivePhase, config.Status.Phase)
+	assert.Empty(config.Status.UpgradeClusterTaskID)
+	c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionUpgradeInProgress)
+	if assert.NotNil(c) {
+		assert.Equal(reasonPreCheckFailed, c.Reason)
+		assert.Contains(c.Message, "NodeCheck (Fatal): node is not ready")
+	}
+	cluster, _ := e.server.Cluster(config.Spec.ClusterID)
+	assert.Equal("v1.25", utils.Value(cluster.Spec.Version))
+
+	// The check is started again after the failed items are fixed.
+	e.server.SetPreCheckFailures()
+	upgradePreCheckRetryInterval = 0
+	for i := 0; i < 20; i++ {
+		config = reconcileOnce()
+		cluster, _ = e.server.Cluster(config.Spec.ClusterID)
+		if utils.Value(cluster.Spec.Version) == "v1.27" && config.Status.UpgradeClusterTaskID == "" &&
+			config.Status.Phase == cceConfigActivePhase {
+			break
+		}
+	}
+	assert.Equal("v1.27", utils.Value(cluster.Spec.Version))
+	assert.Empty(config.Status.UpgradeClusterTaskID)
+	assert.Nil(config.Status.UpgradePreCheck)
+	assert.True(meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionUpgradeInProgress))
+
+	events := strings.Join(recordedEvents(e.handler.recorder), "\n")
+	assert.Contains(events, `Warning Failed pre-upgrade check of cluster [cce-test] to "v1.27" failed`)
+	assert.Contains(events, `Normal Upgrading start upgrade cluster [cce-test] to "v1.27"`)
+}
+
+func Test_CCEClusterConfig_MultiVersionUpgrade(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+	e.server.SetUpgradePaths(map[string][]string{
+		"v1.25": {"v1.27"},
+		"v1.27": {"v1.28"},
+		"v1.28": {"v1.29"},
+	})
+
+	if _, err := e.configs.Create(newTestConfig("cce-test")); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+	recordedEvents(e.handler.recorder)
+
+	reconcileOnce := func() *ccev1.CCEClusterConfig {
+		t.Helper()
+		config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatal(err)
+		}
+		config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		return config
+	}
+
+	config = config.DeepCopy()
+	config.Spec.Version = "v1.29"
+	config.Spec.UpgradeStrategy.SkipPreCheck = true
+	if _, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	config = reconcileOnce()
+	if assert.NotNil(config.Status.Upgrade) {
+		assert.Equal("v1.29", config.Status.Upgrade.TargetVersion)
+		assert.Equal("v1.27", config.Status.Upgrade.CurrentHop)
+		assert.Equal([]string{"v1.28", "v1.29"}, config.Status.Upgrade.RemainingHops)
+		assert.Len(config.Status.Upgrade.TaskIDs, 1)
+	}
+
+	// The cluster is upgraded one hop per upgrade task.
+	var taskIDs []string
+	cluster, _ := e.server.Cluster(config.Spec.ClusterID)
+	for i := 0; i < 30; i++ {
+		config = reconcileOnce()
+		if config.Status.Upgrade != nil {
+			taskIDs = config.Status.Upgrade.TaskIDs
+		}
+		cluster, _ = e.server.Cluster(config.Spec.ClusterID)
+		if utils.Value(cluster.Spec.Version) == "v1.29" && config.Status.UpgradeClusterTaskID == "" &&
+			config.Status.Phase == cceConfigActivePhase {
+			break
+		}
+	}
+	assert.Equal("v1.29", utils.Value(cluster.Spec.Version))
+	assert.Len(taskIDs, 3)
+	assert.Nil(config.Status.Upgrade)
+	assert.True(meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionUpgradeInProgress))
+
+	events := strings.Join(recordedEvents(e.handler.recorder), "\n")
+	for _, v := range []string{"v1.27", "v1.28", "v1.29"} {
+		assert.Contains(events, `Normal Upgrading start upgrade cluster [cce-test] to "`+v+`"`)
+	}
+}
+
+func Test_CCEClusterConfig_MaintenanceWindow(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	if _, err := e.configs.Create(newTestConfig("cce-test")); err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	config := e.reconcile(t, "cce-test", cceConfigUpdatingPhase)
+	if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+		t.Fatal(err)
+	}
+	e.syncCreatedNodePoolIDs(t, "cce-test")
+	config = e.reconcile(t, "cce-test", cceConfigActivePhase)
+
+	reconcileOnce := func() *ccev1.CCEClusterConfig {
+		t.Helper()
+		config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		if _, err := e.handler.OnCCEConfigChanged("", config); err != nil {
+			t.Fatal(err)
+		}
+		config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+		return config
+	}
+
+	// The upgrade is deferred while the description is updated.
+	start := time.Now().UTC().Add(2 * time.Hour)
+	config = config.DeepCopy()
+	config.Spec.MaintenanceWindows = []ccev1.CCEMaintenanceWindow{{
+		StartTime: start.Format("15:04"),
+		EndTime:   start.Add(time.Hour).Format("15:04"),
+	}}
+	config.Spec.Version = "v1.27"
+	config.Spec.UpgradeStrategy.SkipPreCheck = true
+	config.Spec.Description = "deferred upgrade"
+	if _, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	config = reconcileOnce()
+	assert.Equal(cceConfigActivePhase, config.Status.Phase)
+	if assert.NotNil(config.Status.Maintenance) && assert.Len(config.Status.Maintenance.PendingOperations, 1) {
+		assert.Equal(planUpgradeCluster, config.Status.Maintenance.PendingOperations[0].Operation)
+		assert.False(config.Status.Maintenance.InWindow)
+	}
+	cluster, _ := e.server.Cluster(config.Spec.ClusterID)
+	assert.Equal("v1.25", utils.Value(cluster.Spec.Version))
+	assert.Equal("deferred upgrade", utils.Value(cluster.Spec.Description))
+
+	// The upgrade starts when the maintenance window opens.
+	config = config.DeepCopy()
+	config.Spec.MaintenanceWindows[0].StartTime = time.Now().UTC().Add(-time.Hour).Format("15:04")
+	if _, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		config = reconcileOnce()
+		cluster, _ = e.server.Cluster(config.Spec.ClusterID)
+		if utils.Value(cluster.Spec.Version) == "v1.27" && config.Status.UpgradeClusterTaskID == "" &&
+			config.Status.Phase == cceConfigActivePhase {
+			break
+		}
+	}
+	assert.Equal("v1.27", utils.Value(cluster.Spec.Version))
+	if assert.NotNil(config.Status.Maintenance) {
+		assert.True(config.Status.Maintenance.InWindow)
+		assert.Empty(config.Status.Maintenance.PendingOperations)
+	}
+	assert.True(meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionOperationsDeferred))
+
+	// The status is removed with the maintenance windows.
+	config = config.DeepCopy()
+	config.Spec.MaintenanceWindows = nil
+	if _, err := e.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+	config = reconcileOnce()
+	assert.Nil(config.Status.Maintenance)
+	assert.Nil(meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionOperationsDeferred))
+}
+
+func Test_CCEClusterConfig_DuplicatedName(t *testing.T) {
+	e := newTestEnv(t)
+
+	_, err := e.configs.Create(newTestConfig("cce-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+
+	c := newTestConfig("cce-test-dup")
+	c.Spec.Name = "cce-test"
+	if c, err = e.configs.Create(c); err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.handler.OnCCEConfigChanged("", c)
+	assert.ErrorContains(t, err, "exists with the same name")
+	assert.Equal(t, 1, e.server.Resources()[fake.KindCluster])
+}
+
+// newMockConfig returns the config of a created cluster managed by the mock
+// driver, the node pools of the config are created in upstream.
+func newMockConfig(phase string) *ccev1.CCEClusterConfig {
+	config := newTestConfig("cce-test")
+	config.Spec.ClusterID = "mock-cluster-id"
+	config.Spec.HostNetwork = ccev1.CCEHostNetwork{
+		VpcID:         "mock-vpc-id",
+		SubnetID:      "mock-subnet-id",
+		SecurityGroup: "mock-security-group-id",
+	}
+	config.Spec.NodePools[0].ID = "mock-nodepool-1-id"
+	config.Status.Phase = phase
+	config.Status.AvailableZone = "cn-north-4a"
+	config.Status.Endpoints = []ccev1.CCEClusterEndpoints{
+		{
+			Url:  "https://192.168.0.10:5443",
+			Type: "Internal",
+		},
+	}
+	return config
+}
+
+func Test_Handler_checkAndUpdate(t *testing.T) {
+	running := &cce_model.ShowUpgradeClusterTaskResponse{
+		Spec:   &cce_model.UpgradeTaskSpec{},
+		Status: &cce_model.UpgradeTaskStatus{Phase: utils.Pointer("Running")},
+	}
+	success := &cce_model.ShowUpgradeClusterTaskResponse{
+		Spec:   &cce_model.UpgradeTaskSpec{},
+		Status: &cce_model.UpgradeTaskStatus{Phase: utils.Pointer("Success")},
+	}
+
+	tests := []struct {
+		name string
+		// update modifies the config before reconcile.
+		update func(config *ccev1.CCEClusterConfig)
+		// setup modifies the mock cluster API.
+		setup    func(config *ccev1.CCEClusterConfig, m *mockClusterAPI)
+		calls    []string
+		phase    string
+		enqueued bool
+		wantErr  string
+		check    func(t *testing.T, config *ccev1.CCEClusterConfig)
+	}{
+		{
+			name:  "cluster in sync",
+			calls: []string{"ShowCluster mock-cluster-id", "ListNodePools mock-cluster-id", "ListNodes mock-cluster-id", "UpdateCluster mock-cluster-id", "UpdateNodePool mock-nodepool-1-id"},
+			phase: cceConfigActivePhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionClusterProvisioned))
+				assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNodePoolsSynced))
+				assert.Equal(t, config.Generation, config.Status.ObservedGeneration)
+			},
+		},
+		{
+			name: "cluster is upgrading",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Status.Phase = cceConfigActivePhase
+			},
+			setup: func(_ *ccev1.CCEClusterConfig, m *mockClusterAPI) {
+				m.cluster.Status.Phase = utils.Pointer(cce.ClusterStatusUpgrading)
+			},
+			calls:    []string{"ShowCluster mock-cluster-id"},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionUpgradeInProgress))
+			},
+		},
+		{
+			name: "upgrade task running",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Version = "v1.27"
+				config.Status.UpgradeClusterTaskID = "mock-upgrade-task-id"
+			},
+			setup: func(_ *ccev1.CCEClusterConfig, m *mockClusterAPI) {
+				m.cluster.Spec.Version = utils.Pointer("v1.25")
+				m.upgradeTask = running
+			},
+			calls:    []string{"ShowCluster mock-cluster-id", "ShowUpgradeClusterTask mock-upgrade-task-id"},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Equal(t, "mock-upgrade-task-id", config.Status.UpgradeClusterTaskID)
+				assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionUpgradeInProgress))
+			},
+		},
+		{
+			name: "upgrade task succeeded",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Version = "v1.27"
+				config.Status.UpgradeClusterTaskID = "mock-upgrade-task-id"
+			},
+			setup: func(_ *ccev1.CCEClusterConfig, m *mockClusterAPI) {
+				m.cluster.Spec.Version = utils.Pointer("v1.25")
+				m.upgradeTask = success
+			},
+			calls: []string{"ShowCluster mock-cluster-id", "ShowUpgradeClusterTask mock-upgrade-task-id"},
+			phase: cceConfigUpdatingPhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Empty(t, config.Status.UpgradeClusterTaskID)
+				c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionUpgradeInProgress)
+				if assert.NotNil(t, c) {
+					assert.Equal(t, metav1.ConditionFalse, c.Status)
+					assert.Equal(t, reasonUpgradeSucceeded, c.Reason)
+				}
+			},
+		},
+		{
+			name: "upgrade task not found",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Version = "v1.27"
+				config.Status.UpgradeClusterTaskID = "mock-upgrade-task-id"
+			},
+			setup: func(_ *ccev1.CCEClusterConfig, m *mockClusterAPI) {
+				m.cluster.Spec.Version = utils.Pointer("v1.25")
+			},
+			calls: []string{"ShowCluster mock-cluster-id", "ShowUpgradeClusterTask mock-upgrade-task-id"},
+			phase: cceConfigUpdatingPhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Empty(t, config.Status.UpgradeClusterTaskID)
+			},
+		},
+		{
+			name: "node pool is synchronizing",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Status.Phase = cceConfigActivePhase
+			},
+			setup: func(config *ccev1.CCEClusterConfig, m *mockClusterAPI) {
+				phase := cce_model.GetNodePoolStatusPhaseEnum().SYNCHRONIZING
+				m.nodePools[0].Status.Phase = &phase
+			},
+			calls:    []string{"ShowCluster mock-cluster-id", "ListNodePools mock-cluster-id", "ListNodes mock-cluster-id"},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionNodePoolsSynced)
+				if assert.NotNil(t, c) {
+					assert.Equal(t, metav1.ConditionFalse, c.Status)
+					assert.Equal(t, reasonNodePoolNotReady, c.Reason)
+				}
+				if assert.Len(t, config.Status.NodePools, 1) {
+					assert.Equal(t, "Synchronizing", config.Status.NodePools[0].Phase)
+				}
+			},
+		},
+		{
+			name: "node pool is sold out",
+			setup: func(config *ccev1.CCEClusterConfig, m *mockClusterAPI) {
+				phase := cce_model.GetNodePoolStatusPhaseEnum().SOLD_OUT
+				m.nodePools[0].Status.Phase = &phase
+				m.nodePools[0].Status.CurrentNode = utils.Pointer(int32(1))
+				m.nodePools[0].Status.Conditions = &[]cce_model.NodePoolCondition{
+					{
+						Type:    utils.Pointer("Scalable"),
+						Status:  utils.Pointer("False"),
+						Reason:  utils.Pointer("ResourceInsufficient"),
+						Message: utils.Pointer("flavor sold out"),
+					},
+				}
+				m.nodes = []cce_model.Node{
+					newMockNode("node-1", "mock-nodepool-1-id", cce_model.GetNodeStatusPhaseEnum().ACTIVE),
+				}
+			},
+			calls:    []string{"ShowCluster mock-cluster-id", "ListNodePools mock-cluster-id", "ListNodes mock-cluster-id"},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Equal(t, []ccev1.CCENodePoolStatus{
+					{
+						Name:         "nodepool-1",
+						ID:           "mock-nodepool-1-id",
+						Phase:        "SoldOut",
+						CurrentNodes: 1,
+						DesiredNodes: 2,
+						LastError:    "Scalable: ResourceInsufficient flavor sold out",
+						Nodes:        []string{"node-1"},
+					},
+				}, config.Status.NodePools)
+			},
+		},
+		{
+			name: "waiting for created node pool IDs",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.CreatedNodePoolIDs = map[string]string{"nodepool-2": "mock-nodepool-2-id"}
+			},
+			calls:    []string{"ShowCluster mock-cluster-id"},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+		},
+		{
+			name: "update cluster status",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Status.AvailableZone = ""
+				config.Status.Endpoints = nil
+			},
+			calls: []string{"ShowCluster mock-cluster-id", "ListNodePools mock-cluster-id", "ListNodes mock-cluster-id", "UpdateCluster mock-cluster-id", "UpdateNodePool mock-nodepool-1-id"},
+			phase: cceConfigActivePhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Equal(t, "cn-north-4a", config.Status.AvailableZone)
+				assert.Len(t, config.Status.Endpoints, 1)
+			},
+		},
+		{
+			name: "invalid version",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Status.Phase = cceConfigActivePhase
+				config.Spec.Version = "invalid"
+			},
+			phase:   cceConfigUpdatingPhase,
+			wantErr: "improper version format",
+		},
+		{
+			name: "show cluster failed",
+			setup: func(_ *ccev1.CCEClusterConfig, m *mockClusterAPI) {
+				m.err = mockNotFoundError("CCE_CM.0003")
+			},
+			calls:   []string{"ShowCluster mock-cluster-id"},
+			phase:   cceConfigUpdatingPhase,
+			wantErr: "CCE_CM.0003",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			config := newMockConfig(cceConfigUpdatingPhase)
+			if tt.update != nil {
+				tt.update(config)
+			}
+			m := &mockClusterAPI{
+				cluster: newMockCluster(config, cce.ClusterStatusAvailable),
+				nodePools: []cce_model.NodePoolResp{
+					newMockNodePool(config.Spec.ClusterID, config.Spec.NodePools[0], nil),
+				},
+			}
+			if tt.setup != nil {
+				tt.setup(config, m)
+			}
+			configs := newFakeCCEClusterConfigStore()
+			config, err := configs.Create(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h, queue := newMockHandler(configs, m, nil)
+
+			_, err = h.checkAndUpdate(config)
+			if tt.wantErr != "" {
+				assert.ErrorContains(err, tt.wantErr)
+			} else {
+				assert.Nil(err)
+			}
+			assert.Equal(tt.calls, m.Calls())
+			assert.Equal(tt.enqueued, len(queue.pop()) > 0)
+			config, _ = configs.Get(config.Namespace, config.Name, metav1.GetOptions{})
+			assert.Equal(tt.phase, config.Status.Phase)
+			if tt.check != nil {
+				tt.check(t, config)
+			}
+		})
+	}
+}
+
+func Test_Handler_updateUpstreamClusterState(t *testing.T) {
+	// The maintenance window opens in two hours.
+	nextWindow := time.Now().UTC().Add(2 * time.Hour).Truncate(time.Minute)
+	closedWindow := ccev1.CCEMaintenanceWindow{
+		StartTime: nextWindow.Format("15:04"),
+		EndTime:   nextWindow.Add(time.Hour).Format("15:04"),
+	}
+	tests := []struct {
+		name string
+		// update modifies the config spec, the upstream spec is built
+		// from the config before the update.
+		update   func(config *ccev1.CCEClusterConfig)
+		setup    func(m *mockClusterAPI)
+		calls    []string
+		events   []string
+		phase    string
+		enqueued bool
+		wantErr  string
+		check    func(t *testing.T, config *ccev1.CCEClusterConfig)
+	}{
+		{
+			name:  "no change",
+			calls: []string{"UpdateCluster mock-cluster-id", "UpdateNodePool mock-nodepool-1-id"},
+			phase: cceConfigActivePhase,
+		},
+		{
+			name: "imported cluster",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Imported = true
+				config.Spec.Version = "v1.27"
+			},
+			phase: cceConfigActivePhase,
+		},
+		{
+			name: "start pre-upgrade check",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Version = "v1.27"
+			},
+			calls:    []string{"ListClusterUpgradePaths", "CreatePreCheck mock-cluster-id v1.27"},
+			events:   []string{`Normal Upgrading start pre-upgrade check of cluster [cce-test] to "v1.27", task ID [mock-precheck-task-id]`},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				if assert.NotNil(t, config.Status.UpgradePreCheck) {
+					assert.Equal(t, "mock-precheck-task-id", config.Status.UpgradePreCheck.TaskID)
+					assert.Equal(t, "v1.27", config.Status.UpgradePreCheck.TargetVersion)
+				}
+				c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionUpgradeInProgress)
+				if assert.NotNil(t, c) {
+					assert.Equal(t, reasonPreChecking, c.Reason)
+				}
+				assert.Empty(t, config.Status.UpgradeClusterTaskID)
+			},
+		},
+		{
+			name: "upgrade cluster",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Version = "v1.27"
+				config.Status.UpgradePreCheck = &ccev1.CCEUpgradePreCheck{
+					TaskID:        "mock-precheck-task-id",
+					TargetVersion: "v1.27",
+					Phase:         cce.PreCheckPhaseRunning,
+					StartTime:     metav1.Now(),
+				}
+			},
+			setup: func(m *mockClusterAPI) {
+				m.preCheck = &cce_model.ShowPreCheckResponse{
+					Status: &cce_model.PrecheckStatus{Phase: utils.Pointer(cce.PreCheckPhaseSuccess)},
+				}
+			},
+			calls:    []string{"ListClusterUpgradePaths", "ShowPreCheck mock-precheck-task-id", "UpgradeCluster mock-cluster-id v1.27"},
+			events:   []string{`Normal Upgrading start upgrade cluster [cce-test] to "v1.27", task id [mock-upgrade-task-id]`},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: false,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Equal(t, "mock-upgrade-task-id", config.Status.UpgradeClusterTaskID)
+				assert.Nil(t, config.Status.UpgradePreCheck)
+				assert.Equal(t, &ccev1.CCEClusterUpgradeStatus{
+					TargetVersion: "v1.27",
+					CurrentHop:    "v1.27",
+					TaskIDs:       []string{"mock-upgrade-task-id"},
+				}, config.Status.Upgrade)
+			},
+		},
+		{
+			name: "disruptive operations deferred outside maintenance window",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.MaintenanceWindows = []ccev1.CCEMaintenanceWindow{closedWindow}
+				config.Spec.Version = "v1.27"
+				config.Spec.Flavor = "cce.s2.small"
+				config.Spec.NodePools = nil
+			},
+			// The cluster info is updated outside the maintenance window.
+			calls: []string{"UpdateCluster mock-cluster-id", "ListNodes mock-cluster-id"},
+			events: []string{"Normal Deferred 3 disruptive operations of cluster [cce-test] are deferred " +
+				"until the maintenance window at " + nextWindow.Format(time.RFC3339)},
+			phase:    cceConfigActivePhase,
+			enqueued: true,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				if assert.NotNil(t, config.Status.Maintenance) {
+					assert.False(t, config.Status.Maintenance.InWindow)
+					assert.True(t, nextWindow.Equal(config.Status.Maintenance.NextWindow.Time))
+					var operations []string
+					for _, op := range config.Status.Maintenance.PendingOperations {
+						operations = append(operations, op.Operation)
+					}
+					assert.Equal(t, []string{planUpgradeCluster, planResizeCluster, planDeleteNodePool}, operations)
+				}
+				assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionOperationsDeferred))
+				c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionNodePoolsSynced)
+				if assert.NotNil(t, c) {
+					assert.Equal(t, reasonOutsideMaintenanceWindow, c.Reason)
+				}
+				assert.Empty(t, config.Status.UpgradeClusterTaskID)
+				assert.Empty(t, config.Status.ResizeClusterJobID)
+			},
+		},
+		{
+			name: "disruptive operations in maintenance window",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.MaintenanceWindows = []ccev1.CCEMaintenanceWindow{
+					closedWindow,
+					{StartTime: "00:00", EndTime: "23:59"},
+					{StartTime: "23:59", EndTime: "00:00"},
+				}
+				config.Spec.Version = "v1.27"
+				config.Spec.UpgradeStrategy.SkipPreCheck = true
+			},
+			calls:  []string{"ListClusterUpgradePaths", "UpgradeCluster mock-cluster-id v1.27"},
+			events: []string{`Normal Upgrading start upgrade cluster [cce-test] to "v1.27", task id [mock-upgrade-task-id]`},
+			phase:  cceConfigUpdatingPhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				if assert.NotNil(t, config.Status.Maintenance) {
+					assert.True(t, config.Status.Maintenance.InWindow)
+					assert.Empty(t, config.Status.Maintenance.PendingOperations)
+				}
+				assert.Equal(t, "mock-upgrade-task-id", config.Status.UpgradeClusterTaskID)
+			},
+		},
+		{
+			name: "no supported upgrade path",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Version = "v1.30"
+			},
+			calls:   []string{"ListClusterUpgradePaths"},
+			wantErr: `no supported upgrade path of cluster from "v1.25" to "v1.30"`,
+			phase:   cceConfigActivePhase,
+		},
+		{
+			name: "upgrade cluster without pre-upgrade check",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Version = "v1.27"
+				config.Spec.UpgradeStrategy.SkipPreCheck = true
+			},
+			calls:  []string{"ListClusterUpgradePaths", "UpgradeCluster mock-cluster-id v1.27"},
+			events: []string{`Normal Upgrading start upgrade cluster [cce-test] to "v1.27", task id [mock-upgrade-task-id]`},
+			phase:  cceConfigUpdatingPhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Equal(t, "mock-upgrade-task-id", config.Status.UpgradeClusterTaskID)
+			},
+		},
+		{
+			name: "pre-upgrade check failed",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Version = "v1.27"
+				config.Status.UpgradePreCheck = &ccev1.CCEUpgradePreCheck{
+					TaskID:        "mock-precheck-task-id",
+					TargetVersion: "v1.27",
+					Phase:         cce.PreCheckPhaseRunning,
+					StartTime:     metav1.Now(),
+				}
+			},
+			setup: func(m *mockClusterAPI) {
+				m.preCheck = &cce_model.ShowPreCheckResponse{
+					Status: &cce_model.PrecheckStatus{
+						Phase: utils.Pointer(cce.PreCheckPhaseFailed),
+						ClusterCheckStatus: &cce_model.ClusterCheckStatus{
+							ItemsStatus: &[]cce_model.PreCheckItemStatus{{
+								Name:    utils.Pointer("DeprecatedAPI"),
+								Level:   utils.Pointer("Fatal"),
+								Phase:   utils.Pointer(cce.PreCheckPhaseFailed),
+								Message: utils.Pointer("deprecated APIs are in use"),
+							}},
+						},
+					},
+				}
+			},
+			// The other settings are updated while the upgrade is blocked.
+			calls: []string{"ListClusterUpgradePaths", "ShowPreCheck mock-precheck-task-id",
+				"UpdateCluster mock-cluster-id", "UpdateNodePool mock-nodepool-1-id"},
+			events: []string{`Warning Failed pre-upgrade check of cluster [cce-test] to "v1.27" failed: ` +
+				`DeprecatedAPI (Fatal): deprecated APIs are in use`},
+			phase:    cceConfigActivePhase,
+			enqueued: true,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Empty(t, config.Status.UpgradeClusterTaskID)
+				if assert.NotNil(t, config.Status.UpgradePreCheck) {
+					assert.Equal(t, cce.PreCheckPhaseFailed, config.Status.UpgradePreCheck.Phase)
+				}
+				c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionUpgradeInProgress)
+				if assert.NotNil(t, c) {
+					assert.Equal(t, metav1.ConditionFalse, c.Status)
+					assert.Equal(t, reasonPreCheckFailed, c.Reason)
+					assert.Contains(t, c.Message, "DeprecatedAPI (Fatal): deprecated APIs are in use")
+				}
+			},
+		},
+		{
+			name: "downgrade cluster",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Version = "v1.23"
+			},
+			phase:   cceConfigActivePhase,
+			wantErr: "unsupported to downgrade",
+		},
+		{
+			name: "resize cluster",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.Flavor = "cce.s2.small"
+			},
+			calls:  []string{"ResizeCluster mock-cluster-id cce.s2.small"},
+			events: []string{`Normal Resizing start resize cluster [cce-test] job ID "mock-resize-job-id"`},
+			phase:  cceConfigUpdatingPhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Equal(t, "mock-resize-job-id", config.Status.ResizeClusterJobID)
+				assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionResizeInProgress))
+			},
+		},
+		{
+			name: "create node pool",
+			update: func(config *ccev1.CCEClusterConfig) {
+				np := config.Spec.NodePools[0]
+				np.ID = ""
+				np.Name = "nodepool-2"
+				config.Spec.NodePools = append(config.Spec.NodePools, np)
+			},
+			calls:    []string{"UpdateCluster mock-cluster-id", "UpdateNodePool mock-nodepool-1-id", "CreateNodePool nodepool-2"},
+			events:   []string{"Normal Creating request to create nodePool [nodepool-2] ID [mock-nodepool-2-id]"},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Equal(t, map[string]string{"nodepool-2": "mock-nodepool-2-id"}, config.Spec.CreatedNodePoolIDs)
+				assert.True(t, meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionNodePoolsSynced))
+			},
+		},
+		{
+			name: "skip creating node pool with existing name",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.NodePools[0].ID = ""
+			},
+			calls: []string{
+				"UpdateCluster mock-cluster-id", "ListNodes mock-cluster-id", "DeleteNodePool mock-nodepool-1-id",
+			},
+			events:   []string{"Normal Deleting request to delete nodePool [nodepool-1] ID [mock-nodepool-1-id]"},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+		},
+		{
+			name: "delete node pool",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.NodePools = nil
+			},
+			calls: []string{
+				"UpdateCluster mock-cluster-id", "ListNodes mock-cluster-id", "DeleteNodePool mock-nodepool-1-id",
+			},
+			events:   []string{"Normal Deleting request to delete nodePool [nodepool-1] ID [mock-nodepool-1-id]"},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+		},
+		{
+			name: "delete node pool with running nodes",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.NodePools = nil
+			},
+			setup: func(m *mockClusterAPI) {
+				m.nodes = []cce_model.Node{
+					newMockNode("node-1", "mock-nodepool-1-id", cce_model.GetNodeStatusPhaseEnum().ACTIVE),
+				}
+			},
+			calls: []string{"UpdateCluster mock-cluster-id", "ListNodes mock-cluster-id"},
+			events: []string{"Warning DeletionBlocked nodePool [nodepool-1] ID [mock-nodepool-1-id] has 1 running nodes, " +
+				"deletion requires annotation [cce.pandaria.io/confirm-nodepool-deletion]"},
+			phase: cceConfigActivePhase,
+		},
+		{
+			name: "delete confirmed node pool",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.NodePools = nil
+				config.Annotations = map[string]string{ConfirmNodePoolDeletionAnnotation: "nodepool-1, nodepool-2"}
+			},
+			setup: func(m *mockClusterAPI) {
+				m.nodes = []cce_model.Node{
+					newMockNode("node-1", "mock-nodepool-1-id", cce_model.GetNodeStatusPhaseEnum().ACTIVE),
+				}
+			},
+			calls:    []string{"UpdateCluster mock-cluster-id", "DeleteNodePool mock-nodepool-1-id"},
+			events:   []string{"Normal Deleting request to delete nodePool [nodepool-1] ID [mock-nodepool-1-id]"},
+			phase:    cceConfigUpdatingPhase,
+			enqueued: true,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				// The consumed confirmation is removed.
+				assert.Equal(t, "nodepool-2", config.Annotations[ConfirmNodePoolDeletionAnnotation])
+			},
+		},
+		{
+			name: "delete protected node pool",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Spec.NodePools = nil
+				config.Spec.NodePoolDeletionProtection.ProtectedNodePools = []string{"nodepool-1"}
+			},
+			calls:  []string{"UpdateCluster mock-cluster-id"},
+			events: []string{"Warning DeletionBlocked nodePool [nodepool-1] ID [mock-nodepool-1-id] is protected"},
+			phase:  cceConfigActivePhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionNodePoolDeletionBlocked)
+				if assert.NotNil(t, c) {
+					assert.Equal(t, metav1.ConditionTrue, c.Status)
+					assert.Equal(t, "nodePool [nodepool-1] ID [mock-nodepool-1-id] is protected", c.Message)
+				}
+				assert.True(t, meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionNodePoolsSynced))
+			},
+		},
+		{
+			name: "deletion blocked condition is cleared",
+			update: func(config *ccev1.CCEClusterConfig) {
+				setCondition(config, ccev1.ConditionNodePoolDeletionBlocked, metav1.ConditionTrue, reasonDeletionBlocked, "blocked")
+			},
+			calls: []string{"UpdateCluster mock-cluster-id", "UpdateNodePool mock-nodepool-1-id"},
+			phase: cceConfigActivePhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.True(t, meta.IsStatusConditionFalse(config.Status.Conditions, ccev1.ConditionNodePoolDeletionBlocked))
+				assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNodePoolsSynced))
+			},
+		},
+		{
+			name: "dry-run",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Annotations = map[string]string{DryRunAnnotation: "true"}
+				config.Spec.Version = "v1.27"
+				config.Spec.Flavor = "cce.s2.small"
+				config.Spec.NodePools[0].InitialNodeCount = 3
+				np := config.Spec.NodePools[0]
+				np.ID = ""
+				np.Name = "nodepool-2"
+				config.Spec.NodePools = append(config.Spec.NodePools, np)
+				config.Spec.NodePools[0].NodeTemplate.Flavor = "c7.xlarge.2"
+			},
+			phase: cceConfigActivePhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Equal(t, []ccev1.CCEClusterOperation{
+					{
+						Operation: "UpgradeCluster", Resource: "cluster", Name: "cce-test", ID: "mock-cluster-id",
+						Current: "v1.25", Desired: "v1.27",
+					},
+					{
+						Operation: "ResizeCluster", Resource: "cluster", Name: "cce-test", ID: "mock-cluster-id",
+						Current: "cce.s1.small", Desired: "cce.s2.small",
+					},
+					{
+						Operation: "ReplaceNodePool", Resource: "nodePool", Name: "nodepool-1", ID: "mock-nodepool-1-id",
+						Current: "flavor=c6.large.2", Desired: "flavor=c7.xlarge.2",
+					},
+					{
+						Operation: "UpdateNodePool", Resource: "nodePool", Name: "nodepool-1", ID: "mock-nodepool-1-id",
+						Current: "initialNodeCount=2 autoscaling=false[0-0]",
+						Desired: "initialNodeCount=3 autoscaling=false[0-0]",
+					},
+					{
+						Operation: "CreateNodePool", Resource: "nodePool", Name: "nodepool-2",
+						Desired: "initialNodeCount=3 autoscaling=false[0-0]",
+					},
+				}, config.Status.Plan)
+			},
+		},
+		{
+			name: "plan is cleared if dry-run disabled",
+			update: func(config *ccev1.CCEClusterConfig) {
+				config.Status.Plan = []ccev1.CCEClusterOperation{{Operation: "UpgradeCluster", Resource: "cluster"}}
+			},
+			calls: []string{"UpdateCluster mock-cluster-id", "UpdateNodePool mock-nodepool-1-id"},
+			phase: cceConfigActivePhase,
+			check: func(t *testing.T, config *ccev1.CCEClusterConfig) {
+				assert.Nil(t, config.Status.Plan)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			config := newMockConfig(cceConfigActivePhase)
+			upstreamSpec, err := BuildUpstreamClusterState(
+				newMockCluster(config, cce.ClusterStatusAvailable),
+				&cce_model.ListNodePoolsResponse{
+					Items: &[]cce_model.NodePoolResp{
+						newMockNodePool(config.Spec.ClusterID, config.Spec.NodePools[0], nil),
+					},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.update != nil {
+				tt.update(config)
+			}
+			m := &mockClusterAPI{}
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			configs := newFakeCCEClusterConfigStore()
+			if config, err = configs.Create(config); err != nil {
+				t.Fatal(err)
+			}
+			h, queue := newMockHandler(configs, m, nil)
+
+			_, err = h.updateUpstreamClusterState(upstreamSpec, config)
+			if tt.wantErr != "" {
+				assert.ErrorContains(err, tt.wantErr)
+			} else {
+				assert.Nil(err)
+			}
+			assert.Equal(tt.calls, m.Calls())
+			assert.Equal(tt.events, recordedEvents(h.recorder))
+			assert.Equal(tt.enqueued, len(queue.pop()) > 0)
+			config, _ = configs.Get(config.Namespace, config.Name, metav1.GetOptions{})
+			assert.Equal(tt.phase, config.Status.Phase)
+			if tt.check != nil {
+				tt.check(t, config)
+			}
+		})
+	}
+}
+
+func Test_Handler_recordError(t *testing.T) {
+	assert := assert.New(t)
+	configs := newFakeCCEClusterConfigStore()
+	config, err := configs.Create(newMockConfig(cceConfigActivePhase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, queue := newMockHandler(configs, &mockClusterAPI{}, nil)
+
+	// The config waiting for the resources is requeued without failure.
+	onChange := h.recordError(func(_ string, config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
+		return config, requeueAfter(time.Second, "waiting for subnet [%s] to be active", "subnet-id")
+	})
+	_, err = onChange("", config)
+	assert.Nil(err)
+	assert.Equal([]string{fakeStoreKey(config.Namespace, config.Name)}, queue.pop())
+	assert.Empty(recordedEvents(h.recorder))
+	config, _ = configs.Get(config.Namespace, config.Name, metav1.GetOptions{})
+	assert.Equal(cceConfigActivePhase, config.Status.Phase)
+
+	onChange = h.recordError(func(_ string, config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
+		return config, mockNotFoundError("CCE_CM.0003")
+	})
+	_, err = onChange("", config)
+	assert.ErrorContains(err, "CCE_CM.0003")
+	events := recordedEvents(h.recorder)
+	if assert.Len(events, 1) {
+		assert.Contains(events[0], "Warning Failed")
+		assert.Contains(events[0], "CCE_CM.0003")
+		assert.NotContains(events[0], "mock-request-id")
+	}
+	config, _ = configs.Get(config.Namespace, config.Name, metav1.GetOptions{})
+	assert.Equal(cceConfigUpdatingPhase, config.Status.Phase)
+	assert.Contains(config.Status.FailureMessage, "CCE_CM.0003")
+
+	onChange = h.recordError(func(_ string, config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
+		return config, nil
+	})
+	config, err = onChange("", config)
+	assert.Nil(err)
+	assert.Empty(recordedEvents(h.recorder))
+	assert.Empty(config.Status.FailureMessage)
+}
+
+func Test_countClusterPhases(t *testing.T) {
+	assert := assert.New(t)
+	configs := newFakeCCEClusterConfigStore()
+	for i, phase := range []string{
+		cceConfigNotCreatedPhase, cceConfigActivePhase, cceConfigActivePhase, cceConfigUpdatingPhase,
+	} {
+		config := newMockConfig(phase)
+		config.Name = fmt.Sprintf("cce-test-%d", i)
+		if _, err := configs.Create(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(map[string]int{
+		"pending":              1,
+		cceConfigActivePhase:   2,
+		cceConfigUpdatingPhase: 1,
+	}, countClusterPhases(configs.cache()))
+}
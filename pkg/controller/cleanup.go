@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,9 +12,21 @@ import (
 	"github.com/cnrancher/cce-operator/pkg/huawei/nat"
 	"github.com/cnrancher/cce-operator/pkg/huawei/vpc"
 	"github.com/cnrancher/cce-operator/pkg/huawei/vpcep"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
 	"github.com/cnrancher/cce-operator/pkg/utils"
 	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/rancher/wrangler/v2/pkg/generic"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Intervals to requeue the config to check the cluster resources are deleted.
+var (
+	removeNodeWaitInterval    = 10 * time.Second
+	removeClusterWaitInterval = 20 * time.Second
+	removeNetworkWaitInterval = 5 * time.Second
 )
 
 func (h *Handler) OnCCEConfigRemoved(_ string, config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
@@ -25,48 +38,81 @@ func (h *Handler) OnCCEConfigRemoved(_ string, config *ccev1.CCEClusterConfig) (
 		}).Infof("cluster [%s] is imported, will not delete CCE cluster", config.Name)
 		return config, nil
 	}
-
-	// Ensure the driver in h.drivers map exists.
-	if err := h.setupHuaweiDriver(&config.Spec); err != nil {
-		return config, err
-	}
-
-	logrus.WithFields(logrus.Fields{
-		"cluster": config.Name,
-		"phase":   "remove",
-	}).Infof("start deleting cluster [%s] resources", config.Name)
-
-	var refresh bool
-	for refresh = true; refresh; {
-		config, refresh, err = h.ensureCCEClusterDeletable(config)
-		if err != nil {
-			time.Sleep(5 * time.Second) // Avoid rate limit.
+	if config.Spec.DeletionProtection {
+		message := fmt.Sprintf("cluster [%s] is protected by deletionProtection, "+
+			"disable it to delete the cluster", config.Name)
+		if config, err = h.updateCondition(config, ccev1.ConditionDeleting, metav1.ConditionFalse,
+			reasonDeletionProtected, message); err != nil {
 			return config, err
 		}
-		if refresh {
-			time.Sleep(10 * time.Second)
-		}
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   "remove",
+		}).Warn(message)
+		h.recorder.Event(config, corev1.EventTypeWarning, eventReasonBlocked, message)
+		// Returns error to keep the finalizer until the protection is disabled.
+		return config, errors.New(message)
 	}
-
-	for refresh = true; refresh; {
-		config, refresh, err = h.deleteCCECluster(config)
-		if err != nil {
-			time.Sleep(5 * time.Second) // Avoid rate limit.
+	if config.Spec.DeletionPolicy == ccev1.DeletionPolicyRetain {
+		if err := h.retainResources(config); err != nil {
 			return config, err
 		}
-		if refresh {
-			time.Sleep(20 * time.Second)
-		}
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   "remove",
+		}).Infof("cluster [%s] deletion policy is %s, will not delete CCE cluster and network resources",
+			config.Name, config.Spec.DeletionPolicy)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+			"retain cluster [%s] ID [%s] and network resources", config.Spec.Name, config.Spec.ClusterID)
+		return config, nil
 	}
 
-	for refresh = true; refresh; {
-		config, refresh, err = h.deleteNetworkResources(config)
-		if err != nil {
-			time.Sleep(5 * time.Second) // Avoid rate limit.
+	// Ensure the driver of the credential and region is cached.
+	if _, err := h.setupHuaweiDriver(&config.Spec); err != nil {
+		return config, err
+	}
+
+	// Resume the deletion from the stage recorded in status, the config is
+	// requeued until the resources of the stage are deleted.
+	steps := deletionSteps
+	if config.Status.Deletion == nil {
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   "remove",
+		}).Infof("start deleting cluster [%s] resources", config.Name)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+			"start deleting cluster [%s] resources", config.Name)
+	} else {
+		for i, step := range deletionSteps {
+			if step.stage == config.Status.Deletion.Stage {
+				steps = deletionSteps[i:]
+				break
+			}
+		}
+	}
+	for _, step := range steps {
+		if step.network && config.Spec.DeletionPolicy == ccev1.DeletionPolicyRetainNetwork {
+			if err := h.retainResources(config); err != nil {
+				return config, err
+			}
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   "remove",
+			}).Infof("cluster [%s] deletion policy is %s, will not delete network resources",
+				config.Name, config.Spec.DeletionPolicy)
+			h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+				"retain network resources of cluster [%s]", config.Spec.Name)
+			return config, nil
+		}
+		if config, err = h.updateDeletionStage(config, step); err != nil {
 			return config, err
 		}
-		if refresh {
-			time.Sleep(5 * time.Second)
+		var wait bool
+		if config, wait, err = step.delete(h, config); err != nil {
+			return config, err
+		} else if wait {
+			h.cceEnqueueAfter(config.Namespace, config.Name, *step.interval)
+			return config, generic.ErrSkip
 		}
 	}
 
@@ -78,10 +124,113 @@ func (h *Handler) OnCCEConfigRemoved(_ string, config *ccev1.CCEClusterConfig) (
 	return config, nil
 }
 
+// deletionStep deletes the resources of a deletion stage, delete returns true
+// to requeue the config after interval to wait for the resources deleted.
+type deletionStep struct {
+	stage    string
+	reason   string
+	message  string
+	network  bool // network resources are retained by the RetainNetwork policy
+	interval *time.Duration
+	delete   func(h *Handler, config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, bool, error)
+}
+
+// deletionSteps are the steps to delete the cluster resources in order.
+var deletionSteps = []deletionStep{
+	{
+		stage:    ccev1.DeletionStageDrainingNodes,
+		reason:   reasonWaitingForNodes,
+		message:  "waiting for the nodes to be deletable",
+		interval: &removeNodeWaitInterval,
+		delete:   (*Handler).ensureCCEClusterDeletable,
+	},
+	{
+		stage:    ccev1.DeletionStageDeletingCluster,
+		reason:   reasonDeletingCluster,
+		message:  "deleting cluster",
+		interval: &removeClusterWaitInterval,
+		delete:   (*Handler).deleteCCECluster,
+	},
+	{
+		stage:    ccev1.DeletionStageDeletingNatGateway,
+		reason:   reasonDeletingNetwork,
+		message:  "deleting NAT Gateway",
+		network:  true,
+		interval: &removeNetworkWaitInterval,
+		delete:   (*Handler).deleteNatGateway,
+	},
+	{
+		stage:    ccev1.DeletionStageDeletingEIPs,
+		reason:   reasonDeletingNetwork,
+		message:  "deleting EIPs",
+		network:  true,
+		interval: &removeNetworkWaitInterval,
+		delete:   (*Handler).deletePublicIPs,
+	},
+	{
+		stage:    ccev1.DeletionStageDeletingSubnet,
+		reason:   reasonDeletingNetwork,
+		message:  "deleting subnet",
+		network:  true,
+		interval: &removeNetworkWaitInterval,
+		delete:   (*Handler).deleteSubnet,
+	},
+	{
+		stage:    ccev1.DeletionStageDeletingVpcep,
+		reason:   reasonDeletingNetwork,
+		message:  "deleting VpcEndpointServices",
+		network:  true,
+		interval: &removeNetworkWaitInterval,
+		delete:   (*Handler).deleteVpcEndpointServices,
+	},
+	{
+		stage:    ccev1.DeletionStageDeletingVPC,
+		reason:   reasonDeletingNetwork,
+		message:  "deleting VPC",
+		network:  true,
+		interval: &removeNetworkWaitInterval,
+		delete:   (*Handler).deleteVPC,
+	},
+}
+
+// updateDeletionStage records the stage of the step in status and the
+// Deleting condition.
+func (h *Handler) updateDeletionStage(
+	config *ccev1.CCEClusterConfig, step deletionStep,
+) (*ccev1.CCEClusterConfig, error) {
+	if config.Status.Deletion != nil && config.Status.Deletion.Stage == step.stage &&
+		!setCondition(config.DeepCopy(), ccev1.ConditionDeleting, metav1.ConditionTrue, step.reason, step.message) {
+		return config, nil
+	}
+	var err error
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		now := metav1.Now()
+		if configUpdate.Status.Deletion == nil {
+			configUpdate.Status.Deletion = &ccev1.CCEDeletionStatus{StartTime: now}
+		}
+		if configUpdate.Status.Deletion.Stage != step.stage {
+			configUpdate.Status.Deletion.Stage = step.stage
+			configUpdate.Status.Deletion.LastTransitionTime = now
+		}
+		setCondition(configUpdate, ccev1.ConditionDeleting, metav1.ConditionTrue, step.reason, step.message)
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	return config, err
+}
+
 func (h *Handler) ensureCCEClusterDeletable(
 	config *ccev1.CCEClusterConfig,
 ) (*ccev1.CCEClusterConfig, bool, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
 	// Cluster was already deleted.
 	if config.Spec.ClusterID == "" {
 		return config, false, nil
@@ -123,7 +272,10 @@ func (h *Handler) ensureCCEClusterDeletable(
 func (h *Handler) deleteCCECluster(
 	config *ccev1.CCEClusterConfig,
 ) (*ccev1.CCEClusterConfig, bool, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
 	if config.Spec.ClusterID == "" {
 		// Cluster was already deleted.
 		return config, false, nil
@@ -168,202 +320,258 @@ func (h *Handler) deleteCCECluster(
 		return config, true, nil
 	}
 
-	if _, err = cce.DeleteCluster(driver.CCE, config.Spec.ClusterID); err != nil {
+	_, err = cce.DeleteCluster(driver.CCE, config.Spec.ClusterID)
+	metrics.ObserveCleanup("cluster", err)
+	if err != nil {
 		return config, false, err
 	}
 	logrus.WithFields(logrus.Fields{
 		"cluster": config.Name,
 		"phase":   "remove",
 	}).Infof("request to delete cluster [%s]", config.Spec.Name)
+	h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+		"request to delete cluster [%s]", config.Spec.Name)
 
 	return config, true, nil
 }
 
-func (h *Handler) deleteNetworkResources(
+// deleteNatGateway deletes the SNAT rules and the NAT Gateway created for
+// the cluster.
+func (h *Handler) deleteNatGateway(
 	config *ccev1.CCEClusterConfig,
 ) (*ccev1.CCEClusterConfig, bool, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
-	var err error
-	if config.Status.CreatedNatGatewayID != "" {
-		natID := config.Status.CreatedNatGatewayID
-		// Delete SNAT Rules before delete NAT Gateway.
-		snatRulesRes, err := nat.ListNatGatewaySnatRules(driver.NAT, []string{natID})
-		if err != nil {
-			return config, false, err
-		}
-		if snatRulesRes == nil || snatRulesRes.SnatRules == nil {
-			return config, false, fmt.Errorf("ListNatGatewaySnatRules returns invalid data")
-		}
-		for _, sr := range *snatRulesRes.SnatRules {
-			if _, err = nat.DeleteNatGatewaySnatRule(driver.NAT, sr.Id, natID); err != nil {
-				return config, false, err
-			}
-			logrus.WithFields(logrus.Fields{
-				"cluster": config.Name,
-				"phase":   "remove",
-			}).Infof("request to delete SNAT Rule [%s] from NAT [%s]",
-				sr.Id, natID)
-		}
-		if len(*snatRulesRes.SnatRules) > 0 {
-			// Requeue to wait for SNAT Rules were deleted from NAT Gateway.
-			return config, true, nil
-		}
-
-		// Delete NatGateway.
-		_, err = nat.ShowNatGateway(driver.NAT, config.Status.CreatedNatGatewayID)
-		if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
-			logrus.WithFields(logrus.Fields{
-				"cluster": config.Name,
-				"phase":   "remove",
-			}).Infof("NAT Gateway [%s] deleted", natID)
-			config = config.DeepCopy()
-			config.Status.CreatedNatGatewayID = ""
-			config.Status.CreatedSNATRuleID = ""
-			config, err = h.cceCC.UpdateStatus(config)
-			return config, true, err
-		} else if err != nil {
-			return config, false, err
-		}
-		_, err = nat.DeleteNatGateway(driver.NAT, natID)
+	natID := config.Status.CreatedNatGatewayID
+	if natID == "" {
+		return config, false, nil
+	}
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
+	// Delete SNAT Rules before delete NAT Gateway.
+	snatRulesRes, err := nat.ListNatGatewaySnatRules(driver.NAT, []string{natID})
+	if err != nil {
+		return config, false, err
+	}
+	if snatRulesRes == nil || snatRulesRes.SnatRules == nil {
+		return config, false, fmt.Errorf("ListNatGatewaySnatRules returns invalid data")
+	}
+	for _, sr := range *snatRulesRes.SnatRules {
+		_, err = nat.DeleteNatGatewaySnatRule(driver.NAT, sr.Id, natID)
+		metrics.ObserveCleanup("snatRule", err)
 		if err != nil {
 			return config, false, err
 		}
 		logrus.WithFields(logrus.Fields{
 			"cluster": config.Name,
 			"phase":   "remove",
-		}).Infof("request to delete NAT Gateway [%v]", natID)
-		// Requeue to wait for NAT Gateway were deleted.
+		}).Infof("request to delete SNAT Rule [%s] from NAT [%s]",
+			sr.Id, natID)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+			"request to delete SNAT Rule [%s] from NAT [%s]", sr.Id, natID)
+	}
+	if len(*snatRulesRes.SnatRules) > 0 {
+		// Requeue to wait for SNAT Rules were deleted from NAT Gateway.
 		return config, true, nil
 	}
 
-	// Delete EIP.
-	if config.Status.CreatedClusterEIPID != "" {
-		eipID := config.Status.CreatedClusterEIPID
-		_, err = eip.ShowPublicip(driver.EIP, eipID)
-		if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
-			config = config.DeepCopy()
-			config.Status.CreatedClusterEIPID = ""
-			config, err = h.cceCC.UpdateStatus(config)
-			logrus.WithFields(logrus.Fields{
-				"cluster": config.Name,
-				"phase":   "remove",
-			}).Infof("cluster EIP [%s] deleted", eipID)
-			return config, true, err
-		} else if err != nil {
-			return config, false, err
-		}
-		if _, err = eip.DeletePublicIP(driver.EIP, eipID); err != nil {
-			return config, false, err
-		}
+	_, err = nat.ShowNatGateway(driver.NAT, natID)
+	if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
 		logrus.WithFields(logrus.Fields{
 			"cluster": config.Name,
 			"phase":   "remove",
-		}).Infof("request to delete EIP [%v]", eipID)
-		return config, true, nil
+		}).Infof("NAT Gateway [%s] deleted", natID)
+		config = config.DeepCopy()
+		config.Status.CreatedNatGatewayID = ""
+		config.Status.CreatedSNATRuleID = ""
+		config, err = h.cceCC.UpdateStatus(config)
+		return config, false, err
+	} else if err != nil {
+		return config, false, err
+	}
+	_, err = nat.DeleteNatGateway(driver.NAT, natID)
+	metrics.ObserveCleanup("natGateway", err)
+	if err != nil {
+		return config, false, err
+	}
+	logrus.WithFields(logrus.Fields{
+		"cluster": config.Name,
+		"phase":   "remove",
+	}).Infof("request to delete NAT Gateway [%v]", natID)
+	h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+		"request to delete NAT Gateway [%v]", natID)
+	// Requeue to wait for NAT Gateway were deleted.
+	return config, true, nil
+}
+
+// deletePublicIPs deletes the cluster EIP and the SNAT Rule EIP created for
+// the cluster.
+func (h *Handler) deletePublicIPs(
+	config *ccev1.CCEClusterConfig,
+) (*ccev1.CCEClusterConfig, bool, error) {
+	if config.Status.CreatedClusterEIPID == "" && config.Status.CreatedSNatRuleEIPID == "" {
+		return config, false, nil
 	}
-	if config.Status.CreatedSNatRuleEIPID != "" {
-		eipID := config.Status.CreatedSNatRuleEIPID
-		_, err = eip.ShowPublicip(driver.EIP, eipID)
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
+	var wait bool
+	for _, eipID := range []string{config.Status.CreatedClusterEIPID, config.Status.CreatedSNatRuleEIPID} {
+		if eipID == "" {
+			continue
+		}
+		_, err := eip.ShowPublicip(driver.EIP, eipID)
 		if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
-			config = config.DeepCopy()
-			config.Status.CreatedSNatRuleEIPID = ""
-			config, err = h.cceCC.UpdateStatus(config)
 			logrus.WithFields(logrus.Fields{
 				"cluster": config.Name,
 				"phase":   "remove",
-			}).Infof("SNAT Rule EIP [%s] deleted", eipID)
-			return config, true, err
+			}).Infof("EIP [%s] deleted", eipID)
+			continue
 		} else if err != nil {
 			return config, false, err
 		}
-		if _, err = eip.DeletePublicIP(driver.EIP, eipID); err != nil {
+		_, err = eip.DeletePublicIP(driver.EIP, eipID)
+		metrics.ObserveCleanup("eip", err)
+		if err != nil {
 			return config, false, err
 		}
 		logrus.WithFields(logrus.Fields{
 			"cluster": config.Name,
 			"phase":   "remove",
 		}).Infof("request to delete EIP [%v]", eipID)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+			"request to delete EIP [%v]", eipID)
+		wait = true
+	}
+	if wait {
+		// Requeue to wait for EIPs were deleted.
 		return config, true, nil
 	}
+	config = config.DeepCopy()
+	config.Status.CreatedClusterEIPID = ""
+	config.Status.CreatedSNatRuleEIPID = ""
+	config, err = h.cceCC.UpdateStatus(config)
+	return config, false, err
+}
 
-	var (
-		vpcID    = config.Status.CreatedVpcID
-		subnetID = config.Status.CreatedSubnetID
-	)
-	if subnetID != "" {
-		_, err = vpc.ShowSubnet(driver.VPC, subnetID)
-		if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
-			logrus.WithFields(logrus.Fields{
-				"cluster": config.Name,
-				"phase":   "remove",
-			}).Infof("subnet [%s] deleted", subnetID)
-			config = config.DeepCopy()
-			config.Status.CreatedSubnetID = ""
-			config, err = h.cceCC.UpdateStatus(config)
-			return config, true, err
-		} else if err != nil {
-			return config, false, err
-		}
-		_, err := vpc.DeleteSubnet(driver.VPC, vpcID, subnetID)
-		if err != nil {
-			return config, false, err
-		}
+// deleteSubnet deletes the subnet created for the cluster.
+func (h *Handler) deleteSubnet(
+	config *ccev1.CCEClusterConfig,
+) (*ccev1.CCEClusterConfig, bool, error) {
+	subnetID := config.Status.CreatedSubnetID
+	if subnetID == "" {
+		return config, false, nil
+	}
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
+	_, err = vpc.ShowSubnet(driver.VPC, subnetID)
+	if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
 		logrus.WithFields(logrus.Fields{
 			"cluster": config.Name,
 			"phase":   "remove",
-		}).Infof("request to delete subnet [%s]", subnetID)
-		return config, true, nil
+		}).Infof("subnet [%s] deleted", subnetID)
+		config = config.DeepCopy()
+		config.Status.CreatedSubnetID = ""
+		config, err = h.cceCC.UpdateStatus(config)
+		return config, false, err
+	} else if err != nil {
+		return config, false, err
 	}
-	if vpcID != "" {
-		vpceps, err := vpcep.ListEndpointService(driver.VPCEP, "")
-		if err != nil {
-			return config, false, err
-		}
-		// Ensure VPC does not have associated VpcEndpointService (vpcepsvc).
-		var vpcepsvcID string
-		if vpceps.EndpointServices != nil && len(*vpceps.EndpointServices) > 0 {
-			for _, v := range *vpceps.EndpointServices {
-				if utils.Value(v.VpcId) != vpcID {
-					continue
-				}
-				vpcepsvcID = utils.Value(v.Id)
-				break
-			}
-		}
-		// VPC has associated VpcEndpointService, delete vpcepsvc before delete VPC.
-		if vpcepsvcID != "" {
-			_, err = vpcep.DeleteVpcepService(driver.VPCEP, vpcepsvcID)
-			if err != nil {
-				return config, false, err
-			}
-			logrus.WithFields(logrus.Fields{
-				"cluster": config.Name,
-				"phase":   "remove",
-			}).Infof("request to delete VpcEndpointService [%s]", vpcepsvcID)
-			return config, true, nil
-		}
-		_, err = vpc.ShowVPC(driver.VPC, vpcID)
-		if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
-			logrus.WithFields(logrus.Fields{
-				"cluster": config.Name,
-				"phase":   "remove",
-			}).Infof("vpc [%s] deleted", vpcID)
-			config = config.DeepCopy()
-			config.Status.CreatedVpcID = ""
-			config, err = h.cceCC.UpdateStatus(config)
-			return config, true, err
-		} else if err != nil {
-			return config, false, err
+	_, err = vpc.DeleteSubnet(driver.VPC, config.Status.CreatedVpcID, subnetID)
+	metrics.ObserveCleanup("subnet", err)
+	if err != nil {
+		return config, false, err
+	}
+	logrus.WithFields(logrus.Fields{
+		"cluster": config.Name,
+		"phase":   "remove",
+	}).Infof("request to delete subnet [%s]", subnetID)
+	h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+		"request to delete subnet [%s]", subnetID)
+	return config, true, nil
+}
+
+// deleteVpcEndpointServices deletes the VpcEndpointServices (vpcepsvc) in the
+// VPC created for the cluster, the VPC can not be deleted until they are
+// deleted.
+func (h *Handler) deleteVpcEndpointServices(
+	config *ccev1.CCEClusterConfig,
+) (*ccev1.CCEClusterConfig, bool, error) {
+	vpcID := config.Status.CreatedVpcID
+	if vpcID == "" {
+		return config, false, nil
+	}
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
+	vpceps, err := vpcep.ListEndpointService(driver.VPCEP, "")
+	if err != nil {
+		return config, false, err
+	}
+	if vpceps == nil || vpceps.EndpointServices == nil {
+		return config, false, nil
+	}
+	var wait bool
+	for _, v := range *vpceps.EndpointServices {
+		if utils.Value(v.VpcId) != vpcID {
+			continue
 		}
-		_, err = vpc.DeleteVPC(driver.VPC, vpcID)
+		vpcepsvcID := utils.Value(v.Id)
+		_, err = vpcep.DeleteVpcepService(driver.VPCEP, vpcepsvcID)
+		metrics.ObserveCleanup("vpcepService", err)
 		if err != nil {
 			return config, false, err
 		}
 		logrus.WithFields(logrus.Fields{
 			"cluster": config.Name,
 			"phase":   "remove",
-		}).Infof("request to delete vpc [%s]", vpcID)
-		return config, true, nil
+		}).Infof("request to delete VpcEndpointService [%s]", vpcepsvcID)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+			"request to delete VpcEndpointService [%s]", vpcepsvcID)
+		wait = true
+	}
+	return config, wait, nil
+}
+
+// deleteVPC deletes the VPC created for the cluster.
+func (h *Handler) deleteVPC(
+	config *ccev1.CCEClusterConfig,
+) (*ccev1.CCEClusterConfig, bool, error) {
+	vpcID := config.Status.CreatedVpcID
+	if vpcID == "" {
+		return config, false, nil
 	}
-	return config, false, nil
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
+	_, err = vpc.ShowVPC(driver.VPC, vpcID)
+	if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   "remove",
+		}).Infof("vpc [%s] deleted", vpcID)
+		config = config.DeepCopy()
+		config.Status.CreatedVpcID = ""
+		config, err = h.cceCC.UpdateStatus(config)
+		return config, false, err
+	} else if err != nil {
+		return config, false, err
+	}
+	_, err = vpc.DeleteVPC(driver.VPC, vpcID)
+	metrics.ObserveCleanup("vpc", err)
+	if err != nil {
+		return config, false, err
+	}
+	logrus.WithFields(logrus.Fields{
+		"cluster": config.Name,
+		"phase":   "remove",
+	}).Infof("request to delete vpc [%s]", vpcID)
+	h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+		"request to delete vpc [%s]", vpcID)
+	return config, true, nil
 }
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_deletionSteps_Network(t *testing.T) {
+	tests := []struct {
+		name   string
+		status ccev1.CCEClusterConfigStatus
+		// setup creates the resources in the mock network API.
+		setup func(m *mockNetworkAPI)
+		// calls are the API calls of each step call requesting the API.
+		calls   [][]string
+		wantErr string
+		// remaining is the number of the mock resources not deleted.
+		remaining int
+	}{
+		{
+			name:  "no resource created",
+			calls: nil,
+		},
+		{
+			name: "delete all resources",
+			status: ccev1.CCEClusterConfigStatus{
+				CreatedClusterEIPID:  "eip-1",
+				CreatedVpcID:         "vpc-1",
+				CreatedSubnetID:      "subnet-1",
+				CreatedNatGatewayID:  "nat-1",
+				CreatedSNatRuleEIPID: "eip-2",
+				CreatedSNATRuleID:    "snat-1",
+			},
+			setup: func(m *mockNetworkAPI) {
+				m.eips["eip-1"] = true
+				m.eips["eip-2"] = true
+				m.vpcs["vpc-1"] = true
+				m.subnets["subnet-1"] = true
+				m.natGateways["nat-1"] = true
+				m.snatRules["snat-1"] = "nat-1"
+				m.vpcepServices["vpcep-1"] = "vpc-1"
+			},
+			calls: [][]string{
+				{"ListNatGatewaySnatRules [nat-1]", "DeleteNatGatewaySnatRule snat-1"},
+				{"ListNatGatewaySnatRules [nat-1]", "ShowNatGateway nat-1", "DeleteNatGateway nat-1"},
+				{"ListNatGatewaySnatRules [nat-1]", "ShowNatGateway nat-1"},
+				{"ShowPublicip eip-1", "DeletePublicip eip-1", "ShowPublicip eip-2", "DeletePublicip eip-2"},
+				{"ShowPublicip eip-1", "ShowPublicip eip-2"},
+				{"ShowSubnet subnet-1", "DeleteSubnet subnet-1"},
+				{"ShowSubnet subnet-1"},
+				{"ListEndpointService", "DeleteEndpointService vpcep-1"},
+				{"ListEndpointService"},
+				{"ShowVpc vpc-1", "DeleteVpc vpc-1"},
+				{"ShowVpc vpc-1"},
+			},
+		},
+		{
+			name: "resources already deleted",
+			status: ccev1.CCEClusterConfigStatus{
+				CreatedClusterEIPID: "eip-1",
+				CreatedVpcID:        "vpc-1",
+				CreatedSubnetID:     "subnet-1",
+				CreatedNatGatewayID: "nat-1",
+			},
+			calls: [][]string{
+				{"ListNatGatewaySnatRules [nat-1]", "ShowNatGateway nat-1"},
+				{"ShowPublicip eip-1"},
+				{"ShowSubnet subnet-1"},
+				{"ListEndpointService"},
+				{"ShowVpc vpc-1"},
+			},
+		},
+		{
+			name: "keep the VPC provided by user",
+			status: ccev1.CCEClusterConfigStatus{
+				CreatedSubnetID: "subnet-1",
+			},
+			setup: func(m *mockNetworkAPI) {
+				m.vpcs["vpc-1"] = true
+				m.subnets["subnet-1"] = true
+			},
+			calls: [][]string{
+				{"ShowSubnet subnet-1", "DeleteSubnet subnet-1"},
+				{"ShowSubnet subnet-1"},
+			},
+			remaining: 1,
+		},
+		{
+			name: "request failed",
+			status: ccev1.CCEClusterConfigStatus{
+				CreatedVpcID: "vpc-1",
+			},
+			setup: func(m *mockNetworkAPI) {
+				m.vpcs["vpc-1"] = true
+				m.err = fmt.Errorf("mock request failed")
+			},
+			calls:     [][]string{{"ListEndpointService"}},
+			wantErr:   "mock request failed",
+			remaining: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			config := newMockConfig(cceConfigActivePhase)
+			config.Status = tt.status
+			m := newMockNetworkAPI()
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			configs := newFakeCCEClusterConfigStore()
+			config, err := configs.Create(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h, _ := newMockHandler(configs, &mockClusterAPI{}, m)
+
+			var calls [][]string
+		steps:
+			for _, step := range deletionSteps {
+				if !step.network {
+					continue
+				}
+				for wait := true; wait && len(calls) < 20; {
+					config, wait, err = step.delete(h, config)
+					if c := m.Calls(); len(c) > 0 {
+						calls = append(calls, c)
+					}
+					if err != nil {
+						break steps
+					}
+				}
+			}
+			if tt.wantErr != "" {
+				assert.ErrorContains(err, tt.wantErr)
+			} else {
+				assert.Nil(err)
+				assert.Equal(ccev1.CCEClusterConfigStatus{}, config.Status)
+			}
+			assert.Equal(tt.calls, calls)
+			assert.Equal(tt.remaining,
+				len(m.vpcs)+len(m.subnets)+len(m.eips)+len(m.natGateways)+len(m.snatRules)+len(m.vpcepServices))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"fmt"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Reasons of the CCEClusterConfig status conditions.
+const (
+	reasonCreatingEIP          = "CreatingEIP"
+	reasonCreatingVPC          = "CreatingVPC"
+	reasonCreatingSubnet       = "CreatingSubnet"
+	reasonCheckingNetwork      = "CheckingNetwork"
+	reasonCreatingNatGateway   = "CreatingNatGateway"
+	reasonCreatingSNATRule     = "CreatingSNATRule"
+	reasonNetworkConfigured    = "Configured"
+	reasonClusterCreating      = "Creating"
+	reasonClusterImporting     = "Importing"
+	reasonClusterFailed        = "Failed"
+	reasonClusterAvailable     = "Available"
+	reasonWaitingNodePoolIDs   = "WaitingForNodePoolIDs"
+	reasonNodePoolNotReady     = "NodePoolNotReady"
+	reasonNodePoolsUpdating    = "Updating"
+	reasonNodePoolsSynced      = "Synced"
+	reasonUpgrading            = "Upgrading"
+	reasonUpgradeSucceeded     = "Succeeded"
+	reasonUpgradeFailed        = "Failed"
+	reasonUpgradeTaskNotFound  = "TaskNotFound"
+	reasonResizing             = "Resizing"
+	reasonResizeCompleted      = "Completed"
+	reasonWaitingForNodes      = "WaitingForNodes"
+	reasonDeletingCluster      = "DeletingCluster"
+	reasonDeletingNetwork      = "DeletingNetwork"
+	reasonDeletionProtected    = "DeletionProtected"
+	reasonClusterVersionSynced = "VersionSynced"
+	reasonDeletionBlocked      = "DeletionBlocked"
+	reasonDeletionNotBlocked   = "NotBlocked"
+	reasonAddonsUpdating       = "Updating"
+	reasonAddonsSynced         = "Synced"
+	reasonAddonFailed          = "AddonFailed"
+	reasonAutoscalerMissing    = "AddonMissing"
+	reasonAutoscalerUnhealthy  = "AddonUnhealthy"
+	reasonAutoscalerRunning    = "AddonRunning"
+	reasonNodePoolReplacing    = "Replacing"
+	reasonNodeOperating        = "NodeOperation"
+	reasonNodesHealthy         = "Healthy"
+	reasonNodesUnhealthy       = "Unhealthy"
+	reasonRemediationPaused    = "RemediationPaused"
+	reasonPreChecking          = "PreChecking"
+	reasonPreCheckFailed       = "PreCheckFailed"
+
+	reasonOutsideMaintenanceWindow = "OutsideMaintenanceWindow"
+	reasonNotDeferred              = "NotDeferred"
+)
+
+// setCondition sets the condition to the config status,
+// returns false if the condition is not changed.
+func setCondition(
+	config *ccev1.CCEClusterConfig, conditionType string, status metav1.ConditionStatus, reason, message string,
+) bool {
+	c := meta.FindStatusCondition(config.Status.Conditions, conditionType)
+	if c != nil && c.Status == status && c.Reason == reason &&
+		c.Message == message && c.ObservedGeneration == config.Generation {
+		return false
+	}
+	meta.SetStatusCondition(&config.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: config.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	return true
+}
+
+// setClusterProvisioned sets the ClusterProvisioned condition to true.
+func setClusterProvisioned(config *ccev1.CCEClusterConfig) bool {
+	return setCondition(config, ccev1.ConditionClusterProvisioned, metav1.ConditionTrue, reasonClusterAvailable,
+		fmt.Sprintf("cluster [%s] ID [%s] is available", config.Spec.Name, config.Spec.ClusterID))
+}
+
+// updateCondition sets the condition to the config status and updates the
+// status only if the condition was changed.
+func (h *Handler) updateCondition(
+	config *ccev1.CCEClusterConfig, conditionType string, status metav1.ConditionStatus, reason, message string,
+) (*ccev1.CCEClusterConfig, error) {
+	if !setCondition(config.DeepCopy(), conditionType, status, reason, message) {
+		return config, nil
+	}
+	var err error
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		if !setCondition(configUpdate, conditionType, status, reason, message) {
+			return nil
+		}
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	return config, err
+}
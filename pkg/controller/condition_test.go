@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_setCondition(t *testing.T) {
+	assert := assert.New(t)
+	config := newMockConfig(cceConfigActivePhase)
+	config.Generation = 1
+
+	assert.True(setCondition(config, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse, reasonNodePoolsUpdating, "updating"))
+	assert.False(setCondition(config, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse, reasonNodePoolsUpdating, "updating"))
+	c := meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionNodePoolsSynced)
+	if !assert.NotNil(c) {
+		return
+	}
+	transitionTime := c.LastTransitionTime
+	assert.Equal(int64(1), c.ObservedGeneration)
+
+	// The lastTransitionTime is not changed if only the message changed.
+	config.Generation = 2
+	assert.True(setCondition(config, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse, reasonNodePoolsUpdating, "still updating"))
+	c = meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionNodePoolsSynced)
+	assert.Equal(transitionTime, c.LastTransitionTime)
+	assert.Equal(int64(2), c.ObservedGeneration)
+	assert.Equal("still updating", c.Message)
+
+	assert.True(setCondition(config, ccev1.ConditionNodePoolsSynced, metav1.ConditionTrue, reasonNodePoolsSynced, "synced"))
+	assert.True(meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionNodePoolsSynced))
+	assert.Len(config.Status.Conditions, 1)
+}
+
+func Test_Handler_updateCondition(t *testing.T) {
+	assert := assert.New(t)
+	configs := newFakeCCEClusterConfigStore()
+	config, err := configs.Create(newMockConfig(cceConfigActivePhase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := newMockHandler(configs, &mockClusterAPI{}, nil)
+
+	config, err = h.updateCondition(config, ccev1.ConditionDeleting, metav1.ConditionTrue, reasonDeletingNetwork, "deleting")
+	assert.Nil(err)
+	version := config.ResourceVersion
+	stored, _ := configs.Get(config.Namespace, config.Name, metav1.GetOptions{})
+	assert.True(meta.IsStatusConditionTrue(stored.Status.Conditions, ccev1.ConditionDeleting))
+
+	// Status is not updated if the condition is unchanged.
+	config, err = h.updateCondition(config, ccev1.ConditionDeleting, metav1.ConditionTrue, reasonDeletingNetwork, "deleting")
+	assert.Nil(err)
+	assert.Equal(version, config.ResourceVersion)
+}
@@ -0,0 +1,307 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/common"
+	"github.com/cnrancher/cce-operator/pkg/huawei/iam"
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	wranglerv1 "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Keys of the Huawei Cloud credential in the secret.
+const (
+	credentialAccessKey      = "huaweicredentialConfig-accessKey"
+	credentialSecretKey      = "huaweicredentialConfig-secretKey"
+	credentialProjectID      = "huaweicredentialConfig-projectID"
+	credentialSecurityToken  = "huaweicredentialConfig-securityToken"
+	credentialExpiresAt      = "huaweicredentialConfig-expiresAt"
+	credentialFilePath       = "huaweicredentialConfig-credentialFile"
+	credentialDomainID       = "huaweicredentialConfig-domainID"
+	credentialAgencyDomain   = "huaweicredentialConfig-agencyDomain"
+	credentialAgencyName     = "huaweicredentialConfig-agencyName"
+	credentialAgencyDuration = "huaweicredentialConfig-agencyDuration"
+)
+
+var (
+	// CredentialFileDir is the directory of the credential files, the
+	// credential files outside it are rejected. The credential files are not
+	// allowed if empty.
+	CredentialFileDir string
+	// credentialRefreshBefore is how long before expiry the temporary
+	// credential is refreshed, the driver is set up when the reconcile starts
+	// so it should be longer than a reconcile takes.
+	credentialRefreshBefore = 10 * time.Minute
+	// credentialFileRetryInterval is how often the credential file is read
+	// again if it was not updated before the refresh time.
+	credentialFileRetryInterval = time.Minute
+	// defaultAgencyDuration is the duration of the credential of the
+	// assumed agency.
+	defaultAgencyDuration = time.Hour
+)
+
+// credentialVersion identifies the credential the driver was created from.
+type credentialVersion struct {
+	secret string // hash of the credential keys in the secret
+	file   string // hash of the credential file, empty if not used
+}
+
+// huaweiCredential is the Huawei Cloud credential read from the secret and
+// the credential file.
+type huaweiCredential struct {
+	accessKey     string
+	secretKey     string
+	securityToken string
+	projectID     string
+	expiresAt     time.Time
+
+	// domainID is the account ID of the access key, agencyDomain and
+	// agencyName are the agency created by the delegating account.
+	domainID       string
+	agencyDomain   string
+	agencyName     string
+	agencyDuration time.Duration
+
+	version credentialVersion
+}
+
+// credentialFile is the format of the credential file, which is the same as
+// the temporary access key returned by IAM.
+type credentialFile struct {
+	Credential struct {
+		Access        string `json:"access"`
+		Secret        string `json:"secret"`
+		SecurityToken string `json:"securitytoken"`
+		ExpiresAt     string `json:"expires_at"`
+	} `json:"credential"`
+}
+
+// credentialHash returns the hash of the Huawei Cloud credential keys in the
+// secret, the raw keys are not kept in the driver cache.
+func credentialHash(secret *corev1.Secret) string {
+	h := sha256.New()
+	for _, key := range []string{
+		credentialAccessKey,
+		credentialSecretKey,
+		credentialProjectID,
+		credentialSecurityToken,
+		credentialExpiresAt,
+		credentialFilePath,
+		credentialDomainID,
+		credentialAgencyDomain,
+		credentialAgencyName,
+		credentialAgencyDuration,
+	} {
+		h.Write(secret.Data[key])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseExpiresAt parses the expiry time of the temporary credential, empty
+// if the credential does not expire.
+func parseExpiresAt(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid credential expiry %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// readCredentialFile reads the credential file in CredentialFileDir, the
+// relative path is relative to the directory. Returns the credential and the
+// hash of the file. The errors are shown in the config status and events, so
+// they do not contain the file contents.
+func readCredentialFile(path string) (*credentialFile, string, error) {
+	if CredentialFileDir == "" {
+		return nil, "", fmt.Errorf("credential file %q is not allowed, the credential file directory is not configured", path)
+	}
+	dir, err := filepath.Abs(CredentialFileDir)
+	if err != nil {
+		logrus.Errorf("invalid credential file directory: %v", err)
+		return nil, "", fmt.Errorf("failed to read credential file %q", path)
+	}
+	file := path
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	if !inDir(dir, filepath.Clean(file)) {
+		return nil, "", fmt.Errorf("credential file %q is outside the credential file directory", path)
+	}
+	// The projected volumes link the files to the current data directory,
+	// the links should not lead outside the directory.
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		logrus.Errorf("failed to resolve credential file directory: %v", err)
+		return nil, "", fmt.Errorf("failed to read credential file %q", path)
+	}
+	if file, err = filepath.EvalSymlinks(file); err != nil {
+		logrus.Warnf("failed to resolve credential file %q: %v", path, err)
+		return nil, "", fmt.Errorf("failed to read credential file %q", path)
+	}
+	if !inDir(resolvedDir, file) {
+		return nil, "", fmt.Errorf("credential file %q is outside the credential file directory", path)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		logrus.Warnf("failed to read credential file %q: %v", path, err)
+		return nil, "", fmt.Errorf("failed to read credential file %q", path)
+	}
+	f := &credentialFile{}
+	if err := json.Unmarshal(data, f); err != nil {
+		logrus.Warnf("invalid credential file %q: %v", path, err)
+		return nil, "", fmt.Errorf("invalid credential file %q", path)
+	}
+	if _, err := parseExpiresAt(f.Credential.ExpiresAt); err != nil {
+		return nil, "", fmt.Errorf("invalid credential expiry in credential file %q", path)
+	}
+	sum := sha256.Sum256(data)
+	return f, hex.EncodeToString(sum[:]), nil
+}
+
+// inDir returns true if the cleaned absolute path is in the directory.
+func inDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readHuaweiCredential reads the credential from the secret, the access key
+// is read from the credential file if the file is configured.
+func readHuaweiCredential(secret *corev1.Secret) (*huaweiCredential, error) {
+	c := &huaweiCredential{
+		accessKey:     string(secret.Data[credentialAccessKey]),
+		secretKey:     string(secret.Data[credentialSecretKey]),
+		securityToken: string(secret.Data[credentialSecurityToken]),
+		projectID:     string(secret.Data[credentialProjectID]),
+		domainID:      string(secret.Data[credentialDomainID]),
+		agencyDomain:  string(secret.Data[credentialAgencyDomain]),
+		agencyName:    string(secret.Data[credentialAgencyName]),
+		version:       credentialVersion{secret: credentialHash(secret)},
+	}
+	expiresAt := string(secret.Data[credentialExpiresAt])
+	if path := string(secret.Data[credentialFilePath]); path != "" {
+		f, version, err := readCredentialFile(path)
+		if err != nil {
+			return nil, err
+		}
+		c.accessKey = f.Credential.Access
+		c.secretKey = f.Credential.Secret
+		c.securityToken = f.Credential.SecurityToken
+		expiresAt = f.Credential.ExpiresAt
+		c.version.file = version
+	}
+	if c.accessKey == "" || c.secretKey == "" || c.projectID == "" {
+		return nil, fmt.Errorf("invalid huawei cloud credential")
+	}
+	var err error
+	if c.expiresAt, err = parseExpiresAt(expiresAt); err != nil {
+		return nil, err
+	}
+	if c.agencyName == "" {
+		return c, nil
+	}
+	if c.agencyDomain == "" || c.domainID == "" {
+		return nil, fmt.Errorf("%s and %s are required to assume agency %q",
+			credentialAgencyDomain, credentialDomainID, c.agencyName)
+	}
+	c.agencyDuration = defaultAgencyDuration
+	if d := string(secret.Data[credentialAgencyDuration]); d != "" {
+		if c.agencyDuration, err = time.ParseDuration(d); err != nil {
+			return nil, fmt.Errorf("invalid agency duration %q: %w", d, err)
+		}
+		// IAM issues the agency credential valid from 15 minutes to 24 hours.
+		if c.agencyDuration < 15*time.Minute || c.agencyDuration > 24*time.Hour {
+			return nil, fmt.Errorf("agency duration %q should be between 15m and 24h", d)
+		}
+	}
+	return c, nil
+}
+
+// getHuaweiCredential reads the credential of the config spec.
+func getHuaweiCredential(
+	secretsCache wranglerv1.SecretCache, spec *ccev1.CCEClusterConfigSpec,
+) (*huaweiCredential, error) {
+	if spec.RegionID == "" {
+		return nil, fmt.Errorf("regionID not provided")
+	}
+	ns, id := utils.Parse(spec.HuaweiCredentialSecret)
+	if spec.HuaweiCredentialSecret == "" {
+		return nil, fmt.Errorf("huawei credential secret not provided")
+	}
+
+	secret, err := secretsCache.Get(ns, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting secret %s/%s: %w", ns, id, err)
+	}
+	return readHuaweiCredential(secret)
+}
+
+// requeueCredentialRefresh enqueues the config to read the credential file
+// again before the temporary credential expires, the credential file is not
+// watched and only read when the config is reconciled.
+func (h *Handler) requeueCredentialRefresh(config *ccev1.CCEClusterConfig) {
+	refreshAt := h.drivers.refreshAt(&config.Spec)
+	if refreshAt.IsZero() {
+		return
+	}
+	after := time.Until(refreshAt)
+	if after < credentialFileRetryInterval {
+		after = credentialFileRetryInterval
+	}
+	h.cceEnqueueAfter(config.Namespace, config.Name, after)
+}
+
+// clientAuth returns the client auth of the region, the agency is assumed
+// for the temporary credential if configured.
+func (c *huaweiCredential) clientAuth(region, endpoint string) (*common.ClientAuth, error) {
+	var auth *common.ClientAuth
+	if c.securityToken != "" {
+		auth = common.NewTemporaryClientAuth(
+			c.accessKey, c.secretKey, c.securityToken, region, c.projectID, c.expiresAt)
+	} else {
+		auth = common.NewClientAuth(c.accessKey, c.secretKey, region, c.projectID)
+	}
+	auth.Endpoint = endpoint
+	if !c.expiresAt.IsZero() {
+		if !time.Now().Before(c.expiresAt) {
+			return nil, fmt.Errorf("temporary credential expired at %s", c.expiresAt.Format(time.RFC3339))
+		}
+		if c.agencyName == "" && time.Until(c.expiresAt) < credentialRefreshBefore {
+			logrus.Warnf("temporary credential expires at %s, should be updated before expiry",
+				c.expiresAt.Format(time.RFC3339))
+		}
+	}
+	if c.agencyName == "" {
+		return auth, nil
+	}
+
+	client := iam.WithMetrics(iam.NewIamClient(auth, c.domainID), ratelimit.ForAccount(region, c.projectID))
+	credential, err := iam.AssumeAgency(client, c.agencyDomain, c.agencyName, c.agencyDuration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assume agency %s/%s: %w", c.agencyDomain, c.agencyName, err)
+	}
+	expiresAt, err := parseExpiresAt(credential.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("assumed agency %s/%s, the credential expires at %s",
+		c.agencyDomain, c.agencyName, expiresAt.Format(time.RFC3339))
+	agencyAuth := common.NewTemporaryClientAuth(
+		credential.Access, credential.Secret, credential.Securitytoken, region, c.projectID, expiresAt)
+	agencyAuth.Endpoint = endpoint
+	return agencyAuth, nil
+}
@@ -0,0 +1,287 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/vpc"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_readHuaweiCredential(t *testing.T) {
+	assert := assert.New(t)
+	newSecret := func(data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{Data: map[string][]byte{
+			credentialAccessKey: []byte("ak"),
+			credentialSecretKey: []byte("sk"),
+			credentialProjectID: []byte("project-id"),
+		}}
+		for k, v := range data {
+			secret.Data[k] = []byte(v)
+		}
+		return secret
+	}
+
+	c, err := readHuaweiCredential(newSecret(nil))
+	assert.Nil(err)
+	assert.Equal("ak", c.accessKey)
+	assert.True(c.expiresAt.IsZero())
+	c, err = readHuaweiCredential(newSecret(map[string]string{
+		credentialSecurityToken: "token",
+		credentialExpiresAt:     "2026-10-17T08:00:00.000000Z",
+	}))
+	assert.Nil(err)
+	assert.Equal("token", c.securityToken)
+	assert.Equal(time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC), c.expiresAt.UTC())
+	c, err = readHuaweiCredential(newSecret(map[string]string{
+		credentialDomainID:     "domain-id",
+		credentialAgencyDomain: "delegating",
+		credentialAgencyName:   "cce-admin",
+	}))
+	assert.Nil(err)
+	assert.Equal(defaultAgencyDuration, c.agencyDuration)
+
+	_, err = readHuaweiCredential(newSecret(map[string]string{credentialProjectID: ""}))
+	assert.ErrorContains(err, "invalid huawei cloud credential")
+	_, err = readHuaweiCredential(newSecret(map[string]string{credentialExpiresAt: "tomorrow"}))
+	assert.ErrorContains(err, "invalid credential expiry")
+	_, err = readHuaweiCredential(newSecret(map[string]string{credentialAgencyName: "cce-admin"}))
+	assert.ErrorContains(err, "are required to assume agency")
+	_, err = readHuaweiCredential(newSecret(map[string]string{
+		credentialDomainID:       "domain-id",
+		credentialAgencyDomain:   "delegating",
+		credentialAgencyName:     "cce-admin",
+		credentialAgencyDuration: "48h",
+	}))
+	assert.ErrorContains(err, "should be between 15m and 24h")
+	setCredentialFileDir(t, t.TempDir())
+	_, err = readHuaweiCredential(newSecret(map[string]string{
+		credentialFilePath: filepath.Join(CredentialFileDir, "not-exist"),
+	}))
+	assert.ErrorContains(err, "failed to read credential file")
+}
+
+// setCredentialFileDir sets CredentialFileDir until the test finishes.
+func setCredentialFileDir(t *testing.T, dir string) {
+	t.Helper()
+
+	old := CredentialFileDir
+	CredentialFileDir = dir
+	t.Cleanup(func() { CredentialFileDir = old })
+}
+
+func Test_readCredentialFile(t *testing.T) {
+	assert := assert.New(t)
+	dir, outside := t.TempDir(), t.TempDir()
+	write := func(path, data string) {
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, "credential.json"), `{"credential":{"access":"ak","secret":"sk"}}`)
+	write(filepath.Join(dir, "invalid.json"), `secret-ak`)
+	write(filepath.Join(dir, "expiry.json"), `{"credential":{"access":"ak","secret":"sk","expires_at":"secret-expiry"}}`)
+	write(filepath.Join(outside, "credential.json"), `{"credential":{"access":"ak","secret":"sk"}}`)
+	if err := os.Symlink(filepath.Join(outside, "credential.json"), filepath.Join(dir, "link.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := readCredentialFile(filepath.Join(dir, "credential.json"))
+	assert.ErrorContains(err, "the credential file directory is not configured")
+	setCredentialFileDir(t, dir)
+	f, version, err := readCredentialFile(filepath.Join(dir, "credential.json"))
+	if assert.Nil(err) {
+		assert.Equal("ak", f.Credential.Access)
+		assert.NotEmpty(version)
+	}
+	f, _, err = readCredentialFile("credential.json")
+	if assert.Nil(err) {
+		assert.Equal("sk", f.Credential.Secret)
+	}
+
+	for _, path := range []string{
+		filepath.Join(outside, "credential.json"),
+		filepath.Join(dir, "..", filepath.Base(outside), "credential.json"),
+		"../" + filepath.Base(outside) + "/credential.json",
+		"/etc/passwd",
+		"link.json",
+	} {
+		_, _, err = readCredentialFile(path)
+		assert.ErrorContains(err, "is outside the credential file directory", path)
+	}
+	// The file contents are not in the errors.
+	_, _, err = readCredentialFile("invalid.json")
+	assert.EqualError(err, `invalid credential file "invalid.json"`)
+	_, _, err = readCredentialFile("expiry.json")
+	assert.EqualError(err, `invalid credential expiry in credential file "expiry.json"`)
+	_, _, err = readCredentialFile("not-exist.json")
+	assert.EqualError(err, `failed to read credential file "not-exist.json"`)
+}
+
+// updateTestSecret updates the data of the credential secret of the test
+// config.
+func (e *testEnv) updateTestSecret(t *testing.T, data map[string]string) {
+	t.Helper()
+
+	secret, err := e.secrets.Get(testNamespace, "cc-test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret = secret.DeepCopy()
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	if _, err := e.secrets.Update(secret); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// securityToken returns the security token sent by the cached driver of the
+// config spec.
+func (e *testEnv) securityToken(t *testing.T, config *ccev1.CCEClusterConfig) string {
+	t.Helper()
+
+	driver, _ := e.handler.drivers.get(&config.Spec)
+	if driver == nil {
+		t.Fatal("driver not cached")
+	}
+	_, _ = vpc.ShowVPC(driver.VPC, "vpc-id")
+	return e.server.SecurityToken()
+}
+
+func Test_Handler_setupHuaweiDriver_Agency(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+	e.updateTestSecret(t, map[string]string{
+		credentialDomainID:     "domain-id",
+		credentialAgencyDomain: "delegating",
+		credentialAgencyName:   "cce-admin",
+	})
+	config := newTestConfig("cce-test")
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]string{"delegating/cce-admin"}, e.server.AssumedAgencies())
+	token := e.securityToken(t, config)
+	assert.NotEmpty(token)
+
+	// The agency credential is reused until it is about to expire.
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(e.server.AssumedAgencies(), 1)
+	e.handler.drivers.drivers[driverKey{config.Spec.HuaweiCredentialSecret, config.Spec.RegionID}].expiresAt =
+		time.Now().Add(credentialRefreshBefore / 2)
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(e.server.AssumedAgencies(), 2)
+	assert.NotEqual(token, e.securityToken(t, config))
+
+	// The unexpired driver is used if the credential failed to be read.
+	e.updateTestSecret(t, map[string]string{credentialDomainID: ""})
+	_, err := e.handler.setupHuaweiDriver(&config.Spec)
+	assert.Nil(err)
+	config.Spec.RegionID = "cn-east-3"
+	_, err = e.handler.setupHuaweiDriver(&config.Spec)
+	assert.ErrorContains(err, "are required to assume agency")
+}
+
+func Test_Handler_setupHuaweiDriver_CredentialFile(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+	setCredentialFileDir(t, t.TempDir())
+	path := filepath.Join(CredentialFileDir, "credential.json")
+	writeCredential := func(token string, expiresAt time.Time) {
+		data := fmt.Sprintf(`{"credential":{"access":"ak-%[1]s","secret":"sk-%[1]s",`+
+			`"securitytoken":"%[1]s","expires_at":"%[2]s"}}`, token, expiresAt.UTC().Format(time.RFC3339))
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCredential("token-1", time.Now().Add(time.Hour))
+	e.updateTestSecret(t, map[string]string{
+		credentialAccessKey: "",
+		credentialSecretKey: "",
+		credentialFilePath:  path,
+	})
+	config := newTestConfig("cce-test")
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("token-1", e.securityToken(t, config))
+
+	// The credential file is re-read if changed.
+	writeCredential("token-2", time.Now().Add(time.Hour))
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("token-2", e.securityToken(t, config))
+
+	// The expired credential is not used.
+	writeCredential("token-3", time.Now().Add(-time.Minute))
+	_, err := e.handler.setupHuaweiDriver(&config.Spec)
+	assert.Nil(err)
+	assert.Equal("token-2", e.securityToken(t, config))
+	config.Spec.RegionID = "cn-east-3"
+	_, err = e.handler.setupHuaweiDriver(&config.Spec)
+	assert.ErrorContains(err, "temporary credential expired")
+}
+
+func Test_Handler_requeueCredentialRefresh(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+	setCredentialFileDir(t, t.TempDir())
+	var requeued []time.Duration
+	e.handler.cceEnqueueAfter = func(_, _ string, after time.Duration) {
+		requeued = append(requeued, after)
+	}
+	config := newTestConfig("cce-test")
+
+	// The config using the credential in the secret is not requeued.
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	e.handler.requeueCredentialRefresh(config)
+	assert.Empty(requeued)
+
+	// The config using the credential file is requeued before the
+	// credential expires.
+	path := filepath.Join(CredentialFileDir, "credential.json")
+	writeCredential := func(expiresAt time.Time) {
+		data := fmt.Sprintf(`{"credential":{"access":"ak","secret":"sk","securitytoken":"token",`+
+			`"expires_at":"%s"}}`, expiresAt.UTC().Format(time.RFC3339))
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCredential(time.Now().Add(time.Hour))
+	e.updateTestSecret(t, map[string]string{
+		credentialAccessKey: "",
+		credentialSecretKey: "",
+		credentialFilePath:  path,
+	})
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	e.handler.requeueCredentialRefresh(config)
+	if assert.Len(requeued, 1) {
+		assert.InDelta(float64(time.Hour-credentialRefreshBefore), float64(requeued[0]), float64(time.Minute))
+	}
+
+	// The credential file not updated before the refresh time is read again
+	// after the retry interval.
+	writeCredential(time.Now().Add(5 * time.Minute))
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	requeued = nil
+	e.handler.requeueCredentialRefresh(config)
+	assert.Equal([]time.Duration{credentialFileRetryInterval}, requeued)
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+)
+
+// Default values used by cce.GetCreateClusterRequest and
+// cce.GetCreateNodePoolRequest when the fields are not specified.
+const (
+	defaultCategory             = "CCE"
+	defaultType                 = "VirtualMachine"
+	defaultContainerNetworkMode = "vpc-router"
+	defaultKubeProxyMode        = "iptables"
+	defaultRuntime              = "docker"
+)
+
+// SetDefaults sets the default values of the empty fields of the
+// non-import cluster spec.
+func SetDefaults(spec *ccev1.CCEClusterConfigSpec) {
+	if spec.Imported {
+		return
+	}
+	if spec.Category == "" {
+		spec.Category = defaultCategory
+	}
+	if spec.Type == "" {
+		spec.Type = defaultType
+	}
+	if spec.ContainerNetwork.Mode == "" {
+		spec.ContainerNetwork.Mode = defaultContainerNetworkMode
+	}
+	if spec.KubeProxyMode == "" {
+		spec.KubeProxyMode = defaultKubeProxyMode
+	}
+	for i := range spec.NodePools {
+		if spec.NodePools[i].NodeTemplate.Runtime == "" {
+			spec.NodePools[i].NodeTemplate.Runtime = defaultRuntime
+		}
+	}
+}
@@ -2,92 +2,243 @@ package controller
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
 	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
 	"github.com/cnrancher/cce-operator/pkg/huawei/common"
 	"github.com/cnrancher/cce-operator/pkg/huawei/dns"
+	"github.com/cnrancher/cce-operator/pkg/huawei/ecs"
 	"github.com/cnrancher/cce-operator/pkg/huawei/eip"
 	"github.com/cnrancher/cce-operator/pkg/huawei/elb"
 	"github.com/cnrancher/cce-operator/pkg/huawei/nat"
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
 	"github.com/cnrancher/cce-operator/pkg/huawei/vpc"
 	"github.com/cnrancher/cce-operator/pkg/huawei/vpcep"
 	"github.com/cnrancher/cce-operator/pkg/utils"
-	huawei_cce "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3"
-	huawei_dns "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/dns/v2"
-	huawei_eip "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2"
-	huawei_elb "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v2"
-	huawei_nat "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/nat/v2"
-	huawei_vpc "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2"
-	huawei_vpcep "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpcep/v1"
-	wranglerv1 "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// HuaweiDriver holds the Huawei Cloud service APIs used by the handler,
+// the services are implemented by the SDK clients by default and can be
+// replaced by mocks in tests.
 type HuaweiDriver struct {
-	VPC   *huawei_vpc.VpcClient
-	EIP   *huawei_eip.EipClient
-	ELB   *huawei_elb.ElbClient
-	CCE   *huawei_cce.CceClient
-	VPCEP *huawei_vpcep.VpcepClient
-	DNS   *huawei_dns.DnsClient
-	NAT   *huawei_nat.NatClient
+	VPC   vpc.VpcAPI
+	EIP   eip.EipAPI
+	ELB   elb.ElbAPI
+	CCE   cce.ClusterAPI
+	VPCEP vpcep.VpcepAPI
+	DNS   dns.DnsAPI
+	NAT   nat.NatAPI
+	ECS   ecs.EcsAPI
 }
 
-func (h *Handler) setupHuaweiDriver(spec *ccev1.CCEClusterConfigSpec) error {
-	auth, err := NewHuaweiClientAuth(h.secretsCache, spec)
-	if err != nil {
-		// Failed to initialize driver from cloud credential, the credential may
-		// deleted by user manually.
-		// Check if the driver is created and cached in drivers map.
-		if _, ok := h.drivers[spec.HuaweiCredentialSecret]; !ok {
-			return fmt.Errorf("failed to create HuaweiClientAuth: %w", err)
+// driverCacheTTL is the time the cached driver is kept since last used.
+var driverCacheTTL = time.Hour
+
+// driverKey is the key of the cached driver, the clients are created for
+// the credential and region.
+type driverKey struct {
+	secret string
+	region string
+}
+
+type cachedDriver struct {
+	driver    *HuaweiDriver
+	version   credentialVersion
+	expiresAt time.Time // zero if the credential does not expire
+	lastUsed  time.Time
+}
+
+// driverCache caches the Huawei drivers by credential secret and region,
+// it is safe for concurrent use.
+type driverCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	drivers map[driverKey]*cachedDriver
+}
+
+func newDriverCache(ttl time.Duration) *driverCache {
+	return &driverCache{
+		ttl:     ttl,
+		drivers: map[driverKey]*cachedDriver{},
+	}
+}
+
+// get returns the cached driver of the config spec, false if not found or
+// the credential expired.
+func (c *driverCache) get(spec *ccev1.CCEClusterConfigSpec) (*HuaweiDriver, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.drivers[driverKey{spec.HuaweiCredentialSecret, spec.RegionID}]
+	if !ok || (!d.expiresAt.IsZero() && !time.Now().Before(d.expiresAt)) {
+		return nil, false
+	}
+	d.lastUsed = time.Now()
+	return d.driver, true
+}
+
+// lookup returns the cached driver of the config spec if it was created from
+// the credential version, and the credential does not need to be refreshed.
+func (c *driverCache) lookup(spec *ccev1.CCEClusterConfigSpec, version credentialVersion) (*HuaweiDriver, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.drivers[driverKey{spec.HuaweiCredentialSecret, spec.RegionID}]
+	if !ok || d.version != version ||
+		(!d.expiresAt.IsZero() && time.Until(d.expiresAt) < credentialRefreshBefore) {
+		return nil, false
+	}
+	d.lastUsed = time.Now()
+	return d.driver, true
+}
+
+// refreshAt returns when the cached driver of the config spec created from
+// the credential file should be refreshed, zero if not cached, not created
+// from the credential file or the credential does not expire.
+func (c *driverCache) refreshAt(spec *ccev1.CCEClusterConfigSpec) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.drivers[driverKey{spec.HuaweiCredentialSecret, spec.RegionID}]
+	if !ok || d.version.file == "" || d.expiresAt.IsZero() {
+		return time.Time{}
+	}
+	return d.expiresAt.Add(-credentialRefreshBefore)
+}
+
+func (c *driverCache) set(
+	spec *ccev1.CCEClusterConfigSpec, version credentialVersion, expiresAt time.Time, driver *HuaweiDriver,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.drivers[driverKey{spec.HuaweiCredentialSecret, spec.RegionID}] = &cachedDriver{
+		driver:    driver,
+		version:   version,
+		expiresAt: expiresAt,
+		lastUsed:  time.Now(),
+	}
+}
+
+// rotate removes the drivers of the credential secret which were not created
+// from the credential of the hash, returns true if any driver was removed.
+func (c *driverCache) rotate(secret, hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := false
+	for k, d := range c.drivers {
+		if k.secret == secret && d.version.secret != hash {
+			delete(c.drivers, k)
+			removed = true
 		}
-		logrus.Warnf("HuaweiClientAuth create failed: [%v], using driver cache", err)
-		return nil
 	}
-	// Update the driver cached in map.
-	h.drivers[spec.HuaweiCredentialSecret] = NewHuaweiDriver(auth)
-	return nil
+	return removed
 }
 
-func NewHuaweiDriver(auth *common.ClientAuth) *HuaweiDriver {
-	return &HuaweiDriver{
-		CCE:   cce.NewCCEClient(auth),
-		ELB:   elb.NewElbClient(auth),
-		VPC:   vpc.NewVpcClient(auth),
-		EIP:   eip.NewEipClient(auth),
-		VPCEP: vpcep.NewVpcepClient(auth),
-		DNS:   dns.NewDnsClient(auth),
-		NAT:   nat.NewNatClient(auth),
+// evictExpired removes the drivers not used in the TTL.
+func (c *driverCache) evictExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, d := range c.drivers {
+		if now.Sub(d.lastUsed) > c.ttl {
+			delete(c.drivers, k)
+		}
 	}
 }
 
-func NewHuaweiClientAuth(
-	secretsCache wranglerv1.SecretCache, spec *ccev1.CCEClusterConfigSpec,
-) (*common.ClientAuth, error) {
-	region := spec.RegionID
-	if region == "" {
-		return nil, fmt.Errorf("regionID not provided")
+// setupHuaweiDriver returns the driver of the config spec and creates it if
+// not cached, the driver of the temporary credential is recreated before the
+// credential expires, so the credential does not expire during the reconcile.
+func (h *Handler) setupHuaweiDriver(spec *ccev1.CCEClusterConfigSpec) (*HuaweiDriver, error) {
+	h.drivers.evictExpired()
+	credential, err := getHuaweiCredential(h.secretsCache, spec)
+	if err == nil {
+		if driver, ok := h.drivers.lookup(spec, credential.version); ok {
+			return driver, nil
+		}
+		var auth *common.ClientAuth
+		if auth, err = credential.clientAuth(spec.RegionID, h.overrides.endpoint); err == nil {
+			driver := NewHuaweiDriver(auth)
+			h.drivers.set(spec, credential.version, auth.ExpiresAt, driver)
+			// The regions of the credential are scanned for orphan resources.
+			if err := h.recordCredentialRegion(spec); err != nil {
+				logrus.Warnf("failed to record region [%s] of credential [%s]: %v",
+					spec.RegionID, spec.HuaweiCredentialSecret, err)
+			}
+			return driver, nil
+		}
 	}
-	ns, id := utils.Parse(spec.HuaweiCredentialSecret)
-	if spec.HuaweiCredentialSecret == "" {
-		return nil, fmt.Errorf("huawei credential secret not provided")
+	// Failed to initialize driver from cloud credential, the credential may
+	// deleted by user manually or the agency failed to be assumed.
+	// Check if the driver is created and cached.
+	driver, ok := h.drivers.get(spec)
+	if !ok {
+		return nil, fmt.Errorf("failed to create HuaweiClientAuth: %w", err)
 	}
+	logrus.Warnf("HuaweiClientAuth create failed: [%v], using driver cache", err)
+	return driver, nil
+}
+
+// driver returns the cached driver of the config spec, the driver is created
+// again if it was removed after the secret changed or the credential expired
+// during the reconcile.
+func (h *Handler) driver(spec *ccev1.CCEClusterConfigSpec) (*HuaweiDriver, error) {
+	if driver, ok := h.drivers.get(spec); ok {
+		return driver, nil
+	}
+	return h.setupHuaweiDriver(spec)
+}
 
-	secret, err := secretsCache.Get(ns, id)
+// OnSecretChanged removes the cached drivers if the keys of the credential
+// secret were changed, and enqueues the configs using the credential to
+// reconcile with the new clients.
+func (h *Handler) OnSecretChanged(_ string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil || secret.DeletionTimestamp != nil {
+		// The cached drivers are used if the secret was deleted.
+		return secret, nil
+	}
+	ref := secret.Namespace + ":" + secret.Name
+	if !h.drivers.rotate(ref, credentialHash(secret)) {
+		return secret, nil
+	}
+	configs, err := h.cceCC.List("", metav1.ListOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("error getting secret %s/%s: %w", ns, id, err)
+		return secret, err
 	}
+	for _, config := range configs.Items {
+		ns, name := utils.Parse(config.Spec.HuaweiCredentialSecret)
+		if ns != secret.Namespace || name != secret.Name {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Infof("credential secret [%s] was changed, reconcile with the new credential", ref)
+		h.cceEnqueue(config.Namespace, config.Name)
+	}
+	return secret, nil
+}
 
-	accessKeyBytes := secret.Data["huaweicredentialConfig-accessKey"]
-	secretKeyBytes := secret.Data["huaweicredentialConfig-secretKey"]
-	projectIDBytes := secret.Data["huaweicredentialConfig-projectID"]
-	if accessKeyBytes == nil || secretKeyBytes == nil || projectIDBytes == nil {
-		return nil, fmt.Errorf("invalid huawei cloud credential")
+// NewHuaweiDriver creates the service clients recording the request metrics,
+// the requests of the account in the region share the same rate limit.
+func NewHuaweiDriver(auth *common.ClientAuth) *HuaweiDriver {
+	policy := ratelimit.ForAccount(auth.Region, auth.Credential.ProjectId)
+	return &HuaweiDriver{
+		CCE:   cce.WithMetrics(cce.NewCCEClient(auth), policy),
+		ELB:   elb.WithMetrics(elb.NewElbClient(auth), policy),
+		VPC:   vpc.WithMetrics(vpc.NewVpcClient(auth), policy),
+		EIP:   eip.WithMetrics(eip.NewEipClient(auth), policy),
+		VPCEP: vpcep.WithMetrics(vpcep.NewVpcepClient(auth), policy),
+		DNS:   dns.WithMetrics(dns.NewDnsClient(auth), policy),
+		NAT:   nat.WithMetrics(nat.NewNatClient(auth), policy),
+		ECS:   ecs.WithMetrics(ecs.NewEcsClient(auth), policy),
 	}
-	accessKey := string(accessKeyBytes)
-	secretKey := string(secretKeyBytes)
-	projectID := string(projectIDBytes)
-	return common.NewClientAuth(accessKey, secretKey, region, projectID), nil
 }
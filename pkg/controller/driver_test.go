@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_driverCache(t *testing.T) {
+	assert := assert.New(t)
+	c := newDriverCache(time.Hour)
+	north := newTestConfig("cce-north").Spec
+	east := newTestConfig("cce-east").Spec
+	east.RegionID = "cn-east-3"
+	get := func(spec *ccev1.CCEClusterConfigSpec) *HuaweiDriver {
+		driver, _ := c.get(spec)
+		return driver
+	}
+
+	// The drivers of the same credential in different regions are cached
+	// separately.
+	v1, v2 := credentialVersion{secret: "hash-1"}, credentialVersion{secret: "hash-2"}
+	northDriver, eastDriver := &HuaweiDriver{}, &HuaweiDriver{}
+	c.set(&north, v1, time.Time{}, northDriver)
+	c.set(&east, v1, time.Time{}, eastDriver)
+	assert.Same(northDriver, get(&north))
+	assert.Same(eastDriver, get(&east))
+	_, ok := c.lookup(&north, v2)
+	assert.False(ok)
+	_, ok = c.lookup(&north, credentialVersion{secret: "hash-1", file: "file-1"})
+	assert.False(ok)
+
+	// The drivers created from the old credential are removed.
+	assert.False(c.rotate(north.HuaweiCredentialSecret, "hash-1"))
+	assert.True(c.rotate(north.HuaweiCredentialSecret, "hash-2"))
+	assert.Nil(get(&north))
+	assert.Nil(get(&east))
+
+	// The drivers of the temporary credential are refreshed before expiry,
+	// and not used after expiry.
+	c.set(&north, v2, time.Now().Add(time.Hour), northDriver)
+	_, ok = c.lookup(&north, v2)
+	assert.True(ok)
+	c.set(&north, v2, time.Now().Add(credentialRefreshBefore/2), northDriver)
+	_, ok = c.lookup(&north, v2)
+	assert.False(ok)
+	assert.Same(northDriver, get(&north))
+	c.set(&north, v2, time.Now().Add(-time.Second), northDriver)
+	assert.Nil(get(&north))
+
+	// The drivers not used in the TTL are evicted.
+	c.set(&north, v2, time.Time{}, northDriver)
+	c.set(&east, v2, time.Time{}, eastDriver)
+	c.drivers[driverKey{east.HuaweiCredentialSecret, east.RegionID}].lastUsed = time.Now().Add(-2 * time.Hour)
+	c.evictExpired()
+	assert.Same(northDriver, get(&north))
+	assert.Nil(get(&east))
+
+	// The cache is safe for concurrent use.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.set(&east, v2, time.Time{}, &HuaweiDriver{})
+			c.get(&north)
+			c.evictExpired()
+			c.rotate(north.HuaweiCredentialSecret, "hash-2")
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_Handler_OnSecretChanged(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+	if _, err := e.configs.Create(newTestConfig("cce-test")); err != nil {
+		t.Fatal(err)
+	}
+	config := newTestConfig("cce-other")
+	config.Spec.HuaweiCredentialSecret = testNamespace + ":cc-other"
+	if _, err := e.configs.Create(config); err != nil {
+		t.Fatal(err)
+	}
+	config = newTestConfig("cce-test")
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	driver, _ := e.handler.drivers.get(&config.Spec)
+	assert.NotNil(driver)
+
+	// The driver is reused if the credential is not changed.
+	if _, err := e.handler.setupHuaweiDriver(&config.Spec); err != nil {
+		t.Fatal(err)
+	}
+	cached, _ := e.handler.drivers.get(&config.Spec)
+	assert.Same(driver, cached)
+	secret, _ := e.secrets.Get(testNamespace, "cc-test", metav1.GetOptions{})
+	if _, err := e.handler.OnSecretChanged("", secret); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(e.queue.pop())
+
+	// The configs using the credential are enqueued if the keys changed.
+	secret = secret.DeepCopy()
+	secret.Data["huaweicredentialConfig-secretKey"] = []byte("rotated-sk")
+	if secret, err := e.secrets.Update(secret); err != nil {
+		t.Fatal(err)
+	} else if _, err := e.handler.OnSecretChanged("", secret); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]string{fakeStoreKey(testNamespace, "cce-test")}, e.queue.pop())
+	_, ok := e.handler.drivers.get(&config.Spec)
+	assert.False(ok)
+	// The removed driver is created again when used during the reconcile.
+	rotated, err := e.handler.driver(&config.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotSame(driver, rotated)
+
+	// The cached driver is used if the secret was deleted.
+	_, err = e.handler.OnSecretChanged("", nil)
+	assert.Nil(err)
+	if err := e.secrets.Delete(testNamespace, "cc-test", nil); err != nil {
+		t.Fatal(err)
+	}
+	cached, err = e.handler.setupHuaweiDriver(&config.Spec)
+	assert.Nil(err)
+	assert.Same(rotated, cached)
+	config.Spec.HuaweiCredentialSecret = testNamespace + ":cc-other"
+	_, err = e.handler.setupHuaweiDriver(&config.Spec)
+	assert.ErrorContains(err, "failed to create HuaweiClientAuth")
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the events emitted on the CCEClusterConfig.
+const (
+	eventReasonCreated   = "Created"
+	eventReasonCreating  = "Creating"
+	eventReasonUpgrading = "Upgrading"
+	eventReasonUpdating  = "Updating"
+	eventReasonResizing  = "Resizing"
+	eventReasonDeleting  = "Deleting"
+	eventReasonFailed    = "Failed"
+	eventReasonBlocked   = "DeletionBlocked"
+	eventReasonNotReady  = "NotReady"
+	eventReasonDraining  = "Draining"
+	eventReasonReplaced  = "Replaced"
+	eventReasonRebooting = "Rebooting"
+	eventReasonSucceeded = "Succeeded"
+	eventReasonUnhealthy = "Unhealthy"
+	eventReasonRemedied  = "Remediated"
+	eventReasonDeferred  = "Deferred"
+)
+
+// newEventRecorder returns the recorder writing the events of the
+// CCEClusterConfig objects to the events client.
+func newEventRecorder(ctx context.Context, events typedcorev1.EventsGetter) record.EventRecorder {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(ccev1.AddToScheme(scheme))
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: events.Events(""),
+	})
+	go func() {
+		<-ctx.Done()
+		broadcaster.Shutdown()
+	}()
+	return broadcaster.NewRecorder(scheme, corev1.EventSource{Component: controllerName})
+}
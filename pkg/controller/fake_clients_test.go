@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	ccecontrollers "github.com/cnrancher/cce-operator/pkg/generated/controllers/cce.pandaria.io/v1"
+	"github.com/rancher/wrangler/v2/pkg/generic"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeStore is an in-memory object store implementing the wrangler client
+// and cache interfaces used by the handler.
+type fakeStore[T generic.RuntimeMetaObject, TList runtime.Object] struct {
+	mu       sync.Mutex
+	resource schema.GroupResource
+	objects  map[string]T
+	version  int
+
+	// copyStatus copies the status of src to dst,
+	// nil if the resource does not have status subresource.
+	copyStatus func(dst, src T)
+	// newList builds the list object from the items.
+	newList func(items []T) TList
+}
+
+func newFakeStore[T generic.RuntimeMetaObject, TList runtime.Object](
+	resource schema.GroupResource, copyStatus func(dst, src T), newList func([]T) TList,
+) *fakeStore[T, TList] {
+	return &fakeStore[T, TList]{
+		resource:   resource,
+		objects:    map[string]T{},
+		copyStatus: copyStatus,
+		newList:    newList,
+	}
+}
+
+func newFakeCCEClusterConfigStore() *fakeStore[*ccev1.CCEClusterConfig, *ccev1.CCEClusterConfigList] {
+	return newFakeStore(
+		ccev1.Resource("cceclusterconfigs"),
+		func(dst, src *ccev1.CCEClusterConfig) {
+			dst.Status = *src.Status.DeepCopy()
+		},
+		func(items []*ccev1.CCEClusterConfig) *ccev1.CCEClusterConfigList {
+			list := &ccev1.CCEClusterConfigList{}
+			for _, item := range items {
+				list.Items = append(list.Items, *item)
+			}
+			return list
+		},
+	)
+}
+
+func newFakeSecretStore() *fakeStore[*corev1.Secret, *corev1.SecretList] {
+	return newFakeStore(
+		corev1.Resource("secrets"),
+		nil,
+		func(items []*corev1.Secret) *corev1.SecretList {
+			list := &corev1.SecretList{}
+			for _, item := range items {
+				list.Items = append(list.Items, *item)
+			}
+			return list
+		},
+	)
+}
+
+func fakeStoreKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+func (s *fakeStore[T, TList]) deepCopy(obj T) T {
+	return obj.DeepCopyObject().(T)
+}
+
+func (s *fakeStore[T, TList]) nextVersion() string {
+	s.version++
+	return strconv.Itoa(s.version)
+}
+
+func (s *fakeStore[T, TList]) Create(obj T) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fakeStoreKey(obj.GetNamespace(), obj.GetName())
+	if _, ok := s.objects[key]; ok {
+		return obj, apierrors.NewAlreadyExists(s.resource, obj.GetName())
+	}
+	obj = s.deepCopy(obj)
+	obj.SetUID(types.UID(fmt.Sprintf("uid-%s", key)))
+	obj.SetGeneration(1)
+	obj.SetCreationTimestamp(metav1.NewTime(time.Now()))
+	obj.SetResourceVersion(s.nextVersion())
+	s.objects[key] = obj
+	return s.deepCopy(obj), nil
+}
+
+func (s *fakeStore[T, TList]) update(obj T, status bool) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fakeStoreKey(obj.GetNamespace(), obj.GetName())
+	stored, ok := s.objects[key]
+	if !ok {
+		return obj, apierrors.NewNotFound(s.resource, obj.GetName())
+	}
+	if obj.GetResourceVersion() != "" && obj.GetResourceVersion() != stored.GetResourceVersion() {
+		return obj, apierrors.NewConflict(s.resource, obj.GetName(),
+			fmt.Errorf("the object has been modified"))
+	}
+	var updated T
+	switch {
+	case status && s.copyStatus == nil:
+		return obj, apierrors.NewNotFound(s.resource, obj.GetName()+"/status")
+	case status:
+		updated = s.deepCopy(stored)
+		s.copyStatus(updated, obj)
+	default:
+		updated = s.deepCopy(obj)
+		if s.copyStatus != nil {
+			s.copyStatus(updated, stored)
+		}
+		updated.SetGeneration(stored.GetGeneration() + 1)
+	}
+	updated.SetResourceVersion(s.nextVersion())
+	s.objects[key] = updated
+	return s.deepCopy(updated), nil
+}
+
+func (s *fakeStore[T, TList]) Update(obj T) (T, error) {
+	return s.update(obj, false)
+}
+
+func (s *fakeStore[T, TList]) UpdateStatus(obj T) (T, error) {
+	return s.update(obj, true)
+}
+
+func (s *fakeStore[T, TList]) Delete(namespace, name string, _ *metav1.DeleteOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fakeStoreKey(namespace, name)
+	if _, ok := s.objects[key]; !ok {
+		return apierrors.NewNotFound(s.resource, name)
+	}
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *fakeStore[T, TList]) Get(namespace, name string, _ metav1.GetOptions) (T, error) {
+	return s.get(namespace, name)
+}
+
+func (s *fakeStore[T, TList]) get(namespace, name string) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[fakeStoreKey(namespace, name)]
+	if !ok {
+		var empty T
+		return empty, apierrors.NewNotFound(s.resource, name)
+	}
+	return s.deepCopy(obj), nil
+}
+
+func (s *fakeStore[T, TList]) items(namespace string) []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.objects))
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := []T{}
+	for _, k := range keys {
+		obj := s.objects[k]
+		if namespace != "" && obj.GetNamespace() != namespace {
+			continue
+		}
+		items = append(items, s.deepCopy(obj))
+	}
+	return items
+}
+
+func (s *fakeStore[T, TList]) List(namespace string, _ metav1.ListOptions) (TList, error) {
+	return s.newList(s.items(namespace)), nil
+}
+
+func (s *fakeStore[T, TList]) Watch(string, metav1.ListOptions) (watch.Interface, error) {
+	return nil, fmt.Errorf("watch is not supported by fake store")
+}
+
+func (s *fakeStore[T, TList]) Patch(
+	namespace, name string, _ types.PatchType, _ []byte, _ ...string,
+) (T, error) {
+	var empty T
+	return empty, fmt.Errorf("patch is not supported by fake store")
+}
+
+func (s *fakeStore[T, TList]) WithImpersonation(
+	rest.ImpersonationConfig,
+) (generic.ClientInterface[T, TList], error) {
+	return s, nil
+}
+
+// cache returns the cache interface backed by the fake store.
+func (s *fakeStore[T, TList]) cache() *fakeCache[T, TList] {
+	return &fakeCache[T, TList]{store: s}
+}
+
+type fakeCache[T generic.RuntimeMetaObject, TList runtime.Object] struct {
+	store *fakeStore[T, TList]
+}
+
+func (c *fakeCache[T, TList]) Get(namespace, name string) (T, error) {
+	return c.store.get(namespace, name)
+}
+
+func (c *fakeCache[T, TList]) List(namespace string, selector labels.Selector) ([]T, error) {
+	var items []T
+	for _, obj := range c.store.items(namespace) {
+		if selector == nil || selector.Matches(labels.Set(obj.GetLabels())) {
+			items = append(items, obj)
+		}
+	}
+	return items, nil
+}
+
+func (c *fakeCache[T, TList]) AddIndexer(string, generic.Indexer[T]) {}
+
+func (c *fakeCache[T, TList]) GetByIndex(string, string) ([]T, error) {
+	return nil, fmt.Errorf("index is not supported by fake cache")
+}
+
+// fakeQueue records the enqueued CCEClusterConfig keys.
+// failingConfigClient fails the first status update matched by fail.
+type failingConfigClient struct {
+	ccecontrollers.CCEClusterConfigClient
+	fail func(config *ccev1.CCEClusterConfig) bool
+}
+
+func (c *failingConfigClient) UpdateStatus(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
+	if c.fail != nil && c.fail(config) {
+		c.fail = nil
+		return config, fmt.Errorf("failed to update status of [%s]", config.Name)
+	}
+	return c.CCEClusterConfigClient.UpdateStatus(config)
+}
+
+type fakeQueue struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (q *fakeQueue) enqueue(namespace, name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.keys = append(q.keys, fakeStoreKey(namespace, name))
+}
+
+func (q *fakeQueue) enqueueAfter(namespace, name string, _ time.Duration) {
+	q.enqueue(namespace, name)
+}
+
+// pop returns and clears the enqueued keys.
+func (q *fakeQueue) pop() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	keys := q.keys
+	q.keys = nil
+	return keys
+}
+
+// recordedEvents returns and clears the events recorded by the fake recorder.
+func recordedEvents(recorder record.EventRecorder) []string {
+	var events []string
+	for {
+		select {
+		case e := <-recorder.(*record.FakeRecorder).Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
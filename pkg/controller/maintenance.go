@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	// Embed the time zone database for the images without tzdata.
+	_ "time/tzdata"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// disruptiveOperations are the operations deferred until the maintenance
+// window opens.
+var disruptiveOperations = map[string]bool{
+	planUpgradeCluster:  true,
+	planResizeCluster:   true,
+	planReplaceNodePool: true,
+	planDeleteNodePool:  true,
+	planDeleteNode:      true,
+	planRebootServer:    true,
+}
+
+// parseWeekday parses the weekday such as Sat or Saturday.
+func parseWeekday(day string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(day, d.String()) || strings.EqualFold(day, d.String()[:3]) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// parseClock parses the time of day in HH:MM.
+func parseClock(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, should be HH:MM", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// maintenanceWindowLocation returns the time zone of the maintenance window.
+func maintenanceWindowLocation(w *ccev1.CCEMaintenanceWindow) (*time.Location, error) {
+	if w.TimeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", w.TimeZone, err)
+	}
+	return loc, nil
+}
+
+// maintenanceWindowOpen returns true if now is in any of the maintenance
+// windows, and the start time of the next maintenance window after now.
+func maintenanceWindowOpen(windows []ccev1.CCEMaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	var (
+		open bool
+		next time.Time
+	)
+	for i := range windows {
+		w := &windows[i]
+		loc, err := maintenanceWindowLocation(w)
+		if err != nil {
+			return false, next, err
+		}
+		startHour, startMinute, err := parseClock(w.StartTime)
+		if err != nil {
+			return false, next, err
+		}
+		endHour, endMinute, err := parseClock(w.EndTime)
+		if err != nil {
+			return false, next, err
+		}
+		days := map[time.Weekday]bool{}
+		for _, d := range w.Days {
+			weekday, ok := parseWeekday(d)
+			if !ok {
+				return false, next, fmt.Errorf("invalid weekday %q", d)
+			}
+			days[weekday] = true
+		}
+
+		// The window started yesterday may not end yet.
+		local := now.In(loc)
+		for offset := -1; offset <= 7; offset++ {
+			day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, loc)
+			if len(days) != 0 && !days[day.Weekday()] {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), startHour, startMinute, 0, 0, loc)
+			end := time.Date(day.Year(), day.Month(), day.Day(), endHour, endMinute, 0, 0, loc)
+			if !end.After(start) {
+				end = end.AddDate(0, 0, 1)
+			}
+			if !now.Before(start) && now.Before(end) {
+				open = true
+			}
+			if start.After(now) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return open, next, nil
+}
+
+// maintenanceDeferred returns true if the disruptive operations should not
+// be started until the maintenance window opens.
+func maintenanceDeferred(config *ccev1.CCEClusterConfig) bool {
+	return config.Status.Maintenance != nil && !config.Status.Maintenance.InWindow
+}
+
+// pendingDisruptiveOperations returns the disruptive operations to make the
+// upstream cluster match the spec, including the remediations of the nodes
+// unhealthy longer than the timeout.
+func pendingDisruptiveOperations(
+	upstreamSpec *ccev1.CCEClusterConfigSpec, config *ccev1.CCEClusterConfig, now time.Time,
+) ([]ccev1.CCEClusterOperation, error) {
+	plan, err := planUpstreamClusterState(upstreamSpec, config)
+	if err != nil {
+		return nil, err
+	}
+	var pending []ccev1.CCEClusterOperation
+	for _, op := range plan {
+		if disruptiveOperations[op.Operation] {
+			pending = append(pending, op)
+		}
+	}
+	remediating := map[string]bool{}
+	for i := range config.Status.NodeRemediations {
+		if !nodeRemediationDone(&config.Status.NodeRemediations[i]) {
+			remediating[config.Status.NodeRemediations[i].NodeID] = true
+		}
+	}
+	for _, u := range config.Status.UnhealthyNodes {
+		for i := range config.Spec.NodePools {
+			np := &config.Spec.NodePools[i]
+			if np.ID != u.NodePoolID || !np.HealthPolicy.Enabled || remediating[u.NodeID] ||
+				now.Sub(u.Since.Time) < unhealthyTimeout(&np.HealthPolicy) {
+				continue
+			}
+			pending = append(pending, ccev1.CCEClusterOperation{
+				Operation: planDeleteNode,
+				Resource:  planResourceNode,
+				Name:      u.Name,
+				ID:        u.NodeID,
+				Current:   u.Reason,
+				Desired:   "action=Remediate",
+			})
+		}
+	}
+	return pending, nil
+}
+
+// maintenanceStatusEqual compares the maintenance status, the next window
+// time is compared as the instant because the location is not kept.
+func maintenanceStatusEqual(a, b *ccev1.CCEMaintenanceStatus) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.InWindow == b.InWindow && a.NextWindow.Equal(&b.NextWindow) &&
+		reflect.DeepEqual(a.PendingOperations, b.PendingOperations)
+}
+
+// syncMaintenance updates the maintenance window status of the cluster, the
+// disruptive operations are deferred if the maintenance windows are
+// configured and none of them is open.
+func (h *Handler) syncMaintenance(
+	config *ccev1.CCEClusterConfig, upstreamSpec *ccev1.CCEClusterConfigSpec,
+) (*ccev1.CCEClusterConfig, error) {
+	var (
+		status  *ccev1.CCEMaintenanceStatus
+		pending []ccev1.CCEClusterOperation
+	)
+	now := time.Now()
+	if len(config.Spec.MaintenanceWindows) != 0 {
+		open, next, err := maintenanceWindowOpen(config.Spec.MaintenanceWindows, now)
+		if err != nil {
+			return config, err
+		}
+		if !open {
+			if pending, err = pendingDisruptiveOperations(upstreamSpec, config, now); err != nil {
+				return config, err
+			}
+		}
+		status = &ccev1.CCEMaintenanceStatus{
+			InWindow:          open,
+			NextWindow:        metav1.NewTime(next.UTC()),
+			PendingOperations: pending,
+		}
+		if len(pending) != 0 {
+			// Reconcile again when the next window opens.
+			h.cceEnqueueAfter(config.Namespace, config.Name, next.Sub(now)+time.Second)
+		}
+	} else if config.Status.Maintenance == nil &&
+		meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionOperationsDeferred) == nil {
+		return config, nil
+	}
+
+	update := func(configUpdate *ccev1.CCEClusterConfig) bool {
+		changed := !maintenanceStatusEqual(configUpdate.Status.Maintenance, status)
+		configUpdate.Status.Maintenance = status
+		switch {
+		case status == nil:
+			if meta.FindStatusCondition(configUpdate.Status.Conditions, ccev1.ConditionOperationsDeferred) != nil {
+				meta.RemoveStatusCondition(&configUpdate.Status.Conditions, ccev1.ConditionOperationsDeferred)
+				changed = true
+			}
+		case len(pending) != 0:
+			changed = setCondition(configUpdate, ccev1.ConditionOperationsDeferred, metav1.ConditionTrue,
+				reasonOutsideMaintenanceWindow, fmt.Sprintf("%d disruptive operations are deferred until %s",
+					len(pending), status.NextWindow.Format(time.RFC3339))) || changed
+		default:
+			changed = setCondition(configUpdate, ccev1.ConditionOperationsDeferred, metav1.ConditionFalse,
+				reasonNotDeferred, "no disruptive operation is deferred") || changed
+		}
+		return changed
+	}
+	if !update(config.DeepCopy()) {
+		return config, nil
+	}
+	if len(pending) != 0 && !meta.IsStatusConditionTrue(config.Status.Conditions, ccev1.ConditionOperationsDeferred) {
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Infof("%d disruptive operations of cluster [%s] are deferred until the maintenance window at %s",
+			len(pending), config.Spec.Name, status.NextWindow.Format(time.RFC3339))
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeferred,
+			"%d disruptive operations of cluster [%s] are deferred until the maintenance window at %s",
+			len(pending), config.Spec.Name, status.NextWindow.Format(time.RFC3339))
+	}
+	var err error
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		if !update(configUpdate) {
+			return nil
+		}
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	return config, err
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_maintenanceWindowOpen(t *testing.T) {
+	assert := assert.New(t)
+	windows := []ccev1.CCEMaintenanceWindow{
+		// Saturday 22:00 to Sunday 06:00 in UTC+8.
+		{Days: []string{"Sat"}, StartTime: "22:00", EndTime: "06:00", TimeZone: "Asia/Shanghai"},
+	}
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	nextStart := time.Date(2024, 6, 8, 22, 0, 0, 0, shanghai)
+
+	// Friday.
+	open, next, err := maintenanceWindowOpen(windows, time.Date(2024, 6, 7, 12, 0, 0, 0, shanghai))
+	assert.Nil(err)
+	assert.False(open)
+	assert.True(nextStart.Equal(next))
+
+	// The window started on Saturday ends on Sunday.
+	open, next, err = maintenanceWindowOpen(windows, time.Date(2024, 6, 9, 5, 0, 0, 0, shanghai))
+	assert.Nil(err)
+	assert.True(open)
+	assert.True(nextStart.AddDate(0, 0, 7).Equal(next))
+	open, _, err = maintenanceWindowOpen(windows, time.Date(2024, 6, 9, 6, 0, 0, 0, shanghai))
+	assert.Nil(err)
+	assert.False(open)
+
+	// The time is compared in the time zone of the window.
+	open, _, err = maintenanceWindowOpen(windows, time.Date(2024, 6, 8, 15, 0, 0, 0, time.UTC))
+	assert.Nil(err)
+	assert.True(open)
+
+	// Every day if the days are empty, the earliest window is the next.
+	windows = append(windows, ccev1.CCEMaintenanceWindow{StartTime: "02:00", EndTime: "04:00"})
+	open, next, err = maintenanceWindowOpen(windows, time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC))
+	assert.Nil(err)
+	assert.False(open)
+	assert.True(time.Date(2024, 6, 8, 2, 0, 0, 0, time.UTC).Equal(next))
+
+	_, _, err = maintenanceWindowOpen([]ccev1.CCEMaintenanceWindow{{StartTime: "2am", EndTime: "04:00"}}, time.Now())
+	assert.NotNil(err)
+}
+
+func Test_pendingDisruptiveOperations(t *testing.T) {
+	assert := assert.New(t)
+	config := newMockConfig(cceConfigActivePhase)
+	upstreamSpec := config.Spec.DeepCopy()
+	config.Spec.Version = "v1.27"
+	config.Spec.Description = "updated"
+	config.Spec.NodePools[0].HealthPolicy.Enabled = true
+	now := time.Now()
+	config.Status.UnhealthyNodes = []ccev1.CCEUnhealthyNode{
+		{
+			NodeID:     "mock-node-1-id",
+			Name:       "node-1",
+			NodePoolID: config.Spec.NodePools[0].ID,
+			Reason:     "CCE node phase is Error",
+			Since:      metav1.NewTime(now.Add(-time.Hour)),
+		},
+		{
+			NodeID:     "mock-node-2-id",
+			Name:       "node-2",
+			NodePoolID: config.Spec.NodePools[0].ID,
+			Reason:     "CCE node phase is Error",
+			Since:      metav1.NewTime(now),
+		},
+	}
+
+	// The cluster info update is not disruptive.
+	pending, err := pendingDisruptiveOperations(upstreamSpec, config, now)
+	assert.Nil(err)
+	if assert.Len(pending, 2) {
+		assert.Equal(planUpgradeCluster, pending[0].Operation)
+		assert.Equal(planDeleteNode, pending[1].Operation)
+		assert.Equal("mock-node-1-id", pending[1].ID)
+	}
+}
@@ -0,0 +1,498 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
+	"github.com/cnrancher/cce-operator/pkg/huawei/eip"
+	"github.com/cnrancher/cce-operator/pkg/huawei/nat"
+	"github.com/cnrancher/cce-operator/pkg/huawei/vpc"
+	"github.com/cnrancher/cce-operator/pkg/huawei/vpcep"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	eip_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+	nat_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/nat/v2/model"
+	vpc_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	vpcep_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpcep/v1/model"
+	"k8s.io/client-go/tools/record"
+)
+
+// mockAPI records the called API names, the embedded service interfaces of
+// the mocks are nil so calling an API not implemented by the mock panics.
+type mockAPI struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *mockAPI) record(format string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, fmt.Sprintf(format, args...))
+}
+
+// Calls returns and clears the recorded API calls.
+func (m *mockAPI) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := m.calls
+	m.calls = nil
+	return calls
+}
+
+func mockNotFoundError(code string) error {
+	return &sdkerr.ServiceResponseError{
+		StatusCode:   http.StatusNotFound,
+		RequestId:    "mock-request-id",
+		ErrorCode:    code,
+		ErrorMessage: "resource not found",
+	}
+}
+
+// mockClusterAPI is a mock of cce.ClusterAPI returning the configured cluster,
+// node pools, upgrade task and pre-upgrade check.
+type mockClusterAPI struct {
+	cce.ClusterAPI
+	mockAPI
+
+	cluster     *cce_model.ShowClusterResponse
+	nodePools   []cce_model.NodePoolResp
+	nodes       []cce_model.Node
+	upgradeTask *cce_model.ShowUpgradeClusterTaskResponse
+	preCheck    *cce_model.ShowPreCheckResponse
+	// err is returned by all APIs if not nil.
+	err error
+}
+
+// newMockCluster builds the CCE cluster from the config spec.
+func newMockCluster(config *ccev1.CCEClusterConfig, phase string) *cce_model.ShowClusterResponse {
+	c := cce.GetCreateClusterRequest(config).Body
+	c.Metadata.Uid = utils.Pointer(config.Spec.ClusterID)
+	c.Spec.HostNetwork.SecurityGroup = utils.Pointer("mock-security-group-id")
+	c.Spec.Az = utils.Pointer("cn-north-4a")
+	return &cce_model.ShowClusterResponse{
+		Kind:       utils.Pointer(c.Kind),
+		ApiVersion: utils.Pointer(c.ApiVersion),
+		Metadata:   c.Metadata,
+		Spec:       c.Spec,
+		Status: &cce_model.ClusterStatus{
+			Phase: utils.Pointer(phase),
+			Endpoints: &[]cce_model.ClusterEndpoints{
+				{
+					Url:  utils.Pointer("https://192.168.0.10:5443"),
+					Type: utils.Pointer("Internal"),
+				},
+			},
+		},
+	}
+}
+
+// newMockNodePool builds the CCE node pool from the node pool config.
+func newMockNodePool(
+	clusterID string, np ccev1.CCENodePool, phase *cce_model.NodePoolStatusPhase,
+) cce_model.NodePoolResp {
+	req, _ := cce.GetCreateNodePoolRequest(clusterID, &np)
+	req.Body.Metadata.Uid = utils.Pointer(np.ID)
+	return cce_model.NodePoolResp{
+		Kind:       req.Body.Kind,
+		ApiVersion: req.Body.ApiVersion,
+		Metadata:   req.Body.Metadata,
+		Spec:       req.Body.Spec,
+		Status: &cce_model.NodePoolStatus{
+			CurrentNode: req.Body.Spec.InitialNodeCount,
+			Phase:       phase,
+		},
+	}
+}
+
+// newMockNode builds the CCE node of the node pool.
+func newMockNode(name, nodePoolID string, phase cce_model.NodeStatusPhase) cce_model.Node {
+	return cce_model.Node{
+		Metadata: &cce_model.NodeMetadata{
+			Name: utils.Pointer(name),
+			Uid:  utils.Pointer("mock-" + name + "-id"),
+			Annotations: map[string]string{
+				cce.NodePoolIDAnnotationKey: nodePoolID,
+			},
+		},
+		Status: &cce_model.NodeStatus{
+			Phase: &phase,
+		},
+	}
+}
+
+func (m *mockClusterAPI) ShowCluster(
+	req *cce_model.ShowClusterRequest,
+) (*cce_model.ShowClusterResponse, error) {
+	m.record("ShowCluster %s", req.ClusterId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.cluster == nil {
+		return nil, mockNotFoundError("CCE_CM.0003")
+	}
+	return m.cluster, nil
+}
+
+func (m *mockClusterAPI) UpdateCluster(
+	req *cce_model.UpdateClusterRequest,
+) (*cce_model.UpdateClusterResponse, error) {
+	m.record("UpdateCluster %s", req.ClusterId)
+	return &cce_model.UpdateClusterResponse{}, m.err
+}
+
+func (m *mockClusterAPI) UpgradeCluster(
+	req *cce_model.UpgradeClusterRequest,
+) (*cce_model.UpgradeClusterResponse, error) {
+	m.record("UpgradeCluster %s %s", req.ClusterId, req.Body.Spec.ClusterUpgradeAction.TargetVersion)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &cce_model.UpgradeClusterResponse{
+		Metadata: &cce_model.UpgradeCluserResponseMetadata{
+			Uid: utils.Pointer("mock-upgrade-task-id"),
+		},
+	}, nil
+}
+
+func (m *mockClusterAPI) ShowUpgradeClusterTask(
+	req *cce_model.ShowUpgradeClusterTaskRequest,
+) (*cce_model.ShowUpgradeClusterTaskResponse, error) {
+	m.record("ShowUpgradeClusterTask %s", req.TaskId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.upgradeTask == nil {
+		return nil, mockNotFoundError("CCE.01404001")
+	}
+	return m.upgradeTask, nil
+}
+
+func (m *mockClusterAPI) CreatePreCheck(
+	req *cce_model.CreatePreCheckRequest,
+) (*cce_model.CreatePreCheckResponse, error) {
+	m.record("CreatePreCheck %s %s", req.ClusterId, utils.Value(req.Body.Spec.TargetVersion))
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &cce_model.CreatePreCheckResponse{
+		Metadata: &cce_model.PrecheckCluserResponseMetadata{
+			Uid: utils.Pointer("mock-precheck-task-id"),
+		},
+	}, nil
+}
+
+func (m *mockClusterAPI) ShowPreCheck(
+	req *cce_model.ShowPreCheckRequest,
+) (*cce_model.ShowPreCheckResponse, error) {
+	m.record("ShowPreCheck %s", req.TaskId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.preCheck == nil {
+		return nil, mockNotFoundError("CCE.01404001")
+	}
+	return m.preCheck, nil
+}
+
+func (m *mockClusterAPI) ListClusterUpgradePaths(
+	req *cce_model.ListClusterUpgradePathsRequest,
+) (*cce_model.ListClusterUpgradePathsResponse, error) {
+	m.record("ListClusterUpgradePaths")
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &cce_model.ListClusterUpgradePathsResponse{
+		UpgradePaths: &[]cce_model.UpgradePath{
+			{
+				Version:        utils.Pointer("v1.25"),
+				TargetVersions: &[]string{"v1.27", "v1.28"},
+			},
+			{
+				Version:        utils.Pointer("v1.27"),
+				TargetVersions: &[]string{"v1.28", "v1.29"},
+			},
+		},
+	}, nil
+}
+
+func (m *mockClusterAPI) ResizeCluster(
+	req *cce_model.ResizeClusterRequest,
+) (*cce_model.ResizeClusterResponse, error) {
+	m.record("ResizeCluster %s %s", req.ClusterId, req.Body.FlavorResize)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &cce_model.ResizeClusterResponse{
+		JobID: utils.Pointer("mock-resize-job-id"),
+	}, nil
+}
+
+func (m *mockClusterAPI) ListNodePools(
+	req *cce_model.ListNodePoolsRequest,
+) (*cce_model.ListNodePoolsResponse, error) {
+	m.record("ListNodePools %s", req.ClusterId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	items := append([]cce_model.NodePoolResp{}, m.nodePools...)
+	return &cce_model.ListNodePoolsResponse{Items: &items}, nil
+}
+
+func (m *mockClusterAPI) ListNodes(
+	req *cce_model.ListNodesRequest,
+) (*cce_model.ListNodesResponse, error) {
+	m.record("ListNodes %s", req.ClusterId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	items := append([]cce_model.Node{}, m.nodes...)
+	return &cce_model.ListNodesResponse{Items: &items}, nil
+}
+
+func (m *mockClusterAPI) CreateNodePool(
+	req *cce_model.CreateNodePoolRequest,
+) (*cce_model.CreateNodePoolResponse, error) {
+	m.record("CreateNodePool %s", req.Body.Metadata.Name)
+	if m.err != nil {
+		return nil, m.err
+	}
+	metadata := *req.Body.Metadata
+	metadata.Uid = utils.Pointer("mock-" + metadata.Name + "-id")
+	return &cce_model.CreateNodePoolResponse{
+		Metadata: &metadata,
+		Spec:     req.Body.Spec,
+	}, nil
+}
+
+func (m *mockClusterAPI) UpdateNodePool(
+	req *cce_model.UpdateNodePoolRequest,
+) (*cce_model.UpdateNodePoolResponse, error) {
+	m.record("UpdateNodePool %s", req.NodepoolId)
+	return &cce_model.UpdateNodePoolResponse{}, m.err
+}
+
+func (m *mockClusterAPI) DeleteNodePool(
+	req *cce_model.DeleteNodePoolRequest,
+) (*cce_model.DeleteNodePoolResponse, error) {
+	m.record("DeleteNodePool %s", req.NodepoolId)
+	return &cce_model.DeleteNodePoolResponse{}, m.err
+}
+
+// mockNetworkAPI is a mock of the VPC, EIP, NAT and VPCEP APIs, the network
+// resources are stored by their IDs and deleted immediately.
+type mockNetworkAPI struct {
+	vpc.VpcAPI
+	eip.EipAPI
+	nat.NatAPI
+	vpcep.VpcepAPI
+	mockAPI
+
+	vpcs        map[string]bool
+	subnets     map[string]bool
+	eips        map[string]bool
+	natGateways map[string]bool
+	// snatRules is the map of SNAT rule ID to NAT gateway ID.
+	snatRules map[string]string
+	// vpcepServices is the map of VPC endpoint service ID to VPC ID.
+	vpcepServices map[string]string
+	// err is returned by all APIs if not nil.
+	err error
+}
+
+func newMockNetworkAPI() *mockNetworkAPI {
+	return &mockNetworkAPI{
+		vpcs:          map[string]bool{},
+		subnets:       map[string]bool{},
+		eips:          map[string]bool{},
+		natGateways:   map[string]bool{},
+		snatRules:     map[string]string{},
+		vpcepServices: map[string]string{},
+	}
+}
+
+func (m *mockNetworkAPI) ShowVpc(req *vpc_model.ShowVpcRequest) (*vpc_model.ShowVpcResponse, error) {
+	m.record("ShowVpc %s", req.VpcId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if !m.vpcs[req.VpcId] {
+		return nil, mockNotFoundError("VPC.0012")
+	}
+	return &vpc_model.ShowVpcResponse{Vpc: &vpc_model.Vpc{Id: req.VpcId}}, nil
+}
+
+func (m *mockNetworkAPI) DeleteVpc(req *vpc_model.DeleteVpcRequest) (*vpc_model.DeleteVpcResponse, error) {
+	m.record("DeleteVpc %s", req.VpcId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	delete(m.vpcs, req.VpcId)
+	return &vpc_model.DeleteVpcResponse{}, nil
+}
+
+func (m *mockNetworkAPI) ShowSubnet(req *vpc_model.ShowSubnetRequest) (*vpc_model.ShowSubnetResponse, error) {
+	m.record("ShowSubnet %s", req.SubnetId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if !m.subnets[req.SubnetId] {
+		return nil, mockNotFoundError("VPC.0202")
+	}
+	return &vpc_model.ShowSubnetResponse{Subnet: &vpc_model.Subnet{Id: req.SubnetId}}, nil
+}
+
+func (m *mockNetworkAPI) DeleteSubnet(req *vpc_model.DeleteSubnetRequest) (*vpc_model.DeleteSubnetResponse, error) {
+	m.record("DeleteSubnet %s", req.SubnetId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	delete(m.subnets, req.SubnetId)
+	return &vpc_model.DeleteSubnetResponse{}, nil
+}
+
+func (m *mockNetworkAPI) ShowPublicip(req *eip_model.ShowPublicipRequest) (*eip_model.ShowPublicipResponse, error) {
+	m.record("ShowPublicip %s", req.PublicipId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if !m.eips[req.PublicipId] {
+		return nil, mockNotFoundError("VPC.0504")
+	}
+	return &eip_model.ShowPublicipResponse{
+		Publicip: &eip_model.PublicipShowResp{Id: utils.Pointer(req.PublicipId)},
+	}, nil
+}
+
+func (m *mockNetworkAPI) DeletePublicip(
+	req *eip_model.DeletePublicipRequest,
+) (*eip_model.DeletePublicipResponse, error) {
+	m.record("DeletePublicip %s", req.PublicipId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	delete(m.eips, req.PublicipId)
+	return &eip_model.DeletePublicipResponse{}, nil
+}
+
+func (m *mockNetworkAPI) ShowNatGateway(
+	req *nat_model.ShowNatGatewayRequest,
+) (*nat_model.ShowNatGatewayResponse, error) {
+	m.record("ShowNatGateway %s", req.NatGatewayId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if !m.natGateways[req.NatGatewayId] {
+		return nil, mockNotFoundError("NAT.0201")
+	}
+	return &nat_model.ShowNatGatewayResponse{
+		NatGateway: &nat_model.NatGatewayResponseBody{Id: req.NatGatewayId},
+	}, nil
+}
+
+func (m *mockNetworkAPI) DeleteNatGateway(
+	req *nat_model.DeleteNatGatewayRequest,
+) (*nat_model.DeleteNatGatewayResponse, error) {
+	m.record("DeleteNatGateway %s", req.NatGatewayId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	delete(m.natGateways, req.NatGatewayId)
+	return &nat_model.DeleteNatGatewayResponse{}, nil
+}
+
+func (m *mockNetworkAPI) ListNatGatewaySnatRules(
+	req *nat_model.ListNatGatewaySnatRulesRequest,
+) (*nat_model.ListNatGatewaySnatRulesResponse, error) {
+	m.record("ListNatGatewaySnatRules %v", utils.Value(req.NatGatewayId))
+	if m.err != nil {
+		return nil, m.err
+	}
+	natIDs := map[string]bool{}
+	for _, id := range utils.Value(req.NatGatewayId) {
+		natIDs[id] = true
+	}
+	rules := []nat_model.NatGatewaySnatRuleResponseBody{}
+	for id, natID := range m.snatRules {
+		if natIDs[natID] {
+			rules = append(rules, nat_model.NatGatewaySnatRuleResponseBody{
+				Id:           id,
+				NatGatewayId: natID,
+			})
+		}
+	}
+	return &nat_model.ListNatGatewaySnatRulesResponse{SnatRules: &rules}, nil
+}
+
+func (m *mockNetworkAPI) DeleteNatGatewaySnatRule(
+	req *nat_model.DeleteNatGatewaySnatRuleRequest,
+) (*nat_model.DeleteNatGatewaySnatRuleResponse, error) {
+	m.record("DeleteNatGatewaySnatRule %s", req.SnatRuleId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	delete(m.snatRules, req.SnatRuleId)
+	return &nat_model.DeleteNatGatewaySnatRuleResponse{}, nil
+}
+
+func (m *mockNetworkAPI) ListEndpointService(
+	req *vpcep_model.ListEndpointServiceRequest,
+) (*vpcep_model.ListEndpointServiceResponse, error) {
+	m.record("ListEndpointService")
+	if m.err != nil {
+		return nil, m.err
+	}
+	services := []vpcep_model.ServiceList{}
+	for id, vpcID := range m.vpcepServices {
+		services = append(services, vpcep_model.ServiceList{
+			Id:    utils.Pointer(id),
+			VpcId: utils.Pointer(vpcID),
+		})
+	}
+	return &vpcep_model.ListEndpointServiceResponse{EndpointServices: &services}, nil
+}
+
+func (m *mockNetworkAPI) DeleteEndpointService(
+	req *vpcep_model.DeleteEndpointServiceRequest,
+) (*vpcep_model.DeleteEndpointServiceResponse, error) {
+	m.record("DeleteEndpointService %s", req.VpcEndpointServiceId)
+	if m.err != nil {
+		return nil, m.err
+	}
+	delete(m.vpcepServices, req.VpcEndpointServiceId)
+	return &vpcep_model.DeleteEndpointServiceResponse{}, nil
+}
+
+// newMockHandler returns the handler using the mock APIs as the driver of
+// the test credential secret.
+func newMockHandler(
+	configs *fakeStore[*ccev1.CCEClusterConfig, *ccev1.CCEClusterConfigList],
+	clusterAPI *mockClusterAPI, networkAPI *mockNetworkAPI,
+) (*Handler, *fakeQueue) {
+	queue := &fakeQueue{}
+	secrets := newFakeSecretStore()
+	driver := &HuaweiDriver{
+		CCE: clusterAPI,
+	}
+	if networkAPI != nil {
+		driver.VPC = networkAPI
+		driver.EIP = networkAPI
+		driver.NAT = networkAPI
+		driver.VPCEP = networkAPI
+	}
+	drivers := newDriverCache(driverCacheTTL)
+	drivers.set(&newTestConfig("").Spec, credentialVersion{}, time.Time{}, driver)
+	return &Handler{
+		cceCC:           configs,
+		cceEnqueue:      queue.enqueue,
+		cceEnqueueAfter: queue.enqueueAfter,
+		secrets:         secrets,
+		secretsCache:    secrets.cache(),
+		drivers:         drivers,
+		recorder:        record.NewFakeRecorder(100),
+	}, queue
+}
@@ -0,0 +1,348 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// defaultUnhealthyTimeout is the default minutes the node stays unhealthy
+	// before it is remediated.
+	defaultUnhealthyTimeout = 10
+	// maxNodeRemediationHistory is the count of the finished remediations
+	// kept in status.
+	maxNodeRemediationHistory = 10
+)
+
+// nodeRemediationDrainTimeout is the maximum time to drain the unhealthy
+// node, the node is deleted even if its pods are not evicted, since the pods
+// on the node not ready cannot be terminated.
+var nodeRemediationDrainTimeout = 10 * time.Minute
+
+// kubernetesNodesNotReady returns the reasons of the Kubernetes nodes which
+// are not ready, by the node name and the internal IP.
+func kubernetesNodesNotReady(nodes []corev1.Node) map[string]string {
+	notReady := map[string]string{}
+	for i := range nodes {
+		node := &nodes[i]
+		for _, c := range node.Status.Conditions {
+			if c.Type != corev1.NodeReady || c.Status == corev1.ConditionTrue {
+				continue
+			}
+			reason := fmt.Sprintf("Kubernetes node Ready condition is %s", c.Status)
+			if c.Reason != "" {
+				reason += fmt.Sprintf(" (%s)", c.Reason)
+			}
+			notReady[node.Name] = reason
+			for _, a := range node.Status.Addresses {
+				if a.Type == corev1.NodeInternalIP {
+					notReady[a.Address] = reason
+				}
+			}
+		}
+	}
+	return notReady
+}
+
+// nodeUnhealthyReason returns the reason if the CCE node is unhealthy. The
+// nodes being installed, upgraded or deleted are not considered unhealthy.
+func nodeUnhealthyReason(n *cce_model.Node, notReady map[string]string) string {
+	if n.Status == nil || n.Status.Phase == nil {
+		return ""
+	}
+	switch n.Status.Phase.Value() {
+	case cce_model.GetNodeStatusPhaseEnum().ABNORMAL.Value(),
+		cce_model.GetNodeStatusPhaseEnum().ERROR.Value():
+		return fmt.Sprintf("CCE node phase is %s", n.Status.Phase.Value())
+	case cce_model.GetNodeStatusPhaseEnum().ACTIVE.Value():
+		return notReady[utils.Value(n.Status.PrivateIP)]
+	}
+	return ""
+}
+
+// unhealthyTimeout returns the duration the node stays unhealthy before it
+// is remediated.
+func unhealthyTimeout(policy *ccev1.CCENodeHealthPolicy) time.Duration {
+	if policy.UnhealthyTimeout == 0 {
+		return defaultUnhealthyTimeout * time.Minute
+	}
+	return time.Duration(policy.UnhealthyTimeout) * time.Minute
+}
+
+// nodeRemediationDone returns true if the remediation is finished.
+func nodeRemediationDone(r *ccev1.CCENodeRemediation) bool {
+	return r.Phase == ccev1.NodeRemediationSucceeded
+}
+
+// trimNodeRemediations keeps the remediations in progress and the latest
+// finished remediations.
+func trimNodeRemediations(remediations []ccev1.CCENodeRemediation) []ccev1.CCENodeRemediation {
+	var (
+		trimmed []ccev1.CCENodeRemediation
+		done    int
+	)
+	for i := len(remediations) - 1; i >= 0; i-- {
+		if nodeRemediationDone(&remediations[i]) {
+			if done++; done > maxNodeRemediationHistory {
+				continue
+			}
+		}
+		trimmed = append([]ccev1.CCENodeRemediation{remediations[i]}, trimmed...)
+	}
+	return trimmed
+}
+
+// syncNodeHealth finds the unhealthy nodes of the node pools enabling the
+// health policy, the nodes unhealthy longer than the timeout are drained
+// and deleted, then the node pools create new nodes to keep the node count.
+func (h *Handler) syncNodeHealth(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, error) {
+	policies := map[string]*ccev1.CCENodePool{}
+	for i := range config.Spec.NodePools {
+		np := &config.Spec.NodePools[i]
+		if np.ID != "" && np.HealthPolicy.Enabled {
+			policies[np.ID] = np
+		}
+	}
+	remediations := append([]ccev1.CCENodeRemediation(nil), config.Status.NodeRemediations...)
+	remediating := map[string]bool{}
+	for i := range remediations {
+		if !nodeRemediationDone(&remediations[i]) {
+			remediating[remediations[i].NodeID] = true
+		}
+	}
+	if len(policies) == 0 && len(remediating) == 0 && len(config.Status.UnhealthyNodes) == 0 &&
+		meta.FindStatusCondition(config.Status.Conditions, ccev1.ConditionNodesHealthy) == nil {
+		return config, nil
+	}
+
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, err
+	}
+	nodes, err := cce.ListNodes(driver.CCE, config.Spec.ClusterID)
+	if err != nil {
+		return config, err
+	}
+	var notReady map[string]string
+	if len(policies) != 0 {
+		if client, err := h.getClusterClient(config); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Warnf("failed to get the client of cluster [%s], check the CCE node phase only: %v",
+				config.Spec.Name, err)
+		} else if nodeList, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{}); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Warnf("failed to list the nodes of cluster [%s], check the CCE node phase only: %v",
+				config.Spec.Name, err)
+		} else {
+			notReady = kubernetesNodesNotReady(nodeList.Items)
+		}
+	}
+	operating := map[string]bool{}
+	for _, op := range config.Status.NodeOperations {
+		if !nodeOperationDone(&op) {
+			operating[op.NodeID] = true
+		}
+	}
+
+	// Find the unhealthy nodes, the time the node was first found unhealthy
+	// is kept until the node is healthy.
+	now := metav1.Now()
+	previous := map[string]ccev1.CCEUnhealthyNode{}
+	for _, u := range config.Status.UnhealthyNodes {
+		previous[u.NodeID] = u
+	}
+	var unhealthy []ccev1.CCEUnhealthyNode
+	total := map[string]int{}
+	unhealthyCount := map[string]int{}
+	if nodes.Items != nil {
+		for _, n := range *nodes.Items {
+			if n.Metadata == nil {
+				continue
+			}
+			npID := n.Metadata.Annotations[cce.NodePoolIDAnnotationKey]
+			if policies[npID] == nil {
+				continue
+			}
+			total[npID]++
+			reason := nodeUnhealthyReason(&n, notReady)
+			if reason == "" {
+				continue
+			}
+			unhealthyCount[npID]++
+			u := ccev1.CCEUnhealthyNode{
+				NodeID:     utils.Value(n.Metadata.Uid),
+				Name:       utils.Value(n.Metadata.Name),
+				NodePoolID: npID,
+				Reason:     reason,
+				Since:      now,
+			}
+			if p, ok := previous[u.NodeID]; ok {
+				u.Since = p.Since
+			}
+			unhealthy = append(unhealthy, u)
+		}
+	}
+
+	// Start the remediation of the nodes unhealthy longer than the timeout,
+	// unless too many nodes of the node pool are unhealthy.
+	paused := map[string]string{}
+	for _, u := range unhealthy {
+		np := policies[u.NodePoolID]
+		if remediating[u.NodeID] || operating[u.NodeID] || now.Sub(u.Since.Time) < unhealthyTimeout(&np.HealthPolicy) ||
+			maintenanceDeferred(config) {
+			continue
+		}
+		limit := int(np.HealthPolicy.MaxUnhealthyPercentage)
+		if limit > 0 && unhealthyCount[u.NodePoolID]*100 > limit*total[u.NodePoolID] {
+			paused[np.Name] = fmt.Sprintf("%d/%d nodes of nodePool [%s] are unhealthy, exceeding maxUnhealthyPercentage %d%%",
+				unhealthyCount[u.NodePoolID], total[u.NodePoolID], np.Name, limit)
+			continue
+		}
+		remediating[u.NodeID] = true
+		remediations = append(remediations, ccev1.CCENodeRemediation{
+			NodeID:     u.NodeID,
+			Name:       u.Name,
+			NodePoolID: u.NodePoolID,
+			Reason:     u.Reason,
+			Phase:      ccev1.NodeRemediationDraining,
+			StartTime:  now,
+			Message:    fmt.Sprintf("draining node [%s] ID [%s]", u.Name, u.NodeID),
+		})
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Infof("start remediating node [%s] ID [%s] of nodePool [%s], unhealthy since %s: %s",
+			u.Name, u.NodeID, np.Name, u.Since.Format(time.RFC3339), u.Reason)
+		h.recorder.Eventf(config, corev1.EventTypeWarning, eventReasonUnhealthy,
+			"start remediating node [%s] ID [%s] of nodePool [%s], unhealthy since %s: %s",
+			u.Name, u.NodeID, np.Name, u.Since.Format(time.RFC3339), u.Reason)
+	}
+
+	for i := range remediations {
+		r := &remediations[i]
+		if nodeRemediationDone(r) {
+			continue
+		}
+		n := findNode(nodes, r.NodeID)
+		switch r.Phase {
+		case ccev1.NodeRemediationDraining:
+			if n == nil {
+				r.Phase = ccev1.NodeRemediationSucceeded
+				r.Message = fmt.Sprintf("node [%s] ID [%s] was deleted", r.Name, r.NodeID)
+				break
+			}
+			remaining, message, err := h.drainNodes(config, nodeAddresses(n), fmt.Sprintf("node [%s]", r.Name))
+			if err != nil {
+				// The pods on the unhealthy node may not be evicted.
+				logrus.WithFields(logrus.Fields{
+					"cluster": config.Name,
+					"phase":   config.Status.Phase,
+				}).Warnf("failed to drain node [%s] ID [%s]: %v", r.Name, r.NodeID, err)
+				remaining, message = 1, fmt.Sprintf("failed to drain node [%s]: %v", r.Name, err)
+			}
+			if remaining > 0 && now.Sub(r.StartTime.Time) < nodeRemediationDrainTimeout {
+				r.Message = message
+				break
+			}
+			if _, err := cce.DeleteNode(driver.CCE, config.Spec.ClusterID, r.NodeID, false); err != nil {
+				return config, err
+			}
+			r.Phase = ccev1.NodeRemediationDeleting
+			r.Message = fmt.Sprintf("deleting node [%s] ID [%s]", r.Name, r.NodeID)
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("request to delete unhealthy node [%s] ID [%s]", r.Name, r.NodeID)
+			h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+				"request to delete unhealthy node [%s] ID [%s]", r.Name, r.NodeID)
+		case ccev1.NodeRemediationDeleting:
+			if n != nil {
+				r.Message = fmt.Sprintf("waiting for node [%s] ID [%s] to be deleted", r.Name, r.NodeID)
+				break
+			}
+			r.Phase = ccev1.NodeRemediationSucceeded
+			r.Message = fmt.Sprintf("node [%s] ID [%s] is deleted, nodePool ID [%s] creates a new node",
+				r.Name, r.NodeID, r.NodePoolID)
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Info(r.Message)
+			h.recorder.Event(config, corev1.EventTypeNormal, eventReasonRemedied, r.Message)
+		}
+	}
+	remediations = trimNodeRemediations(remediations)
+
+	var (
+		status  = metav1.ConditionTrue
+		reason  = reasonNodesHealthy
+		message = "all nodes are healthy"
+		pending = len(unhealthy) != 0
+	)
+	if len(unhealthy) != 0 {
+		status, reason = metav1.ConditionFalse, reasonNodesUnhealthy
+		message = fmt.Sprintf("%d nodes are unhealthy", len(unhealthy))
+	}
+	if len(paused) != 0 {
+		reason = reasonRemediationPaused
+		var messages []string
+		for _, name := range sortedMapKeys(paused) {
+			messages = append(messages, paused[name])
+		}
+		message = strings.Join(messages, "; ")
+	}
+	for i := range remediations {
+		pending = pending || !nodeRemediationDone(&remediations[i])
+	}
+	sort.SliceStable(unhealthy, func(i, j int) bool { return unhealthy[i].Name < unhealthy[j].Name })
+
+	var changed bool
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.UnhealthyNodes = unhealthy
+		configUpdate.Status.NodeRemediations = remediations
+		changed = false
+		if len(policies) == 0 {
+			meta.RemoveStatusCondition(&configUpdate.Status.Conditions, ccev1.ConditionNodesHealthy)
+		} else {
+			changed = setCondition(configUpdate, ccev1.ConditionNodesHealthy, status, reason, message)
+		}
+		if !changed && reflect.DeepEqual(config.Status, configUpdate.Status) {
+			return nil
+		}
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	if err != nil {
+		return config, err
+	}
+	if changed && reason == reasonRemediationPaused {
+		h.recorder.Event(config, corev1.EventTypeWarning, eventReasonUnhealthy,
+			"remediation is paused: "+message)
+	}
+	if pending {
+		h.cceEnqueueAfter(config.Namespace, config.Name, 30*time.Second)
+	}
+	return config, nil
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_nodeUnhealthyReason(t *testing.T) {
+	assert := assert.New(t)
+	notReady := kubernetesNodesNotReady([]corev1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionUnknown, Reason: "NodeStatusUnknown"},
+				},
+				Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.224.0.11"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "10.224.0.12"},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		},
+		{
+			// The node without Ready condition is not considered unhealthy.
+			ObjectMeta: metav1.ObjectMeta{Name: "10.224.0.13"},
+		},
+	})
+	assert.Equal(map[string]string{
+		"node-1":      "Kubernetes node Ready condition is Unknown (NodeStatusUnknown)",
+		"10.224.0.11": "Kubernetes node Ready condition is Unknown (NodeStatusUnknown)",
+	}, notReady)
+
+	phases := cce_model.GetNodeStatusPhaseEnum()
+	for _, c := range []struct {
+		phase cce_model.NodeStatusPhase
+		ip    string
+		want  string
+	}{
+		{phases.ACTIVE, "10.224.0.11", "Kubernetes node Ready condition is Unknown (NodeStatusUnknown)"},
+		{phases.ACTIVE, "10.224.0.12", ""},
+		{phases.ABNORMAL, "10.224.0.12", "CCE node phase is Abnormal"},
+		{phases.ERROR, "10.224.0.13", "CCE node phase is Error"},
+		{phases.INSTALLING, "10.224.0.11", ""},
+		{phases.DELETING, "10.224.0.11", ""},
+	} {
+		phase := c.phase
+		n := &cce_model.Node{Status: &cce_model.NodeStatus{Phase: &phase, PrivateIP: utils.Pointer(c.ip)}}
+		assert.Equal(c.want, nodeUnhealthyReason(n, notReady), phase.Value())
+	}
+}
+
+func Test_trimNodeRemediations(t *testing.T) {
+	assert := assert.New(t)
+	var remediations []ccev1.CCENodeRemediation
+	for i := 0; i < maxNodeRemediationHistory+2; i++ {
+		remediations = append(remediations, ccev1.CCENodeRemediation{
+			NodeID: utils.RandomHex(5),
+			Phase:  ccev1.NodeRemediationSucceeded,
+		})
+	}
+	remediations[0].Phase = ccev1.NodeRemediationDeleting
+
+	trimmed := trimNodeRemediations(remediations)
+	if assert.Len(trimmed, maxNodeRemediationHistory+1) {
+		// The remediation in progress and the latest finished ones are kept.
+		assert.Equal(remediations[0], trimmed[0])
+		assert.Equal(remediations[2:], trimmed[1:])
+	}
+}
@@ -0,0 +1,339 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei"
+	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
+	"github.com/cnrancher/cce-operator/pkg/huawei/ecs"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	ecs_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// nodeOperationKey returns the key identifying the node operation, the same
+// action on the same node is executed only once.
+func nodeOperationKey(node, action string) string {
+	return action + "/" + node
+}
+
+// findNode returns the CCE node whose name, ID or private IP is ref.
+func findNode(nodes *cce_model.ListNodesResponse, ref string) *cce_model.Node {
+	if nodes == nil || nodes.Items == nil {
+		return nil
+	}
+	for i := range *nodes.Items {
+		n := &(*nodes.Items)[i]
+		if n.Metadata == nil || n.Status == nil {
+			continue
+		}
+		if utils.Value(n.Metadata.Name) == ref || utils.Value(n.Metadata.Uid) == ref || utils.Value(n.Status.PrivateIP) == ref {
+			return n
+		}
+	}
+	return nil
+}
+
+// nodeAddresses returns the addresses matching the Kubernetes node of the
+// CCE node.
+func nodeAddresses(n *cce_model.Node) map[string]bool {
+	addresses := map[string]bool{}
+	if ip := utils.Value(n.Status.PrivateIP); ip != "" {
+		addresses[ip] = true
+	}
+	return addresses
+}
+
+// nodeOperationDone returns true if the node operation is finished.
+func nodeOperationDone(op *ccev1.CCENodeOperationStatus) bool {
+	return op.Phase == ccev1.NodeOperationSucceeded || op.Phase == ccev1.NodeOperationFailed
+}
+
+// syncNodeOperations executes the node operations in spec and reports their
+// outcome in status. The nodes are drained before they are deleted or
+// rebooted. Returns true if any node is being drained or deleted, the node
+// pools should not be scaled until the nodes are deleted.
+func (h *Handler) syncNodeOperations(config *ccev1.CCEClusterConfig) (*ccev1.CCEClusterConfig, bool, error) {
+	if len(config.Spec.NodeOperations) == 0 && len(config.Status.NodeOperations) == 0 {
+		return config, false, nil
+	}
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
+	existing := make(map[string]ccev1.CCENodeOperationStatus, len(config.Status.NodeOperations))
+	for _, op := range config.Status.NodeOperations {
+		existing[nodeOperationKey(op.Node, op.Action)] = op
+	}
+
+	var (
+		operations []ccev1.CCENodeOperationStatus
+		nodes      *cce_model.ListNodesResponse
+	)
+	for _, spec := range config.Spec.NodeOperations {
+		op, ok := existing[nodeOperationKey(spec.Node, spec.Action)]
+		if !ok && maintenanceDeferred(config) {
+			// Start the operation in the maintenance window.
+			continue
+		}
+		if !ok {
+			op = ccev1.CCENodeOperationStatus{
+				Node:   spec.Node,
+				Action: spec.Action,
+			}
+		}
+		if nodeOperationDone(&op) {
+			operations = append(operations, op)
+			continue
+		}
+		if nodes == nil {
+			if nodes, err = cce.ListNodes(driver.CCE, config.Spec.ClusterID); err != nil {
+				return config, false, err
+			}
+		}
+		if op.NodeID == "" {
+			n := findNode(nodes, op.Node)
+			if n == nil {
+				h.finishNodeOperation(config, &op, ccev1.NodeOperationFailed,
+					fmt.Sprintf("node [%s] not found", op.Node))
+				operations = append(operations, op)
+				continue
+			}
+			op.NodeID = utils.Value(n.Metadata.Uid)
+			op.NodePoolID = n.Metadata.Annotations[cce.NodePoolIDAnnotationKey]
+			op.Phase = ccev1.NodeOperationDraining
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("start draining node [%s] ID [%s] to %s", op.Node, op.NodeID, strings.ToLower(op.Action))
+			h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDraining,
+				"start draining node [%s] ID [%s] to %s", op.Node, op.NodeID, strings.ToLower(op.Action))
+		}
+		n := findNode(nodes, op.NodeID)
+		switch op.Phase {
+		case ccev1.NodeOperationDraining:
+			if n == nil {
+				h.finishNodeOperation(config, &op, ccev1.NodeOperationFailed,
+					fmt.Sprintf("node [%s] ID [%s] was deleted", op.Node, op.NodeID))
+				break
+			}
+			remaining, message, err := h.drainNodes(config, nodeAddresses(n), fmt.Sprintf("node [%s]", op.Node))
+			if err != nil {
+				return config, false, err
+			}
+			if remaining > 0 {
+				op.Message = message
+				break
+			}
+			if op.Action == ccev1.NodeActionReboot {
+				if utils.Value(n.Status.ServerId) == "" {
+					h.finishNodeOperation(config, &op, ccev1.NodeOperationFailed,
+						fmt.Sprintf("server ID of node [%s] ID [%s] not found", op.Node, op.NodeID))
+					break
+				}
+				res, err := ecs.RebootServer(driver.ECS, utils.Value(n.Status.ServerId))
+				if err != nil {
+					return config, false, err
+				}
+				op.JobID = utils.Value(res.JobId)
+				op.Phase = ccev1.NodeOperationRebooting
+				op.Message = fmt.Sprintf("rebooting node [%s] ID [%s], job ID [%s]", op.Node, op.NodeID, op.JobID)
+				h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonRebooting,
+					"request to reboot node [%s] ID [%s]", op.Node, op.NodeID)
+			} else {
+				// The node count of the node pool is decreased when deleting
+				// the node, the replaced node is recreated by the node pool.
+				scaleDown := op.Action == ccev1.NodeActionDelete
+				if _, err := cce.DeleteNode(driver.CCE, config.Spec.ClusterID, op.NodeID, scaleDown); err != nil {
+					return config, false, err
+				}
+				op.Phase = ccev1.NodeOperationDeleting
+				op.Message = fmt.Sprintf("deleting node [%s] ID [%s]", op.Node, op.NodeID)
+				h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+					"request to delete node [%s] ID [%s]", op.Node, op.NodeID)
+			}
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Info(op.Message)
+		case ccev1.NodeOperationDeleting:
+			if n != nil {
+				op.Message = fmt.Sprintf("waiting for node [%s] ID [%s] to be deleted", op.Node, op.NodeID)
+				break
+			}
+			if op.Action == ccev1.NodeActionDelete {
+				if err := h.scaleDownNodePool(config, op.NodePoolID); err != nil {
+					return config, false, err
+				}
+				h.finishNodeOperation(config, &op, ccev1.NodeOperationSucceeded,
+					fmt.Sprintf("node [%s] ID [%s] is deleted", op.Node, op.NodeID))
+				break
+			}
+			// The node pool creates a new node to restore the node count.
+			op.Phase = ccev1.NodeOperationRecreating
+			op.Message = fmt.Sprintf("waiting for nodePool ID [%s] to create a new node", op.NodePoolID)
+		case ccev1.NodeOperationRecreating:
+			var np *ccev1.CCENodePool
+			for i := range config.Spec.NodePools {
+				if config.Spec.NodePools[i].ID == op.NodePoolID {
+					np = &config.Spec.NodePools[i]
+				}
+			}
+			if np != nil && !np.Autoscaling.Enable {
+				if running := runningNodeCount(nodes)[op.NodePoolID]; running < int(np.InitialNodeCount) {
+					op.Message = fmt.Sprintf("waiting for nodePool [%s] ID [%s] nodes, %d/%d running",
+						np.Name, np.ID, running, np.InitialNodeCount)
+					break
+				}
+			}
+			h.finishNodeOperation(config, &op, ccev1.NodeOperationSucceeded,
+				fmt.Sprintf("node [%s] ID [%s] is replaced", op.Node, op.NodeID))
+		case ccev1.NodeOperationRebooting:
+			res, err := ecs.ShowJob(driver.ECS, op.JobID)
+			if err != nil {
+				return config, false, err
+			}
+			var status string
+			if res.Status != nil {
+				status = res.Status.Value()
+			}
+			switch status {
+			case ecs_model.GetShowJobResponseStatusEnum().SUCCESS.Value():
+				if n != nil {
+					if err := h.uncordonNodes(config, nodeAddresses(n)); err != nil {
+						return config, false, err
+					}
+				}
+				h.finishNodeOperation(config, &op, ccev1.NodeOperationSucceeded,
+					fmt.Sprintf("node [%s] ID [%s] is rebooted", op.Node, op.NodeID))
+			case ecs_model.GetShowJobResponseStatusEnum().FAIL.Value():
+				// Keep the node cordoned, the node may be unhealthy.
+				h.finishNodeOperation(config, &op, ccev1.NodeOperationFailed,
+					fmt.Sprintf("failed to reboot node [%s] ID [%s]: %s",
+						op.Node, op.NodeID, utils.Value(res.FailReason)))
+			default:
+				op.Message = fmt.Sprintf("waiting for node [%s] ID [%s] to reboot, job ID [%s]",
+					op.Node, op.NodeID, op.JobID)
+			}
+		}
+		operations = append(operations, op)
+	}
+
+	var (
+		busy     bool
+		pending  bool
+		messages []string
+	)
+	for i := range operations {
+		op := &operations[i]
+		if nodeOperationDone(op) {
+			continue
+		}
+		pending = true
+		if op.Phase == ccev1.NodeOperationDraining || op.Phase == ccev1.NodeOperationDeleting {
+			busy = true
+			messages = append(messages, fmt.Sprintf("node [%s] %s: %s", op.Node, op.Phase, op.Message))
+		}
+	}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.NodeOperations = operations
+		changed := !reflect.DeepEqual(config.Status.NodeOperations, operations)
+		if busy {
+			configUpdate.Status.Phase = cceConfigUpdatingPhase
+			changed = setCondition(configUpdate, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse,
+				reasonNodeOperating, strings.Join(messages, "; ")) || changed ||
+				config.Status.Phase != cceConfigUpdatingPhase
+		}
+		if !changed {
+			return nil
+		}
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	if err != nil {
+		return config, false, err
+	}
+	if pending {
+		h.cceEnqueueAfter(config.Namespace, config.Name, 30*time.Second)
+	}
+	return config, busy, nil
+}
+
+// scaleDownNodePool lowers the node count of the node pool in spec to the
+// upstream node count after its node is deleted, otherwise the node pool is
+// scaled up to the node count in spec again.
+func (h *Handler) scaleDownNodePool(config *ccev1.CCEClusterConfig, nodePoolID string) error {
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return err
+	}
+	res, err := cce.ShowNodePool(driver.CCE, config.Spec.ClusterID, nodePoolID)
+	if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if res.Spec == nil {
+		return nil
+	}
+	count := utils.Value(res.Spec.InitialNodeCount)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err := h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		for i := range configUpdate.Spec.NodePools {
+			np := &configUpdate.Spec.NodePools[i]
+			if np.ID != nodePoolID || np.InitialNodeCount <= count {
+				continue
+			}
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("scale down nodePool [%s] ID [%s] from %d to %d nodes",
+				np.Name, np.ID, np.InitialNodeCount, count)
+			np.InitialNodeCount = count
+			_, err = h.cceCC.Update(configUpdate)
+			return err
+		}
+		return nil
+	})
+}
+
+// finishNodeOperation sets the final phase of the node operation and
+// reports the outcome by event.
+func (h *Handler) finishNodeOperation(
+	config *ccev1.CCEClusterConfig, op *ccev1.CCENodeOperationStatus, phase, message string,
+) {
+	op.Phase = phase
+	op.Message = message
+	if phase == ccev1.NodeOperationFailed {
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Warnf("node operation %s [%s] failed: %s", op.Action, op.Node, message)
+		h.recorder.Eventf(config, corev1.EventTypeWarning, eventReasonFailed,
+			"node operation %s [%s] failed: %s", op.Action, op.Node, message)
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"cluster": config.Name,
+		"phase":   config.Status.Phase,
+	}).Infof("node operation %s [%s] succeeded: %s", op.Action, op.Node, message)
+	h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonSucceeded,
+		"node operation %s [%s] succeeded: %s", op.Action, op.Node, message)
+}
@@ -0,0 +1,513 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei"
+	"github.com/cnrancher/cce-operator/pkg/huawei/common"
+	"github.com/cnrancher/cce-operator/pkg/huawei/eip"
+	"github.com/cnrancher/cce-operator/pkg/huawei/nat"
+	"github.com/cnrancher/cce-operator/pkg/huawei/vpc"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	nat_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/nat/v2/model"
+	vpc_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+)
+
+// Kinds of the tagged resources, in the order the orphans are deleted.
+const (
+	orphanKindNatGateway = "natGateway"
+	orphanKindEIP        = "eip"
+	orphanKindSubnet     = "subnet"
+	orphanKindVPC        = "vpc"
+)
+
+var orphanKinds = []string{orphanKindNatGateway, orphanKindEIP, orphanKindSubnet, orphanKindVPC}
+
+// CredentialRegionsConfigMap is the ConfigMap in the operator namespace
+// recording the regions the credential secrets were used in, the orphan
+// resources are scanned in the regions even if no config uses the credential
+// any more.
+const CredentialRegionsConfigMap = "cce-operator-credential-regions"
+
+// credentialRegionsKey returns the key of the credential secret in the data
+// of the ConfigMap, the namespace and name of the secret cannot contain "_".
+func credentialRegionsKey(ns, name string) string {
+	return ns + "_" + name
+}
+
+// credentialRegions returns the regions recorded in the ConfigMap data.
+func credentialRegions(value string) map[string]bool {
+	regions := map[string]bool{}
+	for _, region := range strings.Split(value, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			regions[region] = true
+		}
+	}
+	return regions
+}
+
+// recordCredentialRegion adds the region of the config spec to the regions
+// of the credential secret recorded in the ConfigMap.
+func (h *Handler) recordCredentialRegion(spec *ccev1.CCEClusterConfigSpec) error {
+	if h.configMaps == nil || spec.RegionID == "" {
+		return nil
+	}
+	ctx := context.TODO()
+	key := credentialRegionsKey(utils.Parse(spec.HuaweiCredentialSecret))
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := h.configMaps.Get(ctx, CredentialRegionsConfigMap, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = h.configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: CredentialRegionsConfigMap},
+				Data:       map[string]string{key: spec.RegionID},
+			}, metav1.CreateOptions{})
+			return err
+		} else if err != nil {
+			return err
+		}
+		regions := credentialRegions(cm.Data[key])
+		if regions[spec.RegionID] {
+			return nil
+		}
+		regions[spec.RegionID] = true
+		list := make([]string, 0, len(regions))
+		for region := range regions {
+			list = append(list, region)
+		}
+		sort.Strings(list)
+		cm = cm.DeepCopy()
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = strings.Join(list, ",")
+		_, err = h.configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// resourceOwner returns the owner tagged to the resources created for the
+// config, nil if the config has no UID.
+func (h *Handler) resourceOwner(config *ccev1.CCEClusterConfig) *common.Owner {
+	if config.UID == "" {
+		return nil
+	}
+	return &common.Owner{
+		Instance:  h.instanceID,
+		Namespace: config.Namespace,
+		Name:      config.Name,
+		UID:       string(config.UID),
+	}
+}
+
+// tagCreatedResources tags the EIPs and NAT Gateway created for the config,
+// which cannot be tagged by the create requests. The resources are recorded
+// in status before tagged, so the tagging is retried by the next reconcile if
+// failed.
+func (h *Handler) tagCreatedResources(config *ccev1.CCEClusterConfig) error {
+	owner := h.resourceOwner(config)
+	status := &config.Status
+	if owner == nil || (status.CreatedClusterEIPID == "" && status.CreatedSNatRuleEIPID == "" &&
+		status.CreatedNatGatewayID == "") {
+		return nil
+	}
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return err
+	}
+	tags := owner.Tags()
+	for _, id := range []string{status.CreatedClusterEIPID, status.CreatedSNatRuleEIPID} {
+		if id == "" {
+			continue
+		}
+		if err := eip.TagPublicIP(driver.EIP, id, tags); err != nil {
+			return fmt.Errorf("failed to tag EIP [%s]: %w", id, err)
+		}
+	}
+	if id := status.CreatedNatGatewayID; id != "" {
+		if err := nat.TagNatGateway(driver.NAT, id, tags); err != nil {
+			return fmt.Errorf("failed to tag NAT Gateway [%s]: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// retainResources tags the network resources created for the config as
+// retained by the deletion policy, so they are not collected as orphans after
+// the config is deleted.
+func (h *Handler) retainResources(config *ccev1.CCEClusterConfig) error {
+	status := &config.Status
+	if status.CreatedVpcID == "" && status.CreatedSubnetID == "" && status.CreatedNatGatewayID == "" &&
+		status.CreatedClusterEIPID == "" && status.CreatedSNatRuleEIPID == "" {
+		return nil
+	}
+	driver, err := h.setupHuaweiDriver(&config.Spec)
+	if err != nil {
+		return err
+	}
+	tags := []common.Tag{{Key: common.TagRetained, Value: "true"}}
+	resources := []struct {
+		id  string
+		tag func(id string) error
+	}{
+		{status.CreatedNatGatewayID, func(id string) error { return nat.TagNatGateway(driver.NAT, id, tags) }},
+		{status.CreatedClusterEIPID, func(id string) error { return eip.TagPublicIP(driver.EIP, id, tags) }},
+		{status.CreatedSNatRuleEIPID, func(id string) error { return eip.TagPublicIP(driver.EIP, id, tags) }},
+		{status.CreatedSubnetID, func(id string) error { return vpc.TagSubnet(driver.VPC, id, tags) }},
+		{status.CreatedVpcID, func(id string) error { return vpc.TagVPC(driver.VPC, id, tags) }},
+	}
+	for _, r := range resources {
+		if r.id == "" {
+			continue
+		}
+		err := r.tag(r.id)
+		if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode == 404 {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to tag retained resource [%s]: %w", r.id, err)
+		}
+	}
+	return nil
+}
+
+// OrphanGCOptions are the options of the garbage collector of the resources
+// whose CCEClusterConfig no longer exists.
+type OrphanGCOptions struct {
+	Interval    time.Duration // interval of the scans, the collector is disabled if zero
+	GracePeriod time.Duration // how long the resource is found orphaned before deleted
+	DryRun      bool          // report the orphan resources without deleting them
+}
+
+// orphanResource is a resource tagged with the UID of a config which no
+// longer exists and not referenced by any other config.
+type orphanResource struct {
+	kind   string
+	id     string
+	name   string
+	owner  common.Owner
+	driver *HuaweiDriver
+}
+
+func (r *orphanResource) key() string {
+	return r.kind + "/" + r.id
+}
+
+// orphanGC lists the tagged resources in the regions of the cached drivers,
+// and deletes the resources whose owner config no longer exists.
+type orphanGC struct {
+	h    *Handler
+	opts OrphanGCOptions
+
+	// firstSeen is the time the orphan resources were first found by key.
+	firstSeen map[string]time.Time
+}
+
+func newOrphanGC(h *Handler, opts OrphanGCOptions) *orphanGC {
+	return &orphanGC{
+		h:         h,
+		opts:      opts,
+		firstSeen: map[string]time.Time{},
+	}
+}
+
+// RunOrphanGC scans the orphan resources periodically until ctx is done, it
+// returns immediately if the interval is zero.
+func (h *Handler) RunOrphanGC(ctx context.Context, opts OrphanGCOptions) {
+	if opts.Interval <= 0 {
+		return
+	}
+	if h.instanceID == "" {
+		logrus.Warnf("orphan resource collector is disabled, the operator instance ID is unknown")
+		return
+	}
+	logrus.Infof("orphan resource collector started, interval [%v] grace period [%v] dry-run [%v]",
+		opts.Interval, opts.GracePeriod, opts.DryRun)
+	gc := newOrphanGC(h, opts)
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := gc.scan(); err != nil {
+				logrus.Warnf("failed to scan orphan resources: %v", err)
+			}
+		}
+	}
+}
+
+// scan finds the orphan resources and deletes the ones orphaned longer than
+// the grace period unless in dry-run mode.
+func (gc *orphanGC) scan() error {
+	configs, err := gc.h.cceCC.List("", metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	// The network resources may be shared by the configs. The resources of
+	// the existing configs are kept even if not recorded in the status yet,
+	// and the resources of the configs being deleted are cleaned up by the
+	// deletion.
+	referenced := map[string]bool{}
+	owners := map[string]bool{}
+	for i := range configs.Items {
+		owners[string(configs.Items[i].UID)] = true
+		for id := range referencedResources(&configs.Items[i]) {
+			referenced[id] = true
+		}
+	}
+	drivers, err := gc.drivers()
+	if err != nil {
+		return err
+	}
+
+	orphans := gc.list(drivers, owners, referenced)
+	counts := map[string]int{}
+	now := time.Now()
+	found := map[string]bool{}
+	for _, r := range orphans {
+		counts[r.kind]++
+		found[r.key()] = true
+		if _, ok := gc.firstSeen[r.key()]; !ok {
+			gc.firstSeen[r.key()] = now
+			logrus.Warnf("found orphan %s [%s] ID [%s] of config [%s/%s] UID [%s]",
+				r.kind, r.name, r.id, r.owner.Namespace, r.owner.Name, r.owner.UID)
+		}
+	}
+	for key := range gc.firstSeen {
+		if !found[key] {
+			delete(gc.firstSeen, key)
+		}
+	}
+	for _, kind := range orphanKinds {
+		metrics.SetOrphanResources(kind, counts[kind])
+	}
+	if gc.opts.DryRun {
+		return nil
+	}
+
+	for _, r := range orphans {
+		if now.Sub(gc.firstSeen[r.key()]) < gc.opts.GracePeriod {
+			continue
+		}
+		if err := gc.delete(r); err != nil {
+			logrus.Warnf("failed to delete orphan %s [%s] ID [%s]: %v", r.kind, r.name, r.id, err)
+		}
+	}
+	return nil
+}
+
+// referencedResources returns the IDs of the network resources used by the
+// config.
+func referencedResources(config *ccev1.CCEClusterConfig) map[string]bool {
+	ids := map[string]bool{}
+	for _, id := range []string{
+		config.Spec.HostNetwork.VpcID,
+		config.Spec.HostNetwork.SubnetID,
+		config.Status.CreatedVpcID,
+		config.Status.CreatedSubnetID,
+		config.Status.CreatedNatGatewayID,
+		config.Status.CreatedClusterEIPID,
+		config.Status.CreatedSNatRuleEIPID,
+	} {
+		if id != "" {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// drivers returns the drivers of the regions recorded for the existing
+// credential secrets.
+func (gc *orphanGC) drivers() (map[driverKey]*HuaweiDriver, error) {
+	drivers := map[driverKey]*HuaweiDriver{}
+	if gc.h.configMaps == nil {
+		return drivers, nil
+	}
+	cm, err := gc.h.configMaps.Get(context.TODO(), CredentialRegionsConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return drivers, nil
+	} else if err != nil {
+		return nil, err
+	}
+	secrets, err := gc.h.secretsCache.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets {
+		for region := range credentialRegions(cm.Data[credentialRegionsKey(secret.Namespace, secret.Name)]) {
+			spec := &ccev1.CCEClusterConfigSpec{
+				HuaweiCredentialSecret: secret.Namespace + ":" + secret.Name,
+				RegionID:               region,
+			}
+			driver, err := gc.h.setupHuaweiDriver(spec)
+			if err != nil {
+				logrus.Warnf("failed to scan orphan resources of credential [%s] in region [%s]: %v",
+					spec.HuaweiCredentialSecret, region, err)
+				continue
+			}
+			drivers[driverKey{spec.HuaweiCredentialSecret, region}] = driver
+		}
+	}
+	return drivers, nil
+}
+
+// list returns the resources tagged by the operator instance whose owner
+// config no longer exists and not referenced by any config, sorted by the
+// deletion order of the kinds.
+func (gc *orphanGC) list(
+	drivers map[driverKey]*HuaweiDriver, owners, referenced map[string]bool,
+) []*orphanResource {
+	var orphans []*orphanResource
+	seen := map[string]bool{}
+	add := func(kind, id, name string, tags map[string]string, driver *HuaweiDriver) {
+		r := &orphanResource{
+			kind: kind,
+			id:   id,
+			name: name,
+			owner: common.Owner{
+				Namespace: tags[common.TagOwnerNamespace],
+				Name:      tags[common.TagOwnerName],
+				UID:       tags[common.TagOwnerUID],
+			},
+			driver: driver,
+		}
+		// The drivers of different credentials may access the same account.
+		if tags[common.TagInstance] != gc.h.instanceID || r.owner.UID == "" || owners[r.owner.UID] ||
+			tags[common.TagRetained] != "" || referenced[r.id] || seen[r.key()] {
+			return
+		}
+		seen[r.key()] = true
+		orphans = append(orphans, r)
+	}
+
+	for key, driver := range drivers {
+		if err := gc.listRegion(driver, add); err != nil {
+			logrus.Warnf("failed to scan orphan resources in region [%s]: %v", key.region, err)
+		}
+	}
+
+	order := map[string]int{}
+	for i, kind := range orphanKinds {
+		order[kind] = i
+	}
+	sort.SliceStable(orphans, func(i, j int) bool {
+		return order[orphans[i].kind] < order[orphans[j].kind]
+	})
+	return orphans
+}
+
+// listRegion lists the resources tagged by the operator instance in the
+// region of the driver.
+func (gc *orphanGC) listRegion(
+	driver *HuaweiDriver, add func(kind, id, name string, tags map[string]string, driver *HuaweiDriver),
+) error {
+	nats, err := nat.ListNatGatewaysByTag(driver.NAT, common.TagInstance, gc.h.instanceID)
+	if err != nil {
+		return fmt.Errorf("failed to list NAT Gateways: %w", err)
+	}
+	for _, r := range nats {
+		tags := map[string]string{}
+		for _, tag := range r.Tags {
+			tags[tag.Key] = tag.Value
+		}
+		add(orphanKindNatGateway, r.ResourceId, r.ResourceName, tags, driver)
+	}
+	eips, err := eip.ListPublicIPsByTag(driver.EIP, common.TagInstance, gc.h.instanceID)
+	if err != nil {
+		return fmt.Errorf("failed to list EIPs: %w", err)
+	}
+	for _, r := range eips {
+		tags := map[string]string{}
+		if r.Tags != nil {
+			for _, tag := range *r.Tags {
+				tags[utils.Value(tag.Key)] = utils.Value(tag.Value)
+			}
+		}
+		add(orphanKindEIP, utils.Value(r.ResourceId), utils.Value(r.ResourceName), tags, driver)
+	}
+	subnets, err := vpc.ListSubnetsByTag(driver.VPC, common.TagInstance, gc.h.instanceID)
+	if err != nil {
+		return fmt.Errorf("failed to list subnets: %w", err)
+	}
+	for _, r := range subnets {
+		tags := map[string]string{}
+		if r.Tags != nil {
+			for _, tag := range *r.Tags {
+				tags[tag.Key] = tag.Value
+			}
+		}
+		add(orphanKindSubnet, r.ResourceId, r.ResourceName, tags, driver)
+	}
+	vpcs, err := vpc.ListVPCsByTag(driver.VPC, common.TagInstance, gc.h.instanceID)
+	if err != nil {
+		return fmt.Errorf("failed to list VPCs: %w", err)
+	}
+	for _, r := range vpcs {
+		tags := map[string]string{}
+		if r.Tags != nil {
+			for _, tag := range *r.Tags {
+				tags[tag.Key] = tag.Value
+			}
+		}
+		add(orphanKindVPC, r.ResourceId, r.ResourceName, tags, driver)
+	}
+	return nil
+}
+
+// delete requests to delete the orphan resource, the resources depending on
+// it are deleted first so it may be deleted in the later scans.
+func (gc *orphanGC) delete(r *orphanResource) error {
+	var err error
+	switch r.kind {
+	case orphanKindNatGateway:
+		var rules *nat_model.ListNatGatewaySnatRulesResponse
+		if rules, err = nat.ListNatGatewaySnatRules(r.driver.NAT, []string{r.id}); err != nil {
+			return err
+		}
+		if rules.SnatRules != nil && len(*rules.SnatRules) > 0 {
+			for _, rule := range *rules.SnatRules {
+				_, err = nat.DeleteNatGatewaySnatRule(r.driver.NAT, rule.Id, r.id)
+				metrics.ObserveCleanup("snatRule", err)
+				if err != nil {
+					return err
+				}
+				logrus.Infof("request to delete SNAT Rule [%s] of orphan NAT Gateway [%s]", rule.Id, r.id)
+			}
+			return nil
+		}
+		_, err = nat.DeleteNatGateway(r.driver.NAT, r.id)
+	case orphanKindEIP:
+		_, err = eip.DeletePublicIP(r.driver.EIP, r.id)
+	case orphanKindSubnet:
+		var subnet *vpc_model.ShowSubnetResponse
+		if subnet, err = vpc.ShowSubnet(r.driver.VPC, r.id); err != nil {
+			return err
+		}
+		if subnet.Subnet == nil {
+			return fmt.Errorf("ShowSubnet returns invalid data")
+		}
+		_, err = vpc.DeleteSubnet(r.driver.VPC, subnet.Subnet.VpcId, r.id)
+	case orphanKindVPC:
+		_, err = vpc.DeleteVPC(r.driver.VPC, r.id)
+	}
+	metrics.ObserveCleanup(r.kind, err)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("request to delete orphan %s [%s] ID [%s] of config [%s/%s]",
+		r.kind, r.name, r.id, r.owner.Namespace, r.owner.Name)
+	return nil
+}
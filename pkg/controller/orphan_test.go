@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/common"
+	"github.com/cnrancher/cce-operator/pkg/huawei/eip"
+	"github.com/cnrancher/cce-operator/pkg/huawei/fake"
+	"github.com/cnrancher/cce-operator/pkg/huawei/nat"
+	"github.com/cnrancher/cce-operator/pkg/huawei/vpc"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_Handler_resourceOwner(t *testing.T) {
+	assert := assert.New(t)
+	h := &Handler{instanceID: "instance-1"}
+
+	config := newTestConfig("c-abcde")
+	assert.Nil(h.resourceOwner(config))
+	config.UID = "uid-1"
+	assert.Equal(&common.Owner{
+		Instance:  "instance-1",
+		Namespace: testNamespace,
+		Name:      "c-abcde",
+		UID:       "uid-1",
+	}, h.resourceOwner(config))
+}
+
+func Test_Handler_recordCredentialRegion(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	config := newTestConfig("cce-test")
+	assert.Nil(e.handler.recordCredentialRegion(&config.Spec))
+	config.Spec.RegionID = "cn-east-3"
+	assert.Nil(e.handler.recordCredentialRegion(&config.Spec))
+	assert.Nil(e.handler.recordCredentialRegion(&config.Spec))
+	cm, err := e.handler.configMaps.Get(context.TODO(), CredentialRegionsConfigMap, metav1.GetOptions{})
+	if !assert.Nil(err) {
+		return
+	}
+	key := credentialRegionsKey(testNamespace, "cc-test")
+	assert.Equal(map[string]string{key: "cn-east-3," + fake.DefaultRegion}, cm.Data)
+	assert.Equal(map[string]bool{"cn-east-3": true, fake.DefaultRegion: true}, credentialRegions(cm.Data[key]))
+
+	// The credential secret is not modified.
+	secret, err := e.secrets.Get(testNamespace, "cc-test", metav1.GetOptions{})
+	if assert.Nil(err) {
+		assert.Empty(secret.Annotations)
+	}
+}
+
+func Test_Handler_tagCreatedResources(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	if _, err := e.configs.Create(newTestConfig("cce-test")); err != nil {
+		t.Fatal(err)
+	}
+	// The created resources are recorded and tagged again after failed to tag.
+	e.server.FailTagging(1)
+	config, _ := e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	_, err := e.handler.OnCCEConfigChanged("", config)
+	assert.ErrorContains(err, "failed to tag EIP")
+	config, _ = e.configs.Get(testNamespace, "cce-test", metav1.GetOptions{})
+	eipID := config.Status.CreatedClusterEIPID
+	if !assert.NotEmpty(eipID) {
+		return
+	}
+	assert.Empty(e.server.Tags(eipID))
+	resources := e.server.Resources()
+
+	config = e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	assert.Equal(eipID, config.Status.CreatedClusterEIPID)
+	assert.Equal(resources[fake.KindEIP], e.server.Resources()[fake.KindEIP])
+	assert.Equal(resources[fake.KindNatGateway], e.server.Resources()[fake.KindNatGateway])
+	for _, id := range []string{eipID, config.Status.CreatedSNatRuleEIPID, config.Status.CreatedNatGatewayID} {
+		assert.Equal(string(config.UID), e.server.Tags(id)[common.TagOwnerUID])
+	}
+}
+
+func Test_orphanGC_scan(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	_, err := e.configs.Create(newTestConfig("cce-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := e.reconcile(t, "cce-test", cceConfigCreatingPhase)
+	uid := string(config.UID)
+	for _, id := range []string{
+		config.Status.CreatedVpcID,
+		config.Status.CreatedSubnetID,
+		config.Status.CreatedClusterEIPID,
+		config.Status.CreatedNatGatewayID,
+		config.Status.CreatedSNatRuleEIPID,
+	} {
+		tags := e.server.Tags(id)
+		assert.Equal(testInstanceID, tags[common.TagInstance])
+		assert.Equal(testNamespace, tags[common.TagOwnerNamespace])
+		assert.Equal("cce-test", tags[common.TagOwnerName])
+		assert.Equal(uid, tags[common.TagOwnerUID])
+	}
+
+	// Create the resources of a config deleted without cleaning up.
+	driver, _ := e.handler.drivers.get(&config.Spec)
+	owner := &common.Owner{Instance: testInstanceID, Namespace: testNamespace, Name: "cce-deleted", UID: "uid-deleted"}
+	vpcRes, err := vpc.CreateVPC(driver.VPC, "vpc-orphan", vpc.DefaultVpcCIDR, owner)
+	if !assert.Nil(err) {
+		return
+	}
+	subnetRes, err := vpc.CreateSubnet(driver.VPC, "subnet-orphan", vpcRes.Vpc.Id, "", "", owner)
+	if !assert.Nil(err) {
+		return
+	}
+	eipRes, err := eip.CreatePublicIP(driver.EIP, &config.Spec.PublicIP.Eip)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(eip.TagPublicIP(driver.EIP, *eipRes.Publicip.Id, owner.Tags()))
+	spec := config.Spec.DeepCopy()
+	spec.HostNetwork.VpcID = vpcRes.Vpc.Id
+	spec.HostNetwork.SubnetID = subnetRes.Subnet.Id
+	natRes, err := nat.CreateNatGateway(driver.NAT, "nat-orphan", spec)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(nat.TagNatGateway(driver.NAT, natRes.NatGateway.Id, owner.Tags()))
+	_, err = nat.CreateNatGatewaySnatRule(driver.NAT, natRes.NatGateway.Id, subnetRes.Subnet.Id, *eipRes.Publicip.Id, 0)
+	if !assert.Nil(err) {
+		return
+	}
+	// The VPC not recorded in the status of the existing config yet, such as
+	// created again after failed to update the status.
+	leakedRes, err := vpc.CreateVPC(driver.VPC, "vpc-leaked", vpc.DefaultVpcCIDR, e.handler.resourceOwner(config))
+	if !assert.Nil(err) {
+		return
+	}
+	// The resources of the config being deleted are cleaned up by the deletion.
+	deleting := newTestConfig("cce-deleting")
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	if deleting, err = e.configs.Create(deleting); err != nil {
+		t.Fatal(err)
+	}
+	_, err = vpc.CreateVPC(driver.VPC, "vpc-deleting", vpc.DefaultVpcCIDR, e.handler.resourceOwner(deleting))
+	if !assert.Nil(err) {
+		return
+	}
+	// The untagged and retained resources, and the resources of the other
+	// operator instances are ignored.
+	_, err = vpc.CreateVPC(driver.VPC, "vpc-untagged", vpc.DefaultVpcCIDR, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	retainedRes, err := vpc.CreateVPC(driver.VPC, "vpc-retained", vpc.DefaultVpcCIDR, owner)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(vpc.TagVPC(driver.VPC, retainedRes.Vpc.Id, []common.Tag{{Key: common.TagRetained, Value: "true"}}))
+	other := *owner
+	other.Instance = "other-instance"
+	_, err = vpc.CreateVPC(driver.VPC, "vpc-other", vpc.DefaultVpcCIDR, &other)
+	if !assert.Nil(err) {
+		return
+	}
+	// The VPC of a deleted config is kept while used by another config.
+	sharedRes, err := vpc.CreateVPC(driver.VPC, "vpc-shared", vpc.DefaultVpcCIDR, owner)
+	if !assert.Nil(err) {
+		return
+	}
+	shared := newTestConfig("cce-shared")
+	shared.Spec.HostNetwork.VpcID = sharedRes.Vpc.Id
+	if _, err := e.configs.Create(shared); err != nil {
+		t.Fatal(err)
+	}
+	resources := e.server.Resources()
+
+	// The recorded regions of the credentials are scanned after the drivers
+	// were evicted from the cache.
+	e.handler.drivers = newDriverCache(driverCacheTTL)
+	gc := newOrphanGC(e.handler, OrphanGCOptions{GracePeriod: time.Hour, DryRun: true})
+	drivers, err := gc.drivers()
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Len(drivers, 1)
+	owners := map[string]bool{string(config.UID): true, string(deleting.UID): true}
+	orphans := gc.list(drivers, owners, map[string]bool{
+		config.Status.CreatedVpcID:         true,
+		config.Status.CreatedSubnetID:      true,
+		config.Status.CreatedClusterEIPID:  true,
+		config.Status.CreatedNatGatewayID:  true,
+		config.Status.CreatedSNatRuleEIPID: true,
+		sharedRes.Vpc.Id:                   true,
+	})
+	ids := []string{}
+	for _, r := range orphans {
+		ids = append(ids, r.kind+"/"+r.name)
+	}
+	assert.Equal([]string{
+		orphanKindNatGateway + "/nat-orphan",
+		orphanKindEIP + "/" + utils.Value(eipRes.Publicip.Alias),
+		orphanKindSubnet + "/subnet-orphan",
+		orphanKindVPC + "/vpc-orphan",
+	}, ids)
+
+	// Nothing is deleted in dry run mode.
+	assert.Nil(gc.scan())
+	assert.Len(gc.firstSeen, 4)
+	assert.Equal(resources, e.server.Resources())
+
+	// Nothing is deleted in the grace period.
+	gc.opts.DryRun = false
+	assert.Nil(gc.scan())
+	assert.Equal(resources, e.server.Resources())
+
+	for key := range gc.firstSeen {
+		gc.firstSeen[key] = time.Now().Add(-2 * time.Hour)
+	}
+	for i := 0; i < 5 && len(gc.firstSeen) > 0; i++ {
+		assert.Nil(gc.scan())
+	}
+	assert.Empty(gc.firstSeen)
+	_, err = vpc.ShowVPC(driver.VPC, leakedRes.Vpc.Id)
+	assert.Nil(err)
+	_, err = vpc.ShowVPC(driver.VPC, config.Status.CreatedVpcID)
+	assert.Nil(err)
+	resources[fake.KindVPC]--
+	resources[fake.KindSubnet]--
+	resources[fake.KindEIP]--
+	resources[fake.KindNatGateway]--
+	resources[fake.KindSNATRule]--
+	assert.Equal(resources, e.server.Resources())
+
+	// The resources of the config are orphaned after the config is deleted.
+	assert.Nil(e.configs.Delete(testNamespace, "cce-test", &metav1.DeleteOptions{}))
+	assert.Nil(gc.scan())
+	assert.Len(gc.firstSeen, 6)
+	assert.Contains(gc.firstSeen, orphanKindVPC+"/"+config.Status.CreatedVpcID)
+	assert.Contains(gc.firstSeen, orphanKindVPC+"/"+leakedRes.Vpc.Id)
+}
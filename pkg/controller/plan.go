@@ -0,0 +1,415 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// DryRunAnnotation enables the dry-run mode of the CCEClusterConfig if the
+// value is "true", the operator writes the planned Huawei Cloud operations to
+// the status and does not perform any mutating call.
+const DryRunAnnotation = "cce.pandaria.io/dry-run"
+
+// Operations and resources of the plan.
+const (
+	planCreatePublicIP       = "CreatePublicIP"
+	planCreateVPC            = "CreateVPC"
+	planCreateSubnet         = "CreateSubnet"
+	planCreateNatGateway     = "CreateNatGateway"
+	planCreateNatGatewaySnat = "CreateNatGatewaySnatRule"
+	planCreateCluster        = "CreateCluster"
+	planUpgradeCluster       = "UpgradeCluster"
+	planResizeCluster        = "ResizeCluster"
+	planUpdateCluster        = "UpdateCluster"
+	planCreateNodePool       = "CreateNodePool"
+	planUpdateNodePool       = "UpdateNodePool"
+	planDeleteNodePool       = "DeleteNodePool"
+	planReplaceNodePool      = "ReplaceNodePool"
+	planDeleteNode           = "DeleteNode"
+	planRebootServer         = "BatchRebootServers"
+	planCreateAddon          = "CreateAddonInstance"
+	planUpdateAddon          = "UpdateAddonInstance"
+	planDeleteAddon          = "DeleteAddonInstance"
+
+	planResourceCluster    = "cluster"
+	planResourceNodePool   = "nodePool"
+	planResourceNode       = "node"
+	planResourcePublicIP   = "publicIP"
+	planResourceVPC        = "vpc"
+	planResourceSubnet     = "subnet"
+	planResourceNatGateway = "natGateway"
+	planResourceSNATRule   = "snatRule"
+	planResourceAddon      = "addon"
+)
+
+func isDryRun(config *ccev1.CCEClusterConfig) bool {
+	v, _ := strconv.ParseBool(config.Annotations[DryRunAnnotation])
+	return v
+}
+
+// planCreate returns the operations to create the cluster and its network
+// resources, it follows the steps of create and generateAndSetNetworking.
+func planCreate(config *ccev1.CCEClusterConfig) []ccev1.CCEClusterOperation {
+	spec := &config.Spec
+	var plan []ccev1.CCEClusterOperation
+	if spec.PublicAccess && spec.PublicIP.CreateEIP && config.Status.ClusterExternalIP == "" {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planCreatePublicIP,
+			Resource:  planResourcePublicIP,
+			Desired:   fmt.Sprintf("bandwidth=%d", spec.PublicIP.Eip.Bandwidth.Size),
+		})
+	}
+	if spec.HostNetwork.VpcID == "" {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planCreateVPC,
+			Resource:  planResourceVPC,
+		})
+	}
+	if spec.HostNetwork.SubnetID == "" {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planCreateSubnet,
+			Resource:  planResourceSubnet,
+		})
+	}
+	if spec.NatGateway.Enabled && config.Status.CreatedNatGatewayID == "" {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planCreateNatGateway,
+			Resource:  planResourceNatGateway,
+		})
+	}
+	if spec.NatGateway.Enabled && config.Status.CreatedSNATRuleID == "" {
+		if spec.NatGateway.ExistingEIPID == "" && config.Status.CreatedSNatRuleEIPID == "" {
+			plan = append(plan, ccev1.CCEClusterOperation{
+				Operation: planCreatePublicIP,
+				Resource:  planResourcePublicIP,
+				Desired:   fmt.Sprintf("bandwidth=%d", spec.NatGateway.SNatRuleEIP.Bandwidth.Size),
+			})
+		}
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planCreateNatGatewaySnat,
+			Resource:  planResourceSNATRule,
+			ID:        spec.NatGateway.ExistingEIPID,
+		})
+	}
+	plan = append(plan, ccev1.CCEClusterOperation{
+		Operation: planCreateCluster,
+		Resource:  planResourceCluster,
+		Name:      spec.Name,
+		Desired:   fmt.Sprintf("version=%s flavor=%s", spec.Version, spec.Flavor),
+	})
+	for _, np := range spec.NodePools {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planCreateNodePool,
+			Resource:  planResourceNodePool,
+			Name:      np.Name,
+			Desired:   nodePoolPlanValue(&np),
+		})
+	}
+	return plan
+}
+
+// planUpstreamClusterState returns the operations updateUpstreamClusterState
+// would request to make the upstream cluster match the config spec.
+func planUpstreamClusterState(
+	upstreamSpec *ccev1.CCEClusterConfigSpec, config *ccev1.CCEClusterConfig,
+) ([]ccev1.CCEClusterOperation, error) {
+	var plan []ccev1.CCEClusterOperation
+	if ok, err := clusterUpgradeable(config.Spec.Version, upstreamSpec.Version); err != nil {
+		return nil, err
+	} else if ok {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planUpgradeCluster,
+			Resource:  planResourceCluster,
+			Name:      config.Spec.Name,
+			ID:        config.Spec.ClusterID,
+			Current:   upstreamSpec.Version,
+			Desired:   config.Spec.Version,
+		})
+	}
+	if ok, err := clusterResizable(config, upstreamSpec); err != nil {
+		return nil, err
+	} else if ok {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planResizeCluster,
+			Resource:  planResourceCluster,
+			Name:      config.Spec.Name,
+			ID:        config.Spec.ClusterID,
+			Current:   upstreamSpec.Flavor,
+			Desired:   config.Spec.Flavor,
+		})
+	}
+	// The empty security group is initialized by the upstream security group.
+	if config.Spec.Name != upstreamSpec.Name ||
+		config.Spec.Description != upstreamSpec.Description ||
+		(config.Spec.HostNetwork.SecurityGroup != "" &&
+			config.Spec.HostNetwork.SecurityGroup != upstreamSpec.HostNetwork.SecurityGroup) {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planUpdateCluster,
+			Resource:  planResourceCluster,
+			Name:      config.Spec.Name,
+			ID:        config.Spec.ClusterID,
+			Current: fmt.Sprintf("name=%s description=%s securityGroup=%s", upstreamSpec.Name,
+				upstreamSpec.Description, upstreamSpec.HostNetwork.SecurityGroup),
+			Desired: fmt.Sprintf("name=%s description=%s securityGroup=%s", config.Spec.Name,
+				config.Spec.Description, config.Spec.HostNetwork.SecurityGroup),
+		})
+	}
+
+	upstreamNodePools := make(map[string]*ccev1.CCENodePool, len(upstreamSpec.NodePools))
+	for i := range upstreamSpec.NodePools {
+		upstreamNodePools[upstreamSpec.NodePools[i].ID] = &upstreamSpec.NodePools[i]
+	}
+	for i := range config.Spec.NodePools {
+		np := &config.Spec.NodePools[i]
+		upstream, ok := upstreamNodePools[np.ID]
+		if np.ID == "" || !ok {
+			continue
+		}
+		if changed := nodeTemplateReplaced(&np.NodeTemplate, &upstream.NodeTemplate); len(changed) != 0 {
+			plan = append(plan, ccev1.CCEClusterOperation{
+				Operation: planReplaceNodePool,
+				Resource:  planResourceNodePool,
+				Name:      np.Name,
+				ID:        np.ID,
+				Current:   nodeTemplatePlanValue(&upstream.NodeTemplate, changed),
+				Desired:   nodeTemplatePlanValue(&np.NodeTemplate, changed),
+			})
+		}
+		if np.Name == upstream.Name && np.InitialNodeCount == upstream.InitialNodeCount &&
+			reflect.DeepEqual(np.Autoscaling, upstream.Autoscaling) &&
+			!nodeTemplateUpdated(&np.NodeTemplate, &upstream.NodeTemplate) {
+			continue
+		}
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planUpdateNodePool,
+			Resource:  planResourceNodePool,
+			Name:      np.Name,
+			ID:        np.ID,
+			Current:   nodePoolPlanValue(upstream),
+			Desired:   nodePoolPlanValue(np),
+		})
+	}
+	toCreate, toDelete := nodePoolChanges(config, upstreamSpec)
+	for _, np := range toCreate {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planCreateNodePool,
+			Resource:  planResourceNodePool,
+			Name:      np.Name,
+			Desired:   nodePoolPlanValue(np),
+		})
+	}
+	for i := range toDelete {
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: planDeleteNodePool,
+			Resource:  planResourceNodePool,
+			Name:      toDelete[i].Name,
+			ID:        toDelete[i].ID,
+			Current:   nodePoolPlanValue(&toDelete[i]),
+		})
+	}
+	started := map[string]bool{}
+	for _, op := range config.Status.NodeOperations {
+		started[nodeOperationKey(op.Node, op.Action)] = true
+	}
+	for _, op := range config.Spec.NodeOperations {
+		if started[nodeOperationKey(op.Node, op.Action)] {
+			continue
+		}
+		operation := planDeleteNode
+		if op.Action == ccev1.NodeActionReboot {
+			operation = planRebootServer
+		}
+		plan = append(plan, ccev1.CCEClusterOperation{
+			Operation: operation,
+			Resource:  planResourceNode,
+			Name:      op.Node,
+			Desired:   "action=" + op.Action,
+		})
+	}
+	return plan, nil
+}
+
+func nodePoolPlanValue(np *ccev1.CCENodePool) string {
+	value := fmt.Sprintf("initialNodeCount=%d autoscaling=%t[%d-%d]", np.InitialNodeCount, np.Autoscaling.Enable,
+		np.Autoscaling.MinNodeCount, np.Autoscaling.MaxNodeCount)
+	if t := np.NodeTemplate.Taints; t != nil && len(*t) > 0 {
+		taints := make([]string, 0, len(*t))
+		for _, t := range *t {
+			taints = append(taints, fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect))
+		}
+		value += fmt.Sprintf(" taints=[%s]", strings.Join(taints, ","))
+	}
+	if t := np.NodeTemplate.K8sTags; t != nil && len(*t) > 0 {
+		value += fmt.Sprintf(" k8sTags=[%s]", planMapValue(*t))
+	}
+	if t := np.NodeTemplate.UserTags; t != nil && len(*t) > 0 {
+		value += fmt.Sprintf(" userTags=[%s]", planMapValue(*t))
+	}
+	return value
+}
+
+// nodeTemplatePlanValue returns the values of the node template fields.
+func nodeTemplatePlanValue(template *ccev1.CCENodeTemplate, fields []string) string {
+	values := make([]string, 0, len(fields))
+	for _, f := range fields {
+		switch f {
+		case "flavor":
+			values = append(values, "flavor="+template.Flavor)
+		case "operatingSystem":
+			values = append(values, "operatingSystem="+template.OperatingSystem)
+		case "rootVolume":
+			values = append(values, fmt.Sprintf("rootVolume=%s:%d", template.RootVolume.Type, template.RootVolume.Size))
+		case "dataVolumes":
+			volumes := make([]string, 0, len(template.DataVolumes))
+			for _, v := range template.DataVolumes {
+				volumes = append(volumes, fmt.Sprintf("%s:%d", v.Type, v.Size))
+			}
+			values = append(values, fmt.Sprintf("dataVolumes=[%s]", strings.Join(volumes, ",")))
+		case "runtime":
+			values = append(values, "runtime="+template.Runtime)
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+func planMapValue(m map[string]string) string {
+	values := make([]string, 0, len(m))
+	for _, k := range sortedMapKeys(m) {
+		values = append(values, k+"="+m[k])
+	}
+	return strings.Join(values, ",")
+}
+
+// nodeTemplateUpdated returns true if the node template fields which can be
+// updated in place are different from the upstream, the fields not set in
+// spec are not managed and not compared.
+func nodeTemplateUpdated(template, upstream *ccev1.CCENodeTemplate) bool {
+	if template.Taints != nil {
+		var current []ccev1.CCENodeTaint
+		if upstream.Taints != nil {
+			current = *upstream.Taints
+		}
+		if (len(*template.Taints) != 0 || len(current) != 0) && !reflect.DeepEqual(*template.Taints, current) {
+			return true
+		}
+	}
+	if template.K8sTags != nil && nodeTagsUpdated(*template.K8sTags, upstream.K8sTags) {
+		return true
+	}
+	if template.UserTags != nil && nodeTagsUpdated(*template.UserTags, upstream.UserTags) {
+		return true
+	}
+	return false
+}
+
+func nodeTagsUpdated(tags map[string]string, upstream *map[string]string) bool {
+	var current map[string]string
+	if upstream != nil {
+		current = *upstream
+	}
+	return (len(tags) != 0 || len(current) != 0) && !reflect.DeepEqual(tags, current)
+}
+
+// clusterResizable returns true if the cluster flavor in spec is different
+// from the upstream and the cluster version supports resizing.
+func clusterResizable(config *ccev1.CCEClusterConfig, upstreamSpec *ccev1.CCEClusterConfigSpec) (bool, error) {
+	if config.Spec.Flavor == "" || config.Spec.Flavor == upstreamSpec.Flavor {
+		return false, nil
+	}
+	cv, err := semver.NewVersion(config.Spec.Version)
+	if err != nil {
+		return false, err
+	}
+	minVersion := semver.New(1, 15, 0, "", "")
+	return cv.Compare(minVersion) >= 0, nil
+}
+
+// nodePoolChanges returns the node pools exist in spec but not exist in
+// upstream and the node pools exist in upstream but not exist in spec.
+func nodePoolChanges(
+	config *ccev1.CCEClusterConfig, upstreamSpec *ccev1.CCEClusterConfigSpec,
+) (toCreate []*ccev1.CCENodePool, toDelete []ccev1.CCENodePool) {
+	upstreamNodePoolIDs := make(map[string]bool, len(upstreamSpec.NodePools))
+	upstreamNodePoolNames := make(map[string]bool, len(upstreamSpec.NodePools))
+	specNodePoolIDs := make(map[string]bool, len(config.Spec.NodePools))
+	replacedIDs, successorIDs := replacingNodePoolIDs(config)
+	for _, np := range upstreamSpec.NodePools {
+		upstreamNodePoolIDs[np.ID] = true
+		upstreamNodePoolNames[np.Name] = true
+	}
+	for i := range config.Spec.NodePools {
+		np := &config.Spec.NodePools[i]
+		if np.ID != "" {
+			specNodePoolIDs[np.ID] = true
+		}
+		if upstreamNodePoolIDs[np.ID] {
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Debugf("found nodePool [%s] ID [%s] exists in cce cluster [%s]",
+				np.Name, np.ID, config.Spec.Name)
+			continue
+		}
+		if replacedIDs[np.ID] {
+			// The node pool was replaced, waiting for Rancher to update the ID.
+			continue
+		}
+		if upstreamNodePoolNames[np.Name] {
+			// Prevent creation of node pools with the same name.
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Debugf("nodePool [%s] exists in cce cluster [%s], skip creation",
+				np.Name, config.Spec.Name)
+			continue
+		}
+		toCreate = append(toCreate, np)
+	}
+	for _, np := range upstreamSpec.NodePools {
+		if specNodePoolIDs[np.ID] || successorIDs[np.ID] {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Debugf("nodePool [%s] ID [%s] exists in upstream but not exists in config spec",
+			np.Name, np.ID)
+		toDelete = append(toDelete, np)
+	}
+	return toCreate, toDelete
+}
+
+// updatePlan writes the plan to the config status if changed.
+func (h *Handler) updatePlan(
+	config *ccev1.CCEClusterConfig, plan []ccev1.CCEClusterOperation,
+) (*ccev1.CCEClusterConfig, error) {
+	if reflect.DeepEqual(config.Status.Plan, plan) {
+		return config, nil
+	}
+	if len(plan) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Infof("dry-run: %d operations planned for cluster [%s]", len(plan), config.Spec.Name)
+	}
+	var err error
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.Plan = plan
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	return config, err
+}
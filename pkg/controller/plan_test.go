@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_planCreate(t *testing.T) {
+	assert := assert.New(t)
+	config := newTestConfig("cce-test")
+
+	var operations []string
+	for _, op := range planCreate(config) {
+		operations = append(operations, op.Operation)
+	}
+	assert.Equal([]string{
+		"CreatePublicIP", "CreateVPC", "CreateSubnet", "CreateNatGateway",
+		"CreatePublicIP", "CreateNatGatewaySnatRule", "CreateCluster", "CreateNodePool",
+	}, operations)
+
+	// Skip the created resources.
+	config.Spec.HostNetwork.VpcID = "mock-vpc-id"
+	config.Spec.HostNetwork.SubnetID = "mock-subnet-id"
+	config.Status.ClusterExternalIP = "1.1.1.1"
+	config.Spec.NatGateway.Enabled = false
+	operations = nil
+	for _, op := range planCreate(config) {
+		operations = append(operations, op.Operation)
+	}
+	assert.Equal([]string{"CreateCluster", "CreateNodePool"}, operations)
+}
+
+func Test_isDryRun(t *testing.T) {
+	assert := assert.New(t)
+	config := newTestConfig("cce-test")
+	assert.False(isDryRun(config))
+	config.Annotations = map[string]string{DryRunAnnotation: "true"}
+	assert.True(isDryRun(config))
+	config.Annotations[DryRunAnnotation] = "invalid"
+	assert.False(isDryRun(config))
+}
+
+func Test_nodeTemplateUpdated(t *testing.T) {
+	assert := assert.New(t)
+	np := newTestConfig("cce-test").Spec.NodePools[0]
+	upstream := np.NodeTemplate
+	assert.False(nodeTemplateUpdated(&np.NodeTemplate, &upstream))
+
+	// Empty and nil values are considered equal.
+	np.NodeTemplate.K8sTags = &map[string]string{}
+	np.NodeTemplate.Taints = &[]ccev1.CCENodeTaint{}
+	assert.False(nodeTemplateUpdated(&np.NodeTemplate, &upstream))
+
+	// The fields not set in spec are not managed.
+	upstream.Taints = &[]ccev1.CCENodeTaint{{Key: "console", Effect: "NoSchedule"}}
+	upstream.UserTags = &map[string]string{"owner": "console"}
+	np.NodeTemplate.Taints = nil
+	np.NodeTemplate.K8sTags = nil
+	assert.False(nodeTemplateUpdated(&np.NodeTemplate, &upstream))
+	np.NodeTemplate.Taints = &[]ccev1.CCENodeTaint{}
+	assert.True(nodeTemplateUpdated(&np.NodeTemplate, &upstream))
+	upstream.Taints = nil
+	upstream.UserTags = nil
+
+	np.NodeTemplate.Taints = &[]ccev1.CCENodeTaint{{Key: "dedicated", Value: "gpu", Effect: "NoSchedule"}}
+	np.NodeTemplate.UserTags = &map[string]string{"team": "infra", "env": "prod"}
+	assert.True(nodeTemplateUpdated(&np.NodeTemplate, &upstream))
+	assert.Equal("initialNodeCount=2 autoscaling=false[0-0] taints=[dedicated=gpu:NoSchedule] "+
+		"userTags=[env=prod,team=infra]", nodePoolPlanValue(&np))
+
+	// The fields which cannot be updated in place are not compared.
+	np.NodeTemplate = upstream
+	np.NodeTemplate.MaxPods = 64
+	assert.False(nodeTemplateUpdated(&np.NodeTemplate, &upstream))
+}
+
+func Test_planNodeOperations(t *testing.T) {
+	assert := assert.New(t)
+	config := newMockConfig(cceConfigActivePhase)
+	config.Spec.NodeOperations = []ccev1.CCENodeOperation{
+		{Node: "10.224.0.10", Action: ccev1.NodeActionReplace},
+		{Node: "10.224.0.11", Action: ccev1.NodeActionReboot},
+		{Node: "10.224.0.12", Action: ccev1.NodeActionDelete},
+	}
+	// The started operations are not planned.
+	config.Status.NodeOperations = []ccev1.CCENodeOperationStatus{
+		{Node: "10.224.0.12", Action: ccev1.NodeActionDelete, Phase: ccev1.NodeOperationSucceeded},
+	}
+	plan, err := planUpstreamClusterState(config.Spec.DeepCopy(), config)
+	assert.Nil(err)
+	assert.Equal([]ccev1.CCEClusterOperation{
+		{Operation: "DeleteNode", Resource: "node", Name: "10.224.0.10", Desired: "action=Replace"},
+		{Operation: "BatchRebootServers", Resource: "node", Name: "10.224.0.11", Desired: "action=Reboot"},
+	}, plan)
+}
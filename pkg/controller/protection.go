@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfirmNodePoolDeletionAnnotation is the annotation confirming the deletion
+// of the node pools holding running nodes, the value is a comma separated
+// list of the node pool names or IDs. The entries are removed after the node
+// pools are requested to delete.
+const ConfirmNodePoolDeletionAnnotation = "cce.pandaria.io/confirm-nodepool-deletion"
+
+// protectNodePoolDeletion filters the node pools to delete by the deletion
+// protection of the config, returns the node pools allowed to be deleted and
+// the reasons of the blocked node pools.
+func protectNodePoolDeletion(
+	client cce.ClusterAPI, config *ccev1.CCEClusterConfig, toDelete []ccev1.CCENodePool,
+) ([]ccev1.CCENodePool, []string, error) {
+	protection := config.Spec.NodePoolDeletionProtection
+	var (
+		allowed []ccev1.CCENodePool
+		blocked []string
+	)
+	for i := range toDelete {
+		if reason := nodePoolProtected(&protection, &toDelete[i]); reason != "" {
+			blocked = append(blocked, reason)
+			continue
+		}
+		allowed = append(allowed, toDelete[i])
+	}
+
+	if !protection.SkipRunningNodesConfirmation && len(allowed) != 0 {
+		confirmed := deletionConfirmedNodePools(config)
+		allConfirmed := true
+		for _, np := range allowed {
+			if !confirmed[np.Name] && !confirmed[np.ID] {
+				allConfirmed = false
+			}
+		}
+		// Node pools confirmed by the annotation are deleted without listing the nodes.
+		if !allConfirmed {
+			nodes, err := cce.ListNodes(client, config.Spec.ClusterID)
+			if err != nil {
+				return nil, nil, err
+			}
+			running := runningNodeCount(nodes)
+			var confirmedOrIdle []ccev1.CCENodePool
+			for _, np := range allowed {
+				if confirmed[np.Name] || confirmed[np.ID] || running[np.ID] == 0 {
+					confirmedOrIdle = append(confirmedOrIdle, np)
+					continue
+				}
+				blocked = append(blocked, fmt.Sprintf(
+					"nodePool [%s] ID [%s] has %d running nodes, deletion requires annotation [%s]",
+					np.Name, np.ID, running[np.ID], ConfirmNodePoolDeletionAnnotation))
+			}
+			allowed = confirmedOrIdle
+		}
+	}
+
+	if limit := int(protection.MaxDeletionsPerReconcile); limit > 0 && len(allowed) > limit {
+		for _, np := range allowed[limit:] {
+			blocked = append(blocked, fmt.Sprintf(
+				"nodePool [%s] ID [%s] exceeds the maximum %d deletions per reconcile", np.Name, np.ID, limit))
+		}
+		allowed = allowed[:limit]
+	}
+	return allowed, blocked, nil
+}
+
+// nodePoolProtected returns the reason the node pool cannot be deleted by
+// the deletion protection, empty if the deletion is not protected.
+func nodePoolProtected(protection *ccev1.CCENodePoolDeletionProtection, np *ccev1.CCENodePool) string {
+	if protection.Enabled {
+		return fmt.Sprintf("nodePool [%s] ID [%s] is protected by the cluster deletion protection", np.Name, np.ID)
+	}
+	for _, s := range protection.ProtectedNodePools {
+		if s == np.Name || s == np.ID {
+			return fmt.Sprintf("nodePool [%s] ID [%s] is protected", np.Name, np.ID)
+		}
+	}
+	return ""
+}
+
+// deletionConfirmedNodePools returns the node pool names and IDs in the
+// confirmation annotation.
+func deletionConfirmedNodePools(config *ccev1.CCEClusterConfig) map[string]bool {
+	confirmed := map[string]bool{}
+	for _, s := range strings.Split(config.Annotations[ConfirmNodePoolDeletionAnnotation], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			confirmed[s] = true
+		}
+	}
+	return confirmed
+}
+
+// removeDeletionConfirmations removes the deleted node pools from the
+// confirmation annotation, so the confirmations are not reused by the node
+// pools created later with the same names.
+func (h *Handler) removeDeletionConfirmations(
+	config *ccev1.CCEClusterConfig, deleted []ccev1.CCENodePool,
+) (*ccev1.CCEClusterConfig, error) {
+	if _, ok := config.Annotations[ConfirmNodePoolDeletionAnnotation]; !ok || len(deleted) == 0 {
+		return config, nil
+	}
+	consumed := map[string]bool{}
+	for _, np := range deleted {
+		consumed[np.Name] = true
+		consumed[np.ID] = true
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		var remaining []string
+		for _, s := range strings.Split(config.Annotations[ConfirmNodePoolDeletionAnnotation], ",") {
+			if s = strings.TrimSpace(s); s != "" && !consumed[s] {
+				remaining = append(remaining, s)
+			}
+		}
+		value := strings.Join(remaining, ",")
+		if value == config.Annotations[ConfirmNodePoolDeletionAnnotation] {
+			return nil
+		}
+		configUpdate := config.DeepCopy()
+		if len(remaining) == 0 {
+			delete(configUpdate.Annotations, ConfirmNodePoolDeletionAnnotation)
+		} else {
+			configUpdate.Annotations[ConfirmNodePoolDeletionAnnotation] = value
+		}
+		config, err = h.cceCC.Update(configUpdate)
+		return err
+	})
+	return config, err
+}
+
+// runningNodeCount returns the active node count by the node pool ID.
+func runningNodeCount(nodes *cce_model.ListNodesResponse) map[string]int {
+	count := map[string]int{}
+	if nodes == nil || nodes.Items == nil {
+		return count
+	}
+	for _, n := range *nodes.Items {
+		if n.Metadata == nil || n.Status == nil || n.Status.Phase == nil {
+			continue
+		}
+		if n.Status.Phase.Value() != cce_model.GetNodeStatusPhaseEnum().ACTIVE.Value() {
+			continue
+		}
+		count[n.Metadata.Annotations[cce.NodePoolIDAnnotationKey]]++
+	}
+	return count
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_protectNodePoolDeletion(t *testing.T) {
+	toDelete := []ccev1.CCENodePool{
+		{Name: "nodepool-1", ID: "mock-nodepool-1-id"},
+		{Name: "nodepool-2", ID: "mock-nodepool-2-id"},
+		{Name: "nodepool-3", ID: "mock-nodepool-3-id"},
+	}
+	nodes := []cce_model.Node{
+		newMockNode("node-1", "mock-nodepool-1-id", cce_model.GetNodeStatusPhaseEnum().ACTIVE),
+		newMockNode("node-2", "mock-nodepool-1-id", cce_model.GetNodeStatusPhaseEnum().ACTIVE),
+		newMockNode("node-3", "mock-nodepool-2-id", cce_model.GetNodeStatusPhaseEnum().DELETING),
+	}
+	tests := []struct {
+		name        string
+		protection  ccev1.CCENodePoolDeletionProtection
+		annotations map[string]string
+		calls       []string
+		allowed     []string
+		blocked     []string
+	}{
+		{
+			name:       "no protection",
+			protection: ccev1.CCENodePoolDeletionProtection{SkipRunningNodesConfirmation: true},
+			allowed:    []string{"nodepool-1", "nodepool-2", "nodepool-3"},
+		},
+		{
+			name:       "cluster deletion protection",
+			protection: ccev1.CCENodePoolDeletionProtection{Enabled: true},
+			blocked: []string{
+				"nodePool [nodepool-1] ID [mock-nodepool-1-id] is protected by the cluster deletion protection",
+				"nodePool [nodepool-2] ID [mock-nodepool-2-id] is protected by the cluster deletion protection",
+				"nodePool [nodepool-3] ID [mock-nodepool-3-id] is protected by the cluster deletion protection",
+			},
+		},
+		{
+			name: "protected node pools",
+			protection: ccev1.CCENodePoolDeletionProtection{
+				ProtectedNodePools: []string{"nodepool-1", "mock-nodepool-3-id"},
+			},
+			calls:   []string{"ListNodes mock-cluster-id"},
+			allowed: []string{"nodepool-2"},
+			blocked: []string{
+				"nodePool [nodepool-1] ID [mock-nodepool-1-id] is protected",
+				"nodePool [nodepool-3] ID [mock-nodepool-3-id] is protected",
+			},
+		},
+		{
+			name: "maximum deletions per reconcile",
+			protection: ccev1.CCENodePoolDeletionProtection{
+				MaxDeletionsPerReconcile:     2,
+				SkipRunningNodesConfirmation: true,
+			},
+			allowed: []string{"nodepool-1", "nodepool-2"},
+			blocked: []string{
+				"nodePool [nodepool-3] ID [mock-nodepool-3-id] exceeds the maximum 2 deletions per reconcile",
+			},
+		},
+		{
+			name:    "node pool with running nodes",
+			calls:   []string{"ListNodes mock-cluster-id"},
+			allowed: []string{"nodepool-2", "nodepool-3"},
+			blocked: []string{
+				"nodePool [nodepool-1] ID [mock-nodepool-1-id] has 2 running nodes, " +
+					"deletion requires annotation [cce.pandaria.io/confirm-nodepool-deletion]",
+			},
+		},
+		{
+			name:        "node pool deletion confirmed",
+			annotations: map[string]string{ConfirmNodePoolDeletionAnnotation: "nodepool-1, nodepool-2,mock-nodepool-3-id"},
+			allowed:     []string{"nodepool-1", "nodepool-2", "nodepool-3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			config := newMockConfig(cceConfigActivePhase)
+			config.Annotations = tt.annotations
+			config.Spec.NodePoolDeletionProtection = tt.protection
+			m := &mockClusterAPI{nodes: nodes}
+
+			allowed, blocked, err := protectNodePoolDeletion(m, config, toDelete)
+			assert.Nil(err)
+			var names []string
+			for _, np := range allowed {
+				names = append(names, np.Name)
+			}
+			assert.Equal(tt.allowed, names)
+			assert.Equal(tt.blocked, blocked)
+			assert.Equal(tt.calls, m.Calls())
+		})
+	}
+}
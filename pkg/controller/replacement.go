@@ -0,0 +1,511 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// maxNodePoolNameLength is the maximum length of the CCE node pool name.
+	maxNodePoolNameLength = 50
+	// mirrorPodAnnotation is the annotation of the static pods, which cannot
+	// be evicted.
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// nodeTemplateReplaced returns the node template fields which cannot be
+// updated in place and are different from the upstream, the node pool needs
+// to be replaced if any field is returned.
+func nodeTemplateReplaced(template, upstream *ccev1.CCENodeTemplate) []string {
+	var fields []string
+	if template.Flavor != "" && template.Flavor != upstream.Flavor {
+		fields = append(fields, "flavor")
+	}
+	if template.OperatingSystem != "" && template.OperatingSystem != upstream.OperatingSystem {
+		fields = append(fields, "operatingSystem")
+	}
+	if template.RootVolume.Size != 0 && template.RootVolume != upstream.RootVolume {
+		fields = append(fields, "rootVolume")
+	}
+	if len(template.DataVolumes) != 0 && !reflect.DeepEqual(template.DataVolumes, upstream.DataVolumes) {
+		fields = append(fields, "dataVolumes")
+	}
+	if template.Runtime != "" && upstream.Runtime != "" && template.Runtime != upstream.Runtime {
+		fields = append(fields, "runtime")
+	}
+	return fields
+}
+
+// successorNodePool returns the node pool created to replace the node pool,
+// the successor is renamed to the name in spec after the replacement.
+func successorNodePool(np *ccev1.CCENodePool) *ccev1.CCENodePool {
+	successor := np.DeepCopy()
+	successor.ID = ""
+	suffix := "-" + utils.RandomHex(5)
+	name := np.Name
+	if len(name)+len(suffix) > maxNodePoolNameLength {
+		name = name[:maxNodePoolNameLength-len(suffix)]
+	}
+	successor.Name = name + suffix
+	return successor
+}
+
+// replacingNodePoolIDs returns the IDs of the node pools being replaced and
+// the IDs of the successor node pools.
+func replacingNodePoolIDs(config *ccev1.CCEClusterConfig) (oldIDs, newIDs map[string]bool) {
+	oldIDs, newIDs = map[string]bool{}, map[string]bool{}
+	for _, r := range config.Status.NodePoolReplacements {
+		oldIDs[r.OldID] = true
+		if r.NewID != "" {
+			newIDs[r.NewID] = true
+		}
+	}
+	return oldIDs, newIDs
+}
+
+// getClusterClient returns the client of the CCE cluster.
+func (h *Handler) getClusterClient(config *ccev1.CCEClusterConfig) (kubernetes.Interface, error) {
+	if h.overrides.clusterClient != nil {
+		return h.overrides.clusterClient(config)
+	}
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return nil, err
+	}
+	return cce.GetClusterClient(driver.CCE, config.Spec.ClusterID, 1)
+}
+
+// syncNodePoolReplacements replaces the node pools whose immutable template
+// fields were changed by creating a successor node pool, draining the nodes
+// of the old node pool and deleting it. The node pools protected from
+// deletion are not replaced. Returns true if any replacement is in progress.
+func (h *Handler) syncNodePoolReplacements(
+	config *ccev1.CCEClusterConfig, upstreamSpec *ccev1.CCEClusterConfigSpec,
+) (*ccev1.CCEClusterConfig, bool, error) {
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
+	upstreamNodePools := make(map[string]*ccev1.CCENodePool, len(upstreamSpec.NodePools))
+	for i := range upstreamSpec.NodePools {
+		upstreamNodePools[upstreamSpec.NodePools[i].ID] = &upstreamSpec.NodePools[i]
+	}
+	specNodePools := make(map[string]*ccev1.CCENodePool, len(config.Spec.NodePools))
+	for i := range config.Spec.NodePools {
+		specNodePools[config.Spec.NodePools[i].Name] = &config.Spec.NodePools[i]
+	}
+
+	var (
+		replacements []ccev1.CCENodePoolReplacement
+		nodes        *cce_model.ListNodesResponse
+	)
+	createdNodePoolIDs := map[string]string{}
+	protection := &config.Spec.NodePoolDeletionProtection
+	blocked := map[string]string{}
+	for _, r := range config.Status.NodePoolReplacements {
+		np, ok := specNodePools[r.Name]
+		if !ok {
+			// The node pool was removed from spec, the old and successor
+			// node pools are deleted as the other node pools not in spec.
+			continue
+		}
+		switch r.Phase {
+		case ccev1.NodePoolReplacementBlocked:
+			// The blocked replacements are checked again below.
+			blocked[r.Name] = r.Message
+			continue
+		case ccev1.NodePoolReplacementCreating:
+			if _, ok := upstreamNodePools[r.NewID]; !ok {
+				r.Message = fmt.Sprintf("waiting for successor nodePool [%s] ID [%s]", r.NewName, r.NewID)
+				break
+			}
+			if nodes == nil {
+				if nodes, err = cce.ListNodes(driver.CCE, config.Spec.ClusterID); err != nil {
+					return config, false, err
+				}
+			}
+			// The successor of the autoscaling node pool may be scaled down to
+			// the min node count.
+			want := np.InitialNodeCount
+			if np.Autoscaling.Enable {
+				want = np.Autoscaling.MinNodeCount
+			}
+			if running := runningNodeCount(nodes)[r.NewID]; running < int(want) {
+				r.Message = fmt.Sprintf("waiting for successor nodePool [%s] ID [%s] nodes, %d/%d running",
+					r.NewName, r.NewID, running, want)
+				break
+			}
+			r.Phase = ccev1.NodePoolReplacementDraining
+			r.Message = fmt.Sprintf("draining the nodes of nodePool [%s] ID [%s]", r.Name, r.OldID)
+			h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDraining,
+				"start draining the nodes of nodePool [%s] ID [%s]", r.Name, r.OldID)
+		case ccev1.NodePoolReplacementDraining:
+			if nodes == nil {
+				if nodes, err = cce.ListNodes(driver.CCE, config.Spec.ClusterID); err != nil {
+					return config, false, err
+				}
+			}
+			remaining, message, err := h.drainNodePool(config, nodes, r.OldID)
+			if err != nil {
+				return config, false, err
+			}
+			if remaining > 0 {
+				r.Message = message
+				break
+			}
+			if reason := nodePoolProtected(protection, &ccev1.CCENodePool{Name: r.Name, ID: r.OldID}); reason != "" {
+				r.Message = fmt.Sprintf("waiting for the deletion of the drained nodePool to be allowed, %s", reason)
+				break
+			}
+			if _, ok := upstreamNodePools[r.OldID]; ok {
+				if _, err := cce.DeleteNodePool(driver.CCE, config.Spec.ClusterID, r.OldID); err != nil {
+					return config, false, err
+				}
+			}
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("request to delete the replaced nodePool [%s] ID [%s]", r.Name, r.OldID)
+			h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonDeleting,
+				"request to delete the replaced nodePool [%s] ID [%s]", r.Name, r.OldID)
+			r.Phase = ccev1.NodePoolReplacementDeleting
+			r.Message = fmt.Sprintf("deleting nodePool [%s] ID [%s]", r.Name, r.OldID)
+		case ccev1.NodePoolReplacementDeleting:
+			if _, ok := upstreamNodePools[r.OldID]; ok {
+				r.Message = fmt.Sprintf("waiting for nodePool [%s] ID [%s] to be deleted", r.Name, r.OldID)
+				break
+			}
+			// Let Rancher update the node pool ID in spec to the successor.
+			createdNodePoolIDs[r.Name] = r.NewID
+			r.Phase = ccev1.NodePoolReplacementCompleted
+			r.Message = fmt.Sprintf("nodePool [%s] ID [%s] is replaced by nodePool [%s] ID [%s]",
+				r.Name, r.OldID, r.NewName, r.NewID)
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Info(r.Message)
+			h.recorder.Event(config, corev1.EventTypeNormal, eventReasonReplaced, r.Message)
+		case ccev1.NodePoolReplacementCompleted:
+			if np.ID == r.NewID {
+				// The node pool ID in spec was updated to the successor.
+				continue
+			}
+		}
+		replacements = append(replacements, r)
+	}
+
+	// Start the replacement of the node pools whose immutable fields changed,
+	// the replacements in progress continue outside the maintenance window.
+	for i := range config.Spec.NodePools {
+		np := &config.Spec.NodePools[i]
+		upstream, ok := upstreamNodePools[np.ID]
+		if np.ID == "" || !ok || nodePoolReplacing(replacements, np.Name) || maintenanceDeferred(config) {
+			continue
+		}
+		changed := nodeTemplateReplaced(&np.NodeTemplate, &upstream.NodeTemplate)
+		if len(changed) == 0 {
+			continue
+		}
+		reason := fmt.Sprintf("immutable fields changed: %s", strings.Join(changed, ", "))
+		if message := nodePoolProtected(protection, np); message != "" {
+			// The replacement deletes the old node pool.
+			message = "replacement blocked, " + message
+			if blocked[np.Name] != message {
+				h.recorder.Eventf(config, corev1.EventTypeWarning, eventReasonBlocked,
+					"nodePool [%s] ID [%s] is not replaced, %s", np.Name, np.ID, message)
+			}
+			replacements = append(replacements, ccev1.CCENodePoolReplacement{
+				Name:    np.Name,
+				OldID:   np.ID,
+				Phase:   ccev1.NodePoolReplacementBlocked,
+				Reason:  reason,
+				Message: message,
+			})
+			continue
+		}
+		res, err := cce.CreateNodePool(driver.CCE, config.Spec.ClusterID, successorNodePool(np))
+		if err != nil {
+			return config, false, err
+		}
+		if res.Metadata == nil {
+			return config, false, fmt.Errorf("CreateNodePool returns invalid data")
+		}
+		r := ccev1.CCENodePoolReplacement{
+			Name:    np.Name,
+			OldID:   np.ID,
+			NewName: res.Metadata.Name,
+			NewID:   utils.Value(res.Metadata.Uid),
+			Phase:   ccev1.NodePoolReplacementCreating,
+			Reason:  reason,
+		}
+		r.Message = fmt.Sprintf("request to create successor nodePool [%s] ID [%s]", r.NewName, r.NewID)
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Infof("request to create nodePool [%s] ID [%s] to replace nodePool [%s] ID [%s], %s",
+			r.NewName, r.NewID, r.Name, r.OldID, r.Reason)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonCreating,
+			"request to create nodePool [%s] ID [%s] to replace nodePool [%s] ID [%s], %s",
+			r.NewName, r.NewID, r.Name, r.OldID, r.Reason)
+		replacements = append(replacements, r)
+		// Record the successor node pool right after creating it, so it is
+		// not created again if the reconcile fails later. The progress of the
+		// other replacements is updated below.
+		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			configUpdate := config.DeepCopy()
+			configUpdate.Status.NodePoolReplacements = nil
+			for _, saved := range config.Status.NodePoolReplacements {
+				if saved.Name != r.Name {
+					configUpdate.Status.NodePoolReplacements = append(configUpdate.Status.NodePoolReplacements, saved)
+				}
+			}
+			configUpdate.Status.NodePoolReplacements = append(configUpdate.Status.NodePoolReplacements, r)
+			config, err = h.cceCC.UpdateStatus(configUpdate)
+			return err
+		}); err != nil {
+			return config, false, err
+		}
+	}
+
+	if len(createdNodePoolIDs) != 0 {
+		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			configUpdate := config.DeepCopy()
+			if configUpdate.Spec.CreatedNodePoolIDs == nil {
+				configUpdate.Spec.CreatedNodePoolIDs = map[string]string{}
+			}
+			for name, id := range createdNodePoolIDs {
+				configUpdate.Spec.CreatedNodePoolIDs[name] = id
+			}
+			config, err = h.cceCC.Update(configUpdate)
+			return err
+		}); err != nil {
+			return config, false, err
+		}
+	}
+
+	var messages []string
+	for _, r := range replacements {
+		if r.Phase != ccev1.NodePoolReplacementBlocked {
+			messages = append(messages, fmt.Sprintf("nodePool [%s] %s: %s", r.Name, r.Phase, r.Message))
+		}
+	}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.NodePoolReplacements = replacements
+		changed := !reflect.DeepEqual(config.Status.NodePoolReplacements, replacements)
+		if len(messages) != 0 {
+			configUpdate.Status.Phase = cceConfigUpdatingPhase
+			changed = setCondition(configUpdate, ccev1.ConditionNodePoolsSynced, metav1.ConditionFalse,
+				reasonNodePoolReplacing, strings.Join(messages, "; ")) || changed ||
+				config.Status.Phase != cceConfigUpdatingPhase
+		}
+		if !changed {
+			return nil
+		}
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	if err != nil {
+		return config, false, err
+	}
+	if len(messages) == 0 {
+		return config, false, nil
+	}
+	h.cceEnqueueAfter(config.Namespace, config.Name, 30*time.Second)
+	return config, true, nil
+}
+
+func nodePoolReplacing(replacements []ccev1.CCENodePoolReplacement, name string) bool {
+	for _, r := range replacements {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// drainNodePool cordons the nodes of the node pool and evicts the pods on
+// them, returns the count of the pods remaining on the nodes and the
+// progress message.
+func (h *Handler) drainNodePool(
+	config *ccev1.CCEClusterConfig, nodes *cce_model.ListNodesResponse, nodePoolID string,
+) (int, string, error) {
+	addresses := map[string]bool{}
+	if nodes != nil && nodes.Items != nil {
+		for _, n := range *nodes.Items {
+			if n.Metadata == nil || n.Status == nil || n.Metadata.Annotations[cce.NodePoolIDAnnotationKey] != nodePoolID {
+				continue
+			}
+			if ip := utils.Value(n.Status.PrivateIP); ip != "" {
+				addresses[ip] = true
+			}
+		}
+	}
+	return h.drainNodes(config, addresses, fmt.Sprintf("nodePool ID [%s]", nodePoolID))
+}
+
+// drainNodes cordons the Kubernetes nodes matching the addresses and evicts
+// the pods on them, returns the count of the pods remaining on the nodes and
+// the progress message. The pods owned by DaemonSets and the static pods are
+// not evicted, the evictions are rejected if they violate the
+// PodDisruptionBudgets.
+func (h *Handler) drainNodes(
+	config *ccev1.CCEClusterConfig, addresses map[string]bool, target string,
+) (int, string, error) {
+	if len(addresses) == 0 {
+		return 0, "", nil
+	}
+
+	client, err := h.getClusterClient(config)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get the client of cluster [%s]: %w", config.Spec.Name, err)
+	}
+	ctx := context.TODO()
+	nodeList, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, "", err
+	}
+	var (
+		remaining int
+		failed    []string
+	)
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		if !kubernetesNodeMatches(node, addresses) {
+			continue
+		}
+		if !node.Spec.Unschedulable {
+			nodeUpdate := node.DeepCopy()
+			nodeUpdate.Spec.Unschedulable = true
+			if _, err = client.CoreV1().Nodes().Update(ctx, nodeUpdate, metav1.UpdateOptions{}); err != nil {
+				return 0, "", fmt.Errorf("failed to cordon node [%s]: %w", node.Name, err)
+			}
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("cordoned node [%s] of %s", node.Name, target)
+		}
+		pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node.Name).String(),
+		})
+		if err != nil {
+			return 0, "", err
+		}
+		for j := range pods.Items {
+			pod := &pods.Items[j]
+			if pod.Spec.NodeName != node.Name || !podEvictable(pod) {
+				continue
+			}
+			remaining++
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			err := client.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pod.Name,
+					Namespace: pod.Namespace,
+				},
+			})
+			switch {
+			case err == nil, apierrors.IsNotFound(err):
+			default:
+				// The eviction may be rejected by the PodDisruptionBudget,
+				// retry in the next reconcile.
+				failed = append(failed, fmt.Sprintf("%s/%s: %v", pod.Namespace, pod.Name, err))
+			}
+		}
+	}
+	message := fmt.Sprintf("waiting for %d pods to be evicted from %s", remaining, target)
+	if len(failed) != 0 {
+		message += fmt.Sprintf(", failed to evict %d pods: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return remaining, message, nil
+}
+
+// uncordonNodes marks the Kubernetes nodes matching the addresses schedulable.
+func (h *Handler) uncordonNodes(config *ccev1.CCEClusterConfig, addresses map[string]bool) error {
+	client, err := h.getClusterClient(config)
+	if err != nil {
+		return fmt.Errorf("failed to get the client of cluster [%s]: %w", config.Spec.Name, err)
+	}
+	ctx := context.TODO()
+	nodeList, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		if !node.Spec.Unschedulable || !kubernetesNodeMatches(node, addresses) {
+			continue
+		}
+		nodeUpdate := node.DeepCopy()
+		nodeUpdate.Spec.Unschedulable = false
+		if _, err = client.CoreV1().Nodes().Update(ctx, nodeUpdate, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to uncordon node [%s]: %w", node.Name, err)
+		}
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Infof("uncordoned node [%s]", node.Name)
+	}
+	return nil
+}
+
+// kubernetesNodeMatches returns true if the node name or the internal IP is
+// in the addresses.
+func kubernetesNodeMatches(node *corev1.Node, addresses map[string]bool) bool {
+	if addresses[node.Name] {
+		return true
+	}
+	for _, a := range node.Status.Addresses {
+		if a.Type == corev1.NodeInternalIP && addresses[a.Address] {
+			return true
+		}
+	}
+	return false
+}
+
+// podEvictable returns true if the pod needs to be evicted before deleting
+// the node.
+func podEvictable(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return false
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_nodeTemplateReplaced(t *testing.T) {
+	assert := assert.New(t)
+	np := newTestConfig("cce-test").Spec.NodePools[0]
+	upstream := np.NodeTemplate
+	assert.Empty(nodeTemplateReplaced(&np.NodeTemplate, &upstream))
+
+	// The fields updated in place do not replace the node pool.
+	np.NodeTemplate.Taints = &[]ccev1.CCENodeTaint{{Key: "dedicated", Effect: "NoSchedule"}}
+	np.NodeTemplate.K8sTags = &map[string]string{"workload": "gpu"}
+	assert.Empty(nodeTemplateReplaced(&np.NodeTemplate, &upstream))
+
+	np.NodeTemplate.Flavor = "c7.xlarge.2"
+	np.NodeTemplate.RootVolume.Size = 100
+	np.NodeTemplate.DataVolumes = append(np.NodeTemplate.DataVolumes, ccev1.CCENodeVolume{Size: 200, Type: "SAS"})
+	np.NodeTemplate.Runtime = "docker"
+	assert.Equal([]string{"flavor", "rootVolume", "dataVolumes", "runtime"},
+		nodeTemplateReplaced(&np.NodeTemplate, &upstream))
+
+	// The successor node pool name is unique and not longer than 50.
+	np.Name = strings.Repeat("a", 48)
+	successor := successorNodePool(&np)
+	assert.Len(successor.Name, maxNodePoolNameLength)
+	assert.Empty(successor.ID)
+	assert.Equal(np.NodeTemplate, successor.NodeTemplate)
+}
+
+func Test_podEvictable(t *testing.T) {
+	assert := assert.New(t)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nginx"}}
+	assert.True(podEvictable(pod))
+
+	pod.Status.Phase = corev1.PodSucceeded
+	assert.False(podEvictable(pod))
+
+	pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:            "node-exporter",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "node-exporter"}},
+	}}
+	assert.False(podEvictable(pod))
+
+	pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "kube-proxy",
+		Annotations: map[string]string{mirrorPodAnnotation: "mirror"},
+	}}
+	assert.False(podEvictable(pod))
+}
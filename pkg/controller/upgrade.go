@@ -2,21 +2,175 @@ package controller
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei"
 	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
 	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/retry"
 )
 
+// syncClusterUpgrade upgrades the cluster from the current version to the
+// spec version over the supported upgrade path, one hop per upgrade task.
+// Returns true if the cluster is being upgraded or the pre-upgrade check is
+// running, false if the upgrade is blocked by the failed pre-upgrade check.
+func (h *Handler) syncClusterUpgrade(
+	config *ccev1.CCEClusterConfig, currentVersion string,
+) (*ccev1.CCEClusterConfig, bool, error) {
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
+	paths, err := cce.ListClusterUpgradePaths(driver.CCE)
+	if err != nil {
+		return config, false, err
+	}
+	hops, err := upgradeHops(paths, currentVersion, config.Spec.Version)
+	if err != nil {
+		return config, false, err
+	}
+	if config, err = h.updateUpgradeProgress(config, hops); err != nil {
+		return config, false, err
+	}
+	if config.Spec.UpgradeStrategy.SkipPreCheck {
+		config, err = h.upgradeCluster(config, hops[0])
+		return config, true, err
+	}
+	var passed bool
+	if config, passed, err = h.preCheckUpgrade(config, currentVersion, hops[0]); err != nil {
+		return config, false, err
+	} else if passed {
+		config, err = h.upgradeCluster(config, hops[0])
+		return config, true, err
+	}
+	return config, !upgradePreCheckFailed(config.Status.UpgradePreCheck), nil
+}
+
+// updateUpgradeProgress records the hops of upgrading the cluster to the spec
+// version in status, the task IDs of the finished hops are kept.
+func (h *Handler) updateUpgradeProgress(
+	config *ccev1.CCEClusterConfig, hops []string,
+) (*ccev1.CCEClusterConfig, error) {
+	progress := &ccev1.CCEClusterUpgradeStatus{
+		TargetVersion: config.Spec.Version,
+		CurrentHop:    hops[0],
+		RemainingHops: hops[1:],
+	}
+	if len(progress.RemainingHops) == 0 {
+		progress.RemainingHops = nil
+	}
+	if config.Status.Upgrade != nil && config.Status.Upgrade.TargetVersion == config.Spec.Version {
+		progress.TaskIDs = config.Status.Upgrade.TaskIDs
+	}
+	if reflect.DeepEqual(config.Status.Upgrade, progress) {
+		return config, nil
+	}
+	logrus.WithFields(logrus.Fields{
+		"cluster": config.Name,
+		"phase":   config.Status.Phase,
+	}).Infof("upgrade path of cluster [%s] to %q: %s",
+		config.Spec.Name, config.Spec.Version, strings.Join(hops, " -> "))
+	var err error
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.Upgrade = progress
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	return config, err
+}
+
+// upgradeHops returns the versions to upgrade the cluster from the current
+// version to the target version in order, by the shortest supported upgrade
+// path. The versions are compared by the major and minor version.
+func upgradeHops(
+	paths *cce_model.ListClusterUpgradePathsResponse, currentVersion, targetVersion string,
+) ([]string, error) {
+	current, err := minorVersion(currentVersion)
+	if err != nil {
+		return nil, err
+	}
+	target, err := minorVersion(targetVersion)
+	if err != nil {
+		return nil, err
+	}
+	next := map[string][]string{}
+	if paths != nil && paths.UpgradePaths != nil {
+		for _, p := range *paths.UpgradePaths {
+			from, err := minorVersion(utils.Value(p.Version))
+			if err != nil || p.TargetVersions == nil {
+				continue
+			}
+			for _, v := range *p.TargetVersions {
+				to, err := minorVersion(v)
+				if err != nil || semver.MustParse(to).GreaterThan(semver.MustParse(target)) {
+					continue
+				}
+				next[from] = append(next[from], to)
+			}
+		}
+	}
+
+	// Search the shortest path, the larger versions are preferred.
+	previous := map[string]string{current: ""}
+	queue := []string{current}
+	for len(queue) != 0 && previous[target] == "" {
+		v := queue[0]
+		queue = queue[1:]
+		versions := next[v]
+		sort.Slice(versions, func(i, j int) bool {
+			return semver.MustParse(versions[i]).GreaterThan(semver.MustParse(versions[j]))
+		})
+		for _, to := range versions {
+			if _, ok := previous[to]; ok {
+				continue
+			}
+			previous[to] = v
+			queue = append(queue, to)
+		}
+	}
+	if _, ok := previous[target]; !ok || target == current {
+		return nil, fmt.Errorf("no supported upgrade path of cluster from %q to %q",
+			currentVersion, targetVersion)
+	}
+	// The last hop is the spec version.
+	hops := []string{targetVersion}
+	for v := previous[target]; v != current; v = previous[v] {
+		hops = append([]string{v}, hops...)
+	}
+	return hops, nil
+}
+
+// minorVersion returns the version as vMajor.Minor, such as v1.25.
+func minorVersion(version string) (string, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("invalid version %q: %w", version, err)
+	}
+	return fmt.Sprintf("v%d.%d", v.Major(), v.Minor()), nil
+}
+
 func (h *Handler) upgradeCluster(
-	config *ccev1.CCEClusterConfig,
+	config *ccev1.CCEClusterConfig, targetVersion string,
 ) (*ccev1.CCEClusterConfig, error) {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
-	res, err := cce.UpgradeCluster(driver.CCE, config)
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, err
+	}
+	res, err := cce.UpgradeCluster(driver.CCE, config, targetVersion)
 	if err != nil {
 		return config, err
 	}
@@ -27,7 +181,10 @@ func (h *Handler) upgradeCluster(
 		"cluster": config.Name,
 		"phase":   config.Status.Phase,
 	}).Infof("start upgrade cluster [%s] to %q, task id [%s]",
-		config.Spec.Name, config.Spec.Version, utils.Value(res.Metadata.Uid))
+		config.Spec.Name, targetVersion, utils.Value(res.Metadata.Uid))
+	h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonUpgrading,
+		"start upgrade cluster [%s] to %q, task id [%s]",
+		config.Spec.Name, targetVersion, utils.Value(res.Metadata.Uid))
 	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
 		if err != nil {
@@ -35,6 +192,13 @@ func (h *Handler) upgradeCluster(
 		}
 		configUpdate := config.DeepCopy()
 		configUpdate.Status.UpgradeClusterTaskID = utils.Value(res.Metadata.Uid)
+		// The check is run again before the next upgrade.
+		configUpdate.Status.UpgradePreCheck = nil
+		if configUpdate.Status.Upgrade != nil {
+			configUpdate.Status.Upgrade.TaskIDs = append(configUpdate.Status.Upgrade.TaskIDs, utils.Value(res.Metadata.Uid))
+		}
+		setCondition(configUpdate, ccev1.ConditionUpgradeInProgress, metav1.ConditionTrue, reasonUpgrading,
+			fmt.Sprintf("upgrading cluster to [%s], task ID [%s]", targetVersion, utils.Value(res.Metadata.Uid)))
 		config, err = h.cceCC.UpdateStatus(configUpdate)
 		return err
 	})
@@ -44,6 +208,157 @@ func (h *Handler) upgradeCluster(
 	return h.enqueueUpdate(config)
 }
 
+// upgradePreCheckRetryInterval is the interval to start the pre-upgrade check
+// again after it failed, the failed check items may be fixed by the user.
+var upgradePreCheckRetryInterval = 10 * time.Minute
+
+// upgradePreCheckFailed returns true if the pre-upgrade check is finished
+// and not passed.
+func upgradePreCheckFailed(check *ccev1.CCEUpgradePreCheck) bool {
+	return check != nil && (check.Phase == cce.PreCheckPhaseFailed || check.Phase == cce.PreCheckPhaseError)
+}
+
+// preCheckUpgrade runs the CCE pre-upgrade check of upgrading the cluster from
+// the current version to the target version. Returns true if the check passed,
+// the upgrade is blocked and the failed check items are reported in status
+// if the check failed.
+func (h *Handler) preCheckUpgrade(
+	config *ccev1.CCEClusterConfig, currentVersion, targetVersion string,
+) (*ccev1.CCEClusterConfig, bool, error) {
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return config, false, err
+	}
+	check := config.Status.UpgradePreCheck
+	now := metav1.Now()
+	switch {
+	case check == nil || check.TargetVersion != targetVersion ||
+		upgradePreCheckFailed(check) && now.Sub(check.StartTime.Time) >= upgradePreCheckRetryInterval:
+		res, err := cce.CreatePreCheck(driver.CCE, config.Spec.ClusterID, currentVersion, targetVersion)
+		if err != nil {
+			return config, false, err
+		}
+		if res == nil || res.Metadata == nil {
+			return config, false, fmt.Errorf("CreatePreCheck returns invalid data")
+		}
+		check = &ccev1.CCEUpgradePreCheck{
+			TaskID:        utils.Value(res.Metadata.Uid),
+			TargetVersion: targetVersion,
+			Phase:         cce.PreCheckPhaseInit,
+			StartTime:     now,
+		}
+		logrus.WithFields(logrus.Fields{
+			"cluster": config.Name,
+			"phase":   config.Status.Phase,
+		}).Infof("start pre-upgrade check of cluster [%s] to %q, task ID [%s]",
+			config.Spec.Name, targetVersion, check.TaskID)
+		h.recorder.Eventf(config, corev1.EventTypeNormal, eventReasonUpgrading,
+			"start pre-upgrade check of cluster [%s] to %q, task ID [%s]",
+			config.Spec.Name, targetVersion, check.TaskID)
+	case check.Phase == cce.PreCheckPhaseSuccess:
+		return config, true, nil
+	case upgradePreCheckFailed(check):
+		h.cceEnqueueAfter(config.Namespace, config.Name, upgradePreCheckRetryInterval-now.Sub(check.StartTime.Time))
+		return config, false, nil
+	default:
+		res, err := cce.ShowPreCheck(driver.CCE, config.Spec.ClusterID, check.TaskID)
+		if err != nil {
+			if hwerr, _ := huawei.NewHuaweiError(err); hwerr.StatusCode != 404 {
+				return config, false, err
+			}
+			// Start the check again if the task was removed.
+			check = check.DeepCopy()
+			check.Phase = cce.PreCheckPhaseError
+			check.Message = fmt.Sprintf("pre-upgrade check task [%s] not found", check.TaskID)
+			check.StartTime = metav1.NewTime(now.Add(-upgradePreCheckRetryInterval))
+			break
+		}
+		if res == nil || res.Status == nil {
+			return config, false, fmt.Errorf("ShowPreCheck returns invalid data")
+		}
+		check = check.DeepCopy()
+		check.Phase = utils.Value(res.Status.Phase)
+		switch check.Phase {
+		case cce.PreCheckPhaseSuccess:
+			check.Message = ""
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Infof("pre-upgrade check of cluster [%s] to %q passed", config.Spec.Name, targetVersion)
+		case cce.PreCheckPhaseFailed, cce.PreCheckPhaseError:
+			failures := cce.PreCheckFailures(res.Status)
+			if len(failures) == 0 {
+				failures = append(failures, utils.Value(res.Status.Message))
+			}
+			check.Message = strings.Join(failures, "; ")
+			logrus.WithFields(logrus.Fields{
+				"cluster": config.Name,
+				"phase":   config.Status.Phase,
+			}).Warnf("pre-upgrade check of cluster [%s] to %q failed: %s",
+				config.Spec.Name, targetVersion, check.Message)
+			h.recorder.Eventf(config, corev1.EventTypeWarning, eventReasonFailed,
+				"pre-upgrade check of cluster [%s] to %q failed: %s",
+				config.Spec.Name, targetVersion, check.Message)
+		}
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.UpgradePreCheck = check
+		switch {
+		case check.Phase == cce.PreCheckPhaseSuccess:
+		case upgradePreCheckFailed(check):
+			setCondition(configUpdate, ccev1.ConditionUpgradeInProgress, metav1.ConditionFalse, reasonPreCheckFailed,
+				fmt.Sprintf("pre-upgrade check to [%s] failed, task ID [%s]: %s",
+					check.TargetVersion, check.TaskID, check.Message))
+		default:
+			configUpdate.Status.Phase = cceConfigUpdatingPhase
+			setCondition(configUpdate, ccev1.ConditionUpgradeInProgress, metav1.ConditionTrue, reasonPreChecking,
+				fmt.Sprintf("waiting for pre-upgrade check task [%s] status [%s]", check.TaskID, check.Phase))
+		}
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	if err != nil {
+		return config, false, err
+	}
+	switch {
+	case check.Phase == cce.PreCheckPhaseSuccess:
+		return config, true, nil
+	case upgradePreCheckFailed(check):
+		h.cceEnqueueAfter(config.Namespace, config.Name, upgradePreCheckRetryInterval-now.Sub(check.StartTime.Time))
+	default:
+		h.cceEnqueueAfter(config.Namespace, config.Name, 30*time.Second)
+	}
+	return config, false, nil
+}
+
+// clearUpgradeStatus removes the pre-upgrade check and the upgrade progress
+// from status if the cluster version matches the spec.
+func (h *Handler) clearUpgradeStatus(
+	config *ccev1.CCEClusterConfig, currentVersion string,
+) (*ccev1.CCEClusterConfig, error) {
+	var err error
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config, err = h.cceCC.Get(config.Namespace, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configUpdate := config.DeepCopy()
+		configUpdate.Status.UpgradePreCheck = nil
+		configUpdate.Status.Upgrade = nil
+		setCondition(configUpdate, ccev1.ConditionUpgradeInProgress, metav1.ConditionFalse, reasonClusterVersionSynced,
+			fmt.Sprintf("cluster version is [%s]", currentVersion))
+		config, err = h.cceCC.UpdateStatus(configUpdate)
+		return err
+	})
+	return config, err
+}
+
 func clusterUpgradeable(oldVer, newVer string) (bool, error) {
 	if oldVer == newVer {
 		return false, nil
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_upgradeHops(t *testing.T) {
+	assert := assert.New(t)
+	paths := &cce_model.ListClusterUpgradePathsResponse{
+		UpgradePaths: &[]cce_model.UpgradePath{
+			{Version: utils.Pointer("v1.21"), TargetVersions: &[]string{"v1.23"}},
+			{Version: utils.Pointer("v1.23"), TargetVersions: &[]string{"v1.25"}},
+			{Version: utils.Pointer("v1.25"), TargetVersions: &[]string{"v1.27", "v1.28"}},
+			{Version: utils.Pointer("v1.27"), TargetVersions: &[]string{"v1.28", "v1.29"}},
+			{Version: utils.Pointer("v1.28"), TargetVersions: &[]string{"v1.29"}},
+		},
+	}
+
+	hops, err := upgradeHops(paths, "v1.25", "v1.27")
+	assert.Nil(err)
+	assert.Equal([]string{"v1.27"}, hops)
+
+	// The shortest path is used and the last hop is the spec version.
+	hops, err = upgradeHops(paths, "v1.23.5-r0", "v1.28.1")
+	assert.Nil(err)
+	assert.Equal([]string{"v1.25", "v1.28.1"}, hops)
+
+	hops, err = upgradeHops(paths, "v1.21", "v1.29")
+	assert.Nil(err)
+	assert.Equal([]string{"v1.23", "v1.25", "v1.28", "v1.29"}, hops)
+
+	_, err = upgradeHops(paths, "v1.25", "v1.30")
+	assert.ErrorContains(err, `no supported upgrade path of cluster from "v1.25" to "v1.30"`)
+	_, err = upgradeHops(paths, "v1.25", "v1.25")
+	assert.NotNil(err)
+	_, err = upgradeHops(nil, "v1.25", "v1.27")
+	assert.NotNil(err)
+	_, err = upgradeHops(paths, "invalid", "v1.27")
+	assert.NotNil(err)
+}
@@ -2,12 +2,17 @@ package controller
 
 import (
 	"fmt"
+	"strings"
 
 	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
 	"github.com/cnrancher/cce-operator/pkg/utils"
 	huawei_cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
 )
 
+// cceSystemLabelPrefix is the prefix of the node labels added by CCE.
+const cceSystemLabelPrefix = "cce.cloud.com/"
+
 func BuildUpstreamClusterState(
 	c *huawei_cce_model.ShowClusterResponse,
 	nodePools *huawei_cce_model.ListNodePoolsResponse,
@@ -174,6 +179,39 @@ func BuildUpstreamNodePoolConfigs(
 		if np.Spec.NodeTemplate.Runtime != nil && np.Spec.NodeTemplate.Runtime.Name != nil {
 			config.NodeTemplate.Runtime = np.Spec.NodeTemplate.Runtime.Name.Value()
 		}
+		if np.Spec.NodeTemplate.Taints != nil && len(*np.Spec.NodeTemplate.Taints) > 0 {
+			taints := make([]ccev1.CCENodeTaint, 0, len(*np.Spec.NodeTemplate.Taints))
+			for _, t := range *np.Spec.NodeTemplate.Taints {
+				taints = append(taints, ccev1.CCENodeTaint{
+					Key:    t.Key,
+					Value:  utils.Value(t.Value),
+					Effect: t.Effect.Value(),
+				})
+			}
+			config.NodeTemplate.Taints = &taints
+		}
+		for k, v := range np.Spec.NodeTemplate.K8sTags {
+			// The labels added by CCE are not managed in spec.
+			if strings.HasPrefix(k, cceSystemLabelPrefix) {
+				continue
+			}
+			if config.NodeTemplate.K8sTags == nil {
+				config.NodeTemplate.K8sTags = &map[string]string{}
+			}
+			(*config.NodeTemplate.K8sTags)[k] = v
+		}
+		if np.Spec.NodeTemplate.UserTags != nil && len(*np.Spec.NodeTemplate.UserTags) > 0 {
+			userTags := map[string]string{}
+			for _, t := range *np.Spec.NodeTemplate.UserTags {
+				userTags[utils.Value(t.Key)] = utils.Value(t.Value)
+			}
+			config.NodeTemplate.UserTags = &userTags
+		}
+		if p := np.Spec.NodeTemplate.ExtendParam; p != nil {
+			config.NodeTemplate.PreInstall = cce.DecodeNodeScript(utils.Value(p.AlphaCcePreInstall))
+			config.NodeTemplate.PostInstall = cce.DecodeNodeScript(utils.Value(p.AlphaCcePostInstall))
+			config.NodeTemplate.MaxPods = utils.Value(p.MaxPods)
+		}
 		if np.Spec.PodSecurityGroups != nil && len(*np.Spec.PodSecurityGroups) > 0 {
 			for _, pg := range *np.Spec.PodSecurityGroups {
 				config.PodSecurityGroups = append(config.PodSecurityGroups, utils.Value(pg.Id))
@@ -186,3 +224,117 @@ func BuildUpstreamNodePoolConfigs(
 	}
 	return nps, nil
 }
+
+// BuildNodePoolStatus builds the status of the node pools, the nodes are
+// grouped into node pools by the node pool ID annotation.
+func BuildNodePoolStatus(
+	nodePools *huawei_cce_model.ListNodePoolsResponse,
+	nodes *huawei_cce_model.ListNodesResponse,
+) []ccev1.CCENodePoolStatus {
+	if nodePools == nil || nodePools.Items == nil || len(*nodePools.Items) == 0 {
+		return nil
+	}
+	nodeNames := map[string][]string{}
+	if nodes != nil && nodes.Items != nil {
+		for _, n := range *nodes.Items {
+			if n.Metadata == nil || n.Metadata.Annotations == nil {
+				continue
+			}
+			id := n.Metadata.Annotations[cce.NodePoolIDAnnotationKey]
+			nodeNames[id] = append(nodeNames[id], utils.Value(n.Metadata.Name))
+		}
+	}
+
+	var status []ccev1.CCENodePoolStatus
+	for _, np := range *nodePools.Items {
+		if np.Metadata == nil {
+			continue
+		}
+		s := ccev1.CCENodePoolStatus{
+			Name: np.Metadata.Name,
+			ID:   utils.Value(np.Metadata.Uid),
+		}
+		if np.Spec != nil {
+			s.DesiredNodes = utils.Value(np.Spec.InitialNodeCount)
+		}
+		if np.Status != nil {
+			if np.Status.Phase != nil {
+				s.Phase = np.Status.Phase.Value()
+			}
+			s.CurrentNodes = utils.Value(np.Status.CurrentNode)
+			s.JobID = utils.Value(np.Status.JobId)
+			s.LastError = nodePoolLastError(np.Status.Conditions)
+		}
+		if s.ID != "" {
+			s.Nodes = nodeNames[s.ID]
+		}
+		status = append(status, s)
+	}
+	return status
+}
+
+// nodeTemplateDrift returns the node template fields which cannot be updated
+// or replaced and are different from the upstream. The scripts are not
+// included in the result.
+func nodeTemplateDrift(template, upstream *ccev1.CCENodeTemplate) []string {
+	var drift []string
+	if template.PreInstall != "" && template.PreInstall != upstream.PreInstall {
+		drift = append(drift, "preInstall")
+	}
+	if template.PostInstall != "" && template.PostInstall != upstream.PostInstall {
+		drift = append(drift, "postInstall")
+	}
+	if template.MaxPods != 0 && template.MaxPods != upstream.MaxPods {
+		drift = append(drift, fmt.Sprintf("maxPods [%d] -> [%d]", upstream.MaxPods, template.MaxPods))
+	}
+	return drift
+}
+
+// setNodePoolDrift sets the drift of the node pool status by comparing the
+// node pools in spec with the upstream node pools.
+func setNodePoolDrift(status []ccev1.CCENodePoolStatus, nodePools, upstreamNodePools []ccev1.CCENodePool) {
+	upstream := make(map[string]*ccev1.CCENodePool, len(upstreamNodePools))
+	for i := range upstreamNodePools {
+		upstream[upstreamNodePools[i].ID] = &upstreamNodePools[i]
+	}
+	templates := make(map[string]*ccev1.CCENodeTemplate, len(nodePools))
+	for i := range nodePools {
+		if nodePools[i].ID != "" {
+			templates[nodePools[i].ID] = &nodePools[i].NodeTemplate
+		}
+	}
+	for i := range status {
+		template, ok := templates[status[i].ID]
+		if !ok || upstream[status[i].ID] == nil {
+			continue
+		}
+		status[i].Drift = strings.Join(nodeTemplateDrift(template, &upstream[status[i].ID].NodeTemplate), "; ")
+	}
+}
+
+// nodePoolLastError returns the message of the latest node pool condition
+// which makes the node pool not scalable.
+func nodePoolLastError(conditions *[]huawei_cce_model.NodePoolCondition) string {
+	if conditions == nil {
+		return ""
+	}
+	var (
+		message        string
+		lastTransition string
+	)
+	for _, c := range *conditions {
+		t, status := utils.Value(c.Type), utils.Value(c.Status)
+		if t == "Scalable" && status != "False" || t != "Scalable" && status != "True" {
+			continue
+		}
+		if message != "" && utils.Value(c.LastTransitTime) < lastTransition {
+			continue
+		}
+		lastTransition = utils.Value(c.LastTransitTime)
+		message = fmt.Sprintf("%s: %s", t, utils.Value(c.Reason))
+		if m := utils.Value(c.Message); m != "" {
+			message += " " + m
+		}
+	}
+	return message
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	cce_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BuildNodePoolStatus(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(BuildNodePoolStatus(nil, nil))
+	assert.Nil(BuildNodePoolStatus(&cce_model.ListNodePoolsResponse{
+		Items: &[]cce_model.NodePoolResp{},
+	}, nil))
+
+	config := newMockConfig(cceConfigActivePhase)
+	np1 := newMockNodePool(config.Spec.ClusterID, config.Spec.NodePools[0], nil)
+	np2Config := config.Spec.NodePools[0]
+	np2Config.Name = "nodepool-2"
+	np2Config.ID = "mock-nodepool-2-id"
+	phase := cce_model.GetNodePoolStatusPhaseEnum().ERROR
+	np2 := newMockNodePool(config.Spec.ClusterID, np2Config, &phase)
+	np2.Status.JobId = utils.Pointer("mock-job-id")
+	np2.Status.Conditions = &[]cce_model.NodePoolCondition{
+		{
+			Type:            utils.Pointer("Scalable"),
+			Status:          utils.Pointer("True"),
+			LastTransitTime: utils.Pointer("2024-01-01T00:00:00Z"),
+		},
+		{
+			Type:            utils.Pointer("Error"),
+			Status:          utils.Pointer("True"),
+			Reason:          utils.Pointer("DeleteFailed"),
+			LastTransitTime: utils.Pointer("2024-01-02T00:00:00Z"),
+		},
+		{
+			Type:            utils.Pointer("QuotaInsufficient"),
+			Status:          utils.Pointer("True"),
+			Reason:          utils.Pointer("InstanceQuota"),
+			Message:         utils.Pointer("quota exceeded"),
+			LastTransitTime: utils.Pointer("2024-01-01T00:00:00Z"),
+		},
+	}
+	nodes := []cce_model.Node{
+		newMockNode("node-1", "mock-nodepool-1-id", cce_model.GetNodeStatusPhaseEnum().ACTIVE),
+		newMockNode("node-2", "mock-nodepool-1-id", cce_model.GetNodeStatusPhaseEnum().BUILD),
+		newMockNode("node-3", "", cce_model.GetNodeStatusPhaseEnum().ACTIVE),
+	}
+
+	status := BuildNodePoolStatus(
+		&cce_model.ListNodePoolsResponse{Items: &[]cce_model.NodePoolResp{np1, np2}},
+		&cce_model.ListNodesResponse{Items: &nodes},
+	)
+	assert.Equal([]ccev1.CCENodePoolStatus{
+		{
+			Name:         "nodepool-1",
+			ID:           "mock-nodepool-1-id",
+			CurrentNodes: 2,
+			DesiredNodes: 2,
+			Nodes:        []string{"node-1", "node-2"},
+		},
+		{
+			Name:         "nodepool-2",
+			ID:           "mock-nodepool-2-id",
+			Phase:        "Error",
+			CurrentNodes: 2,
+			DesiredNodes: 2,
+			JobID:        "mock-job-id",
+			LastError:    "Error: DeleteFailed",
+		},
+	}, status)
+}
+
+func Test_setNodePoolDrift(t *testing.T) {
+	assert := assert.New(t)
+	status := []ccev1.CCENodePoolStatus{
+		{Name: "nodepool-1", ID: "np-1"},
+		{Name: "nodepool-2", ID: "np-2"},
+		{Name: "nodepool-3", ID: "np-3"},
+	}
+	nodePools := []ccev1.CCENodePool{
+		{Name: "nodepool-1", ID: "np-1", NodeTemplate: ccev1.CCENodeTemplate{
+			PreInstall:  "echo pre",
+			PostInstall: "echo post",
+			MaxPods:     64,
+		}},
+		{Name: "nodepool-2", ID: "np-2", NodeTemplate: ccev1.CCENodeTemplate{
+			PreInstall: "echo pre",
+			MaxPods:    64,
+		}},
+	}
+	upstream := []ccev1.CCENodePool{
+		{Name: "nodepool-1", ID: "np-1", NodeTemplate: ccev1.CCENodeTemplate{MaxPods: 110}},
+		{Name: "nodepool-2", ID: "np-2", NodeTemplate: ccev1.CCENodeTemplate{
+			PreInstall:  "echo pre",
+			PostInstall: "echo post",
+			MaxPods:     64,
+		}},
+		{Name: "nodepool-3", ID: "np-3", NodeTemplate: ccev1.CCENodeTemplate{MaxPods: 110}},
+	}
+	setNodePoolDrift(status, nodePools, upstream)
+	assert.Equal("preInstall; postInstall; maxPods [110] -> [64]", status[0].Drift)
+	// The fields not set in spec are not compared.
+	assert.Empty(status[1].Drift)
+	assert.Empty(status[2].Drift)
+}
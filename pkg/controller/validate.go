@@ -2,6 +2,7 @@ package controller
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/Masterminds/semver/v3"
 	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
@@ -49,12 +50,40 @@ func validateNodePool(config *ccev1.CCEClusterConfig) error {
 		if nt.OperatingSystem == "" {
 			return fmt.Errorf(cannotBeEmptyError, "nodePool.nodeTemplate.operatingSystem", config.Name)
 		}
+		var taints []ccev1.CCENodeTaint
+		if nt.Taints != nil {
+			taints = *nt.Taints
+		}
+		for _, t := range taints {
+			if t.Key == "" {
+				return fmt.Errorf(cannotBeEmptyError, "nodePool.nodeTemplate.taints.key", config.Name)
+			}
+			switch t.Effect {
+			case "NoSchedule", "PreferNoSchedule", "NoExecute":
+			default:
+				return fmt.Errorf("invalid effect [%s] of taint [%s] in nodePool [%s], "+
+					"should be one of NoSchedule, PreferNoSchedule or NoExecute", t.Effect, t.Key, pool.Name)
+			}
+		}
+		if nt.MaxPods < 0 {
+			return fmt.Errorf("invalid maxPods [%d] of nodePool [%s]", nt.MaxPods, pool.Name)
+		}
+		if hp := pool.HealthPolicy; hp.UnhealthyTimeout < 0 {
+			return fmt.Errorf("invalid healthPolicy.unhealthyTimeout [%d] of nodePool [%s]",
+				hp.UnhealthyTimeout, pool.Name)
+		} else if hp.MaxUnhealthyPercentage < 0 || hp.MaxUnhealthyPercentage > 100 {
+			return fmt.Errorf("invalid healthPolicy.maxUnhealthyPercentage [%d] of nodePool [%s], "+
+				"should be between 0 and 100", hp.MaxUnhealthyPercentage, pool.Name)
+		}
 	}
 	return nil
 }
 
 func (h *Handler) validateCreate(config *ccev1.CCEClusterConfig) error {
-	driver := h.drivers[config.Spec.HuaweiCredentialSecret]
+	driver, err := h.driver(&config.Spec)
+	if err != nil {
+		return err
+	}
 	// Check for existing cceclusterconfigs with the same display name
 	cceConfigs, err := h.cceCC.List(config.Namespace, metav1.ListOptions{})
 	if err != nil {
@@ -66,7 +95,46 @@ func (h *Handler) validateCreate(config *ccev1.CCEClusterConfig) error {
 				"exists with the same name", config.Spec.Name)
 		}
 	}
+	if err := ValidateCreate(config); err != nil {
+		return err
+	}
 
+	if config.Spec.Imported {
+		_, err := cce.ShowCluster(driver.CCE, config.Spec.ClusterID)
+		if err != nil {
+			hwerr, _ := huawei.NewHuaweiError(err)
+			if hwerr.StatusCode == 404 {
+				return fmt.Errorf("failed to find cluster [%s]: %v",
+					config.Spec.ClusterID, hwerr.ErrorMessage)
+			}
+			return err
+		}
+		return nil
+	}
+	// Cluster may already created, skip validation.
+	if config.Spec.ClusterID != "" {
+		return nil
+	}
+	listClustersRes, err := cce.ListClusters(driver.CCE)
+	if err != nil {
+		return err
+	}
+	if listClustersRes == nil || listClustersRes.Items == nil {
+		return fmt.Errorf("ListClusters returns invalid data")
+	}
+	for _, cluster := range *listClustersRes.Items {
+		if config.Spec.Name == cluster.Metadata.Name {
+			return fmt.Errorf("cannot create cluster [%s] because a cluster"+
+				" in CCE exists with the same name", cluster.Metadata.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateCreate validates the spec of the config to be created without
+// requesting the Huawei Cloud API, it is used by both the controller and the
+// admission webhook.
+func ValidateCreate(config *ccev1.CCEClusterConfig) error {
 	if config.Spec.HuaweiCredentialSecret == "" {
 		return fmt.Errorf(cannotBeEmptyError, "huaweiCredentialSecret", config.Name)
 	}
@@ -76,91 +144,227 @@ func (h *Handler) validateCreate(config *ccev1.CCEClusterConfig) error {
 	if config.Spec.Name == "" {
 		return fmt.Errorf(cannotBeEmptyError, "name", config.Name)
 	}
+	if err := validateDeletionPolicy(config); err != nil {
+		return err
+	}
 
 	if config.Spec.Imported {
 		if config.Spec.ClusterID == "" {
 			return fmt.Errorf(cannotBeEmptyError, "clusterID", config.Name)
 		}
-		_, err := cce.ShowCluster(driver.CCE, config.Spec.ClusterID)
-		if err != nil {
-			hwerr, _ := huawei.NewHuaweiError(err)
-			if hwerr.StatusCode == 404 {
-				return fmt.Errorf("failed to find cluster [%s]: %v",
-					config.Spec.ClusterID, hwerr.ErrorMessage)
-			}
-			return err
+		return nil
+	}
+	// Cluster may already created, skip validation.
+	if config.Spec.ClusterID != "" {
+		return nil
+	}
+	if config.Spec.Type == "" {
+		return fmt.Errorf(cannotBeEmptyError, "type", config.Name)
+	}
+	if config.Spec.Flavor == "" {
+		return fmt.Errorf(cannotBeEmptyError, "flavor", config.Name)
+	}
+	if config.Spec.Version == "" {
+		return fmt.Errorf(cannotBeEmptyError, "version", config.Name)
+	}
+	if err := validateVersion(config); err != nil {
+		return err
+	}
+	if config.Spec.KubernetesSvcIPRange == "" {
+		return fmt.Errorf(cannotBeEmptyError, "kubernetesSvcIPRange", config.Name)
+	}
+	if config.Spec.ExtendParam.ClusterExternalIP != "" || config.Spec.PublicIP.CreateEIP {
+		if !config.Spec.PublicAccess {
+			return fmt.Errorf("'publicAccess' can not be 'false' when 'clusterExternalIP' provided " +
+				"or 'publicIP.createEIP' is true")
 		}
-	} else {
-		// Cluster may already created, skip validation.
-		if config.Spec.ClusterID != "" {
-			return nil
+	}
+	if config.Spec.PublicAccess {
+		if config.Spec.ExtendParam.ClusterExternalIP == "" && !config.Spec.PublicIP.CreateEIP {
+			return fmt.Errorf(
+				"should provide 'clusterExternalIP' or setup 'publicIP' if 'publicAccess' is true")
 		}
-		listClustersRes, err := cce.ListClusters(driver.CCE)
-		if err != nil {
-			return err
+		if config.Spec.PublicIP.CreateEIP && config.Spec.PublicIP.Eip.Bandwidth.Size == 0 {
+			return fmt.Errorf(
+				"'publicIP.eip.bandwidth.size' should be configured when 'createEIP' is true")
 		}
-		if listClustersRes == nil || listClustersRes.Items == nil {
-			return fmt.Errorf("ListClusters returns invalid data")
+	}
+	if config.Spec.NatGateway.Enabled {
+		if config.Spec.NatGateway.ExistingEIPID == "" && config.Spec.NatGateway.SNatRuleEIP.Bandwidth.Size == 0 {
+			return fmt.Errorf(
+				"'natGateway.publicIP' should be configured when NAT enabled and 'existingEIPID' not provided")
 		}
-		for _, cluster := range *listClustersRes.Items {
-			if config.Spec.Name == cluster.Metadata.Name {
-				return fmt.Errorf("cannot create cluster [%s] because a cluster"+
-					" in CCE exists with the same name", cluster.Metadata.Name)
-			}
+	}
+	if len(config.Spec.NodePools) == 0 {
+		return fmt.Errorf(cannotBeEmptyError, "nodePools", config.Name)
+	}
+	if err := validateAddons(config); err != nil {
+		return err
+	}
+	if err := validateUpgradeStrategy(config); err != nil {
+		return err
+	}
+	if err := validateMaintenanceWindows(config); err != nil {
+		return err
+	}
+	return validateNodePool(config)
+}
+
+func validateVersion(config *ccev1.CCEClusterConfig) error {
+	if config.Spec.Version == "" {
+		return nil
+	}
+	if _, err := semver.NewVersion(config.Spec.Version + ".0"); err != nil {
+		return fmt.Errorf("improper version format for cluster [%s]: %s, %v",
+			config.Spec.Name, config.Spec.Version, err)
+	}
+	return nil
+}
+
+func validateAddons(config *ccev1.CCEClusterConfig) error {
+	names := map[string]bool{}
+	for i := range config.Spec.Addons {
+		addon := &config.Spec.Addons[i]
+		if addon.Name == "" {
+			return fmt.Errorf(cannotBeEmptyError, "addon.name", config.Name)
 		}
-		if config.Spec.Type == "" {
-			return fmt.Errorf(cannotBeEmptyError, "type", config.Name)
+		if names[addon.Name] {
+			return fmt.Errorf("addon [%s] is duplicated in cluster [%s]", addon.Name, config.Name)
 		}
-		if config.Spec.Flavor == "" {
-			return fmt.Errorf(cannotBeEmptyError, "flavor", config.Name)
+		names[addon.Name] = true
+		if _, err := cce.AddonValues(addon); err != nil {
+			return err
 		}
-		if config.Spec.Version == "" {
-			return fmt.Errorf(cannotBeEmptyError, "version", config.Name)
+	}
+	threshold := config.Spec.ClusterAutoscaler.ScaleDownUtilizationThreshold
+	if threshold != "" {
+		f, err := strconv.ParseFloat(threshold, 64)
+		if err != nil || f < 0 || f > 1 {
+			return fmt.Errorf("invalid clusterAutoscaler.scaleDownUtilizationThreshold [%s] of cluster [%s], "+
+				"should be a number between 0 and 1", threshold, config.Name)
 		}
-		if config.Spec.KubernetesSvcIPRange == "" {
-			return fmt.Errorf(cannotBeEmptyError, "kubernetesSvcIPRange", config.Name)
+	}
+	switch config.Spec.ClusterAutoscaler.Expander {
+	case "", "random", "most-pods", "least-waste", "priority":
+	default:
+		return fmt.Errorf("invalid clusterAutoscaler.expander [%s] of cluster [%s], "+
+			"should be one of random, most-pods, least-waste or priority",
+			config.Spec.ClusterAutoscaler.Expander, config.Name)
+	}
+	return nil
+}
+
+func validateNodeOperations(config *ccev1.CCEClusterConfig) error {
+	nodes := map[string]bool{}
+	for _, op := range config.Spec.NodeOperations {
+		if op.Node == "" {
+			return fmt.Errorf(cannotBeEmptyError, "nodeOperation.node", config.Name)
 		}
-		if config.Spec.ExtendParam.ClusterExternalIP != "" || config.Spec.PublicIP.CreateEIP {
-			if !config.Spec.PublicAccess {
-				return fmt.Errorf("'publicAccess' can not be 'false' when 'clusterExternalIP' provided " +
-					"or 'publicIP.createEIP' is true")
-			}
+		switch op.Action {
+		case ccev1.NodeActionReplace, ccev1.NodeActionDelete, ccev1.NodeActionReboot:
+		default:
+			return fmt.Errorf("invalid action [%s] of node operation [%s] in cluster [%s], "+
+				"should be one of %s, %s or %s", op.Action, op.Node, config.Name,
+				ccev1.NodeActionReplace, ccev1.NodeActionDelete, ccev1.NodeActionReboot)
 		}
-		if config.Spec.PublicAccess {
-			if config.Spec.ExtendParam.ClusterExternalIP == "" && !config.Spec.PublicIP.CreateEIP {
-				return fmt.Errorf(
-					"should provide 'clusterExternalIP' or setup 'publicIP' if 'publicAccess' is true")
-			}
-			if config.Spec.PublicIP.CreateEIP && config.Spec.PublicIP.Eip.Bandwidth.Size == 0 {
-				return fmt.Errorf(
-					"'publicIP.eip.bandwidth.size' should be configured when 'createEIP' is true")
-			}
+		if nodes[op.Node] {
+			return fmt.Errorf("node [%s] has more than one operation in cluster [%s]", op.Node, config.Name)
 		}
-		if config.Spec.NatGateway.Enabled {
-			if config.Spec.NatGateway.ExistingEIPID == "" && config.Spec.NatGateway.SNatRuleEIP.Bandwidth.Size == 0 {
-				return fmt.Errorf(
-					"'natGateway.publicIP' should be configured when NAT enabled and 'existingEIPID' not provided")
-			}
+		nodes[op.Node] = true
+	}
+	return nil
+}
+
+func validateUpgradeStrategy(config *ccev1.CCEClusterConfig) error {
+	strategy := &config.Spec.UpgradeStrategy
+	switch strategy.Type {
+	case "", ccev1.UpgradeStrategyInPlaceRollingUpdate:
+	default:
+		return fmt.Errorf("invalid upgradeStrategy.type [%s] of cluster [%s], only %s is supported",
+			strategy.Type, config.Name, ccev1.UpgradeStrategyInPlaceRollingUpdate)
+	}
+	if strategy.BatchSize < 0 || strategy.BatchSize > 40 {
+		return fmt.Errorf("invalid upgradeStrategy.batchSize [%d] of cluster [%s], should be between 1 and 40",
+			strategy.BatchSize, config.Name)
+	}
+	nodePools := map[string]bool{}
+	for _, np := range config.Spec.NodePools {
+		nodePools[np.Name] = true
+	}
+	ordered := map[string]bool{}
+	for _, name := range strategy.NodePoolOrder {
+		if !nodePools[name] {
+			return fmt.Errorf("nodePool [%s] in upgradeStrategy.nodePoolOrder not found in cluster [%s]",
+				name, config.Name)
+		}
+		if ordered[name] {
+			return fmt.Errorf("nodePool [%s] is duplicated in upgradeStrategy.nodePoolOrder of cluster [%s]",
+				name, config.Name)
 		}
-		if len(config.Spec.NodePools) == 0 {
-			return fmt.Errorf(cannotBeEmptyError, "nodePools", config.Name)
+		ordered[name] = true
+	}
+	addons := map[string]bool{}
+	for i := range strategy.Addons {
+		addon := &strategy.Addons[i]
+		if addon.Name == "" {
+			return fmt.Errorf(cannotBeEmptyError, "upgradeStrategy.addon.name", config.Name)
+		}
+		if addon.Version == "" {
+			return fmt.Errorf("version of upgradeStrategy.addon [%s] cannot be empty for cluster [%s]",
+				addon.Name, config.Name)
 		}
-		if err = validateNodePool(config); err != nil {
+		if addons[addon.Name] {
+			return fmt.Errorf("addon [%s] is duplicated in upgradeStrategy.addons of cluster [%s]",
+				addon.Name, config.Name)
+		}
+		addons[addon.Name] = true
+		if _, err := cce.AddonValues(addon); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
-func validateUpdate(config *ccev1.CCEClusterConfig) error {
-	if config.Spec.Version != "" {
-		var err error
-		_, err = semver.NewVersion(config.Spec.Version + ".0")
-		if err != nil {
-			return fmt.Errorf("improper version format for cluster [%s]: %s, %v",
-				config.Spec.Name, config.Spec.Version, err)
+func validateMaintenanceWindows(config *ccev1.CCEClusterConfig) error {
+	for i := range config.Spec.MaintenanceWindows {
+		w := &config.Spec.MaintenanceWindows[i]
+		for _, d := range w.Days {
+			if _, ok := parseWeekday(d); !ok {
+				return fmt.Errorf("invalid day [%s] of maintenanceWindow in cluster [%s], "+
+					"should be a weekday such as Sat or Saturday", d, config.Name)
+			}
+		}
+		if _, _, err := parseClock(w.StartTime); err != nil {
+			return fmt.Errorf("invalid startTime of maintenanceWindow in cluster [%s]: %w", config.Name, err)
+		}
+		if _, _, err := parseClock(w.EndTime); err != nil {
+			return fmt.Errorf("invalid endTime of maintenanceWindow in cluster [%s]: %w", config.Name, err)
 		}
+		if w.StartTime == w.EndTime {
+			return fmt.Errorf("startTime and endTime of maintenanceWindow in cluster [%s] cannot be the same",
+				config.Name)
+		}
+		if _, err := maintenanceWindowLocation(w); err != nil {
+			return fmt.Errorf("invalid timeZone of maintenanceWindow in cluster [%s]: %w", config.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateDeletionPolicy(config *ccev1.CCEClusterConfig) error {
+	switch config.Spec.DeletionPolicy {
+	case "", ccev1.DeletionPolicyDelete, ccev1.DeletionPolicyRetain, ccev1.DeletionPolicyRetainNetwork:
+		return nil
+	}
+	return fmt.Errorf("invalid deletionPolicy [%s] of cluster [%s], should be one of %s, %s or %s",
+		config.Spec.DeletionPolicy, config.Name, ccev1.DeletionPolicyDelete,
+		ccev1.DeletionPolicyRetain, ccev1.DeletionPolicyRetainNetwork)
+}
+
+// ValidateUpdate validates the spec of the config to be updated.
+func ValidateUpdate(config *ccev1.CCEClusterConfig) error {
+	if err := validateVersion(config); err != nil {
+		return err
 	}
 	if config.Spec.Name == "" {
 		return fmt.Errorf(cannotBeEmptyError, "name", config.Name)
@@ -171,6 +375,9 @@ func validateUpdate(config *ccev1.CCEClusterConfig) error {
 	if config.Spec.HuaweiCredentialSecret == "" {
 		return fmt.Errorf(cannotBeEmptyError, "huaweiCredentialSecret", config.Name)
 	}
+	if err := validateDeletionPolicy(config); err != nil {
+		return err
+	}
 	if config.Spec.Imported {
 		if config.Spec.ClusterID == "" {
 			return fmt.Errorf(cannotBeEmptyError, "clusterID", config.Name)
@@ -183,6 +390,77 @@ func validateUpdate(config *ccev1.CCEClusterConfig) error {
 	if len(config.Spec.NodePools) == 0 {
 		return fmt.Errorf(cannotBeEmptyError, "nodePools", config.Name)
 	}
+	if err := validateAddons(config); err != nil {
+		return err
+	}
+	if err := validateNodeOperations(config); err != nil {
+		return err
+	}
+	if err := validateUpgradeStrategy(config); err != nil {
+		return err
+	}
+	if err := validateMaintenanceWindows(config); err != nil {
+		return err
+	}
 
 	return validateNodePool(config)
 }
+
+// ValidateImmutable checks the fields which cannot be changed once the
+// cluster was created or imported.
+func ValidateImmutable(old, config *ccev1.CCEClusterConfig) error {
+	if old.Spec.ClusterID == "" {
+		return nil
+	}
+	oldSpec, spec := old.Spec.DeepCopy(), config.Spec.DeepCopy()
+	SetDefaults(oldSpec)
+	SetDefaults(spec)
+
+	fields := []struct {
+		name     string
+		old, new any
+	}{
+		{"regionID", oldSpec.RegionID, spec.RegionID},
+		{"category", oldSpec.Category, spec.Category},
+		{"type", oldSpec.Type, spec.Type},
+		{"containerNetwork", oldSpec.ContainerNetwork, spec.ContainerNetwork},
+		{"kubernetesSvcIPRange", oldSpec.KubernetesSvcIPRange, spec.KubernetesSvcIPRange},
+		{"hostNetwork.vpcID", oldSpec.HostNetwork.VpcID, spec.HostNetwork.VpcID},
+		{"hostNetwork.subnetID", oldSpec.HostNetwork.SubnetID, spec.HostNetwork.SubnetID},
+	}
+	for _, f := range fields {
+		if f.old != f.new {
+			return fmt.Errorf("field [%s] is immutable after the cluster [%s] was created", f.name, config.Name)
+		}
+	}
+
+	// The scripts and max pods of the node template cannot be updated after
+	// the node pool was created.
+	oldNodePools := map[string]*ccev1.CCENodePool{}
+	for i := range oldSpec.NodePools {
+		if oldSpec.NodePools[i].ID != "" {
+			oldNodePools[oldSpec.NodePools[i].ID] = &oldSpec.NodePools[i]
+		}
+	}
+	for _, np := range spec.NodePools {
+		oldNP, ok := oldNodePools[np.ID]
+		if np.ID == "" || !ok {
+			continue
+		}
+		fields = []struct {
+			name     string
+			old, new any
+		}{
+			{"nodeTemplate.preInstall", oldNP.NodeTemplate.PreInstall, np.NodeTemplate.PreInstall},
+			{"nodeTemplate.postInstall", oldNP.NodeTemplate.PostInstall, np.NodeTemplate.PostInstall},
+			{"nodeTemplate.maxPods", oldNP.NodeTemplate.MaxPods, np.NodeTemplate.MaxPods},
+		}
+		for _, f := range fields {
+			if f.old != f.new {
+				return fmt.Errorf("field [%s] of nodePool [%s] is immutable after the nodePool was created",
+					f.name, np.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidateCreate(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(ValidateCreate(newTestConfig("cce-test")))
+
+	config := newTestConfig("cce-test")
+	config.Spec.NodePools[0].NodeTemplate.SSHKey = ""
+	assert.ErrorContains(ValidateCreate(config), "nodePool.nodeTemplate.sshKey")
+
+	config = newTestConfig("cce-test")
+	config.Spec.NodePools = append(config.Spec.NodePools, config.Spec.NodePools[0])
+	assert.ErrorContains(ValidateCreate(config), "duplicated")
+
+	config = newTestConfig("cce-test")
+	config.Spec.PublicIP.CreateEIP = false
+	assert.ErrorContains(ValidateCreate(config), "publicAccess")
+
+	config = newTestConfig("cce-test")
+	config.Spec.Version = "1.x"
+	assert.ErrorContains(ValidateCreate(config), "improper version")
+
+	config = newTestConfig("cce-test")
+	config.Spec.DeletionPolicy = "Orphan"
+	assert.ErrorContains(ValidateCreate(config), "invalid deletionPolicy")
+	config.Spec.DeletionPolicy = ccev1.DeletionPolicyRetainNetwork
+	assert.Nil(ValidateCreate(config))
+
+	config = newTestConfig("cce-test")
+	config.Spec.Addons = []ccev1.CCEAddon{{Name: "coredns"}, {Name: "coredns"}}
+	assert.ErrorContains(ValidateCreate(config), "addon [coredns] is duplicated")
+
+	config = newTestConfig("cce-test")
+	config.Spec.NodePools[0].NodeTemplate.Taints = &[]ccev1.CCENodeTaint{{Key: "dedicated", Effect: "NoRun"}}
+	assert.ErrorContains(ValidateCreate(config), "invalid effect [NoRun] of taint [dedicated]")
+	config.Spec.NodePools[0].NodeTemplate.Taints = &[]ccev1.CCENodeTaint{{Key: "dedicated", Effect: "NoExecute"}}
+	assert.Nil(ValidateCreate(config))
+
+	config = newTestConfig("cce-test")
+	config.Spec.NodePools[0].HealthPolicy = ccev1.CCENodeHealthPolicy{Enabled: true, MaxUnhealthyPercentage: 120}
+	assert.ErrorContains(ValidateCreate(config), "invalid healthPolicy.maxUnhealthyPercentage [120]")
+	config.Spec.NodePools[0].HealthPolicy.MaxUnhealthyPercentage = 40
+	config.Spec.NodePools[0].HealthPolicy.UnhealthyTimeout = -1
+	assert.ErrorContains(ValidateCreate(config), "invalid healthPolicy.unhealthyTimeout [-1]")
+	config.Spec.NodePools[0].HealthPolicy.UnhealthyTimeout = 5
+	assert.Nil(ValidateCreate(config))
+
+	config = newTestConfig("cce-test")
+	config.Spec.ClusterAutoscaler.ScaleDownUtilizationThreshold = "1.5"
+	assert.ErrorContains(ValidateCreate(config), "invalid clusterAutoscaler.scaleDownUtilizationThreshold")
+	config.Spec.ClusterAutoscaler.ScaleDownUtilizationThreshold = "0.5"
+	config.Spec.ClusterAutoscaler.Expander = "fastest"
+	assert.ErrorContains(ValidateCreate(config), "invalid clusterAutoscaler.expander")
+	config.Spec.ClusterAutoscaler.Expander = "least-waste"
+	assert.Nil(ValidateCreate(config))
+
+	config = newTestConfig("cce-test")
+	config.Spec.Imported = true
+	assert.ErrorContains(ValidateCreate(config), "clusterID")
+	config.Spec.ClusterID = "mock-cluster-id"
+	config.Spec.NodePools = nil
+	assert.Nil(ValidateCreate(config))
+}
+
+func Test_ValidateUpdate(t *testing.T) {
+	assert := assert.New(t)
+	config := newMockConfig(cceConfigActivePhase)
+	config.Spec.NodeOperations = []ccev1.CCENodeOperation{
+		{Node: "10.224.0.10", Action: ccev1.NodeActionReplace},
+		{Node: "mock-node-2-id", Action: ccev1.NodeActionReboot},
+	}
+	assert.Nil(ValidateUpdate(config))
+
+	config.Spec.NodeOperations[1].Action = "Restart"
+	assert.ErrorContains(ValidateUpdate(config), "invalid action [Restart] of node operation [mock-node-2-id]")
+	config.Spec.NodeOperations[1] = ccev1.CCENodeOperation{Node: "10.224.0.10", Action: ccev1.NodeActionDelete}
+	assert.ErrorContains(ValidateUpdate(config), "node [10.224.0.10] has more than one operation")
+	config.Spec.NodeOperations[1].Node = ""
+	assert.ErrorContains(ValidateUpdate(config), "nodeOperation.node")
+
+	config = newMockConfig(cceConfigActivePhase)
+	config.Spec.UpgradeStrategy = ccev1.CCEUpgradeStrategy{
+		Type:          ccev1.UpgradeStrategyInPlaceRollingUpdate,
+		BatchSize:     10,
+		NodePoolOrder: []string{config.Spec.NodePools[0].Name},
+		Addons:        []ccev1.CCEAddon{{Name: "coredns", Version: "1.28.4"}},
+	}
+	assert.Nil(ValidateUpdate(config))
+	config.Spec.UpgradeStrategy.Type = "replace"
+	assert.ErrorContains(ValidateUpdate(config), "invalid upgradeStrategy.type [replace]")
+	config.Spec.UpgradeStrategy.Type = ""
+	config.Spec.UpgradeStrategy.BatchSize = 50
+	assert.ErrorContains(ValidateUpdate(config), "invalid upgradeStrategy.batchSize [50]")
+	config.Spec.UpgradeStrategy.BatchSize = 0
+	config.Spec.UpgradeStrategy.NodePoolOrder = []string{"nodepool-x"}
+	assert.ErrorContains(ValidateUpdate(config), "nodePool [nodepool-x] in upgradeStrategy.nodePoolOrder not found")
+	config.Spec.UpgradeStrategy.NodePoolOrder = nil
+	config.Spec.UpgradeStrategy.Addons[0].Version = ""
+	assert.ErrorContains(ValidateUpdate(config), "version of upgradeStrategy.addon [coredns] cannot be empty")
+
+	config = newMockConfig(cceConfigActivePhase)
+	config.Spec.MaintenanceWindows = []ccev1.CCEMaintenanceWindow{
+		{Days: []string{"Sat", "sunday"}, StartTime: "22:00", EndTime: "06:00", TimeZone: "Asia/Shanghai"},
+	}
+	assert.Nil(ValidateUpdate(config))
+	config.Spec.MaintenanceWindows[0].Days = []string{"weekend"}
+	assert.ErrorContains(ValidateUpdate(config), "invalid day [weekend] of maintenanceWindow")
+	config.Spec.MaintenanceWindows[0].Days = nil
+	config.Spec.MaintenanceWindows[0].StartTime = "25:00"
+	assert.ErrorContains(ValidateUpdate(config), "invalid startTime of maintenanceWindow")
+	config.Spec.MaintenanceWindows[0].StartTime = "06:00"
+	assert.ErrorContains(ValidateUpdate(config), "cannot be the same")
+	config.Spec.MaintenanceWindows[0].StartTime = "22:00"
+	config.Spec.MaintenanceWindows[0].TimeZone = "Mars/Olympus"
+	assert.ErrorContains(ValidateUpdate(config), "invalid timeZone of maintenanceWindow")
+}
+
+func Test_ValidateImmutable(t *testing.T) {
+	assert := assert.New(t)
+	old := newMockConfig(cceConfigActivePhase)
+
+	config := old.DeepCopy()
+	config.Spec.Version = "v1.27"
+	config.Spec.NodePools = nil
+	assert.Nil(ValidateImmutable(old, config))
+
+	// Empty fields are considered as the default values.
+	old.Spec.KubeProxyMode = ""
+	config = old.DeepCopy()
+	config.Spec.KubeProxyMode = defaultKubeProxyMode
+	config.Spec.ContainerNetwork.Mode = ""
+	assert.Nil(ValidateImmutable(old, config))
+
+	for field, update := range map[string]func(*ccev1.CCEClusterConfigSpec){
+		"regionID":             func(s *ccev1.CCEClusterConfigSpec) { s.RegionID = "cn-east-3" },
+		"category":             func(s *ccev1.CCEClusterConfigSpec) { s.Category = "Turbo" },
+		"type":                 func(s *ccev1.CCEClusterConfigSpec) { s.Type = "ARM64" },
+		"containerNetwork":     func(s *ccev1.CCEClusterConfigSpec) { s.ContainerNetwork.CIDR = "10.0.0.0/16" },
+		"kubernetesSvcIPRange": func(s *ccev1.CCEClusterConfigSpec) { s.KubernetesSvcIPRange = "10.0.0.0/16" },
+		"hostNetwork.vpcID":    func(s *ccev1.CCEClusterConfigSpec) { s.HostNetwork.VpcID = "vpc-id" },
+		"hostNetwork.subnetID": func(s *ccev1.CCEClusterConfigSpec) { s.HostNetwork.SubnetID = "subnet-id" },
+		"nodeTemplate.preInstall": func(s *ccev1.CCEClusterConfigSpec) {
+			s.NodePools[0].NodeTemplate.PreInstall = "echo pre"
+		},
+		"nodeTemplate.maxPods": func(s *ccev1.CCEClusterConfigSpec) { s.NodePools[0].NodeTemplate.MaxPods = 64 },
+	} {
+		config := old.DeepCopy()
+		update(&config.Spec)
+		assert.ErrorContains(ValidateImmutable(old, config), field)
+	}
+
+	// Taints and labels of the created node pools and the template of the
+	// node pools to create are mutable.
+	config = old.DeepCopy()
+	config.Spec.NodePools[0].NodeTemplate.Taints = &[]ccev1.CCENodeTaint{{Key: "k", Effect: "NoSchedule"}}
+	config.Spec.NodePools[0].NodeTemplate.K8sTags = &map[string]string{"k": "v"}
+	config.Spec.NodePools = append(config.Spec.NodePools, ccev1.CCENodePool{
+		Name:         "nodepool-new",
+		NodeTemplate: ccev1.CCENodeTemplate{MaxPods: 64},
+	})
+	assert.Nil(ValidateImmutable(old, config))
+
+	// Fields are mutable before the cluster was created.
+	old.Spec.ClusterID = ""
+	config = old.DeepCopy()
+	config.Spec.RegionID = "cn-east-3"
+	assert.Nil(ValidateImmutable(old, config))
+}
+
+func Test_SetDefaults(t *testing.T) {
+	assert := assert.New(t)
+	spec := &ccev1.CCEClusterConfigSpec{
+		NodePools: []ccev1.CCENodePool{
+			{Name: "nodepool-1"},
+			{Name: "nodepool-2", NodeTemplate: ccev1.CCENodeTemplate{Runtime: "containerd"}},
+		},
+	}
+	SetDefaults(spec)
+	assert.Equal("CCE", spec.Category)
+	assert.Equal("VirtualMachine", spec.Type)
+	assert.Equal("vpc-router", spec.ContainerNetwork.Mode)
+	assert.Equal("iptables", spec.KubeProxyMode)
+	assert.Equal("docker", spec.NodePools[0].NodeTemplate.Runtime)
+	assert.Equal("containerd", spec.NodePools[1].NodeTemplate.Runtime)
+
+	spec = &ccev1.CCEClusterConfigSpec{Imported: true}
+	SetDefaults(spec)
+	assert.Empty(spec.Category)
+}
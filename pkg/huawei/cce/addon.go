@@ -1,35 +1,125 @@
 package cce
 
 import (
+	"encoding/json"
+	"fmt"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
 	"github.com/cnrancher/cce-operator/pkg/utils"
-	cce "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3"
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	addonInstallAnnotationKey = "addon.install/type"
+	addonUpgradeAnnotationKey = "addon.upgrade/type"
 )
 
-// TODO:
+// AddonValues decodes the values of the addon spec.
+func AddonValues(addon *ccev1.CCEAddon) (map[string]any, error) {
+	values := map[string]any{}
+	if addon.Values == nil || len(addon.Values.Raw) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(addon.Values.Raw, &values); err != nil {
+		return nil, fmt.Errorf("failed to decode values of addon [%s]: %w", addon.Name, err)
+	}
+	return values, nil
+}
+
+// CreateAddonInstance installs the addon to the cluster, the latest version
+// supported by the cluster is installed if the addon version is empty.
 func CreateAddonInstance(
-	client *cce.CceClient,
+	client ClusterAPI, clusterID string, addon *ccev1.CCEAddon,
 ) (*model.CreateAddonInstanceResponse, error) {
-	return client.CreateAddonInstance(&model.CreateAddonInstanceRequest{
+	values, err := AddonValues(addon)
+	if err != nil {
+		return nil, err
+	}
+	req := &model.CreateAddonInstanceRequest{
 		Body: &model.InstanceRequest{
+			Kind:       "Addon",
+			ApiVersion: "v3",
 			Metadata: &model.AddonMetadata{
-				Name:  utils.Pointer(""),
-				Alias: utils.Pointer(""),
+				Annotations: map[string]string{
+					addonInstallAnnotationKey: "install",
+				},
 			},
 			Spec: &model.InstanceRequestSpec{
-				ClusterID:         "",
-				AddonTemplateName: "",
-				Values:            map[string]interface{}{},
+				ClusterID:         clusterID,
+				AddonTemplateName: addon.Name,
+				Values:            values,
 			},
 		},
-	})
+	}
+	if addon.Version != "" {
+		req.Body.Spec.Version = utils.Pointer(addon.Version)
+	}
+	res, err := client.CreateAddonInstance(req)
+	if err != nil {
+		logrus.Debugf("CreateAddonInstance failed: %v", utils.PrintObject(req))
+	}
+	return res, err
+}
+
+// UpdateAddonInstance upgrades or reconfigures the addon instance, the values
+// should be the full installation values of the addon.
+func UpdateAddonInstance(
+	client ClusterAPI, clusterID, addonID, templateName, version string, values map[string]any,
+) (*model.UpdateAddonInstanceResponse, error) {
+	req := &model.UpdateAddonInstanceRequest{
+		Id: addonID,
+		Body: &model.InstanceRequest{
+			Kind:       "Addon",
+			ApiVersion: "v3",
+			Metadata: &model.AddonMetadata{
+				Annotations: map[string]string{
+					addonUpgradeAnnotationKey: "upgrade",
+				},
+			},
+			Spec: &model.InstanceRequestSpec{
+				Version:           utils.Pointer(version),
+				ClusterID:         clusterID,
+				AddonTemplateName: templateName,
+				Values:            values,
+			},
+		},
+	}
+	res, err := client.UpdateAddonInstance(req)
+	if err != nil {
+		logrus.Debugf("UpdateAddonInstance failed: %v", utils.PrintObject(req))
+	}
+	return res, err
+}
+
+func DeleteAddonInstance(
+	client ClusterAPI, clusterID, addonID string,
+) (*model.DeleteAddonInstanceResponse, error) {
+	req := &model.DeleteAddonInstanceRequest{
+		Id:        addonID,
+		ClusterId: utils.Pointer(clusterID),
+	}
+	res, err := client.DeleteAddonInstance(req)
+	if err != nil {
+		logrus.Debugf("DeleteAddonInstance failed: %v", utils.PrintObject(req))
+	}
+	return res, err
 }
 
+// ListAddonInstances lists the addon instances of the cluster, all addon
+// instances are listed if the addonName is empty.
 func ListAddonInstances(
-	client *cce.CceClient, clusterID, addonName string,
+	client ClusterAPI, clusterID, addonName string,
 ) (*model.ListAddonInstancesResponse, error) {
-	return client.ListAddonInstances(&model.ListAddonInstancesRequest{
-		AddonTemplateName: &addonName,
-		ClusterId:         clusterID,
-	})
+	req := &model.ListAddonInstancesRequest{
+		ClusterId: clusterID,
+	}
+	if addonName != "" {
+		req.AddonTemplateName = utils.Pointer(addonName)
+	}
+	res, err := client.ListAddonInstances(req)
+	if err != nil {
+		logrus.Debugf("ListAddonInstances failed: %v", utils.PrintObject(req))
+	}
+	return res, err
 }
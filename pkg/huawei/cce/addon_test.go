@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
 	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
 	"github.com/cnrancher/cce-operator/pkg/utils"
 )
@@ -24,7 +25,7 @@ func Test_CreateAddonInstance(t *testing.T) {
 	if client == nil {
 		return
 	}
-	res, err := cce.CreateAddonInstance(client)
+	res, err := cce.CreateAddonInstance(client, "", &ccev1.CCEAddon{Name: "metrics-server"})
 	if err != nil {
 		t.Error(err)
 		return
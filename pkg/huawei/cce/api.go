@@ -0,0 +1,37 @@
+package cce
+
+import (
+	cce "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+)
+
+// ClusterAPI is the CCE (Cloud Container Engine) API used by the operator,
+// the *cce.CceClient of the Huawei Cloud SDK is the default implementation.
+type ClusterAPI interface {
+	ListClusters(request *model.ListClustersRequest) (*model.ListClustersResponse, error)
+	CreateCluster(request *model.CreateClusterRequest) (*model.CreateClusterResponse, error)
+	ShowCluster(request *model.ShowClusterRequest) (*model.ShowClusterResponse, error)
+	UpdateCluster(request *model.UpdateClusterRequest) (*model.UpdateClusterResponse, error)
+	DeleteCluster(request *model.DeleteClusterRequest) (*model.DeleteClusterResponse, error)
+	UpgradeCluster(request *model.UpgradeClusterRequest) (*model.UpgradeClusterResponse, error)
+	ShowUpgradeClusterTask(request *model.ShowUpgradeClusterTaskRequest) (*model.ShowUpgradeClusterTaskResponse, error)
+	ListClusterUpgradePaths(request *model.ListClusterUpgradePathsRequest) (*model.ListClusterUpgradePathsResponse, error)
+	CreatePreCheck(request *model.CreatePreCheckRequest) (*model.CreatePreCheckResponse, error)
+	ShowPreCheck(request *model.ShowPreCheckRequest) (*model.ShowPreCheckResponse, error)
+	ResizeCluster(request *model.ResizeClusterRequest) (*model.ResizeClusterResponse, error)
+	CreateKubernetesClusterCert(request *model.CreateKubernetesClusterCertRequest) (*model.CreateKubernetesClusterCertResponse, error)
+	ListNodePools(request *model.ListNodePoolsRequest) (*model.ListNodePoolsResponse, error)
+	CreateNodePool(request *model.CreateNodePoolRequest) (*model.CreateNodePoolResponse, error)
+	ShowNodePool(request *model.ShowNodePoolRequest) (*model.ShowNodePoolResponse, error)
+	UpdateNodePool(request *model.UpdateNodePoolRequest) (*model.UpdateNodePoolResponse, error)
+	DeleteNodePool(request *model.DeleteNodePoolRequest) (*model.DeleteNodePoolResponse, error)
+	ListNodes(request *model.ListNodesRequest) (*model.ListNodesResponse, error)
+	ShowNode(request *model.ShowNodeRequest) (*model.ShowNodeResponse, error)
+	DeleteNode(request *model.DeleteNodeRequest) (*model.DeleteNodeResponse, error)
+	ListAddonInstances(request *model.ListAddonInstancesRequest) (*model.ListAddonInstancesResponse, error)
+	CreateAddonInstance(request *model.CreateAddonInstanceRequest) (*model.CreateAddonInstanceResponse, error)
+	UpdateAddonInstance(request *model.UpdateAddonInstanceRequest) (*model.UpdateAddonInstanceResponse, error)
+	DeleteAddonInstance(request *model.DeleteAddonInstanceRequest) (*model.DeleteAddonInstanceResponse, error)
+}
+
+var _ ClusterAPI = (*cce.CceClient)(nil)
@@ -30,16 +30,27 @@ const (
 	ClusterStatusEmpty          = "Empty"          // 集群无任何资源
 )
 
+const (
+	PreCheckPhaseInit    = "Init"    // 初始化
+	PreCheckPhaseRunning = "Running" // 运行中
+	PreCheckPhaseSuccess = "Success" // 成功
+	PreCheckPhaseFailed  = "Failed"  // 失败
+	PreCheckPhaseError   = "Error"   // 错误
+)
+
+// defaultUpgradeBatchSize is the default nodes upgraded in a batch.
+const defaultUpgradeBatchSize = 20
+
 func NewCCEClient(auth *common.ClientAuth) *cce.CceClient {
 	return cce.NewCceClient(
 		cce.CceClientBuilder().
-			WithRegion(region.ValueOf(auth.Region)).
+			WithRegion(auth.ServiceRegion(region.ValueOf)).
 			WithCredential(auth.Credential).
 			Build())
 }
 
 func CreateCluster(
-	client *cce.CceClient, config *ccev1.CCEClusterConfig,
+	client ClusterAPI, config *ccev1.CCEClusterConfig,
 ) (*model.CreateClusterResponse, error) {
 	req := GetCreateClusterRequest(config)
 	res, err := client.CreateCluster(req)
@@ -169,7 +180,7 @@ func GetCreateClusterRequest(config *ccev1.CCEClusterConfig) *model.CreateCluste
 	return request
 }
 
-func ShowCluster(client *cce.CceClient, ID string) (*model.ShowClusterResponse, error) {
+func ShowCluster(client ClusterAPI, ID string) (*model.ShowClusterResponse, error) {
 	res, err := client.ShowCluster(&model.ShowClusterRequest{
 		ClusterId: ID,
 	})
@@ -179,7 +190,7 @@ func ShowCluster(client *cce.CceClient, ID string) (*model.ShowClusterResponse,
 	return res, err
 }
 
-func ListClusters(client *cce.CceClient) (*model.ListClustersResponse, error) {
+func ListClusters(client ClusterAPI) (*model.ListClustersResponse, error) {
 	res, err := client.ListClusters(&model.ListClustersRequest{})
 	if err != nil {
 		logrus.Debugf("ListClusters failed")
@@ -188,7 +199,7 @@ func ListClusters(client *cce.CceClient) (*model.ListClustersResponse, error) {
 }
 
 func UpdateCluster(
-	client *cce.CceClient, config *ccev1.CCEClusterConfig,
+	client ClusterAPI, config *ccev1.CCEClusterConfig,
 ) (*model.UpdateClusterResponse, error) {
 	req := GetUpdateClusterRequest(config)
 	res, err := client.UpdateCluster(req)
@@ -221,9 +232,12 @@ func GetUpdateClusterRequest(config *ccev1.CCEClusterConfig) *model.UpdateCluste
 }
 
 func UpgradeCluster(
-	client *cce.CceClient, config *ccev1.CCEClusterConfig,
+	client ClusterAPI, config *ccev1.CCEClusterConfig, targetVersion string,
 ) (*model.UpgradeClusterResponse, error) {
-	req := GetUpgradeClusterRequest(config)
+	req, err := GetUpgradeClusterRequest(config, targetVersion)
+	if err != nil {
+		return nil, err
+	}
 	res, err := client.UpgradeCluster(req)
 	if err != nil {
 		logrus.Debugf("UpgradeCluster failed: %v", utils.PrintObject(req))
@@ -231,7 +245,57 @@ func UpgradeCluster(
 	return res, err
 }
 
-func GetUpgradeClusterRequest(config *ccev1.CCEClusterConfig) *model.UpgradeClusterRequest {
+// GetUpgradeClusterRequest builds the request upgrading the cluster to the
+// target version by the upgrade strategy, the node pools in nodePoolOrder are
+// upgraded first. The addons in the strategy are upgraded only if the target
+// version is the spec version.
+func GetUpgradeClusterRequest(
+	config *ccev1.CCEClusterConfig, targetVersion string,
+) (*model.UpgradeClusterRequest, error) {
+	strategy := &config.Spec.UpgradeStrategy
+	strategyType := strategy.Type
+	if strategyType == "" {
+		strategyType = ccev1.UpgradeStrategyInPlaceRollingUpdate
+	}
+	step := strategy.BatchSize
+	if step == 0 {
+		step = defaultUpgradeBatchSize
+	}
+	var nodePoolOrder map[string]int32
+	for i, name := range strategy.NodePoolOrder {
+		for _, np := range config.Spec.NodePools {
+			if np.Name != name || np.ID == "" {
+				continue
+			}
+			if nodePoolOrder == nil {
+				nodePoolOrder = map[string]int32{}
+			}
+			// The node pool with the larger priority is upgraded first.
+			nodePoolOrder[np.ID] = int32(len(strategy.NodePoolOrder) - i)
+		}
+	}
+	var addons *[]model.UpgradeAddonConfig
+	for i := range strategy.Addons {
+		if targetVersion != config.Spec.Version {
+			break
+		}
+		addon := &strategy.Addons[i]
+		values, err := AddonValues(addon)
+		if err != nil {
+			return nil, err
+		}
+		if addons == nil {
+			addons = &[]model.UpgradeAddonConfig{}
+		}
+		var v interface{} = values
+		*addons = append(*addons, model.UpgradeAddonConfig{
+			AddonTemplateName: addon.Name,
+			Operation:         "patch",
+			Version:           addon.Version,
+			Values:            &v,
+		})
+	}
+
 	req := &model.UpgradeClusterRequest{
 		ClusterId: config.Spec.ClusterID,
 		Body: &model.UpgradeClusterRequestBody{
@@ -241,25 +305,115 @@ func GetUpgradeClusterRequest(config *ccev1.CCEClusterConfig) *model.UpgradeClus
 			},
 			Spec: &model.UpgradeSpec{
 				ClusterUpgradeAction: &model.ClusterUpgradeAction{
-					Addons:        nil,
+					Addons:        addons,
 					NodeOrder:     nil,
-					NodePoolOrder: nil,
+					NodePoolOrder: nodePoolOrder,
 					Strategy: &model.UpgradeStrategy{
-						Type: "inPlaceRollingUpdate",
+						Type: strategyType,
 						InPlaceRollingUpdate: &model.InPlaceRollingUpdate{
-							UserDefinedStep: utils.Pointer(int32(20)),
+							UserDefinedStep: utils.Pointer(step),
 						},
 					},
-					TargetVersion: config.Spec.Version,
+					TargetVersion: targetVersion,
 				},
 			},
 		},
 	}
-	return req
+	return req, nil
+}
+
+// ListClusterUpgradePaths lists the versions which the clusters of each
+// version can be upgraded to.
+func ListClusterUpgradePaths(client ClusterAPI) (*model.ListClusterUpgradePathsResponse, error) {
+	req := &model.ListClusterUpgradePathsRequest{}
+	res, err := client.ListClusterUpgradePaths(req)
+	if err != nil {
+		logrus.Debugf("ListClusterUpgradePaths failed: %v", utils.PrintObject(req))
+	}
+	return res, err
+}
+
+// CreatePreCheck starts the pre-upgrade check of the cluster to the target
+// version.
+func CreatePreCheck(
+	client ClusterAPI, clusterID, clusterVersion, targetVersion string,
+) (*model.CreatePreCheckResponse, error) {
+	req := &model.CreatePreCheckRequest{
+		ClusterId: clusterID,
+		Body: &model.PrecheckClusterRequestBody{
+			ApiVersion: "v3",
+			Kind:       "PreCheckTask",
+			Spec: &model.PrecheckSpec{
+				ClusterID:      utils.Pointer(clusterID),
+				ClusterVersion: utils.Pointer(clusterVersion),
+				TargetVersion:  utils.Pointer(targetVersion),
+			},
+		},
+	}
+	res, err := client.CreatePreCheck(req)
+	if err != nil {
+		logrus.Debugf("CreatePreCheck failed: %v", utils.PrintObject(req))
+	}
+	return res, err
+}
+
+func ShowPreCheck(
+	client ClusterAPI, clusterID, taskID string,
+) (*model.ShowPreCheckResponse, error) {
+	req := &model.ShowPreCheckRequest{
+		ClusterId: clusterID,
+		TaskId:    taskID,
+	}
+	res, err := client.ShowPreCheck(req)
+	if err != nil {
+		logrus.Debugf("ShowPreCheck failed: %v", utils.PrintObject(req))
+	}
+	return res, err
+}
+
+// PreCheckFailures returns the messages of the failed pre-upgrade check
+// items.
+func PreCheckFailures(status *model.PrecheckStatus) []string {
+	if status == nil {
+		return nil
+	}
+	var failures []string
+	add := func(node string, items *[]model.PreCheckItemStatus) {
+		if items == nil {
+			return
+		}
+		for _, item := range *items {
+			if utils.Value(item.Phase) != PreCheckPhaseFailed {
+				continue
+			}
+			message := fmt.Sprintf("%s (%s): %s",
+				utils.Value(item.Name), utils.Value(item.Level), utils.Value(item.Message))
+			if node != "" {
+				message = fmt.Sprintf("node [%s] %s", node, message)
+			}
+			failures = append(failures, message)
+		}
+	}
+	if status.ClusterCheckStatus != nil {
+		add("", status.ClusterCheckStatus.ItemsStatus)
+	}
+	if status.AddonCheckStatus != nil {
+		add("", status.AddonCheckStatus.ItemsStatus)
+	}
+	if status.NodeCheckStatus != nil && status.NodeCheckStatus.NodeStageStatus != nil {
+		for _, n := range *status.NodeCheckStatus.NodeStageStatus {
+			var name string
+			if n.NodeInfo != nil {
+				name = utils.Value(n.NodeInfo.Name)
+			}
+			add(name, n.ItemsStatus)
+		}
+	}
+	return failures
 }
 
 func ShowUpgradeClusterTask(
-	client *cce.CceClient, clusterID string, taskID string,
+	client ClusterAPI, clusterID string, taskID string,
 ) (*model.ShowUpgradeClusterTaskResponse, error) {
 	req := &model.ShowUpgradeClusterTaskRequest{
 		ClusterId: clusterID,
@@ -273,7 +427,7 @@ func ShowUpgradeClusterTask(
 }
 
 func ResizeCluster(
-	client *cce.CceClient, ID, flavor, isAutoPay string,
+	client ClusterAPI, ID, flavor, isAutoPay string,
 ) (*model.ResizeClusterResponse, error) {
 	req := &model.ResizeClusterRequest{
 		ClusterId: ID,
@@ -291,7 +445,7 @@ func ResizeCluster(
 	return res, err
 }
 
-func DeleteCluster(client *cce.CceClient, ID string) (*model.DeleteClusterResponse, error) {
+func DeleteCluster(client ClusterAPI, ID string) (*model.DeleteClusterResponse, error) {
 	res, err := client.DeleteCluster(&model.DeleteClusterRequest{
 		ClusterId: ID,
 	})
@@ -302,7 +456,7 @@ func DeleteCluster(client *cce.CceClient, ID string) (*model.DeleteClusterRespon
 }
 
 func GetClusterRestConfig(
-	client *cce.CceClient, clusterID string, duration int32,
+	client ClusterAPI, clusterID string, duration int32,
 ) (*rest.Config, error) {
 	clusterCert, err := GetClusterCert(client, clusterID, duration)
 	if err != nil {
@@ -321,7 +475,7 @@ func GetClusterRestConfig(
 }
 
 func GetClusterClient(
-	client *cce.CceClient, clusterID string, duration int32,
+	client ClusterAPI, clusterID string, duration int32,
 ) (kubernetes.Interface, error) {
 	config, err := GetClusterRestConfig(client, clusterID, duration)
 	if err != nil {
@@ -336,7 +490,7 @@ func GetClusterClient(
 }
 
 func GetClusterCert(
-	client *cce.CceClient, clusterID string, duration int32,
+	client ClusterAPI, clusterID string, duration int32,
 ) (*model.CreateKubernetesClusterCertResponse, error) {
 	if duration > 365*30 || duration < -1 {
 		return nil, fmt.Errorf(
@@ -6,10 +6,14 @@ import (
 	"os"
 	"testing"
 
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
 	"github.com/cnrancher/cce-operator/pkg/huawei/cce"
 	"github.com/cnrancher/cce-operator/pkg/huawei/common"
 	"github.com/cnrancher/cce-operator/pkg/utils"
 	huawei_cce "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 var (
@@ -77,3 +81,118 @@ func Test_GetClusterCert(t *testing.T) {
 	}
 	fmt.Printf("%s\n", utils.PrintObject(certs))
 }
+
+func Test_GetUpgradeClusterRequest(t *testing.T) {
+	assert := assert.New(t)
+	config := &ccev1.CCEClusterConfig{
+		Spec: ccev1.CCEClusterConfigSpec{
+			ClusterID: "cluster-id",
+			Version:   "v1.28",
+			NodePools: []ccev1.CCENodePool{
+				{Name: "nodepool-1", ID: "nodepool-1-id"},
+				{Name: "nodepool-2", ID: "nodepool-2-id"},
+			},
+		},
+	}
+	req, err := cce.GetUpgradeClusterRequest(config, "v1.28")
+	if assert.Nil(err) {
+		action := req.Body.Spec.ClusterUpgradeAction
+		assert.Equal("v1.28", action.TargetVersion)
+		assert.Equal(ccev1.UpgradeStrategyInPlaceRollingUpdate, action.Strategy.Type)
+		assert.Equal(int32(20), utils.Value(action.Strategy.InPlaceRollingUpdate.UserDefinedStep))
+		assert.Nil(action.NodePoolOrder)
+		assert.Nil(action.Addons)
+	}
+
+	config.Spec.UpgradeStrategy = ccev1.CCEUpgradeStrategy{
+		BatchSize:     5,
+		NodePoolOrder: []string{"nodepool-2", "nodepool-1"},
+		Addons: []ccev1.CCEAddon{{
+			Name:    "coredns",
+			Version: "1.28.4",
+			Values:  &runtime.RawExtension{Raw: []byte(`{"flavor":{"replicas":3}}`)},
+		}},
+	}
+	req, err = cce.GetUpgradeClusterRequest(config, "v1.28")
+	if assert.Nil(err) {
+		action := req.Body.Spec.ClusterUpgradeAction
+		assert.Equal(int32(5), utils.Value(action.Strategy.InPlaceRollingUpdate.UserDefinedStep))
+		assert.Equal(map[string]int32{"nodepool-2-id": 2, "nodepool-1-id": 1}, action.NodePoolOrder)
+		if assert.NotNil(action.Addons) && assert.Len(*action.Addons, 1) {
+			addon := (*action.Addons)[0]
+			assert.Equal("coredns", addon.AddonTemplateName)
+			assert.Equal("patch", addon.Operation)
+			assert.Equal("1.28.4", addon.Version)
+			assert.Equal(map[string]any{"flavor": map[string]any{"replicas": float64(3)}}, *addon.Values)
+		}
+	}
+
+	// The addons are upgraded with the last hop.
+	req, err = cce.GetUpgradeClusterRequest(config, "v1.27")
+	if assert.Nil(err) {
+		assert.Equal("v1.27", req.Body.Spec.ClusterUpgradeAction.TargetVersion)
+		assert.Nil(req.Body.Spec.ClusterUpgradeAction.Addons)
+	}
+}
+
+func Test_GetUpdateNodePoolRequest(t *testing.T) {
+	assert := assert.New(t)
+	np := &ccev1.CCENodePool{Name: "nodepool-1", ID: "nodepool-1-id"}
+	upstream := &ccev1.CCENodeTemplate{
+		Taints:   &[]ccev1.CCENodeTaint{{Key: "console", Effect: "NoSchedule"}},
+		K8sTags:  &map[string]string{"owner": "console"},
+		UserTags: &map[string]string{"team": "infra"},
+	}
+
+	// The taints and tags not set in spec are kept as the upstream.
+	template := cce.GetUpdateNodePoolRequest("cluster-id", np, upstream).Body.Spec.NodeTemplate
+	if assert.Len(template.Taints, 1) {
+		assert.Equal("console", template.Taints[0].Key)
+	}
+	assert.Equal(map[string]string{"owner": "console"}, template.K8sTags)
+	if assert.Len(template.UserTags, 1) {
+		assert.Equal("team", utils.Value(template.UserTags[0].Key))
+	}
+
+	// The taints and tags set in spec are updated, empty values remove them.
+	np.NodeTemplate.Taints = &[]ccev1.CCENodeTaint{}
+	np.NodeTemplate.K8sTags = &map[string]string{"workload": "gpu"}
+	template = cce.GetUpdateNodePoolRequest("cluster-id", np, upstream).Body.Spec.NodeTemplate
+	assert.NotNil(template.Taints)
+	assert.Empty(template.Taints)
+	assert.Equal(map[string]string{"workload": "gpu"}, template.K8sTags)
+	assert.Len(template.UserTags, 1)
+}
+
+func Test_PreCheckFailures(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(cce.PreCheckFailures(nil))
+
+	item := func(name, phase string) model.PreCheckItemStatus {
+		return model.PreCheckItemStatus{
+			Name:    utils.Pointer(name),
+			Level:   utils.Pointer("Fatal"),
+			Phase:   utils.Pointer(phase),
+			Message: utils.Pointer(name + " failed"),
+		}
+	}
+	status := &model.PrecheckStatus{
+		Phase: utils.Pointer(cce.PreCheckPhaseFailed),
+		ClusterCheckStatus: &model.ClusterCheckStatus{
+			ItemsStatus: &[]model.PreCheckItemStatus{item("MasterCheck", cce.PreCheckPhaseFailed)},
+		},
+		AddonCheckStatus: &model.AddonCheckStatus{
+			ItemsStatus: &[]model.PreCheckItemStatus{item("AddonCheck", cce.PreCheckPhaseSuccess)},
+		},
+		NodeCheckStatus: &model.NodeCheckStatus{
+			NodeStageStatus: &[]model.NodeStageStatus{{
+				NodeInfo:    &model.NodeInfo{Name: utils.Pointer("node-1")},
+				ItemsStatus: &[]model.PreCheckItemStatus{item("NodeCheck", cce.PreCheckPhaseFailed)},
+			}},
+		},
+	}
+	assert.Equal([]string{
+		"MasterCheck (Fatal): MasterCheck failed",
+		"node [node-1] NodeCheck (Fatal): NodeCheck failed",
+	}, cce.PreCheckFailures(status))
+}
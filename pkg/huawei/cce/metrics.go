@@ -0,0 +1,189 @@
+package cce
+
+import (
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+)
+
+// metricsClusterAPI records the request metrics of the ClusterAPI.
+type metricsClusterAPI struct {
+	api    ClusterAPI
+	policy *ratelimit.Policy
+}
+
+// WithMetrics returns the ClusterAPI recording the request metrics of api, the
+// requests are rate limited and retried by policy if not nil.
+func WithMetrics(api ClusterAPI, policy *ratelimit.Policy) ClusterAPI {
+	return &metricsClusterAPI{api: api, policy: policy}
+}
+
+func (m *metricsClusterAPI) ListClusters(request *model.ListClustersRequest) (*model.ListClustersResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ListClusters", func() (_ *model.ListClustersResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ListClusters", time.Now(), &err)
+		return m.api.ListClusters(request)
+	})
+}
+
+func (m *metricsClusterAPI) CreateCluster(request *model.CreateClusterRequest) (*model.CreateClusterResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "CreateCluster", func() (_ *model.CreateClusterResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "CreateCluster", time.Now(), &err)
+		return m.api.CreateCluster(request)
+	})
+}
+
+func (m *metricsClusterAPI) ShowCluster(request *model.ShowClusterRequest) (*model.ShowClusterResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ShowCluster", func() (_ *model.ShowClusterResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ShowCluster", time.Now(), &err)
+		return m.api.ShowCluster(request)
+	})
+}
+
+func (m *metricsClusterAPI) UpdateCluster(request *model.UpdateClusterRequest) (*model.UpdateClusterResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "UpdateCluster", func() (_ *model.UpdateClusterResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "UpdateCluster", time.Now(), &err)
+		return m.api.UpdateCluster(request)
+	})
+}
+
+func (m *metricsClusterAPI) DeleteCluster(request *model.DeleteClusterRequest) (*model.DeleteClusterResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "DeleteCluster", func() (_ *model.DeleteClusterResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "DeleteCluster", time.Now(), &err)
+		return m.api.DeleteCluster(request)
+	})
+}
+
+func (m *metricsClusterAPI) UpgradeCluster(request *model.UpgradeClusterRequest) (*model.UpgradeClusterResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "UpgradeCluster", func() (_ *model.UpgradeClusterResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "UpgradeCluster", time.Now(), &err)
+		return m.api.UpgradeCluster(request)
+	})
+}
+
+func (m *metricsClusterAPI) ShowUpgradeClusterTask(request *model.ShowUpgradeClusterTaskRequest) (*model.ShowUpgradeClusterTaskResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ShowUpgradeClusterTask", func() (_ *model.ShowUpgradeClusterTaskResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ShowUpgradeClusterTask", time.Now(), &err)
+		return m.api.ShowUpgradeClusterTask(request)
+	})
+}
+
+func (m *metricsClusterAPI) ListClusterUpgradePaths(request *model.ListClusterUpgradePathsRequest) (*model.ListClusterUpgradePathsResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ListClusterUpgradePaths", func() (_ *model.ListClusterUpgradePathsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ListClusterUpgradePaths", time.Now(), &err)
+		return m.api.ListClusterUpgradePaths(request)
+	})
+}
+
+func (m *metricsClusterAPI) CreatePreCheck(request *model.CreatePreCheckRequest) (*model.CreatePreCheckResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "CreatePreCheck", func() (_ *model.CreatePreCheckResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "CreatePreCheck", time.Now(), &err)
+		return m.api.CreatePreCheck(request)
+	})
+}
+
+func (m *metricsClusterAPI) ShowPreCheck(request *model.ShowPreCheckRequest) (*model.ShowPreCheckResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ShowPreCheck", func() (_ *model.ShowPreCheckResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ShowPreCheck", time.Now(), &err)
+		return m.api.ShowPreCheck(request)
+	})
+}
+
+func (m *metricsClusterAPI) ResizeCluster(request *model.ResizeClusterRequest) (*model.ResizeClusterResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ResizeCluster", func() (_ *model.ResizeClusterResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ResizeCluster", time.Now(), &err)
+		return m.api.ResizeCluster(request)
+	})
+}
+
+func (m *metricsClusterAPI) CreateKubernetesClusterCert(request *model.CreateKubernetesClusterCertRequest) (*model.CreateKubernetesClusterCertResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "CreateKubernetesClusterCert", func() (_ *model.CreateKubernetesClusterCertResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "CreateKubernetesClusterCert", time.Now(), &err)
+		return m.api.CreateKubernetesClusterCert(request)
+	})
+}
+
+func (m *metricsClusterAPI) ListNodePools(request *model.ListNodePoolsRequest) (*model.ListNodePoolsResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ListNodePools", func() (_ *model.ListNodePoolsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ListNodePools", time.Now(), &err)
+		return m.api.ListNodePools(request)
+	})
+}
+
+func (m *metricsClusterAPI) CreateNodePool(request *model.CreateNodePoolRequest) (*model.CreateNodePoolResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "CreateNodePool", func() (_ *model.CreateNodePoolResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "CreateNodePool", time.Now(), &err)
+		return m.api.CreateNodePool(request)
+	})
+}
+
+func (m *metricsClusterAPI) ShowNodePool(request *model.ShowNodePoolRequest) (*model.ShowNodePoolResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ShowNodePool", func() (_ *model.ShowNodePoolResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ShowNodePool", time.Now(), &err)
+		return m.api.ShowNodePool(request)
+	})
+}
+
+func (m *metricsClusterAPI) UpdateNodePool(request *model.UpdateNodePoolRequest) (*model.UpdateNodePoolResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "UpdateNodePool", func() (_ *model.UpdateNodePoolResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "UpdateNodePool", time.Now(), &err)
+		return m.api.UpdateNodePool(request)
+	})
+}
+
+func (m *metricsClusterAPI) DeleteNodePool(request *model.DeleteNodePoolRequest) (*model.DeleteNodePoolResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "DeleteNodePool", func() (_ *model.DeleteNodePoolResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "DeleteNodePool", time.Now(), &err)
+		return m.api.DeleteNodePool(request)
+	})
+}
+
+func (m *metricsClusterAPI) ListNodes(request *model.ListNodesRequest) (*model.ListNodesResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ListNodes", func() (_ *model.ListNodesResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ListNodes", time.Now(), &err)
+		return m.api.ListNodes(request)
+	})
+}
+
+func (m *metricsClusterAPI) ShowNode(request *model.ShowNodeRequest) (*model.ShowNodeResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ShowNode", func() (_ *model.ShowNodeResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ShowNode", time.Now(), &err)
+		return m.api.ShowNode(request)
+	})
+}
+
+func (m *metricsClusterAPI) DeleteNode(request *model.DeleteNodeRequest) (*model.DeleteNodeResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "DeleteNode", func() (_ *model.DeleteNodeResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "DeleteNode", time.Now(), &err)
+		return m.api.DeleteNode(request)
+	})
+}
+
+func (m *metricsClusterAPI) ListAddonInstances(request *model.ListAddonInstancesRequest) (*model.ListAddonInstancesResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "ListAddonInstances", func() (_ *model.ListAddonInstancesResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "ListAddonInstances", time.Now(), &err)
+		return m.api.ListAddonInstances(request)
+	})
+}
+
+func (m *metricsClusterAPI) CreateAddonInstance(request *model.CreateAddonInstanceRequest) (*model.CreateAddonInstanceResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "CreateAddonInstance", func() (_ *model.CreateAddonInstanceResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "CreateAddonInstance", time.Now(), &err)
+		return m.api.CreateAddonInstance(request)
+	})
+}
+
+func (m *metricsClusterAPI) UpdateAddonInstance(request *model.UpdateAddonInstanceRequest) (*model.UpdateAddonInstanceResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "UpdateAddonInstance", func() (_ *model.UpdateAddonInstanceResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "UpdateAddonInstance", time.Now(), &err)
+		return m.api.UpdateAddonInstance(request)
+	})
+}
+
+func (m *metricsClusterAPI) DeleteAddonInstance(request *model.DeleteAddonInstanceRequest) (*model.DeleteAddonInstanceResponse, error) {
+	return ratelimit.Call(m.policy, "cce", "DeleteAddonInstance", func() (_ *model.DeleteAddonInstanceResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("cce", "DeleteAddonInstance", time.Now(), &err)
+		return m.api.DeleteAddonInstance(request)
+	})
+}
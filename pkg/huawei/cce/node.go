@@ -1,9 +1,11 @@
 package cce
 
 import (
+	"encoding/base64"
+	"sort"
+
 	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
 	"github.com/cnrancher/cce-operator/pkg/utils"
-	cce "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3"
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
 	"github.com/sirupsen/logrus"
 )
@@ -13,7 +15,7 @@ const (
 )
 
 func CreateNodePool(
-	client *cce.CceClient, clusterID string, nodePool *ccev1.CCENodePool,
+	client ClusterAPI, clusterID string, nodePool *ccev1.CCENodePool,
 ) (*model.CreateNodePoolResponse, error) {
 	req, err := GetCreateNodePoolRequest(clusterID, nodePool)
 	if err != nil {
@@ -26,7 +28,7 @@ func CreateNodePool(
 	return res, err
 }
 
-func ListNodes(client *cce.CceClient, clusterID string) (*model.ListNodesResponse, error) {
+func ListNodes(client ClusterAPI, clusterID string) (*model.ListNodesResponse, error) {
 	request := &model.ListNodesRequest{
 		ClusterId: clusterID,
 	}
@@ -38,7 +40,7 @@ func ListNodes(client *cce.CceClient, clusterID string) (*model.ListNodesRespons
 }
 
 func ListNodePools(
-	client *cce.CceClient, clusterID string, showDefaultNP bool,
+	client ClusterAPI, clusterID string, showDefaultNP bool,
 ) (*model.ListNodePoolsResponse, error) {
 	var sdnp *string
 	if showDefaultNP {
@@ -55,7 +57,7 @@ func ListNodePools(
 }
 
 func ShowNode(
-	client *cce.CceClient, clusterID, nodeID string,
+	client ClusterAPI, clusterID, nodeID string,
 ) (*model.ShowNodeResponse, error) {
 	request := &model.ShowNodeRequest{
 		ClusterId: clusterID,
@@ -69,7 +71,7 @@ func ShowNode(
 }
 
 func ShowNodePool(
-	client *cce.CceClient, clusterID, npID string,
+	client ClusterAPI, clusterID, npID string,
 ) (*model.ShowNodePoolResponse, error) {
 	res, err := client.ShowNodePool(&model.ShowNodePoolRequest{
 		ClusterId:  clusterID,
@@ -83,9 +85,9 @@ func ShowNodePool(
 }
 
 func UpdateNodePool(
-	client *cce.CceClient, clusterID string, nodePool *ccev1.CCENodePool,
+	client ClusterAPI, clusterID string, nodePool *ccev1.CCENodePool, upstream *ccev1.CCENodeTemplate,
 ) (*model.UpdateNodePoolResponse, error) {
-	req := GetUpdateNodePoolRequest(clusterID, nodePool)
+	req := GetUpdateNodePoolRequest(clusterID, nodePool, upstream)
 	res, err := client.UpdateNodePool(req)
 	if err != nil {
 		logrus.Debugf("UpdateNodePool failed: %v",
@@ -94,9 +96,25 @@ func UpdateNodePool(
 	return res, err
 }
 
+// GetUpdateNodePoolRequest builds the request to update the node pool, the
+// taints and tags not set in spec are not managed and kept as the upstream.
 func GetUpdateNodePoolRequest(
-	clusterID string, nodePool *ccev1.CCENodePool,
+	clusterID string, nodePool *ccev1.CCENodePool, upstream *ccev1.CCENodeTemplate,
 ) *model.UpdateNodePoolRequest {
+	template := &nodePool.NodeTemplate
+	if upstream == nil {
+		upstream = &ccev1.CCENodeTemplate{}
+	}
+	taints, k8sTags, userTags := template.Taints, template.K8sTags, template.UserTags
+	if taints == nil {
+		taints = upstream.Taints
+	}
+	if k8sTags == nil {
+		k8sTags = upstream.K8sTags
+	}
+	if userTags == nil {
+		userTags = upstream.UserTags
+	}
 	req := &model.UpdateNodePoolRequest{
 		ClusterId:  clusterID,
 		NodepoolId: nodePool.ID,
@@ -105,7 +123,11 @@ func GetUpdateNodePoolRequest(
 				Name: nodePool.Name,
 			},
 			Spec: &model.NodePoolSpecUpdate{
-				NodeTemplate:     &model.NodeSpecUpdate{},
+				NodeTemplate: &model.NodeSpecUpdate{
+					Taints:   nodeTaints(taints),
+					K8sTags:  nodeK8sTags(k8sTags),
+					UserTags: nodeUserTags(userTags),
+				},
 				InitialNodeCount: nodePool.InitialNodeCount,
 				Autoscaling: &model.NodePoolNodeAutoscaling{
 					Enable:                &nodePool.Autoscaling.Enable,
@@ -120,21 +142,28 @@ func GetUpdateNodePoolRequest(
 	return req
 }
 
+// DeleteNode deletes the node. The node count of the node pool is decreased
+// if scaleDown, otherwise the node pool creates a new node to replace it.
 func DeleteNode(
-	client *cce.CceClient, clusterID string, nodeID string,
+	client ClusterAPI, clusterID string, nodeID string, scaleDown bool,
 ) (*model.DeleteNodeResponse, error) {
-	res, err := client.DeleteNode(&model.DeleteNodeRequest{
+	request := &model.DeleteNodeRequest{
 		ClusterId: clusterID,
 		NodeId:    nodeID,
-	})
+	}
+	if !scaleDown {
+		noScaleDown := model.GetDeleteNodeRequestNodepoolScaleDownEnum().NO_SCALE_DOWN
+		request.NodepoolScaleDown = &noScaleDown
+	}
+	res, err := client.DeleteNode(request)
 	if err != nil {
-		logrus.Debugf("DeleteNode failed: %v", utils.PrintObject(res))
+		logrus.Debugf("DeleteNode failed: %v", utils.PrintObject(request))
 	}
 	return res, err
 }
 
 func DeleteNodePool(
-	client *cce.CceClient, clusterID, npID string,
+	client ClusterAPI, clusterID, npID string,
 ) (*model.DeleteNodePoolResponse, error) {
 	res, err := client.DeleteNodePool(&model.DeleteNodePoolRequest{
 		ClusterId:  clusterID,
@@ -238,6 +267,27 @@ func GetCreateNodePoolRequest(
 	nodePoolBody.Spec.NodeTemplate.Runtime = &model.Runtime{
 		Name: &runtime,
 	}
+	if t := np.NodeTemplate.Taints; t != nil && len(*t) > 0 {
+		taints := nodeTaints(t)
+		nodePoolBody.Spec.NodeTemplate.Taints = &taints
+	}
+	if t := np.NodeTemplate.K8sTags; t != nil && len(*t) > 0 {
+		nodePoolBody.Spec.NodeTemplate.K8sTags = nodeK8sTags(t)
+	}
+	if t := np.NodeTemplate.UserTags; t != nil && len(*t) > 0 {
+		userTags := nodeUserTags(t)
+		nodePoolBody.Spec.NodeTemplate.UserTags = &userTags
+	}
+	extendParam := nodePoolBody.Spec.NodeTemplate.ExtendParam
+	if np.NodeTemplate.PreInstall != "" {
+		extendParam.AlphaCcePreInstall = utils.Pointer(EncodeNodeScript(np.NodeTemplate.PreInstall))
+	}
+	if np.NodeTemplate.PostInstall != "" {
+		extendParam.AlphaCcePostInstall = utils.Pointer(EncodeNodeScript(np.NodeTemplate.PostInstall))
+	}
+	if np.NodeTemplate.MaxPods > 0 {
+		extendParam.MaxPods = &np.NodeTemplate.MaxPods
+	}
 	if len(np.CustomSecurityGroups) > 0 {
 		nodePoolBody.Spec.CustomSecurityGroups = &np.CustomSecurityGroups
 	}
@@ -247,3 +297,80 @@ func GetCreateNodePoolRequest(
 	}
 	return request, nil
 }
+
+// nodeTaints converts the taints of the node template, the update request
+// requires a non-nil list to remove the taints not in the spec.
+func nodeTaints(taints *[]ccev1.CCENodeTaint) []model.Taint {
+	if taints == nil {
+		return []model.Taint{}
+	}
+	result := make([]model.Taint, 0, len(*taints))
+	for _, t := range *taints {
+		var effect model.TaintEffect
+		switch t.Effect {
+		case "PreferNoSchedule":
+			effect = model.GetTaintEffectEnum().PREFER_NO_SCHEDULE
+		case "NoExecute":
+			effect = model.GetTaintEffectEnum().NO_EXECUTE
+		default:
+			effect = model.GetTaintEffectEnum().NO_SCHEDULE
+		}
+		taint := model.Taint{
+			Key:    t.Key,
+			Effect: effect,
+		}
+		if t.Value != "" {
+			taint.Value = utils.Pointer(t.Value)
+		}
+		result = append(result, taint)
+	}
+	return result
+}
+
+func nodeK8sTags(tags *map[string]string) map[string]string {
+	if tags == nil {
+		return map[string]string{}
+	}
+	result := make(map[string]string, len(*tags))
+	for k, v := range *tags {
+		result[k] = v
+	}
+	return result
+}
+
+// nodeUserTags converts the user tags of the node template, sorted by the
+// key to keep the request stable.
+func nodeUserTags(tags *map[string]string) []model.UserTag {
+	if tags == nil {
+		return []model.UserTag{}
+	}
+	keys := make([]string, 0, len(*tags))
+	for k := range *tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]model.UserTag, 0, len(*tags))
+	for _, k := range keys {
+		result = append(result, model.UserTag{
+			Key:   utils.Pointer(k),
+			Value: utils.Pointer((*tags)[k]),
+		})
+	}
+	return result
+}
+
+// EncodeNodeScript encodes the pre-install or post-install script of the
+// node template, CCE requires the scripts to be base64 encoded.
+func EncodeNodeScript(script string) string {
+	return base64.StdEncoding.EncodeToString([]byte(script))
+}
+
+// DecodeNodeScript decodes the script returned by CCE, the script is
+// returned as is if it is not base64 encoded.
+func DecodeNodeScript(script string) string {
+	b, err := base64.StdEncoding.DecodeString(script)
+	if err != nil {
+		return script
+	}
+	return string(b)
+}
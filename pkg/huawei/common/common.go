@@ -2,9 +2,11 @@ package common
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cnrancher/cce-operator/pkg/utils"
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/auth/basic"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/region"
 )
 
 var (
@@ -12,9 +14,66 @@ var (
 	DefaultResourceDescription = "Managed by Rancher, do not edit!"
 )
 
+// Tag keys of the resources created by the operator, which identify the
+// operator instance and the CCEClusterConfig owning the resource.
+const (
+	TagInstance       = "cce-operator-instance"
+	TagOwnerNamespace = "cce-operator-namespace"
+	TagOwnerName      = "cce-operator-name"
+	TagOwnerUID       = "cce-operator-uid"
+	// TagRetained is added to the resources retained by the deletion policy
+	// after the CCEClusterConfig was deleted.
+	TagRetained = "cce-operator-retained"
+)
+
+// maxTagValueLength is the max length of the tag values of the network
+// resources.
+const maxTagValueLength = 43
+
+// Tag is a tag of the Huawei Cloud resource.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Owner is the CCEClusterConfig owning the resources created by the operator,
+// Instance identifies the operator installation the config belongs to.
+type Owner struct {
+	Instance  string
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// Tags returns the tags identifying the owner, the namespace and name longer
+// than the max tag value length are truncated, the owner is identified by
+// the UID.
+func (o *Owner) Tags() []Tag {
+	truncate := func(s string) string {
+		if len(s) > maxTagValueLength {
+			return s[:maxTagValueLength]
+		}
+		return s
+	}
+	return []Tag{
+		{Key: TagInstance, Value: o.Instance},
+		{Key: TagOwnerNamespace, Value: truncate(o.Namespace)},
+		{Key: TagOwnerName, Value: truncate(o.Name)},
+		{Key: TagOwnerUID, Value: o.UID},
+	}
+}
+
 type ClientAuth struct {
 	Region     string
 	Credential *basic.Credentials
+
+	// Endpoint overrides the service endpoints resolved from Region,
+	// all service clients send requests to this address if not empty.
+	Endpoint string
+
+	// ExpiresAt is the time the temporary credential expires,
+	// zero if the credential does not expire.
+	ExpiresAt time.Time
 }
 
 func NewClientAuth(ak, sk, region, projectID string) *ClientAuth {
@@ -28,6 +87,32 @@ func NewClientAuth(ak, sk, region, projectID string) *ClientAuth {
 	}
 }
 
+// NewTemporaryClientAuth returns the ClientAuth of the temporary access key
+// and security token, which expires at expiresAt.
+func NewTemporaryClientAuth(
+	ak, sk, securityToken, region, projectID string, expiresAt time.Time,
+) *ClientAuth {
+	return &ClientAuth{
+		Region: region,
+		Credential: basic.NewCredentialsBuilder().
+			WithAk(ak).
+			WithSk(sk).
+			WithSecurityToken(securityToken).
+			WithProjectId(projectID).
+			Build(),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// ServiceRegion returns the region used to build the service client,
+// valueOf is the ValueOf function of the region package of the service.
+func (a *ClientAuth) ServiceRegion(valueOf func(string) *region.Region) *region.Region {
+	if a.Endpoint != "" {
+		return region.NewRegion(a.Region, a.Endpoint)
+	}
+	return valueOf(a.Region)
+}
+
 func GenResourceName(name string) string {
 	return fmt.Sprintf("%s-%s-%s",
 		resourceNamePrefix, name, utils.RandomHex(5))
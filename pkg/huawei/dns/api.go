@@ -0,0 +1,14 @@
+package dns
+
+import (
+	dns "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/dns/v2"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/dns/v2/model"
+)
+
+// DnsAPI is the DNS API used by the operator,
+// the *dns.DnsClient of the Huawei Cloud SDK is the default implementation.
+type DnsAPI interface {
+	ListNameServers(request *model.ListNameServersRequest) (*model.ListNameServersResponse, error)
+}
+
+var _ DnsAPI = (*dns.DnsClient)(nil)
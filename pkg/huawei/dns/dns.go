@@ -11,12 +11,12 @@ import (
 func NewDnsClient(c *common.ClientAuth) *dns.DnsClient {
 	return dns.NewDnsClient(
 		dns.DnsClientBuilder().
-			WithRegion(region.ValueOf(c.Region)).
+			WithRegion(c.ServiceRegion(region.ValueOf)).
 			WithCredential(c.Credential).
 			Build())
 }
 
-func ListNameServers(client *dns.DnsClient, region string) (*model.ListNameServersResponse, error) {
+func ListNameServers(client DnsAPI, region string) (*model.ListNameServersResponse, error) {
 	res, err := client.ListNameServers(&model.ListNameServersRequest{
 		Region: &region,
 	})
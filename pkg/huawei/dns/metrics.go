@@ -0,0 +1,28 @@
+package dns
+
+import (
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/dns/v2/model"
+)
+
+// metricsDnsAPI records the request metrics of the DnsAPI.
+type metricsDnsAPI struct {
+	api    DnsAPI
+	policy *ratelimit.Policy
+}
+
+// WithMetrics returns the DnsAPI recording the request metrics of api, the
+// requests are rate limited and retried by policy if not nil.
+func WithMetrics(api DnsAPI, policy *ratelimit.Policy) DnsAPI {
+	return &metricsDnsAPI{api: api, policy: policy}
+}
+
+func (m *metricsDnsAPI) ListNameServers(request *model.ListNameServersRequest) (*model.ListNameServersResponse, error) {
+	return ratelimit.Call(m.policy, "dns", "ListNameServers", func() (_ *model.ListNameServersResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("dns", "ListNameServers", time.Now(), &err)
+		return m.api.ListNameServers(request)
+	})
+}
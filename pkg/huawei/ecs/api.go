@@ -0,0 +1,15 @@
+package ecs
+
+import (
+	ecs "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+)
+
+// EcsAPI is the ECS (Elastic Cloud Server) API used by the operator,
+// the *ecs.EcsClient of the Huawei Cloud SDK is the default implementation.
+type EcsAPI interface {
+	BatchRebootServers(request *model.BatchRebootServersRequest) (*model.BatchRebootServersResponse, error)
+	ShowJob(request *model.ShowJobRequest) (*model.ShowJobResponse, error)
+}
+
+var _ EcsAPI = (*ecs.EcsClient)(nil)
@@ -0,0 +1,45 @@
+package ecs
+
+import (
+	"github.com/cnrancher/cce-operator/pkg/huawei/common"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	ecs "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/region"
+	"github.com/sirupsen/logrus"
+)
+
+func NewEcsClient(auth *common.ClientAuth) *ecs.EcsClient {
+	return ecs.NewEcsClient(
+		ecs.EcsClientBuilder().
+			WithRegion(auth.ServiceRegion(region.ValueOf)).
+			WithCredential(auth.Credential).
+			Build())
+}
+
+// RebootServer soft reboots the server, returns the ID of the reboot job.
+func RebootServer(client EcsAPI, serverID string) (*model.BatchRebootServersResponse, error) {
+	req := &model.BatchRebootServersRequest{
+		Body: &model.BatchRebootServersRequestBody{
+			Reboot: &model.BatchRebootSeversOption{
+				Servers: []model.ServerId{{Id: serverID}},
+				Type:    model.GetBatchRebootSeversOptionTypeEnum().SOFT,
+			},
+		},
+	}
+	res, err := client.BatchRebootServers(req)
+	if err != nil {
+		logrus.Debugf("BatchRebootServers failed: %v", utils.PrintObject(req))
+	}
+	return res, err
+}
+
+func ShowJob(client EcsAPI, jobID string) (*model.ShowJobResponse, error) {
+	res, err := client.ShowJob(&model.ShowJobRequest{
+		JobId: jobID,
+	})
+	if err != nil {
+		logrus.Debugf("ShowJob failed: jobID [%s]", jobID)
+	}
+	return res, err
+}
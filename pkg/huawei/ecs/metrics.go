@@ -0,0 +1,35 @@
+package ecs
+
+import (
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+)
+
+// metricsEcsAPI records the request metrics of the EcsAPI.
+type metricsEcsAPI struct {
+	api    EcsAPI
+	policy *ratelimit.Policy
+}
+
+// WithMetrics returns the EcsAPI recording the request metrics of api, the
+// requests are rate limited and retried by policy if not nil.
+func WithMetrics(api EcsAPI, policy *ratelimit.Policy) EcsAPI {
+	return &metricsEcsAPI{api: api, policy: policy}
+}
+
+func (m *metricsEcsAPI) BatchRebootServers(request *model.BatchRebootServersRequest) (*model.BatchRebootServersResponse, error) {
+	return ratelimit.Call(m.policy, "ecs", "BatchRebootServers", func() (_ *model.BatchRebootServersResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("ecs", "BatchRebootServers", time.Now(), &err)
+		return m.api.BatchRebootServers(request)
+	})
+}
+
+func (m *metricsEcsAPI) ShowJob(request *model.ShowJobRequest) (*model.ShowJobResponse, error) {
+	return ratelimit.Call(m.policy, "ecs", "ShowJob", func() (_ *model.ShowJobResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("ecs", "ShowJob", time.Now(), &err)
+		return m.api.ShowJob(request)
+	})
+}
@@ -0,0 +1,18 @@
+package eip
+
+import (
+	eip "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+)
+
+// EipAPI is the EIP (Elastic IP) API used by the operator,
+// the *eip.EipClient of the Huawei Cloud SDK is the default implementation.
+type EipAPI interface {
+	CreatePublicip(request *model.CreatePublicipRequest) (*model.CreatePublicipResponse, error)
+	ShowPublicip(request *model.ShowPublicipRequest) (*model.ShowPublicipResponse, error)
+	DeletePublicip(request *model.DeletePublicipRequest) (*model.DeletePublicipResponse, error)
+	BatchCreatePublicipTags(request *model.BatchCreatePublicipTagsRequest) (*model.BatchCreatePublicipTagsResponse, error)
+	ListPublicipsByTags(request *model.ListPublicipsByTagsRequest) (*model.ListPublicipsByTagsResponse, error)
+}
+
+var _ EipAPI = (*eip.EipClient)(nil)
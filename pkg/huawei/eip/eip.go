@@ -13,13 +13,13 @@ import (
 func NewEipClient(c *common.ClientAuth) *eip.EipClient {
 	return eip.NewEipClient(
 		eip.EipClientBuilder().
-			WithRegion(region.ValueOf(c.Region)).
+			WithRegion(c.ServiceRegion(region.ValueOf)).
 			WithCredential(c.Credential).
 			Build())
 }
 
 func CreatePublicIP(
-	client *eip.EipClient, param *ccev1.CCEEip,
+	client EipAPI, param *ccev1.CCEEip,
 ) (*model.CreatePublicipResponse, error) {
 	body := &model.CreatePublicipRequestBody{
 		Bandwidth: &model.CreatePublicipBandwidthOption{
@@ -64,7 +64,7 @@ func CreatePublicIP(
 	return res, err
 }
 
-func ShowPublicip(client *eip.EipClient, ID string) (*model.ShowPublicipResponse, error) {
+func ShowPublicip(client EipAPI, ID string) (*model.ShowPublicipResponse, error) {
 	res, err := client.ShowPublicip(&model.ShowPublicipRequest{
 		PublicipId: ID,
 	})
@@ -74,7 +74,7 @@ func ShowPublicip(client *eip.EipClient, ID string) (*model.ShowPublicipResponse
 	return res, err
 }
 
-func DeletePublicIP(client *eip.EipClient, ID string) (*model.DeletePublicipResponse, error) {
+func DeletePublicIP(client EipAPI, ID string) (*model.DeletePublicipResponse, error) {
 	res, err := client.DeletePublicip(&model.DeletePublicipRequest{
 		PublicipId: ID,
 	})
@@ -83,3 +83,52 @@ func DeletePublicIP(client *eip.EipClient, ID string) (*model.DeletePublicipResp
 	}
 	return res, err
 }
+
+// listPageSize is the number of the resources per page listed by tags.
+const listPageSize int32 = 1000
+
+// TagPublicIP adds the tags to the EIP.
+func TagPublicIP(client EipAPI, id string, tags []common.Tag) error {
+	req := &model.BatchCreatePublicipTagsRequest{
+		PublicipId: id,
+		Body: &model.BatchCreatePublicipTagsRequestBody{
+			Action: model.GetBatchCreatePublicipTagsRequestBodyActionEnum().CREATE,
+		},
+	}
+	for _, tag := range tags {
+		req.Body.Tags = append(req.Body.Tags, model.ResourceTagOption{Key: tag.Key, Value: tag.Value})
+	}
+	_, err := client.BatchCreatePublicipTags(req)
+	if err != nil {
+		logrus.Debugf("BatchCreatePublicipTags failed: %v", utils.PrintObject(req))
+	}
+	return err
+}
+
+// ListPublicIPsByTag returns the EIPs having the tag of the value.
+func ListPublicIPsByTag(client EipAPI, key, value string) ([]model.ListResourceResp, error) {
+	var resources []model.ListResourceResp
+	for offset := int32(0); ; {
+		req := &model.ListPublicipsByTagsRequest{
+			Body: &model.ListPublicipsByTagsRequestBody{
+				Action: model.GetListPublicipsByTagsRequestBodyActionEnum().FILTER,
+				Limit:  utils.Pointer(listPageSize),
+				Offset: utils.Pointer(offset),
+				Tags:   &[]model.TagReq{{Key: key, Values: []string{value}}},
+			},
+		}
+		res, err := client.ListPublicipsByTags(req)
+		if err != nil {
+			logrus.Debugf("ListPublicipsByTags failed: %v", utils.PrintObject(req))
+			return nil, err
+		}
+		if res.Resources == nil {
+			return resources, nil
+		}
+		resources = append(resources, *res.Resources...)
+		if int32(len(*res.Resources)) < listPageSize {
+			return resources, nil
+		}
+		offset += int32(len(*res.Resources))
+	}
+}
@@ -0,0 +1,56 @@
+package eip
+
+import (
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+)
+
+// metricsEipAPI records the request metrics of the EipAPI.
+type metricsEipAPI struct {
+	api    EipAPI
+	policy *ratelimit.Policy
+}
+
+// WithMetrics returns the EipAPI recording the request metrics of api, the
+// requests are rate limited and retried by policy if not nil.
+func WithMetrics(api EipAPI, policy *ratelimit.Policy) EipAPI {
+	return &metricsEipAPI{api: api, policy: policy}
+}
+
+func (m *metricsEipAPI) CreatePublicip(request *model.CreatePublicipRequest) (*model.CreatePublicipResponse, error) {
+	return ratelimit.Call(m.policy, "eip", "CreatePublicip", func() (_ *model.CreatePublicipResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("eip", "CreatePublicip", time.Now(), &err)
+		return m.api.CreatePublicip(request)
+	})
+}
+
+func (m *metricsEipAPI) ShowPublicip(request *model.ShowPublicipRequest) (*model.ShowPublicipResponse, error) {
+	return ratelimit.Call(m.policy, "eip", "ShowPublicip", func() (_ *model.ShowPublicipResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("eip", "ShowPublicip", time.Now(), &err)
+		return m.api.ShowPublicip(request)
+	})
+}
+
+func (m *metricsEipAPI) DeletePublicip(request *model.DeletePublicipRequest) (*model.DeletePublicipResponse, error) {
+	return ratelimit.Call(m.policy, "eip", "DeletePublicip", func() (_ *model.DeletePublicipResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("eip", "DeletePublicip", time.Now(), &err)
+		return m.api.DeletePublicip(request)
+	})
+}
+
+func (m *metricsEipAPI) BatchCreatePublicipTags(request *model.BatchCreatePublicipTagsRequest) (*model.BatchCreatePublicipTagsResponse, error) {
+	return ratelimit.Call(m.policy, "eip", "BatchCreatePublicipTags", func() (_ *model.BatchCreatePublicipTagsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("eip", "BatchCreatePublicipTags", time.Now(), &err)
+		return m.api.BatchCreatePublicipTags(request)
+	})
+}
+
+func (m *metricsEipAPI) ListPublicipsByTags(request *model.ListPublicipsByTagsRequest) (*model.ListPublicipsByTagsResponse, error) {
+	return ratelimit.Call(m.policy, "eip", "ListPublicipsByTags", func() (_ *model.ListPublicipsByTagsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("eip", "ListPublicipsByTags", time.Now(), &err)
+		return m.api.ListPublicipsByTags(request)
+	})
+}
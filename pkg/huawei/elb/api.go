@@ -0,0 +1,26 @@
+package elb
+
+import (
+	elb "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v2"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v2/model"
+)
+
+// ElbAPI is the ELB (Elastic Load Balance) API used by the operator,
+// the *elb.ElbClient of the Huawei Cloud SDK is the default implementation.
+type ElbAPI interface {
+	CreateLoadbalancer(request *model.CreateLoadbalancerRequest) (*model.CreateLoadbalancerResponse, error)
+	ShowLoadbalancer(request *model.ShowLoadbalancerRequest) (*model.ShowLoadbalancerResponse, error)
+	DeleteLoadbalancer(request *model.DeleteLoadbalancerRequest) (*model.DeleteLoadbalancerResponse, error)
+	ListListeners(request *model.ListListenersRequest) (*model.ListListenersResponse, error)
+	CreateListener(request *model.CreateListenerRequest) (*model.CreateListenerResponse, error)
+	UpdateListener(request *model.UpdateListenerRequest) (*model.UpdateListenerResponse, error)
+	DeleteListener(request *model.DeleteListenerRequest) (*model.DeleteListenerResponse, error)
+	CreatePool(request *model.CreatePoolRequest) (*model.CreatePoolResponse, error)
+	ShowPool(request *model.ShowPoolRequest) (*model.ShowPoolResponse, error)
+	DeletePool(request *model.DeletePoolRequest) (*model.DeletePoolResponse, error)
+	CreateMember(request *model.CreateMemberRequest) (*model.CreateMemberResponse, error)
+	DeleteMember(request *model.DeleteMemberRequest) (*model.DeleteMemberResponse, error)
+	DeleteHealthmonitor(request *model.DeleteHealthmonitorRequest) (*model.DeleteHealthmonitorResponse, error)
+}
+
+var _ ElbAPI = (*elb.ElbClient)(nil)
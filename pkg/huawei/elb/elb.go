@@ -13,7 +13,7 @@ import (
 func NewElbClient(c *common.ClientAuth) *elb.ElbClient {
 	client := elb.NewElbClient(
 		elb.ElbClientBuilder().
-			WithRegion(region.ValueOf(c.Region)).
+			WithRegion(c.ServiceRegion(region.ValueOf)).
 			WithCredential(c.Credential).
 			Build())
 
@@ -21,7 +21,7 @@ func NewElbClient(c *common.ClientAuth) *elb.ElbClient {
 }
 
 func CreateELB(
-	client *elb.ElbClient, name, desc, subnetID string,
+	client ElbAPI, name, desc, subnetID string,
 ) (*elb_model.CreateLoadbalancerResponse, error) {
 	request := &elb_model.CreateLoadbalancerRequest{
 		Body: &elb_model.CreateLoadbalancerRequestBody{
@@ -39,7 +39,7 @@ func CreateELB(
 	return res, err
 }
 
-func GetLoadBalancer(client *elb.ElbClient, ID string) (*elb_model.ShowLoadbalancerResponse, error) {
+func GetLoadBalancer(client ElbAPI, ID string) (*elb_model.ShowLoadbalancerResponse, error) {
 	request := &elb_model.ShowLoadbalancerRequest{
 		LoadbalancerId: ID,
 	}
@@ -50,7 +50,7 @@ func GetLoadBalancer(client *elb.ElbClient, ID string) (*elb_model.ShowLoadbalan
 	return res, err
 }
 
-func ListListeners(client *elb.ElbClient) (*elb_model.ListListenersResponse, error) {
+func ListListeners(client ElbAPI) (*elb_model.ListListenersResponse, error) {
 	request := &elb_model.ListListenersRequest{
 		Limit: utils.Pointer(int32(1000)),
 	}
@@ -61,7 +61,7 @@ func ListListeners(client *elb.ElbClient) (*elb_model.ListListenersResponse, err
 	return res, err
 }
 
-func UpdateListener(client *elb.ElbClient, ID string) (*elb_model.UpdateListenerResponse, error) {
+func UpdateListener(client ElbAPI, ID string) (*elb_model.UpdateListenerResponse, error) {
 	request := &elb_model.UpdateListenerRequest{
 		ListenerId: ID,
 		Body: &elb_model.UpdateListenerRequestBody{
@@ -75,7 +75,7 @@ func UpdateListener(client *elb.ElbClient, ID string) (*elb_model.UpdateListener
 	return res, err
 }
 
-func DeleteListener(client *elb.ElbClient, ID string) (*elb_model.DeleteListenerResponse, error) {
+func DeleteListener(client ElbAPI, ID string) (*elb_model.DeleteListenerResponse, error) {
 	request := &elb_model.DeleteListenerRequest{
 		ListenerId: ID,
 	}
@@ -86,7 +86,7 @@ func DeleteListener(client *elb.ElbClient, ID string) (*elb_model.DeleteListener
 	return res, err
 }
 
-func CreateListener(client *elb.ElbClient, ELBID, name, desc string) (*elb_model.CreateListenerResponse, error) {
+func CreateListener(client ElbAPI, ELBID, name, desc string) (*elb_model.CreateListenerResponse, error) {
 	request := &elb_model.CreateListenerRequest{
 		Body: &elb_model.CreateListenerRequestBody{
 			Listener: &elb_model.CreateListenerReq{
@@ -106,7 +106,7 @@ func CreateListener(client *elb.ElbClient, ELBID, name, desc string) (*elb_model
 }
 
 func AddBackends(
-	client *elb.ElbClient, listerID, elbID, subnetID, poolID string, backends *[]cce_model.Node,
+	client ElbAPI, listerID, elbID, subnetID, poolID string, backends *[]cce_model.Node,
 ) (*elb_model.CreatePoolResponse, error) {
 	request := &elb_model.CreatePoolRequest{
 		Body: &elb_model.CreatePoolRequestBody{
@@ -140,7 +140,7 @@ func AddBackends(
 	return backendGroup, err
 }
 
-func ShowPool(client *elb.ElbClient, ID string) (*elb_model.ShowPoolResponse, error) {
+func ShowPool(client ElbAPI, ID string) (*elb_model.ShowPoolResponse, error) {
 	request := &elb_model.ShowPoolRequest{
 		PoolId: ID,
 	}
@@ -151,14 +151,14 @@ func ShowPool(client *elb.ElbClient, ID string) (*elb_model.ShowPoolResponse, er
 	return response, nil
 }
 
-func DeleteHealthcheck(client *elb.ElbClient, ID string) (*elb_model.DeleteHealthmonitorResponse, error) {
+func DeleteHealthcheck(client ElbAPI, ID string) (*elb_model.DeleteHealthmonitorResponse, error) {
 	request := &elb_model.DeleteHealthmonitorRequest{
 		HealthmonitorId: ID,
 	}
 	return client.DeleteHealthmonitor(request)
 }
 
-func DeleteMember(client *elb.ElbClient, poolID string, memberID string) (*elb_model.DeleteMemberResponse, error) {
+func DeleteMember(client ElbAPI, poolID string, memberID string) (*elb_model.DeleteMemberResponse, error) {
 	request := &elb_model.DeleteMemberRequest{
 		PoolId:   poolID,
 		MemberId: memberID,
@@ -166,14 +166,14 @@ func DeleteMember(client *elb.ElbClient, poolID string, memberID string) (*elb_m
 	return client.DeleteMember(request)
 }
 
-func DeletePool(client *elb.ElbClient, ID string) (*elb_model.DeletePoolResponse, error) {
+func DeletePool(client ElbAPI, ID string) (*elb_model.DeletePoolResponse, error) {
 	request := &elb_model.DeletePoolRequest{
 		PoolId: ID,
 	}
 	return client.DeletePool(request)
 }
 
-func DeleteLoadBalancer(client *elb.ElbClient, ID string) (*elb_model.DeleteLoadbalancerResponse, error) {
+func DeleteLoadBalancer(client ElbAPI, ID string) (*elb_model.DeleteLoadbalancerResponse, error) {
 	request := &elb_model.DeleteLoadbalancerRequest{
 		LoadbalancerId: ID,
 	}
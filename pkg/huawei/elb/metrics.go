@@ -0,0 +1,112 @@
+package elb
+
+import (
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v2/model"
+)
+
+// metricsElbAPI records the request metrics of the ElbAPI.
+type metricsElbAPI struct {
+	api    ElbAPI
+	policy *ratelimit.Policy
+}
+
+// WithMetrics returns the ElbAPI recording the request metrics of api, the
+// requests are rate limited and retried by policy if not nil.
+func WithMetrics(api ElbAPI, policy *ratelimit.Policy) ElbAPI {
+	return &metricsElbAPI{api: api, policy: policy}
+}
+
+func (m *metricsElbAPI) CreateLoadbalancer(request *model.CreateLoadbalancerRequest) (*model.CreateLoadbalancerResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "CreateLoadbalancer", func() (_ *model.CreateLoadbalancerResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "CreateLoadbalancer", time.Now(), &err)
+		return m.api.CreateLoadbalancer(request)
+	})
+}
+
+func (m *metricsElbAPI) ShowLoadbalancer(request *model.ShowLoadbalancerRequest) (*model.ShowLoadbalancerResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "ShowLoadbalancer", func() (_ *model.ShowLoadbalancerResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "ShowLoadbalancer", time.Now(), &err)
+		return m.api.ShowLoadbalancer(request)
+	})
+}
+
+func (m *metricsElbAPI) DeleteLoadbalancer(request *model.DeleteLoadbalancerRequest) (*model.DeleteLoadbalancerResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "DeleteLoadbalancer", func() (_ *model.DeleteLoadbalancerResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "DeleteLoadbalancer", time.Now(), &err)
+		return m.api.DeleteLoadbalancer(request)
+	})
+}
+
+func (m *metricsElbAPI) ListListeners(request *model.ListListenersRequest) (*model.ListListenersResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "ListListeners", func() (_ *model.ListListenersResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "ListListeners", time.Now(), &err)
+		return m.api.ListListeners(request)
+	})
+}
+
+func (m *metricsElbAPI) CreateListener(request *model.CreateListenerRequest) (*model.CreateListenerResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "CreateListener", func() (_ *model.CreateListenerResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "CreateListener", time.Now(), &err)
+		return m.api.CreateListener(request)
+	})
+}
+
+func (m *metricsElbAPI) UpdateListener(request *model.UpdateListenerRequest) (*model.UpdateListenerResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "UpdateListener", func() (_ *model.UpdateListenerResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "UpdateListener", time.Now(), &err)
+		return m.api.UpdateListener(request)
+	})
+}
+
+func (m *metricsElbAPI) DeleteListener(request *model.DeleteListenerRequest) (*model.DeleteListenerResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "DeleteListener", func() (_ *model.DeleteListenerResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "DeleteListener", time.Now(), &err)
+		return m.api.DeleteListener(request)
+	})
+}
+
+func (m *metricsElbAPI) CreatePool(request *model.CreatePoolRequest) (*model.CreatePoolResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "CreatePool", func() (_ *model.CreatePoolResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "CreatePool", time.Now(), &err)
+		return m.api.CreatePool(request)
+	})
+}
+
+func (m *metricsElbAPI) ShowPool(request *model.ShowPoolRequest) (*model.ShowPoolResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "ShowPool", func() (_ *model.ShowPoolResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "ShowPool", time.Now(), &err)
+		return m.api.ShowPool(request)
+	})
+}
+
+func (m *metricsElbAPI) DeletePool(request *model.DeletePoolRequest) (*model.DeletePoolResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "DeletePool", func() (_ *model.DeletePoolResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "DeletePool", time.Now(), &err)
+		return m.api.DeletePool(request)
+	})
+}
+
+func (m *metricsElbAPI) CreateMember(request *model.CreateMemberRequest) (*model.CreateMemberResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "CreateMember", func() (_ *model.CreateMemberResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "CreateMember", time.Now(), &err)
+		return m.api.CreateMember(request)
+	})
+}
+
+func (m *metricsElbAPI) DeleteMember(request *model.DeleteMemberRequest) (*model.DeleteMemberResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "DeleteMember", func() (_ *model.DeleteMemberResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "DeleteMember", time.Now(), &err)
+		return m.api.DeleteMember(request)
+	})
+}
+
+func (m *metricsElbAPI) DeleteHealthmonitor(request *model.DeleteHealthmonitorRequest) (*model.DeleteHealthmonitorResponse, error) {
+	return ratelimit.Call(m.policy, "elb", "DeleteHealthmonitor", func() (_ *model.DeleteHealthmonitorResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("elb", "DeleteHealthmonitor", time.Now(), &err)
+		return m.api.DeleteHealthmonitor(request)
+	})
+}
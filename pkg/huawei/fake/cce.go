@@ -0,0 +1,915 @@
+package fake
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+)
+
+const (
+	clusterPrefix = "/api/v3/projects/{project_id}/clusters"
+	nodePoolIDKey = "kubernetes.io/node-pool.id"
+)
+
+type clusterRecord struct {
+	cluster *model.Cluster
+	op      *operation
+}
+
+type nodePoolRecord struct {
+	clusterID string
+	nodePool  *model.NodePoolResp
+	op        *operation
+}
+
+type nodeRecord struct {
+	clusterID  string
+	nodePoolID string
+	node       *model.Node
+	op         *operation
+}
+
+type upgradeTaskRecord struct {
+	clusterID string
+	task      *model.ShowUpgradeClusterTaskResponse
+}
+
+type preCheckRecord struct {
+	clusterID string
+	task      *model.ShowPreCheckResponse
+	op        *operation
+}
+
+type addonRecord struct {
+	addon *model.AddonInstance
+	op    *operation
+}
+
+func (s *Server) registerCCERoutes() {
+	s.handle(http.MethodGet, clusterPrefix, s.listClusters)
+	s.handle(http.MethodPost, clusterPrefix, s.createCluster)
+	s.handle(http.MethodGet, clusterPrefix+"/{cluster_id}", s.showCluster)
+	s.handle(http.MethodPut, clusterPrefix+"/{cluster_id}", s.updateCluster)
+	s.handle(http.MethodDelete, clusterPrefix+"/{cluster_id}", s.deleteCluster)
+	s.handle(http.MethodPost, clusterPrefix+"/{cluster_id}/clustercert", s.createClusterCert)
+	s.handle(http.MethodPost, clusterPrefix+"/{cluster_id}/operation/upgrade", s.upgradeCluster)
+	s.handle(http.MethodGet, clusterPrefix+"/{cluster_id}/operation/upgrade/tasks/{task_id}",
+		s.showUpgradeClusterTask)
+	s.handle(http.MethodGet, "/api/v3/clusterupgradepaths", s.listClusterUpgradePaths)
+	s.handle(http.MethodPost, clusterPrefix+"/{cluster_id}/operation/precheck", s.createPreCheck)
+	s.handle(http.MethodGet, clusterPrefix+"/{cluster_id}/operation/precheck/tasks/{task_id}", s.showPreCheck)
+	s.handle(http.MethodPost, clusterPrefix+"/{cluster_id}/operation/resize", s.resizeCluster)
+
+	s.handle(http.MethodGet, clusterPrefix+"/{cluster_id}/nodepools", s.listNodePools)
+	s.handle(http.MethodPost, clusterPrefix+"/{cluster_id}/nodepools", s.createNodePool)
+	s.handle(http.MethodGet, clusterPrefix+"/{cluster_id}/nodepools/{nodepool_id}", s.showNodePool)
+	s.handle(http.MethodPut, clusterPrefix+"/{cluster_id}/nodepools/{nodepool_id}", s.updateNodePool)
+	s.handle(http.MethodDelete, clusterPrefix+"/{cluster_id}/nodepools/{nodepool_id}", s.deleteNodePool)
+
+	s.handle(http.MethodGet, clusterPrefix+"/{cluster_id}/nodes", s.listNodes)
+	s.handle(http.MethodGet, clusterPrefix+"/{cluster_id}/nodes/{node_id}", s.showNode)
+	s.handle(http.MethodDelete, clusterPrefix+"/{cluster_id}/nodes/{node_id}", s.deleteNode)
+
+	s.handle(http.MethodGet, "/api/v3/addons", s.listAddonInstances)
+	s.handle(http.MethodPost, "/api/v3/addons", s.createAddonInstance)
+	s.handle(http.MethodPut, "/api/v3/addons/{id}", s.updateAddonInstance)
+	s.handle(http.MethodDelete, "/api/v3/addons/{id}", s.deleteAddonInstance)
+}
+
+// Cluster returns a copy of the cluster stored in the fake server.
+func (s *Server) Cluster(id string) (*model.Cluster, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.clusters[id]
+	if !ok {
+		return nil, false
+	}
+	cluster := *c.cluster
+	return &cluster, true
+}
+
+// SetNodePhase updates the phase of the CCE node.
+func (s *Server) SetNodePhase(id string, phase model.NodeStatusPhase) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.nodes[id]
+	if !ok {
+		return false
+	}
+	n.node.Status.Phase = &phase
+	return true
+}
+
+// defaultUpgradePaths returns the upgrade paths of the fake server, a cluster
+// can be upgraded to the next one or two minor versions.
+func defaultUpgradePaths() map[string][]string {
+	return map[string][]string{
+		"v1.21": {"v1.23"},
+		"v1.23": {"v1.25"},
+		"v1.25": {"v1.27", "v1.28"},
+		"v1.27": {"v1.28", "v1.29"},
+		"v1.28": {"v1.29"},
+	}
+}
+
+// SetUpgradePaths replaces the versions the clusters can be upgraded to by
+// the cluster version.
+func (s *Server) SetUpgradePaths(paths map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.upgradePaths = paths
+}
+
+// SetPreCheckFailures makes the pre-upgrade checks started later fail with
+// the items, the checks succeed if no item is set.
+func (s *Server) SetPreCheckFailures(items ...model.PreCheckItemStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.preCheckFailures = items
+}
+
+func (s *Server) getCluster(w http.ResponseWriter, id string) (*clusterRecord, bool) {
+	c, ok := s.clusters[id]
+	if !ok {
+		notFound(w, "CCE.01404001", "cluster", id)
+		return nil, false
+	}
+	return c, true
+}
+
+func (s *Server) observeCluster(c *clusterRecord) {
+	observe(&c.op)
+}
+
+func (s *Server) listClusters(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+	for _, id := range sortedKeys(s.clusters) {
+		s.observeCluster(s.clusters[id])
+	}
+	items := []model.Cluster{}
+	for _, id := range sortedKeys(s.clusters) {
+		items = append(items, *s.clusters[id].cluster)
+	}
+	writeJSON(w, http.StatusOK, &model.ListClustersResponse{
+		Kind:       utils.Pointer("Cluster"),
+		ApiVersion: utils.Pointer("v3"),
+		Items:      &items,
+	})
+}
+
+func (s *Server) createCluster(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req := &model.Cluster{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Metadata == nil || req.Spec == nil || req.Spec.HostNetwork == nil {
+		writeError(w, http.StatusBadRequest, "CCE.01400001", "invalid cluster spec")
+		return
+	}
+	for _, c := range s.clusters {
+		if c.cluster.Metadata.Name == req.Metadata.Name {
+			writeError(w, http.StatusConflict, "CCE.01409001",
+				fmt.Sprintf("cluster name %q already exists", req.Metadata.Name))
+			return
+		}
+	}
+	if _, ok := s.vpcs[req.Spec.HostNetwork.Vpc]; !ok {
+		notFound(w, "CCE.01400002", "vpc", req.Spec.HostNetwork.Vpc)
+		return
+	}
+	if _, ok := s.subnets[req.Spec.HostNetwork.Subnet]; !ok {
+		notFound(w, "CCE.01400002", "subnet", req.Spec.HostNetwork.Subnet)
+		return
+	}
+
+	id := s.newID(KindCluster)
+	req.Kind = "Cluster"
+	req.ApiVersion = "v3"
+	req.Metadata.Uid = utils.Pointer(id)
+	req.Metadata.Alias = utils.Pointer(req.Metadata.Name)
+	req.Metadata.CreationTimestamp = utils.Pointer(time.Now().UTC().Format(time.RFC3339))
+	if utils.Value(req.Spec.HostNetwork.SecurityGroup) == "" {
+		req.Spec.HostNetwork.SecurityGroup = utils.Pointer(s.newID("sg"))
+	}
+	if req.Spec.ExtendParam != nil && utils.Value(req.Spec.ExtendParam.ClusterAZ) != "" {
+		req.Spec.Az = req.Spec.ExtendParam.ClusterAZ
+	} else {
+		req.Spec.Az = utils.Pointer(s.Region + "a")
+	}
+	req.Status = &model.ClusterStatus{
+		Phase:     utils.Pointer("Creating"),
+		JobID:     utils.Pointer(s.newID("job")),
+		Endpoints: &[]model.ClusterEndpoints{},
+	}
+	c := &clusterRecord{cluster: req}
+	c.op = s.newOperation(func() {
+		c.cluster.Status.Phase = utils.Pointer("Available")
+		endpoints := []model.ClusterEndpoints{{
+			Url:  utils.Pointer("https://192.168.0.10:5443"),
+			Type: utils.Pointer("Internal"),
+		}}
+		if c.cluster.Spec.ExtendParam != nil && utils.Value(c.cluster.Spec.ExtendParam.ClusterExternalIP) != "" {
+			endpoints = append(endpoints, model.ClusterEndpoints{
+				Url:  utils.Pointer(fmt.Sprintf("https://%s:5443", *c.cluster.Spec.ExtendParam.ClusterExternalIP)),
+				Type: utils.Pointer("External"),
+			})
+		}
+		c.cluster.Status.Endpoints = &endpoints
+	})
+	s.clusters[id] = c
+
+	// CCE creates a VPC endpoint service for the cluster, which is not
+	// deleted with the cluster.
+	vpcepID := s.newID(KindVpcepService)
+	s.vpcepServices[vpcepID] = &vpcepServiceRecord{
+		id:    vpcepID,
+		vpcID: req.Spec.HostNetwork.Vpc,
+	}
+
+	writeJSON(w, http.StatusCreated, req)
+}
+
+func (s *Server) showCluster(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	c, ok := s.getCluster(w, params["cluster_id"])
+	if !ok {
+		return
+	}
+	s.observeCluster(c)
+	if _, ok := s.clusters[params["cluster_id"]]; !ok {
+		// Cluster deletion completed.
+		notFound(w, "CCE.01404001", "cluster", params["cluster_id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, c.cluster)
+}
+
+func (s *Server) updateCluster(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	c, ok := s.getCluster(w, params["cluster_id"])
+	if !ok {
+		return
+	}
+	req := &model.ClusterInformation{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Metadata != nil && utils.Value(req.Metadata.Alias) != "" {
+		c.cluster.Metadata.Alias = req.Metadata.Alias
+	}
+	if req.Spec != nil {
+		if req.Spec.Description != nil {
+			c.cluster.Spec.Description = req.Spec.Description
+		}
+		if req.Spec.HostNetwork != nil && utils.Value(req.Spec.HostNetwork.SecurityGroup) != "" {
+			c.cluster.Spec.HostNetwork.SecurityGroup = req.Spec.HostNetwork.SecurityGroup
+		}
+	}
+	writeJSON(w, http.StatusOK, c.cluster)
+}
+
+func (s *Server) deleteCluster(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	id := params["cluster_id"]
+	c, ok := s.getCluster(w, id)
+	if !ok {
+		return
+	}
+	c.cluster.Status.Phase = utils.Pointer("Deleting")
+	c.op = s.newOperation(func() {
+		delete(s.clusters, id)
+		for npID, np := range s.nodePools {
+			if np.clusterID == id {
+				delete(s.nodePools, npID)
+			}
+		}
+		for nodeID, n := range s.nodes {
+			if n.clusterID == id {
+				delete(s.nodes, nodeID)
+			}
+		}
+		for addonID, a := range s.addons {
+			if a.addon.Spec.ClusterID == id {
+				delete(s.addons, addonID)
+			}
+		}
+	})
+	writeJSON(w, http.StatusOK, c.cluster)
+}
+
+func (s *Server) createClusterCert(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	c, ok := s.getCluster(w, params["cluster_id"])
+	if !ok {
+		return
+	}
+	ca := base64.StdEncoding.EncodeToString([]byte("fake-ca-" + params["cluster_id"]))
+	clusters := []model.Clusters{}
+	var endpoints []model.ClusterEndpoints
+	if c.cluster.Status.Endpoints != nil {
+		endpoints = *c.cluster.Status.Endpoints
+	}
+	for _, e := range endpoints {
+		name := "internalCluster"
+		if utils.Value(e.Type) == "External" {
+			name = "externalClusterTLSVerify"
+		}
+		clusters = append(clusters, model.Clusters{
+			Name: utils.Pointer(name),
+			Cluster: &model.ClusterCert{
+				Server:                   e.Url,
+				CertificateAuthorityData: utils.Pointer(ca),
+			},
+		})
+	}
+	writeJSON(w, http.StatusOK, &model.CreateKubernetesClusterCertResponse{
+		Kind:           utils.Pointer("Config"),
+		ApiVersion:     utils.Pointer("v1"),
+		Clusters:       &clusters,
+		CurrentContext: utils.Pointer("internal"),
+	})
+}
+
+func (s *Server) upgradeCluster(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	id := params["cluster_id"]
+	c, ok := s.getCluster(w, id)
+	if !ok {
+		return
+	}
+	req := &model.UpgradeClusterRequestBody{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Spec == nil || req.Spec.ClusterUpgradeAction == nil {
+		writeError(w, http.StatusBadRequest, "CCE.01400001", "invalid upgrade spec")
+		return
+	}
+	if utils.Value(c.cluster.Status.Phase) != "Available" {
+		writeError(w, http.StatusConflict, "CCE.01400013",
+			fmt.Sprintf("cluster %s status %s is not available", id, utils.Value(c.cluster.Status.Phase)))
+		return
+	}
+	targetVersion := req.Spec.ClusterUpgradeAction.TargetVersion
+	supported := false
+	for _, v := range s.upgradePaths[utils.Value(c.cluster.Spec.Version)] {
+		supported = supported || v == targetVersion
+	}
+	if !supported {
+		writeError(w, http.StatusBadRequest, "CCE.01400001",
+			fmt.Sprintf("unsupported to upgrade cluster %s from %s to %s",
+				id, utils.Value(c.cluster.Spec.Version), targetVersion))
+		return
+	}
+	taskID := s.newID("upgradetask")
+	task := &upgradeTaskRecord{
+		clusterID: id,
+		task: &model.ShowUpgradeClusterTaskResponse{
+			ApiVersion: utils.Pointer("v3"),
+			Kind:       utils.Pointer("UpgradeTask"),
+			Metadata: &model.UpgradeTaskMetadata{
+				Uid: utils.Pointer(taskID),
+			},
+			Spec: &model.UpgradeTaskSpec{
+				Version:       c.cluster.Spec.Version,
+				TargetVersion: utils.Pointer(targetVersion),
+			},
+			Status: &model.UpgradeTaskStatus{
+				Phase: utils.Pointer("Running"),
+			},
+		},
+	}
+	s.upgradeTasks[taskID] = task
+	c.cluster.Status.Phase = utils.Pointer("Upgrading")
+	c.op = s.newOperation(func() {
+		c.cluster.Spec.Version = utils.Pointer(targetVersion)
+		c.cluster.Status.Phase = utils.Pointer("Available")
+		task.task.Status.Phase = utils.Pointer("Success")
+	})
+	writeJSON(w, http.StatusOK, &model.UpgradeClusterResponse{
+		Metadata: &model.UpgradeCluserResponseMetadata{
+			Uid: utils.Pointer(taskID),
+		},
+	})
+}
+
+func (s *Server) showUpgradeClusterTask(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	task, ok := s.upgradeTasks[params["task_id"]]
+	if !ok || task.clusterID != params["cluster_id"] {
+		notFound(w, "CCE.01404001", "upgrade task", params["task_id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, task.task)
+}
+
+func (s *Server) listClusterUpgradePaths(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+	versions := make([]string, 0, len(s.upgradePaths))
+	for v := range s.upgradePaths {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	paths := make([]model.UpgradePath, 0, len(versions))
+	for _, v := range versions {
+		targets := append([]string(nil), s.upgradePaths[v]...)
+		paths = append(paths, model.UpgradePath{
+			Version:         utils.Pointer(v),
+			PlatformVersion: utils.Pointer("cce.1.0"),
+			TargetVersions:  &targets,
+		})
+	}
+	writeJSON(w, http.StatusOK, &model.ListClusterUpgradePathsResponse{
+		ApiVersion:   utils.Pointer("v3"),
+		Kind:         utils.Pointer("UpgradePath"),
+		UpgradePaths: &paths,
+	})
+}
+
+func (s *Server) createPreCheck(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	id := params["cluster_id"]
+	c, ok := s.getCluster(w, id)
+	if !ok {
+		return
+	}
+	req := &model.PrecheckClusterRequestBody{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Spec == nil || utils.Value(req.Spec.TargetVersion) == "" {
+		writeError(w, http.StatusBadRequest, "CCE.01400001", "invalid precheck spec")
+		return
+	}
+	taskID := s.newID("prechecktask")
+	task := &preCheckRecord{
+		clusterID: id,
+		task: &model.ShowPreCheckResponse{
+			ApiVersion: utils.Pointer("v3"),
+			Kind:       utils.Pointer("PreCheckTask"),
+			Metadata: &model.PrecheckTaskMetadata{
+				Uid:               utils.Pointer(taskID),
+				CreationTimestamp: utils.Pointer(time.Now().UTC().Format(time.RFC3339)),
+			},
+			Spec: &model.PrecheckSpec{
+				ClusterID:      utils.Pointer(id),
+				ClusterVersion: c.cluster.Spec.Version,
+				TargetVersion:  req.Spec.TargetVersion,
+			},
+			Status: &model.PrecheckStatus{
+				Phase: utils.Pointer("Running"),
+			},
+		},
+	}
+	failures := append([]model.PreCheckItemStatus(nil), s.preCheckFailures...)
+	task.op = s.newOperation(func() {
+		if len(failures) == 0 {
+			task.task.Status.Phase = utils.Pointer("Success")
+			return
+		}
+		task.task.Status.Phase = utils.Pointer("Failed")
+		task.task.Status.ClusterCheckStatus = &model.ClusterCheckStatus{
+			Phase:       utils.Pointer("Failed"),
+			ItemsStatus: &failures,
+		}
+	})
+	s.preChecks[taskID] = task
+	writeJSON(w, http.StatusOK, &model.CreatePreCheckResponse{
+		ApiVersion: task.task.ApiVersion,
+		Kind:       task.task.Kind,
+		Metadata: &model.PrecheckCluserResponseMetadata{
+			Uid: utils.Pointer(taskID),
+		},
+		Spec:   task.task.Spec,
+		Status: task.task.Status,
+	})
+}
+
+func (s *Server) showPreCheck(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	task, ok := s.preChecks[params["task_id"]]
+	if !ok || task.clusterID != params["cluster_id"] {
+		notFound(w, "CCE.01404001", "precheck task", params["task_id"])
+		return
+	}
+	observe(&task.op)
+	writeJSON(w, http.StatusOK, task.task)
+}
+
+func (s *Server) resizeCluster(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	c, ok := s.getCluster(w, params["cluster_id"])
+	if !ok {
+		return
+	}
+	req := &model.ResizeClusterRequestBody{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if utils.Value(c.cluster.Status.Phase) != "Available" {
+		writeError(w, http.StatusConflict, "CCE.01400013",
+			fmt.Sprintf("cluster status %s is not available", utils.Value(c.cluster.Status.Phase)))
+		return
+	}
+	flavor := req.FlavorResize
+	c.cluster.Status.Phase = utils.Pointer("Resizing")
+	c.op = s.newOperation(func() {
+		c.cluster.Spec.Flavor = flavor
+		c.cluster.Status.Phase = utils.Pointer("Available")
+	})
+	writeJSON(w, http.StatusOK, &model.ResizeClusterResponse{
+		JobID: utils.Pointer(s.newID("job")),
+	})
+}
+
+func (s *Server) clusterNodePools(clusterID string) []*nodePoolRecord {
+	var records []*nodePoolRecord
+	for _, id := range sortedKeys(s.nodePools) {
+		if np := s.nodePools[id]; np.clusterID == clusterID {
+			records = append(records, np)
+		}
+	}
+	return records
+}
+
+func (s *Server) listNodePools(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	id := params["cluster_id"]
+	if _, ok := s.getCluster(w, id); !ok {
+		return
+	}
+	for _, np := range s.clusterNodePools(id) {
+		observe(&np.op)
+	}
+	items := []model.NodePoolResp{}
+	for _, np := range s.clusterNodePools(id) {
+		items = append(items, *np.nodePool)
+	}
+	writeJSON(w, http.StatusOK, &model.ListNodePoolsResponse{
+		Kind:       utils.Pointer("List"),
+		ApiVersion: utils.Pointer("v3"),
+		Items:      &items,
+	})
+}
+
+func (s *Server) createNodePool(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	clusterID := params["cluster_id"]
+	c, ok := s.getCluster(w, clusterID)
+	if !ok {
+		return
+	}
+	req := &model.NodePool{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Metadata == nil || req.Spec == nil || req.Spec.NodeTemplate == nil {
+		writeError(w, http.StatusBadRequest, "CCE.01400001", "invalid node pool spec")
+		return
+	}
+	if utils.Value(c.cluster.Status.Phase) != "Available" {
+		writeError(w, http.StatusConflict, "CCE.01400013",
+			fmt.Sprintf("cluster status %s is not available", utils.Value(c.cluster.Status.Phase)))
+		return
+	}
+	for _, np := range s.clusterNodePools(clusterID) {
+		if np.nodePool.Metadata.Name == req.Metadata.Name {
+			writeError(w, http.StatusConflict, "CCE.01409001",
+				fmt.Sprintf("node pool name %q already exists", req.Metadata.Name))
+			return
+		}
+	}
+	id := s.newID(KindNodePool)
+	req.Metadata.Uid = utils.Pointer(id)
+	req.Metadata.CreationTimestamp = utils.Pointer(time.Now().UTC().Format(time.RFC3339))
+	if req.Spec.Autoscaling == nil {
+		req.Spec.Autoscaling = &model.NodePoolNodeAutoscaling{}
+	}
+	np := &nodePoolRecord{
+		clusterID: clusterID,
+		nodePool: &model.NodePoolResp{
+			Kind:       "NodePool",
+			ApiVersion: "v3",
+			Metadata:   req.Metadata,
+			Spec:       req.Spec,
+			Status: &model.NodePoolStatus{
+				Phase:       ptr(model.GetNodePoolStatusPhaseEnum().SYNCHRONIZING),
+				CurrentNode: utils.Pointer(int32(0)),
+			},
+		},
+	}
+	np.op = s.newOperation(func() { s.syncNodePool(np) })
+	s.nodePools[id] = np
+	writeJSON(w, http.StatusCreated, np.nodePool)
+}
+
+// syncNodePool creates or deletes nodes to match the node pool initial node count.
+func (s *Server) syncNodePool(np *nodePoolRecord) {
+	npID := utils.Value(np.nodePool.Metadata.Uid)
+	var nodes []*nodeRecord
+	for _, id := range sortedKeys(s.nodes) {
+		if n := s.nodes[id]; n.nodePoolID == npID {
+			nodes = append(nodes, n)
+		}
+	}
+	desired := int(utils.Value(np.nodePool.Spec.InitialNodeCount))
+	for i := len(nodes); i < desired; i++ {
+		id := s.newID(KindNode)
+		n := &nodeRecord{
+			clusterID:  np.clusterID,
+			nodePoolID: npID,
+			node: &model.Node{
+				Kind:       utils.Pointer("Node"),
+				ApiVersion: utils.Pointer("v3"),
+				Metadata: &model.NodeMetadata{
+					Name: utils.Pointer(fmt.Sprintf("%s-%s", np.nodePool.Metadata.Name, utils.RandomHex(5))),
+					Uid:  utils.Pointer(id),
+					Annotations: map[string]string{
+						nodePoolIDKey: npID,
+					},
+				},
+				Spec: np.nodePool.Spec.NodeTemplate,
+				Status: &model.NodeStatus{
+					Phase:     ptr(model.GetNodeStatusPhaseEnum().INSTALLING),
+					PrivateIP: utils.Pointer(fmt.Sprintf("10.224.0.%d", 10+s.sequence%240)),
+					ServerId:  utils.Pointer(fmt.Sprintf("server-%d", s.sequence)),
+				},
+			},
+		}
+		n.op = s.newOperation(func() {
+			n.node.Status.Phase = ptr(model.GetNodeStatusPhaseEnum().ACTIVE)
+		})
+		s.nodes[id] = n
+	}
+	for i := desired; i < len(nodes); i++ {
+		delete(s.nodes, utils.Value(nodes[i].node.Metadata.Uid))
+	}
+	np.nodePool.Status.Phase = nil
+	np.nodePool.Status.CurrentNode = utils.Pointer(int32(desired))
+}
+
+func (s *Server) getNodePool(w http.ResponseWriter, params map[string]string) (*nodePoolRecord, bool) {
+	np, ok := s.nodePools[params["nodepool_id"]]
+	if !ok || np.clusterID != params["cluster_id"] {
+		notFound(w, "CCE.01404001", "node pool", params["nodepool_id"])
+		return nil, false
+	}
+	return np, true
+}
+
+func (s *Server) showNodePool(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	np, ok := s.getNodePool(w, params)
+	if !ok {
+		return
+	}
+	observe(&np.op)
+	if _, ok := s.nodePools[params["nodepool_id"]]; !ok {
+		notFound(w, "CCE.01404001", "node pool", params["nodepool_id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, np.nodePool)
+}
+
+func (s *Server) updateNodePool(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	np, ok := s.getNodePool(w, params)
+	if !ok {
+		return
+	}
+	req := &model.NodePoolUpdate{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Spec == nil {
+		writeError(w, http.StatusBadRequest, "CCE.01400001", "invalid node pool spec")
+		return
+	}
+	if req.Metadata != nil && req.Metadata.Name != "" {
+		np.nodePool.Metadata.Name = req.Metadata.Name
+	}
+	if req.Spec.Autoscaling != nil {
+		np.nodePool.Spec.Autoscaling = req.Spec.Autoscaling
+	}
+	if t := req.Spec.NodeTemplate; t != nil && np.nodePool.Spec.NodeTemplate != nil {
+		np.nodePool.Spec.NodeTemplate.Taints = &t.Taints
+		np.nodePool.Spec.NodeTemplate.K8sTags = t.K8sTags
+		np.nodePool.Spec.NodeTemplate.UserTags = &t.UserTags
+	}
+	if req.Spec.InitialNodeCount != utils.Value(np.nodePool.Spec.InitialNodeCount) {
+		np.nodePool.Spec.InitialNodeCount = utils.Pointer(req.Spec.InitialNodeCount)
+		np.nodePool.Status.Phase = ptr(model.GetNodePoolStatusPhaseEnum().SYNCHRONIZING)
+		np.op = s.newOperation(func() { s.syncNodePool(np) })
+	}
+	writeJSON(w, http.StatusOK, np.nodePool)
+}
+
+func (s *Server) deleteNodePool(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	np, ok := s.getNodePool(w, params)
+	if !ok {
+		return
+	}
+	npID := params["nodepool_id"]
+	np.nodePool.Status.Phase = ptr(model.GetNodePoolStatusPhaseEnum().DELETING)
+	np.op = s.newOperation(func() {
+		delete(s.nodePools, npID)
+		for id, n := range s.nodes {
+			if n.nodePoolID == npID {
+				delete(s.nodes, id)
+			}
+		}
+	})
+	writeJSON(w, http.StatusOK, np.nodePool)
+}
+
+func (s *Server) clusterNodes(clusterID string) []*nodeRecord {
+	var records []*nodeRecord
+	for _, id := range sortedKeys(s.nodes) {
+		if n := s.nodes[id]; n.clusterID == clusterID {
+			records = append(records, n)
+		}
+	}
+	return records
+}
+
+func (s *Server) listNodes(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	id := params["cluster_id"]
+	if _, ok := s.getCluster(w, id); !ok {
+		return
+	}
+	for _, n := range s.clusterNodes(id) {
+		observe(&n.op)
+	}
+	items := []model.Node{}
+	for _, n := range s.clusterNodes(id) {
+		items = append(items, *n.node)
+	}
+	writeJSON(w, http.StatusOK, &model.ListNodesResponse{
+		Kind:       utils.Pointer("List"),
+		ApiVersion: utils.Pointer("v3"),
+		Items:      &items,
+	})
+}
+
+func (s *Server) getNode(w http.ResponseWriter, params map[string]string) (*nodeRecord, bool) {
+	n, ok := s.nodes[params["node_id"]]
+	if !ok || n.clusterID != params["cluster_id"] {
+		notFound(w, "CCE.01404001", "node", params["node_id"])
+		return nil, false
+	}
+	return n, true
+}
+
+func (s *Server) showNode(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	n, ok := s.getNode(w, params)
+	if !ok {
+		return
+	}
+	observe(&n.op)
+	if _, ok := s.nodes[params["node_id"]]; !ok {
+		notFound(w, "CCE.01404001", "node", params["node_id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, n.node)
+}
+
+func (s *Server) deleteNode(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	n, ok := s.getNode(w, params)
+	if !ok {
+		return
+	}
+	id := params["node_id"]
+	scaleDown := r.URL.Query().Get("nodepoolScaleDown") != "NoScaleDown"
+	n.node.Status.Phase = ptr(model.GetNodeStatusPhaseEnum().DELETING)
+	n.op = s.newOperation(func() {
+		delete(s.nodes, id)
+		np, ok := s.nodePools[n.nodePoolID]
+		if !ok {
+			return
+		}
+		if !scaleDown {
+			// The node pool creates a new node to restore the node count.
+			s.syncNodePool(np)
+			return
+		}
+		// Deleting a node from the node pool decreases the node count.
+		count := utils.Value(np.nodePool.Spec.InitialNodeCount)
+		if count > 0 {
+			np.nodePool.Spec.InitialNodeCount = utils.Pointer(count - 1)
+			np.nodePool.Status.CurrentNode = utils.Pointer(count - 1)
+		}
+	})
+	writeJSON(w, http.StatusOK, n.node)
+}
+
+func (s *Server) listAddonInstances(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	clusterID := r.URL.Query().Get("cluster_id")
+	templateName := r.URL.Query().Get("addon_template_name")
+	items := []model.AddonInstance{}
+	for _, id := range sortedKeys(s.addons) {
+		observe(&s.addons[id].op)
+		r, ok := s.addons[id]
+		if !ok {
+			continue
+		}
+		a := r.addon
+		if clusterID != "" && a.Spec.ClusterID != clusterID {
+			continue
+		}
+		if templateName != "" && a.Spec.AddonTemplateName != templateName {
+			continue
+		}
+		items = append(items, *a)
+	}
+	writeJSON(w, http.StatusOK, &model.ListAddonInstancesResponse{
+		Kind:       utils.Pointer("Addon"),
+		ApiVersion: utils.Pointer("v3"),
+		Items:      &items,
+	})
+}
+
+func (s *Server) createAddonInstance(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req := &model.InstanceRequest{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Spec == nil || req.Spec.AddonTemplateName == "" {
+		writeError(w, http.StatusBadRequest, "CCE.01400001", "invalid addon spec")
+		return
+	}
+	if _, ok := s.getCluster(w, req.Spec.ClusterID); !ok {
+		return
+	}
+	id := s.newID(KindAddon)
+	addon := &model.AddonInstance{
+		Kind:       "Addon",
+		ApiVersion: "v3",
+		Metadata: &model.AddonMetadata{
+			Uid:   utils.Pointer(id),
+			Name:  utils.Pointer(req.Spec.AddonTemplateName),
+			Alias: utils.Pointer(req.Spec.AddonTemplateName),
+		},
+		Spec: &model.InstanceSpec{
+			ClusterID:         req.Spec.ClusterID,
+			Version:           utils.Value(req.Spec.Version),
+			AddonTemplateName: req.Spec.AddonTemplateName,
+			Values:            req.Spec.Values,
+		},
+		Status: &model.AddonInstanceStatus{
+			Status: model.GetAddonInstanceStatusStatusEnum().RUNNING,
+			CurrentVersion: &model.Versions{
+				Version: utils.Value(req.Spec.Version),
+			},
+		},
+	}
+	s.addons[id] = &addonRecord{addon: addon}
+	writeJSON(w, http.StatusCreated, addon)
+}
+
+func (s *Server) getAddon(w http.ResponseWriter, params map[string]string) (*addonRecord, bool) {
+	a, ok := s.addons[params["id"]]
+	if !ok {
+		notFound(w, "CCE.01404001", "addon", params["id"])
+		return nil, false
+	}
+	return a, true
+}
+
+func (s *Server) updateAddonInstance(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	a, ok := s.getAddon(w, params)
+	if !ok {
+		return
+	}
+	req := &model.InstanceRequest{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Spec == nil || req.Spec.Version == nil {
+		writeError(w, http.StatusBadRequest, "CCE.01400001", "invalid addon spec")
+		return
+	}
+	a.addon.Spec.Version = *req.Spec.Version
+	a.addon.Spec.Values = req.Spec.Values
+	a.addon.Status.Status = model.GetAddonInstanceStatusStatusEnum().UPGRADING
+	a.op = s.newOperation(func() {
+		a.addon.Status.Status = model.GetAddonInstanceStatusStatusEnum().RUNNING
+		a.addon.Status.CurrentVersion = &model.Versions{Version: a.addon.Spec.Version}
+	})
+	writeJSON(w, http.StatusOK, a.addon)
+}
+
+func (s *Server) deleteAddonInstance(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	a, ok := s.getAddon(w, params)
+	if !ok {
+		return
+	}
+	id := params["id"]
+	a.addon.Status.Status = model.GetAddonInstanceStatusStatusEnum().DELETING
+	a.op = s.newOperation(func() {
+		delete(s.addons, id)
+	})
+	writeJSON(w, http.StatusOK, "success")
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
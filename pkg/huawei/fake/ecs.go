@@ -0,0 +1,79 @@
+package fake
+
+import (
+	"net/http"
+
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	ecs_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+)
+
+type jobRecord struct {
+	job *ecs_model.ShowJobResponse
+	op  *operation
+}
+
+func (s *Server) registerECSRoutes() {
+	s.handle(http.MethodPost, "/v1/{project_id}/cloudservers/action", s.batchRebootServers)
+	s.handle(http.MethodGet, "/v1/{project_id}/jobs/{job_id}", s.showJob)
+}
+
+// Reboots returns the IDs of the servers rebooted, in request order.
+func (s *Server) Reboots() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.reboots...)
+}
+
+// serverNode returns the CCE node of the server.
+func (s *Server) serverNode(serverID string) (*nodeRecord, bool) {
+	for _, n := range s.nodes {
+		if utils.Value(n.node.Status.ServerId) == serverID {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Server) batchRebootServers(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req := &ecs_model.BatchRebootServersRequestBody{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Reboot == nil || len(req.Reboot.Servers) == 0 {
+		writeError(w, http.StatusBadRequest, "Ecs.0005", "invalid reboot request")
+		return
+	}
+	for _, server := range req.Reboot.Servers {
+		if _, ok := s.serverNode(server.Id); !ok {
+			notFound(w, "Ecs.0114", "server", server.Id)
+			return
+		}
+	}
+	id := s.newID("job")
+	j := &jobRecord{
+		job: &ecs_model.ShowJobResponse{
+			JobId:   utils.Pointer(id),
+			JobType: utils.Pointer("batchRebootServer"),
+			Status:  ptr(ecs_model.GetShowJobResponseStatusEnum().RUNNING),
+		},
+	}
+	j.op = s.newOperation(func() {
+		j.job.Status = ptr(ecs_model.GetShowJobResponseStatusEnum().SUCCESS)
+	})
+	s.jobs[id] = j
+	for _, server := range req.Reboot.Servers {
+		s.reboots = append(s.reboots, server.Id)
+	}
+	writeJSON(w, http.StatusOK, &ecs_model.BatchRebootServersResponse{JobId: utils.Pointer(id)})
+}
+
+func (s *Server) showJob(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	j, ok := s.jobs[params["job_id"]]
+	if !ok {
+		notFound(w, "Ecs.0200", "job", params["job_id"])
+		return
+	}
+	observe(&j.op)
+	writeJSON(w, http.StatusOK, j.job)
+}
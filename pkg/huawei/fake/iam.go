@@ -0,0 +1,60 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	iam_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3/model"
+)
+
+func (s *Server) registerIAMRoutes() {
+	s.handle(http.MethodPost, "/v3.0/OS-CREDENTIAL/securitytokens", s.createTemporaryAccessKeyByAgency)
+}
+
+// AssumedAgencies returns the agencies assumed as domain/agency, in request
+// order.
+func (s *Server) AssumedAgencies() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.assumedAgencies...)
+}
+
+// SecurityToken returns the security token of the last request, empty if
+// the request was signed by the permanent access key.
+func (s *Server) SecurityToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.securityToken
+}
+
+func (s *Server) createTemporaryAccessKeyByAgency(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req := &iam_model.CreateTemporaryAccessKeyByAgencyRequestBody{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Auth == nil || req.Auth.Identity == nil || req.Auth.Identity.AssumeRole == nil ||
+		req.Auth.Identity.AssumeRole.AgencyName == "" {
+		writeError(w, http.StatusBadRequest, "1100", "invalid assume role request")
+		return
+	}
+	role := req.Auth.Identity.AssumeRole
+	duration := time.Duration(utils.Value(role.DurationSeconds)) * time.Second
+	if duration == 0 {
+		duration = 15 * time.Minute
+	}
+	s.sequence++
+	s.assumedAgencies = append(s.assumedAgencies,
+		fmt.Sprintf("%s/%s", utils.Value(role.DomainName), role.AgencyName))
+	writeJSON(w, http.StatusCreated, &iam_model.CreateTemporaryAccessKeyByAgencyResponse{
+		Credential: &iam_model.Credential{
+			ExpiresAt:     time.Now().Add(duration).UTC().Format("2006-01-02T15:04:05.000000Z"),
+			Access:        fmt.Sprintf("fake-temporary-ak-%d", s.sequence),
+			Secret:        fmt.Sprintf("fake-temporary-sk-%d", s.sequence),
+			Securitytoken: fmt.Sprintf("fake-security-token-%d", s.sequence),
+		},
+	})
+}
@@ -0,0 +1,459 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	dns_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/dns/v2/model"
+	eip_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+	nat_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/nat/v2/model"
+	vpc_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	vpcep_model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpcep/v1/model"
+)
+
+type vpcRecord struct {
+	vpc *vpc_model.Vpc
+}
+
+type subnetRecord struct {
+	subnet *vpc_model.Subnet
+}
+
+type eipRecord struct {
+	id            string
+	alias         string
+	address       string
+	ipType        string
+	bandwidthSize int32
+}
+
+type natGatewayRecord struct {
+	natGateway *nat_model.NatGatewayResponseBody
+	op         *operation
+}
+
+type snatRuleRecord struct {
+	id           string
+	natGatewayID string
+	networkID    string
+	eipID        string
+	sourceType   int32
+}
+
+type vpcepServiceRecord struct {
+	id    string
+	vpcID string
+}
+
+func (s *Server) registerNetworkRoutes() {
+	s.handle(http.MethodPost, "/v1/{project_id}/vpcs", s.createVpc)
+	s.handle(http.MethodGet, "/v1/{project_id}/vpcs/{vpc_id}", s.showVpc)
+	s.handle(http.MethodDelete, "/v1/{project_id}/vpcs/{vpc_id}", s.deleteVpc)
+	s.handle(http.MethodPost, "/v1/{project_id}/subnets", s.createSubnet)
+	s.handle(http.MethodGet, "/v1/{project_id}/subnets/{subnet_id}", s.showSubnet)
+	s.handle(http.MethodDelete, "/v1/{project_id}/vpcs/{vpc_id}/subnets/{subnet_id}", s.deleteSubnet)
+
+	s.handle(http.MethodPost, "/v1/{project_id}/publicips", s.createPublicip)
+	s.handle(http.MethodGet, "/v1/{project_id}/publicips/{publicip_id}", s.showPublicip)
+	s.handle(http.MethodDelete, "/v1/{project_id}/publicips/{publicip_id}", s.deletePublicip)
+
+	s.handle(http.MethodPost, "/v2/{project_id}/nat_gateways", s.createNatGateway)
+	s.handle(http.MethodGet, "/v2/{project_id}/nat_gateways/{nat_gateway_id}", s.showNatGateway)
+	s.handle(http.MethodDelete, "/v2/{project_id}/nat_gateways/{nat_gateway_id}", s.deleteNatGateway)
+	s.handle(http.MethodPost, "/v2/{project_id}/snat_rules", s.createSnatRule)
+	s.handle(http.MethodGet, "/v2/{project_id}/snat_rules", s.listSnatRules)
+	s.handle(http.MethodDelete, "/v2/{project_id}/nat_gateways/{nat_gateway_id}/snat_rules/{snat_rule_id}",
+		s.deleteSnatRule)
+
+	s.handle(http.MethodGet, "/v1/{project_id}/vpc-endpoint-services", s.listEndpointServices)
+	s.handle(http.MethodDelete, "/v1/{project_id}/vpc-endpoint-services/{vpc_endpoint_service_id}",
+		s.deleteEndpointService)
+
+	s.handle(http.MethodGet, "/v2/nameservers", s.listNameServers)
+}
+
+func (s *Server) createVpc(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req := &vpc_model.CreateVpcRequestBody{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Vpc == nil {
+		writeError(w, http.StatusBadRequest, "VPC.0001", "invalid vpc option")
+		return
+	}
+	id := s.newID(KindVPC)
+	vpc := &vpc_model.Vpc{
+		Id:          id,
+		Name:        utils.Value(req.Vpc.Name),
+		Cidr:        utils.Value(req.Vpc.Cidr),
+		Description: utils.Value(req.Vpc.Description),
+		Routes:      []vpc_model.Route{},
+		Status:      vpc_model.GetVpcStatusEnum().OK,
+		TenantId:    s.ProjectID,
+	}
+	s.vpcs[id] = &vpcRecord{vpc: vpc}
+	s.setTags(id, parseCreateTags(req.Vpc.Tags))
+	writeJSON(w, http.StatusOK, &vpc_model.CreateVpcResponse{Vpc: vpc})
+}
+
+func (s *Server) showVpc(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	v, ok := s.vpcs[params["vpc_id"]]
+	if !ok {
+		notFound(w, "VPC.0012", "vpc", params["vpc_id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, &vpc_model.ShowVpcResponse{Vpc: v.vpc})
+}
+
+func (s *Server) deleteVpc(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	id := params["vpc_id"]
+	if _, ok := s.vpcs[id]; !ok {
+		notFound(w, "VPC.0012", "vpc", id)
+		return
+	}
+	for _, subnet := range s.subnets {
+		if subnet.subnet.VpcId == id {
+			writeError(w, http.StatusConflict, "VPC.0120",
+				fmt.Sprintf("vpc %s has subnet %s, delete subnets firstly", id, subnet.subnet.Id))
+			return
+		}
+	}
+	for _, svc := range s.vpcepServices {
+		if svc.vpcID == id {
+			writeError(w, http.StatusConflict, "VPC.0120",
+				fmt.Sprintf("vpc %s has endpoint service %s, delete endpoint service firstly", id, svc.id))
+			return
+		}
+	}
+	delete(s.vpcs, id)
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) createSubnet(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req := &vpc_model.CreateSubnetRequestBody{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Subnet == nil {
+		writeError(w, http.StatusBadRequest, "VPC.0201", "invalid subnet option")
+		return
+	}
+	if _, ok := s.vpcs[req.Subnet.VpcId]; !ok {
+		writeError(w, http.StatusBadRequest, "VPC.0202",
+			fmt.Sprintf("the virSubnet is not in the vpc %s", req.Subnet.VpcId))
+		return
+	}
+	id := s.newID(KindSubnet)
+	subnet := &vpc_model.Subnet{
+		Id:           id,
+		Name:         req.Subnet.Name,
+		Description:  utils.Value(req.Subnet.Description),
+		Cidr:         req.Subnet.Cidr,
+		GatewayIp:    req.Subnet.GatewayIp,
+		DhcpEnable:   utils.Value(req.Subnet.DhcpEnable),
+		PrimaryDns:   utils.Value(req.Subnet.PrimaryDns),
+		SecondaryDns: utils.Value(req.Subnet.SecondaryDns),
+		VpcId:        req.Subnet.VpcId,
+		Status:       vpc_model.GetSubnetStatusEnum().ACTIVE,
+		TenantId:     s.ProjectID,
+	}
+	s.subnets[id] = &subnetRecord{subnet: subnet}
+	s.setTags(id, parseCreateTags(req.Subnet.Tags))
+	writeJSON(w, http.StatusOK, &vpc_model.CreateSubnetResponse{Subnet: subnet})
+}
+
+func (s *Server) showSubnet(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	subnet, ok := s.subnets[params["subnet_id"]]
+	if !ok {
+		notFound(w, "VPC.0202", "subnet", params["subnet_id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, &vpc_model.ShowSubnetResponse{Subnet: subnet.subnet})
+}
+
+func (s *Server) deleteSubnet(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	id := params["subnet_id"]
+	subnet, ok := s.subnets[id]
+	if !ok || subnet.subnet.VpcId != params["vpc_id"] {
+		notFound(w, "VPC.0202", "subnet", id)
+		return
+	}
+	for _, n := range s.natGateways {
+		if n.natGateway.InternalNetworkId == id {
+			writeError(w, http.StatusConflict, "VPC.0204",
+				fmt.Sprintf("subnet %s is used by NAT gateway %s", id, n.natGateway.Id))
+			return
+		}
+	}
+	for _, c := range s.clusters {
+		if c.cluster.Spec.HostNetwork.Subnet == id {
+			writeError(w, http.StatusConflict, "VPC.0204",
+				fmt.Sprintf("subnet %s is used by cluster %s", id, utils.Value(c.cluster.Metadata.Uid)))
+			return
+		}
+	}
+	delete(s.subnets, id)
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) createPublicip(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req := &eip_model.CreatePublicipRequestBody{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Publicip == nil || req.Bandwidth == nil {
+		writeError(w, http.StatusBadRequest, "EIP.0001", "invalid publicip option")
+		return
+	}
+	id := s.newID(KindEIP)
+	e := &eipRecord{
+		id:            id,
+		alias:         utils.Value(req.Publicip.Alias),
+		address:       fmt.Sprintf("100.85.%d.%d", s.sequence/250, s.sequence%250+1),
+		ipType:        req.Publicip.Type,
+		bandwidthSize: utils.Value(req.Bandwidth.Size),
+	}
+	s.eips[id] = e
+	writeJSON(w, http.StatusOK, &eip_model.CreatePublicipResponse{
+		Publicip: &eip_model.PublicipCreateResp{
+			BandwidthSize:   utils.Pointer(e.bandwidthSize),
+			Id:              utils.Pointer(e.id),
+			PublicIpAddress: utils.Pointer(e.address),
+			TenantId:        utils.Pointer(s.ProjectID),
+			Type:            utils.Pointer(e.ipType),
+			Alias:           utils.Pointer(e.alias),
+		},
+	})
+}
+
+func (s *Server) showPublicip(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	e, ok := s.eips[params["publicip_id"]]
+	if !ok {
+		notFound(w, "VPC.0504", "publicip", params["publicip_id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, &eip_model.ShowPublicipResponse{
+		Publicip: &eip_model.PublicipShowResp{
+			BandwidthSize:   utils.Pointer(e.bandwidthSize),
+			Id:              utils.Pointer(e.id),
+			PublicIpAddress: utils.Pointer(e.address),
+			TenantId:        utils.Pointer(s.ProjectID),
+			Type:            utils.Pointer(e.ipType),
+			Alias:           utils.Pointer(e.alias),
+		},
+	})
+}
+
+func (s *Server) deletePublicip(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	id := params["publicip_id"]
+	if _, ok := s.eips[id]; !ok {
+		notFound(w, "VPC.0504", "publicip", id)
+		return
+	}
+	for _, rule := range s.snatRules {
+		if rule.eipID == id {
+			writeError(w, http.StatusConflict, "VPC.0505",
+				fmt.Sprintf("publicip %s is used by SNAT rule %s", id, rule.id))
+			return
+		}
+	}
+	delete(s.eips, id)
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) createNatGateway(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req := &nat_model.CreateNatGatewayRequestBody{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.NatGateway == nil {
+		writeError(w, http.StatusBadRequest, "NAT.0001", "invalid NAT gateway option")
+		return
+	}
+	if _, ok := s.vpcs[req.NatGateway.RouterId]; !ok {
+		notFound(w, "NAT.0002", "vpc", req.NatGateway.RouterId)
+		return
+	}
+	if _, ok := s.subnets[req.NatGateway.InternalNetworkId]; !ok {
+		notFound(w, "NAT.0002", "subnet", req.NatGateway.InternalNetworkId)
+		return
+	}
+	id := s.newID(KindNatGateway)
+	n := &natGatewayRecord{
+		natGateway: &nat_model.NatGatewayResponseBody{
+			Id:                id,
+			TenantId:          s.ProjectID,
+			Name:              req.NatGateway.Name,
+			Description:       utils.Value(req.NatGateway.Description),
+			Spec:              nat_model.GetNatGatewayResponseBodySpecEnum().E_1,
+			Status:            nat_model.GetNatGatewayResponseBodyStatusEnum().PENDING_CREATE,
+			AdminStateUp:      true,
+			RouterId:          req.NatGateway.RouterId,
+			InternalNetworkId: req.NatGateway.InternalNetworkId,
+		},
+	}
+	n.op = s.newOperation(func() {
+		n.natGateway.Status = nat_model.GetNatGatewayResponseBodyStatusEnum().ACTIVE
+	})
+	s.natGateways[id] = n
+	writeJSON(w, http.StatusCreated, &nat_model.CreateNatGatewayResponse{NatGateway: n.natGateway})
+}
+
+func (s *Server) showNatGateway(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	n, ok := s.natGateways[params["nat_gateway_id"]]
+	if !ok {
+		notFound(w, "NAT.0201", "NAT gateway", params["nat_gateway_id"])
+		return
+	}
+	observe(&n.op)
+	writeJSON(w, http.StatusOK, &nat_model.ShowNatGatewayResponse{NatGateway: n.natGateway})
+}
+
+func (s *Server) deleteNatGateway(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	id := params["nat_gateway_id"]
+	if _, ok := s.natGateways[id]; !ok {
+		notFound(w, "NAT.0201", "NAT gateway", id)
+		return
+	}
+	for _, rule := range s.snatRules {
+		if rule.natGatewayID == id {
+			writeError(w, http.StatusConflict, "NAT.0207",
+				fmt.Sprintf("NAT gateway %s has SNAT rule %s, delete SNAT rules firstly", id, rule.id))
+			return
+		}
+	}
+	delete(s.natGateways, id)
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) createSnatRule(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req := &nat_model.CreateNatGatewaySnatRuleRequestOption{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.SnatRule == nil {
+		writeError(w, http.StatusBadRequest, "NAT.0001", "invalid SNAT rule option")
+		return
+	}
+	if _, ok := s.natGateways[req.SnatRule.NatGatewayId]; !ok {
+		notFound(w, "NAT.0201", "NAT gateway", req.SnatRule.NatGatewayId)
+		return
+	}
+	e, ok := s.eips[req.SnatRule.FloatingIpId]
+	if !ok {
+		notFound(w, "NAT.0301", "publicip", req.SnatRule.FloatingIpId)
+		return
+	}
+	id := s.newID(KindSNATRule)
+	rule := &snatRuleRecord{
+		id:           id,
+		natGatewayID: req.SnatRule.NatGatewayId,
+		networkID:    utils.Value(req.SnatRule.NetworkId),
+		eipID:        e.id,
+		sourceType:   utils.Value(req.SnatRule.SourceType),
+	}
+	s.snatRules[id] = rule
+	writeJSON(w, http.StatusCreated, &nat_model.CreateNatGatewaySnatRuleResponse{
+		SnatRule: &nat_model.CreateNatGatewaySnatRuleResponseBody{
+			Id:                rule.id,
+			TenantId:          s.ProjectID,
+			NatGatewayId:      rule.natGatewayID,
+			SourceType:        rule.sourceType,
+			FloatingIpId:      rule.eipID,
+			Status:            nat_model.GetCreateNatGatewaySnatRuleResponseBodyStatusEnum().ACTIVE,
+			NetworkId:         rule.networkID,
+			AdminStateUp:      true,
+			FloatingIpAddress: e.address,
+		},
+	})
+}
+
+func (s *Server) listSnatRules(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	natIDs := map[string]bool{}
+	for _, id := range r.URL.Query()["nat_gateway_id"] {
+		natIDs[id] = true
+	}
+	rules := []nat_model.NatGatewaySnatRuleResponseBody{}
+	for _, id := range sortedKeys(s.snatRules) {
+		rule := s.snatRules[id]
+		if len(natIDs) > 0 && !natIDs[rule.natGatewayID] {
+			continue
+		}
+		var address string
+		if e, ok := s.eips[rule.eipID]; ok {
+			address = e.address
+		}
+		rules = append(rules, nat_model.NatGatewaySnatRuleResponseBody{
+			Id:                rule.id,
+			TenantId:          s.ProjectID,
+			NatGatewayId:      rule.natGatewayID,
+			SourceType:        rule.sourceType,
+			FloatingIpId:      rule.eipID,
+			Status:            nat_model.GetNatGatewaySnatRuleResponseBodyStatusEnum().ACTIVE,
+			NetworkId:         rule.networkID,
+			AdminStateUp:      true,
+			FloatingIpAddress: address,
+		})
+	}
+	writeJSON(w, http.StatusOK, &nat_model.ListNatGatewaySnatRulesResponse{SnatRules: &rules})
+}
+
+func (s *Server) deleteSnatRule(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	rule, ok := s.snatRules[params["snat_rule_id"]]
+	if !ok || rule.natGatewayID != params["nat_gateway_id"] {
+		notFound(w, "NAT.0302", "SNAT rule", params["snat_rule_id"])
+		return
+	}
+	delete(s.snatRules, rule.id)
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) listEndpointServices(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	filterID := r.URL.Query().Get("id")
+	services := []vpcep_model.ServiceList{}
+	for _, id := range sortedKeys(s.vpcepServices) {
+		svc := s.vpcepServices[id]
+		if filterID != "" && svc.id != filterID {
+			continue
+		}
+		services = append(services, vpcep_model.ServiceList{
+			Id:        utils.Pointer(svc.id),
+			VpcId:     utils.Pointer(svc.vpcID),
+			ProjectId: utils.Pointer(s.ProjectID),
+		})
+	}
+	writeJSON(w, http.StatusOK, &vpcep_model.ListEndpointServiceResponse{
+		EndpointServices: &services,
+		TotalCount:       utils.Pointer(int32(len(services))),
+	})
+}
+
+func (s *Server) deleteEndpointService(w http.ResponseWriter, _ *http.Request, params map[string]string) {
+	id := params["vpc_endpoint_service_id"]
+	if _, ok := s.vpcepServices[id]; !ok {
+		notFound(w, "EndPoint.0005", "endpoint service", id)
+		return
+	}
+	delete(s.vpcepServices, id)
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) listNameServers(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	region := r.URL.Query().Get("region")
+	if region == "" {
+		region = s.Region
+	}
+	writeJSON(w, http.StatusOK, &dns_model.ListNameServersResponse{
+		Nameservers: &[]dns_model.NameServersResp{
+			{
+				Type:   utils.Pointer("private"),
+				Region: utils.Pointer(region),
+				NsRecords: &[]dns_model.NsRecords{
+					{Address: utils.Pointer("100.125.1.250"), Priority: utils.Pointer(int32(1))},
+					{Address: utils.Pointer("100.125.129.250"), Priority: utils.Pointer(int32(2))},
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,290 @@
+// Package fake provides an in-process fake Huawei Cloud API server, which
+// implements the CCE, VPC, EIP, NAT, VPCEP, DNS, ECS and IAM endpoints used by
+// the cce-operator. It is used to run the controller tests without network.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/common"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/cce/v3/model"
+)
+
+const (
+	DefaultRegion    = "cn-north-4"
+	DefaultProjectID = "fake-project-id"
+
+	KindCluster      = "cluster"
+	KindNodePool     = "nodepool"
+	KindNode         = "node"
+	KindAddon        = "addon"
+	KindVPC          = "vpc"
+	KindSubnet       = "subnet"
+	KindEIP          = "eip"
+	KindNatGateway   = "natgateway"
+	KindSNATRule     = "snatrule"
+	KindVpcepService = "vpcepservice"
+)
+
+// Server is a stateful fake Huawei Cloud API server.
+//
+// Asynchronous operations (cluster creation, upgrade, deletion, node pool
+// scaling, etc.) are completed after the resource was observed by Delay
+// read requests, so the transient phases can be tested deterministically.
+type Server struct {
+	*httptest.Server
+
+	Region    string
+	ProjectID string
+	// Delay is the number of read requests before an async operation completes.
+	Delay int
+
+	mu       sync.Mutex
+	routes   []route
+	sequence int
+
+	clusters      map[string]*clusterRecord
+	nodePools     map[string]*nodePoolRecord
+	nodes         map[string]*nodeRecord
+	upgradeTasks  map[string]*upgradeTaskRecord
+	preChecks     map[string]*preCheckRecord
+	addons        map[string]*addonRecord
+	vpcs          map[string]*vpcRecord
+	subnets       map[string]*subnetRecord
+	eips          map[string]*eipRecord
+	natGateways   map[string]*natGatewayRecord
+	snatRules     map[string]*snatRuleRecord
+	vpcepServices map[string]*vpcepServiceRecord
+	jobs          map[string]*jobRecord
+	reboots       []string
+	// tags are the tags of the VPCs, subnets, EIPs and NAT Gateways by ID.
+	tags map[string]map[string]string
+
+	// preCheckFailures are the failed items of the new pre-upgrade checks.
+	preCheckFailures []model.PreCheckItemStatus
+	// upgradePaths are the versions the clusters can be upgraded to by the
+	// cluster version.
+	upgradePaths map[string][]string
+	// assumedAgencies are the agencies assumed as domain/agency.
+	assumedAgencies []string
+	// securityToken is the security token of the last request.
+	securityToken string
+	// throttled is the number of the next requests rejected by the rate limit.
+	throttled int
+	// tagFailures is the number of the next tagging requests failed.
+	tagFailures int
+}
+
+// operation is an async operation of a fake resource.
+type operation struct {
+	remaining int
+	complete  func()
+}
+
+type routeHandler func(w http.ResponseWriter, r *http.Request, params map[string]string)
+
+type route struct {
+	method   string
+	segments []string
+	handler  routeHandler
+}
+
+// NewServer starts a new fake Huawei Cloud API server, the caller should
+// call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		Region:        DefaultRegion,
+		ProjectID:     DefaultProjectID,
+		Delay:         1,
+		clusters:      map[string]*clusterRecord{},
+		nodePools:     map[string]*nodePoolRecord{},
+		nodes:         map[string]*nodeRecord{},
+		upgradeTasks:  map[string]*upgradeTaskRecord{},
+		preChecks:     map[string]*preCheckRecord{},
+		addons:        map[string]*addonRecord{},
+		vpcs:          map[string]*vpcRecord{},
+		subnets:       map[string]*subnetRecord{},
+		eips:          map[string]*eipRecord{},
+		natGateways:   map[string]*natGatewayRecord{},
+		snatRules:     map[string]*snatRuleRecord{},
+		vpcepServices: map[string]*vpcepServiceRecord{},
+		jobs:          map[string]*jobRecord{},
+		tags:          map[string]map[string]string{},
+		upgradePaths:  defaultUpgradePaths(),
+	}
+	s.registerCCERoutes()
+	s.registerNetworkRoutes()
+	s.registerECSRoutes()
+	s.registerIAMRoutes()
+	s.registerTagRoutes()
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// ClientAuth returns the ClientAuth pointed at the fake server.
+func (s *Server) ClientAuth() *common.ClientAuth {
+	auth := common.NewClientAuth("fake-ak", "fake-sk", s.Region, s.ProjectID)
+	auth.Endpoint = s.URL
+	return auth
+}
+
+// Throttle rejects the next n requests by the rate limit of the API gateway.
+func (s *Server) Throttle(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.throttled = n
+}
+
+// FailTagging fails the next n requests tagging the resources.
+func (s *Server) FailTagging(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tagFailures = n
+}
+
+// Resources returns the number of existing resources by kind.
+func (s *Server) Resources() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return map[string]int{
+		KindCluster:      len(s.clusters),
+		KindNodePool:     len(s.nodePools),
+		KindNode:         len(s.nodes),
+		KindAddon:        len(s.addons),
+		KindVPC:          len(s.vpcs),
+		KindSubnet:       len(s.subnets),
+		KindEIP:          len(s.eips),
+		KindNatGateway:   len(s.natGateways),
+		KindSNATRule:     len(s.snatRules),
+		KindVpcepService: len(s.vpcepServices),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.securityToken = r.Header.Get("X-Security-Token")
+	if s.throttled > 0 {
+		s.throttled--
+		writeError(w, http.StatusTooManyRequests, "APIGW.0308", "the throttling threshold has been reached")
+		return
+	}
+	segments := splitPath(r.URL.Path)
+	for _, rt := range s.routes {
+		if rt.method != r.Method {
+			continue
+		}
+		params, ok := matchSegments(rt.segments, segments)
+		if !ok {
+			continue
+		}
+		if projectID, ok := params["project_id"]; ok && projectID != s.ProjectID {
+			writeError(w, http.StatusUnauthorized, "APIGW.0301", "incorrect project ID")
+			return
+		}
+		rt.handler(w, r, params)
+		return
+	}
+	writeError(w, http.StatusNotFound, "APIGW.0101",
+		fmt.Sprintf("the API does not exist: %s %s", r.Method, r.URL.Path))
+}
+
+func (s *Server) handle(method, pattern string, handler routeHandler) {
+	s.routes = append(s.routes, route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+func (s *Server) newID(kind string) string {
+	s.sequence++
+	return fmt.Sprintf("%s-%08d-0000-0000-%012d", kind, s.sequence, s.sequence)
+}
+
+func (s *Server) newOperation(complete func()) *operation {
+	return &operation{
+		remaining: s.Delay,
+		complete:  complete,
+	}
+}
+
+// observe advances the async operation, the operation is completed and
+// removed if it was observed by enough read requests.
+func observe(op **operation) {
+	if *op == nil {
+		return
+	}
+	o := *op
+	o.remaining--
+	if o.remaining > 0 {
+		return
+	}
+	*op = nil
+	o.complete()
+}
+
+func splitPath(p string) []string {
+	return strings.Split(strings.Trim(p, "/"), "/")
+}
+
+func matchSegments(pattern, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			params[strings.Trim(p, "{}")] = segments[i]
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "APIGW.0201",
+			fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", "fake-request-id")
+	w.WriteHeader(status)
+	if v == nil {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]string{
+		"error_code": code,
+		"error_msg":  message,
+	})
+}
+
+func notFound(w http.ResponseWriter, code, kind, id string) {
+	writeError(w, http.StatusNotFound, code,
+		fmt.Sprintf("%s %s not found", kind, id))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,129 @@
+package fake
+
+import (
+	"testing"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/huawei"
+	"github.com/cnrancher/cce-operator/pkg/huawei/common"
+	"github.com/cnrancher/cce-operator/pkg/huawei/eip"
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/huawei/vpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Server_VPC(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	client := vpc.NewVpcClient(s.ClientAuth())
+	res, err := vpc.CreateVPC(client, "vpc-test", vpc.DefaultVpcCIDR, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(1, s.Resources()[KindVPC])
+	show, err := vpc.ShowVPC(client, res.Vpc.Id)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal("vpc-test", show.Vpc.Name)
+
+	_, err = vpc.DeleteVPC(client, res.Vpc.Id)
+	assert.Nil(err)
+	_, err = vpc.ShowVPC(client, res.Vpc.Id)
+	hwerr, _ := huawei.NewHuaweiError(err)
+	assert.Equal(int32(404), hwerr.StatusCode)
+	assert.Equal("VPC.0012", hwerr.ErrorCode)
+}
+
+func Test_Server_Tags(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	owner := &common.Owner{Instance: "instance-1", Namespace: "cattle-global-data", Name: "c-abcde", UID: "uid-1"}
+	vpcClient := vpc.NewVpcClient(s.ClientAuth())
+	vpcRes, err := vpc.CreateVPC(vpcClient, "vpc-owned", vpc.DefaultVpcCIDR, owner)
+	if !assert.Nil(err) {
+		return
+	}
+	_, err = vpc.CreateVPC(vpcClient, "vpc-untagged", vpc.DefaultVpcCIDR, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal("uid-1", s.Tags(vpcRes.Vpc.Id)[common.TagOwnerUID])
+	vpcs, err := vpc.ListVPCsByTag(vpcClient, common.TagInstance, "instance-2")
+	assert.Nil(err)
+	assert.Len(vpcs, 0)
+	vpcs, err = vpc.ListVPCsByTag(vpcClient, common.TagInstance, "instance-1")
+	if !assert.Nil(err) {
+		return
+	}
+	if assert.Len(vpcs, 1) {
+		assert.Equal(vpcRes.Vpc.Id, vpcs[0].ResourceId)
+		assert.Equal("vpc-owned", vpcs[0].ResourceName)
+	}
+
+	eipClient := eip.NewEipClient(s.ClientAuth())
+	eipRes, err := eip.CreatePublicIP(eipClient, &ccev1.CCEEip{Iptype: "5_bgp"})
+	if !assert.Nil(err) {
+		return
+	}
+	eips, err := eip.ListPublicIPsByTag(eipClient, common.TagInstance, "instance-1")
+	assert.Nil(err)
+	assert.Len(eips, 0)
+	assert.Nil(eip.TagPublicIP(eipClient, *eipRes.Publicip.Id, owner.Tags()))
+	eips, err = eip.ListPublicIPsByTag(eipClient, common.TagInstance, "instance-1")
+	if !assert.Nil(err) || !assert.Len(eips, 1) {
+		return
+	}
+	assert.Equal(*eipRes.Publicip.Id, *eips[0].ResourceId)
+	tags := map[string]string{}
+	for _, tag := range *eips[0].Tags {
+		tags[*tag.Key] = *tag.Value
+	}
+	assert.Equal(map[string]string{
+		common.TagInstance:       "instance-1",
+		common.TagOwnerNamespace: "cattle-global-data",
+		common.TagOwnerName:      "c-abcde",
+		common.TagOwnerUID:       "uid-1",
+	}, tags)
+}
+
+func Test_Server_IncorrectProjectID(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	auth := s.ClientAuth()
+	auth.Credential.ProjectId = "incorrect-project-id"
+	_, err := vpc.ShowVPC(vpc.NewVpcClient(auth), "vpc-id")
+	hwerr, _ := huawei.NewHuaweiError(err)
+	assert.Equal(t, int32(401), hwerr.StatusCode)
+}
+
+func Test_Server_Throttle(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	ratelimit.BaseDelay = time.Millisecond
+	client := vpc.WithMetrics(vpc.NewVpcClient(s.ClientAuth()), ratelimit.NewPolicy(100, 10))
+	res, err := vpc.CreateVPC(client, "vpc-test", vpc.DefaultVpcCIDR, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	// The throttled requests are retried.
+	s.Throttle(2)
+	_, err = vpc.ShowVPC(client, res.Vpc.Id)
+	assert.Nil(err)
+
+	// The request fails if throttled after the max retries.
+	s.Throttle(ratelimit.MaxRetries + 1)
+	_, err = vpc.ShowVPC(client, res.Vpc.Id)
+	assert.True(ratelimit.Throttled(err))
+	_, err = vpc.ShowVPC(client, res.Vpc.Id)
+	assert.Nil(err)
+}
@@ -0,0 +1,221 @@
+package fake
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// tagResource is a resource in the response of listing resources by tags,
+// which is the same for the VPC, subnet, EIP and NAT Gateway.
+type tagResource struct {
+	ResourceID   string        `json:"resource_id"`
+	ResourceName string        `json:"resource_name"`
+	Tags         []resourceTag `json:"tags"`
+}
+
+type resourceTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// tagsRequest is the request body of tagging a resource and listing the
+// resources by tags.
+type tagsRequest struct {
+	Action string `json:"action"`
+	Tags   []struct {
+		Key    string   `json:"key"`
+		Value  string   `json:"value"`
+		Values []string `json:"values"`
+	} `json:"tags"`
+}
+
+func (s *Server) registerTagRoutes() {
+	s.handle(http.MethodPost, "/v2.0/{project_id}/vpcs/resource_instances/action", s.listResourcesByTags(KindVPC))
+	s.handle(http.MethodPost, "/v2.0/{project_id}/subnets/resource_instances/action",
+		s.listResourcesByTags(KindSubnet))
+	s.handle(http.MethodPost, "/v2.0/{project_id}/publicips/resource_instances/action",
+		s.listResourcesByTags(KindEIP))
+	s.handle(http.MethodPost, "/v3/{project_id}/nat_gateways/resource_instances/action",
+		s.listResourcesByTags(KindNatGateway))
+	s.handle(http.MethodPost, "/v2.0/{project_id}/vpcs/{vpc_id}/tags/action", s.tagVpc)
+	s.handle(http.MethodPost, "/v2.0/{project_id}/subnets/{subnet_id}/tags/action", s.tagSubnet)
+	s.handle(http.MethodPost, "/v2.0/{project_id}/publicips/{publicip_id}/tags/action", s.tagPublicip)
+	s.handle(http.MethodPost, "/v3/{project_id}/nat_gateways/{nat_gateway_id}/tags/action", s.tagNatGateway)
+}
+
+// Tags returns the tags of the resource.
+func (s *Server) Tags(id string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tags := map[string]string{}
+	for k, v := range s.tags[id] {
+		tags[k] = v
+	}
+	return tags
+}
+
+// Tag adds the tags to the resource.
+func (s *Server) Tag(id string, tags map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setTags(id, tags)
+}
+
+func (s *Server) setTags(id string, tags map[string]string) {
+	if len(tags) == 0 {
+		return
+	}
+	if s.tags[id] == nil {
+		s.tags[id] = map[string]string{}
+	}
+	for k, v := range tags {
+		s.tags[id][k] = v
+	}
+}
+
+// parseCreateTags parses the tags of the VPC and subnet create options in the
+// key*value format.
+func parseCreateTags(tags *[]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	m := map[string]string{}
+	for _, tag := range *tags {
+		k, v, _ := strings.Cut(tag, "*")
+		m[k] = v
+	}
+	return m
+}
+
+// resourceNames returns the names of the resources of the kind by ID.
+func (s *Server) resourceNames(kind string) map[string]string {
+	names := map[string]string{}
+	switch kind {
+	case KindVPC:
+		for id, v := range s.vpcs {
+			names[id] = v.vpc.Name
+		}
+	case KindSubnet:
+		for id, v := range s.subnets {
+			names[id] = v.subnet.Name
+		}
+	case KindEIP:
+		for id, v := range s.eips {
+			names[id] = v.alias
+		}
+	case KindNatGateway:
+		for id, v := range s.natGateways {
+			names[id] = v.natGateway.Name
+		}
+	}
+	return names
+}
+
+func (s *Server) listResourcesByTags(kind string) routeHandler {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		req := &tagsRequest{}
+		if !decodeBody(w, r, req) {
+			return
+		}
+		resources := []tagResource{}
+		for id, name := range s.resourceNames(kind) {
+			tags := s.tags[id]
+			matched := true
+			for _, filter := range req.Tags {
+				v, ok := tags[filter.Key]
+				if !ok {
+					matched = false
+					break
+				}
+				if len(filter.Values) > 0 && !contains(filter.Values, v) {
+					matched = false
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+			resource := tagResource{ResourceID: id, ResourceName: name, Tags: []resourceTag{}}
+			for k, v := range tags {
+				resource.Tags = append(resource.Tags, resourceTag{Key: k, Value: v})
+			}
+			sort.Slice(resource.Tags, func(i, j int) bool { return resource.Tags[i].Key < resource.Tags[j].Key })
+			resources = append(resources, resource)
+		}
+		sort.Slice(resources, func(i, j int) bool { return resources[i].ResourceID < resources[j].ResourceID })
+		writeJSON(w, http.StatusOK, map[string]any{
+			"resources":   resources,
+			"total_count": len(resources),
+		})
+	}
+}
+
+func (s *Server) tagVpc(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	id := params["vpc_id"]
+	if _, ok := s.vpcs[id]; !ok {
+		notFound(w, "VPC.0012", "vpc", id)
+		return
+	}
+	s.tagResource(w, r, id)
+}
+
+func (s *Server) tagSubnet(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	id := params["subnet_id"]
+	if _, ok := s.subnets[id]; !ok {
+		notFound(w, "VPC.0202", "subnet", id)
+		return
+	}
+	s.tagResource(w, r, id)
+}
+
+func (s *Server) tagPublicip(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	id := params["publicip_id"]
+	if _, ok := s.eips[id]; !ok {
+		notFound(w, "VPC.0504", "publicip", id)
+		return
+	}
+	s.tagResource(w, r, id)
+}
+
+func (s *Server) tagNatGateway(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	id := params["nat_gateway_id"]
+	if _, ok := s.natGateways[id]; !ok {
+		notFound(w, "NAT.0201", "NAT gateway", id)
+		return
+	}
+	s.tagResource(w, r, id)
+}
+
+func (s *Server) tagResource(w http.ResponseWriter, r *http.Request, id string) {
+	if s.tagFailures > 0 {
+		s.tagFailures--
+		writeError(w, http.StatusBadRequest, "TMS.0001", "failed to tag resource "+id)
+		return
+	}
+	req := &tagsRequest{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	if req.Action != "create" {
+		writeError(w, http.StatusBadRequest, "APIGW.0201", "unsupported action "+req.Action)
+		return
+	}
+	tags := map[string]string{}
+	for _, tag := range req.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	s.setTags(id, tags)
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
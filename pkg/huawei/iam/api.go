@@ -0,0 +1,14 @@
+package iam
+
+import (
+	iam "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3/model"
+)
+
+// IamAPI is the Identity and Access Management API used by the operator,
+// the *iam.IamClient of the Huawei Cloud SDK is the default implementation.
+type IamAPI interface {
+	CreateTemporaryAccessKeyByAgency(request *model.CreateTemporaryAccessKeyByAgencyRequest) (*model.CreateTemporaryAccessKeyByAgencyResponse, error)
+}
+
+var _ IamAPI = (*iam.IamClient)(nil)
@@ -0,0 +1,62 @@
+package iam
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/common"
+	"github.com/cnrancher/cce-operator/pkg/utils"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/auth/global"
+	iam "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3/model"
+	region "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3/region"
+	"github.com/sirupsen/logrus"
+)
+
+// NewIamClient creates the IAM client of the access key of auth, IAM is a
+// global service so the domain ID of the account is used instead of the
+// project ID.
+func NewIamClient(auth *common.ClientAuth, domainID string) *iam.IamClient {
+	return iam.NewIamClient(
+		iam.IamClientBuilder().
+			WithRegion(auth.ServiceRegion(region.ValueOf)).
+			WithCredential(global.NewCredentialsBuilder().
+				WithAk(auth.Credential.AK).
+				WithSk(auth.Credential.SK).
+				WithSecurityToken(auth.Credential.SecurityToken).
+				WithDomainId(domainID).
+				Build()).
+			Build())
+}
+
+// AssumeAgency gets the temporary access key and security token of the agency
+// created by the delegating account domainName, valid for duration.
+func AssumeAgency(
+	client IamAPI, domainName, agencyName string, duration time.Duration,
+) (*model.Credential, error) {
+	req := &model.CreateTemporaryAccessKeyByAgencyRequest{
+		Body: &model.CreateTemporaryAccessKeyByAgencyRequestBody{
+			Auth: &model.AgencyAuth{
+				Identity: &model.AgencyAuthIdentity{
+					Methods: []model.AgencyAuthIdentityMethods{
+						model.GetAgencyAuthIdentityMethodsEnum().ASSUME_ROLE,
+					},
+					AssumeRole: &model.IdentityAssumerole{
+						AgencyName:      agencyName,
+						DomainName:      utils.Pointer(domainName),
+						DurationSeconds: utils.Pointer(int32(duration.Seconds())),
+					},
+				},
+			},
+		},
+	}
+	res, err := client.CreateTemporaryAccessKeyByAgency(req)
+	if err != nil {
+		logrus.Debugf("CreateTemporaryAccessKeyByAgency failed: %v", utils.PrintObject(req))
+		return nil, err
+	}
+	if res.Credential == nil {
+		return nil, fmt.Errorf("no credential returned by assuming agency %s/%s", domainName, agencyName)
+	}
+	return res.Credential, nil
+}
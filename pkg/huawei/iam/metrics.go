@@ -0,0 +1,28 @@
+package iam
+
+import (
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3/model"
+)
+
+// metricsIamAPI records the request metrics of the IamAPI.
+type metricsIamAPI struct {
+	api    IamAPI
+	policy *ratelimit.Policy
+}
+
+// WithMetrics returns the IamAPI recording the request metrics of api, the
+// requests are rate limited and retried by policy if not nil.
+func WithMetrics(api IamAPI, policy *ratelimit.Policy) IamAPI {
+	return &metricsIamAPI{api: api, policy: policy}
+}
+
+func (m *metricsIamAPI) CreateTemporaryAccessKeyByAgency(request *model.CreateTemporaryAccessKeyByAgencyRequest) (*model.CreateTemporaryAccessKeyByAgencyResponse, error) {
+	return ratelimit.Call(m.policy, "iam", "CreateTemporaryAccessKeyByAgency", func() (_ *model.CreateTemporaryAccessKeyByAgencyResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("iam", "CreateTemporaryAccessKeyByAgency", time.Now(), &err)
+		return m.api.CreateTemporaryAccessKeyByAgency(request)
+	})
+}
@@ -0,0 +1,21 @@
+package nat
+
+import (
+	nat "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/nat/v2"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/nat/v2/model"
+)
+
+// NatAPI is the NAT Gateway API used by the operator,
+// the *nat.NatClient of the Huawei Cloud SDK is the default implementation.
+type NatAPI interface {
+	CreateNatGateway(request *model.CreateNatGatewayRequest) (*model.CreateNatGatewayResponse, error)
+	ShowNatGateway(request *model.ShowNatGatewayRequest) (*model.ShowNatGatewayResponse, error)
+	DeleteNatGateway(request *model.DeleteNatGatewayRequest) (*model.DeleteNatGatewayResponse, error)
+	CreateNatGatewaySnatRule(request *model.CreateNatGatewaySnatRuleRequest) (*model.CreateNatGatewaySnatRuleResponse, error)
+	ListNatGatewaySnatRules(request *model.ListNatGatewaySnatRulesRequest) (*model.ListNatGatewaySnatRulesResponse, error)
+	DeleteNatGatewaySnatRule(request *model.DeleteNatGatewaySnatRuleRequest) (*model.DeleteNatGatewaySnatRuleResponse, error)
+	BatchCreateDeleteNatGatewayTag(request *model.BatchCreateDeleteNatGatewayTagRequest) (*model.BatchCreateDeleteNatGatewayTagResponse, error)
+	ListNatGatewayByTag(request *model.ListNatGatewayByTagRequest) (*model.ListNatGatewayByTagResponse, error)
+}
+
+var _ NatAPI = (*nat.NatClient)(nil)
@@ -0,0 +1,77 @@
+package nat
+
+import (
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/nat/v2/model"
+)
+
+// metricsNatAPI records the request metrics of the NatAPI.
+type metricsNatAPI struct {
+	api    NatAPI
+	policy *ratelimit.Policy
+}
+
+// WithMetrics returns the NatAPI recording the request metrics of api, the
+// requests are rate limited and retried by policy if not nil.
+func WithMetrics(api NatAPI, policy *ratelimit.Policy) NatAPI {
+	return &metricsNatAPI{api: api, policy: policy}
+}
+
+func (m *metricsNatAPI) CreateNatGateway(request *model.CreateNatGatewayRequest) (*model.CreateNatGatewayResponse, error) {
+	return ratelimit.Call(m.policy, "nat", "CreateNatGateway", func() (_ *model.CreateNatGatewayResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("nat", "CreateNatGateway", time.Now(), &err)
+		return m.api.CreateNatGateway(request)
+	})
+}
+
+func (m *metricsNatAPI) ShowNatGateway(request *model.ShowNatGatewayRequest) (*model.ShowNatGatewayResponse, error) {
+	return ratelimit.Call(m.policy, "nat", "ShowNatGateway", func() (_ *model.ShowNatGatewayResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("nat", "ShowNatGateway", time.Now(), &err)
+		return m.api.ShowNatGateway(request)
+	})
+}
+
+func (m *metricsNatAPI) DeleteNatGateway(request *model.DeleteNatGatewayRequest) (*model.DeleteNatGatewayResponse, error) {
+	return ratelimit.Call(m.policy, "nat", "DeleteNatGateway", func() (_ *model.DeleteNatGatewayResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("nat", "DeleteNatGateway", time.Now(), &err)
+		return m.api.DeleteNatGateway(request)
+	})
+}
+
+func (m *metricsNatAPI) CreateNatGatewaySnatRule(request *model.CreateNatGatewaySnatRuleRequest) (*model.CreateNatGatewaySnatRuleResponse, error) {
+	return ratelimit.Call(m.policy, "nat", "CreateNatGatewaySnatRule", func() (_ *model.CreateNatGatewaySnatRuleResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("nat", "CreateNatGatewaySnatRule", time.Now(), &err)
+		return m.api.CreateNatGatewaySnatRule(request)
+	})
+}
+
+func (m *metricsNatAPI) ListNatGatewaySnatRules(request *model.ListNatGatewaySnatRulesRequest) (*model.ListNatGatewaySnatRulesResponse, error) {
+	return ratelimit.Call(m.policy, "nat", "ListNatGatewaySnatRules", func() (_ *model.ListNatGatewaySnatRulesResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("nat", "ListNatGatewaySnatRules", time.Now(), &err)
+		return m.api.ListNatGatewaySnatRules(request)
+	})
+}
+
+func (m *metricsNatAPI) DeleteNatGatewaySnatRule(request *model.DeleteNatGatewaySnatRuleRequest) (*model.DeleteNatGatewaySnatRuleResponse, error) {
+	return ratelimit.Call(m.policy, "nat", "DeleteNatGatewaySnatRule", func() (_ *model.DeleteNatGatewaySnatRuleResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("nat", "DeleteNatGatewaySnatRule", time.Now(), &err)
+		return m.api.DeleteNatGatewaySnatRule(request)
+	})
+}
+
+func (m *metricsNatAPI) BatchCreateDeleteNatGatewayTag(request *model.BatchCreateDeleteNatGatewayTagRequest) (*model.BatchCreateDeleteNatGatewayTagResponse, error) {
+	return ratelimit.Call(m.policy, "nat", "BatchCreateDeleteNatGatewayTag", func() (_ *model.BatchCreateDeleteNatGatewayTagResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("nat", "BatchCreateDeleteNatGatewayTag", time.Now(), &err)
+		return m.api.BatchCreateDeleteNatGatewayTag(request)
+	})
+}
+
+func (m *metricsNatAPI) ListNatGatewayByTag(request *model.ListNatGatewayByTagRequest) (*model.ListNatGatewayByTagResponse, error) {
+	return ratelimit.Call(m.policy, "nat", "ListNatGatewayByTag", func() (_ *model.ListNatGatewayByTagResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("nat", "ListNatGatewayByTag", time.Now(), &err)
+		return m.api.ListNatGatewayByTag(request)
+	})
+}
@@ -1,6 +1,8 @@
 package nat
 
 import (
+	"strconv"
+
 	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
 	"github.com/cnrancher/cce-operator/pkg/huawei/common"
 	"github.com/cnrancher/cce-operator/pkg/utils"
@@ -13,13 +15,13 @@ import (
 func NewNatClient(auth *common.ClientAuth) *nat.NatClient {
 	return nat.NewNatClient(
 		nat.NatClientBuilder().
-			WithRegion(region.ValueOf(auth.Region)).
+			WithRegion(auth.ServiceRegion(region.ValueOf)).
 			WithCredential(auth.Credential).
 			Build())
 }
 
 func CreateNatGateway(
-	client *nat.NatClient, name string, spec *ccev1.CCEClusterConfigSpec,
+	client NatAPI, name string, spec *ccev1.CCEClusterConfigSpec,
 ) (*model.CreateNatGatewayResponse, error) {
 	req := &model.CreateNatGatewayRequest{
 		Body: &model.CreateNatGatewayRequestBody{
@@ -41,7 +43,7 @@ func CreateNatGateway(
 }
 
 func ShowNatGateway(
-	client *nat.NatClient, id string,
+	client NatAPI, id string,
 ) (*model.ShowNatGatewayResponse, error) {
 	req := &model.ShowNatGatewayRequest{
 		NatGatewayId: id,
@@ -54,7 +56,7 @@ func ShowNatGateway(
 }
 
 func DeleteNatGateway(
-	client *nat.NatClient, id string,
+	client NatAPI, id string,
 ) (*model.DeleteNatGatewayResponse, error) {
 	req := &model.DeleteNatGatewayRequest{
 		NatGatewayId: id,
@@ -67,7 +69,7 @@ func DeleteNatGateway(
 }
 
 func CreateNatGatewaySnatRule(
-	client *nat.NatClient, natID, networkID, eipID string, sourceType int32,
+	client NatAPI, natID, networkID, eipID string, sourceType int32,
 ) (*model.CreateNatGatewaySnatRuleResponse, error) {
 	req := &model.CreateNatGatewaySnatRuleRequest{
 		Body: &model.CreateNatGatewaySnatRuleRequestOption{
@@ -88,7 +90,7 @@ func CreateNatGatewaySnatRule(
 }
 
 func ListNatGatewaySnatRules(
-	client *nat.NatClient, natIDs []string,
+	client NatAPI, natIDs []string,
 ) (*model.ListNatGatewaySnatRulesResponse, error) {
 	req := &model.ListNatGatewaySnatRulesRequest{
 		NatGatewayId: &natIDs,
@@ -101,7 +103,7 @@ func ListNatGatewaySnatRules(
 }
 
 func DeleteNatGatewaySnatRule(
-	client *nat.NatClient, snatRuleID, natGatewayID string,
+	client NatAPI, snatRuleID, natGatewayID string,
 ) (*model.DeleteNatGatewaySnatRuleResponse, error) {
 	req := &model.DeleteNatGatewaySnatRuleRequest{
 		NatGatewayId: natGatewayID,
@@ -113,3 +115,52 @@ func DeleteNatGatewaySnatRule(
 	}
 	return res, err
 }
+
+// listPageSize is the number of the resources per page listed by tags.
+const listPageSize = 1000
+
+// TagNatGateway adds the tags to the NAT Gateway.
+func TagNatGateway(client NatAPI, id string, tags []common.Tag) error {
+	req := &model.BatchCreateDeleteNatGatewayTagRequest{
+		NatGatewayId: id,
+		Body: &model.BatchCreateDeleteNatTagsRequestBody{
+			Action: "create",
+		},
+	}
+	for _, tag := range tags {
+		req.Body.Tags = append(req.Body.Tags, model.PublicTags{Key: tag.Key, Value: tag.Value})
+	}
+	_, err := client.BatchCreateDeleteNatGatewayTag(req)
+	if err != nil {
+		logrus.Debugf("BatchCreateDeleteNatGatewayTag failed: %v", utils.PrintObject(req))
+	}
+	return err
+}
+
+// ListNatGatewaysByTag returns the NAT Gateways having the tag of the value.
+func ListNatGatewaysByTag(client NatAPI, key, value string) ([]model.PublicResource, error) {
+	var resources []model.PublicResource
+	for offset := 0; ; {
+		req := &model.ListNatGatewayByTagRequest{
+			Body: &model.ListNatsByTagsRequestBody{
+				Action: "filter",
+				Limit:  utils.Pointer(strconv.Itoa(listPageSize)),
+				Offset: utils.Pointer(strconv.Itoa(offset)),
+				Tags:   &[]model.PublicTag{{Key: key, Values: []string{value}}},
+			},
+		}
+		res, err := client.ListNatGatewayByTag(req)
+		if err != nil {
+			logrus.Debugf("ListNatGatewayByTag failed: %v", utils.PrintObject(req))
+			return nil, err
+		}
+		if res.Resources == nil {
+			return resources, nil
+		}
+		resources = append(resources, *res.Resources...)
+		if len(*res.Resources) < listPageSize {
+			return resources, nil
+		}
+		offset += len(*res.Resources)
+	}
+}
@@ -0,0 +1,170 @@
+// Package ratelimit limits and retries the Huawei Cloud API requests on the
+// client side. The requests of the same account in the same region share a
+// token bucket, and the requests failed with the retryable errors are retried
+// with exponential backoff and jitter.
+package ratelimit
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+var (
+	// QPS and Burst are the token bucket options of the requests of an
+	// account in a region, they should be set before any request is sent.
+	QPS   float32 = 10
+	Burst         = 20
+
+	// MaxRetries is the max number of the retries of a failed request.
+	MaxRetries = 3
+	// BaseDelay is the backoff of the first retry, doubled for each retry
+	// until MaxDelay.
+	BaseDelay = 500 * time.Millisecond
+	MaxDelay  = 5 * time.Second
+)
+
+// throttlingCodes are the error codes returned when the requests are
+// throttled by the API gateway.
+var throttlingCodes = map[string]bool{
+	"APIGW.0308": true,
+}
+
+// readOperationPrefixes are the prefixes of the operations without side
+// effects, which are safe to retry after the server errors.
+var readOperationPrefixes = []string{"List", "Show", "Get"}
+
+// Policy rate limits the requests of an account in a region, and retries the
+// requests failed with the retryable errors.
+type Policy struct {
+	limiter    flowcontrol.RateLimiter
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	sleep      func(time.Duration)
+}
+
+var (
+	mu       sync.Mutex
+	policies = map[string]*Policy{}
+)
+
+// NewPolicy returns the policy of its own token bucket.
+func NewPolicy(qps float32, burst int) *Policy {
+	return &Policy{
+		limiter:    flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+		maxRetries: MaxRetries,
+		baseDelay:  BaseDelay,
+		maxDelay:   MaxDelay,
+		sleep:      time.Sleep,
+	}
+}
+
+// ForAccount returns the policy shared by the clients of the project in the
+// region, the project ID identifies the account in the region.
+func ForAccount(region, projectID string) *Policy {
+	mu.Lock()
+	defer mu.Unlock()
+
+	key := region + "/" + projectID
+	p, ok := policies[key]
+	if !ok {
+		p = NewPolicy(QPS, Burst)
+		policies[key] = p
+	}
+	return p
+}
+
+// Throttled returns true if the request was rejected by the rate limit of
+// the API gateway.
+func Throttled(err error) bool {
+	if err == nil || !huawei.IsHuaweiError(err) {
+		return false
+	}
+	hwerr, _ := huawei.NewHuaweiError(err)
+	return hwerr.StatusCode == 429 || throttlingCodes[hwerr.ErrorCode]
+}
+
+// Retryable returns true if the operation failed with err can be retried.
+// The throttled requests were not processed so they are always retryable,
+// the read operations are also retried after the server and network errors.
+func Retryable(operation string, err error) bool {
+	if err == nil {
+		return false
+	}
+	if Throttled(err) {
+		return true
+	}
+	read := false
+	for _, prefix := range readOperationPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			read = true
+			break
+		}
+	}
+	if !read {
+		return false
+	}
+	var (
+		connErr    *sdkerr.ConnectionError
+		timeoutErr *sdkerr.RequestTimeoutError
+		netErr     net.Error
+	)
+	if errors.As(err, &connErr) || errors.As(err, &timeoutErr) || errors.As(err, &netErr) {
+		return true
+	}
+	if !huawei.IsHuaweiError(err) {
+		return false
+	}
+	hwerr, _ := huawei.NewHuaweiError(err)
+	switch hwerr.StatusCode {
+	case 500, 502, 503, 504:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the retry, the exponential backoff with
+// jitter in [delay/2, delay).
+func (p *Policy) backoff(retry int) time.Duration {
+	delay := p.baseDelay
+	for i := 0; i < retry && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// Call sends the request of the service operation by call, the requests are
+// sent at the rate of the policy and retried on the retryable errors. The
+// request is sent only once if policy is nil.
+func Call[T any](p *Policy, service, operation string, call func() (T, error)) (T, error) {
+	if p == nil {
+		return call()
+	}
+	for retry := 0; ; retry++ {
+		p.limiter.Accept()
+		res, err := call()
+		if err == nil || retry >= p.maxRetries || !Retryable(operation, err) {
+			return res, err
+		}
+		delay := p.backoff(retry)
+		metrics.ObserveHuaweiAPIRetry(service, operation)
+		logrus.Debugf("%s %s failed, retry in %v: %v", service, operation, delay, err)
+		p.sleep(delay)
+	}
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Retryable(t *testing.T) {
+	assert := assert.New(t)
+	throttled := sdkerr.ServiceResponseError{StatusCode: 429, ErrorCode: "APIGW.0308", ErrorMessage: "throttled"}
+	unavailable := sdkerr.ServiceResponseError{StatusCode: 503, ErrorCode: "CCE.01500001", ErrorMessage: "unavailable"}
+	notFound := sdkerr.ServiceResponseError{StatusCode: 404, ErrorCode: "VPC.0202", ErrorMessage: "not found"}
+
+	assert.True(Throttled(throttled))
+	assert.False(Throttled(unavailable))
+	assert.False(Throttled(fmt.Errorf("AAABBB")))
+	assert.False(Throttled(nil))
+
+	assert.True(Retryable("CreateCluster", throttled))
+	assert.True(Retryable("ShowCluster", throttled))
+	assert.True(Retryable("ShowCluster", unavailable))
+	assert.False(Retryable("CreateCluster", unavailable))
+	assert.False(Retryable("ShowCluster", notFound))
+	assert.True(Retryable("ListClusters", sdkerr.NewConnectionError("connection reset")))
+	assert.False(Retryable("DeleteCluster", sdkerr.NewRequestTimeoutError("timeout")))
+	assert.False(Retryable("ShowCluster", nil))
+}
+
+func Test_Policy_backoff(t *testing.T) {
+	assert := assert.New(t)
+	p := NewPolicy(10, 10)
+	p.baseDelay = 100 * time.Millisecond
+	p.maxDelay = time.Second
+	for retry, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for i := 0; i < 10; i++ {
+			d := p.backoff(retry)
+			assert.GreaterOrEqual(d, max/2)
+			assert.Less(d, max)
+		}
+	}
+}
+
+func Test_Call(t *testing.T) {
+	assert := assert.New(t)
+	p := NewPolicy(1000, 100)
+	var delays []time.Duration
+	p.sleep = func(d time.Duration) {
+		delays = append(delays, d)
+	}
+	throttled := sdkerr.ServiceResponseError{StatusCode: 429, ErrorCode: "APIGW.0308", ErrorMessage: "throttled"}
+
+	// The throttled request is retried until succeeded.
+	calls := 0
+	res, err := Call(p, "cce", "CreateCluster", func() (string, error) {
+		calls++
+		if calls < 3 {
+			return "", throttled
+		}
+		return "ok", nil
+	})
+	assert.Nil(err)
+	assert.Equal("ok", res)
+	assert.Equal(3, calls)
+	assert.Len(delays, 2)
+
+	// The request is not retried more than the max retries.
+	calls, delays = 0, nil
+	_, err = Call(p, "cce", "CreateCluster", func() (string, error) {
+		calls++
+		return "", throttled
+	})
+	assert.Equal(throttled, err)
+	assert.Equal(p.maxRetries+1, calls)
+	assert.Len(delays, p.maxRetries)
+
+	// The non-retryable error is returned immediately.
+	calls = 0
+	_, err = Call(p, "cce", "CreateCluster", func() (string, error) {
+		calls++
+		return "", fmt.Errorf("invalid request")
+	})
+	assert.NotNil(err)
+	assert.Equal(1, calls)
+
+	// The request is sent once without policy.
+	calls = 0
+	_, err = Call(nil, "cce", "ShowCluster", func() (string, error) {
+		calls++
+		return "", throttled
+	})
+	assert.NotNil(err)
+	assert.Equal(1, calls)
+}
+
+func Test_ForAccount(t *testing.T) {
+	assert := assert.New(t)
+	assert.Same(ForAccount("cn-north-4", "project-1"), ForAccount("cn-north-4", "project-1"))
+	assert.NotSame(ForAccount("cn-north-4", "project-1"), ForAccount("cn-east-3", "project-1"))
+	assert.NotSame(ForAccount("cn-north-4", "project-1"), ForAccount("cn-north-4", "project-2"))
+}
@@ -0,0 +1,28 @@
+package vpc
+
+import (
+	vpc "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+)
+
+// VpcAPI is the VPC (Virtual Private Cloud) API used by the operator,
+// the *vpc.VpcClient of the Huawei Cloud SDK is the default implementation.
+type VpcAPI interface {
+	CreateVpc(request *model.CreateVpcRequest) (*model.CreateVpcResponse, error)
+	ShowVpc(request *model.ShowVpcRequest) (*model.ShowVpcResponse, error)
+	DeleteVpc(request *model.DeleteVpcRequest) (*model.DeleteVpcResponse, error)
+	CreateSubnet(request *model.CreateSubnetRequest) (*model.CreateSubnetResponse, error)
+	ShowSubnet(request *model.ShowSubnetRequest) (*model.ShowSubnetResponse, error)
+	DeleteSubnet(request *model.DeleteSubnetRequest) (*model.DeleteSubnetResponse, error)
+	ListVpcRoutes(request *model.ListVpcRoutesRequest) (*model.ListVpcRoutesResponse, error)
+	ShowVpcRoute(request *model.ShowVpcRouteRequest) (*model.ShowVpcRouteResponse, error)
+	DeleteVpcRoute(request *model.DeleteVpcRouteRequest) (*model.DeleteVpcRouteResponse, error)
+	ListRouteTables(request *model.ListRouteTablesRequest) (*model.ListRouteTablesResponse, error)
+	ListSecurityGroups(request *model.ListSecurityGroupsRequest) (*model.ListSecurityGroupsResponse, error)
+	ListVpcsByTags(request *model.ListVpcsByTagsRequest) (*model.ListVpcsByTagsResponse, error)
+	ListSubnetsByTags(request *model.ListSubnetsByTagsRequest) (*model.ListSubnetsByTagsResponse, error)
+	BatchCreateVpcTags(request *model.BatchCreateVpcTagsRequest) (*model.BatchCreateVpcTagsResponse, error)
+	BatchCreateSubnetTags(request *model.BatchCreateSubnetTagsRequest) (*model.BatchCreateSubnetTagsResponse, error)
+}
+
+var _ VpcAPI = (*vpc.VpcClient)(nil)
@@ -0,0 +1,126 @@
+package vpc
+
+import (
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+)
+
+// metricsVpcAPI records the request metrics of the VpcAPI.
+type metricsVpcAPI struct {
+	api    VpcAPI
+	policy *ratelimit.Policy
+}
+
+// WithMetrics returns the VpcAPI recording the request metrics of api, the
+// requests are rate limited and retried by policy if not nil.
+func WithMetrics(api VpcAPI, policy *ratelimit.Policy) VpcAPI {
+	return &metricsVpcAPI{api: api, policy: policy}
+}
+
+func (m *metricsVpcAPI) CreateVpc(request *model.CreateVpcRequest) (*model.CreateVpcResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "CreateVpc", func() (_ *model.CreateVpcResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "CreateVpc", time.Now(), &err)
+		return m.api.CreateVpc(request)
+	})
+}
+
+func (m *metricsVpcAPI) ShowVpc(request *model.ShowVpcRequest) (*model.ShowVpcResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "ShowVpc", func() (_ *model.ShowVpcResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "ShowVpc", time.Now(), &err)
+		return m.api.ShowVpc(request)
+	})
+}
+
+func (m *metricsVpcAPI) DeleteVpc(request *model.DeleteVpcRequest) (*model.DeleteVpcResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "DeleteVpc", func() (_ *model.DeleteVpcResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "DeleteVpc", time.Now(), &err)
+		return m.api.DeleteVpc(request)
+	})
+}
+
+func (m *metricsVpcAPI) CreateSubnet(request *model.CreateSubnetRequest) (*model.CreateSubnetResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "CreateSubnet", func() (_ *model.CreateSubnetResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "CreateSubnet", time.Now(), &err)
+		return m.api.CreateSubnet(request)
+	})
+}
+
+func (m *metricsVpcAPI) ShowSubnet(request *model.ShowSubnetRequest) (*model.ShowSubnetResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "ShowSubnet", func() (_ *model.ShowSubnetResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "ShowSubnet", time.Now(), &err)
+		return m.api.ShowSubnet(request)
+	})
+}
+
+func (m *metricsVpcAPI) DeleteSubnet(request *model.DeleteSubnetRequest) (*model.DeleteSubnetResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "DeleteSubnet", func() (_ *model.DeleteSubnetResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "DeleteSubnet", time.Now(), &err)
+		return m.api.DeleteSubnet(request)
+	})
+}
+
+func (m *metricsVpcAPI) ListVpcRoutes(request *model.ListVpcRoutesRequest) (*model.ListVpcRoutesResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "ListVpcRoutes", func() (_ *model.ListVpcRoutesResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "ListVpcRoutes", time.Now(), &err)
+		return m.api.ListVpcRoutes(request)
+	})
+}
+
+func (m *metricsVpcAPI) ShowVpcRoute(request *model.ShowVpcRouteRequest) (*model.ShowVpcRouteResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "ShowVpcRoute", func() (_ *model.ShowVpcRouteResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "ShowVpcRoute", time.Now(), &err)
+		return m.api.ShowVpcRoute(request)
+	})
+}
+
+func (m *metricsVpcAPI) DeleteVpcRoute(request *model.DeleteVpcRouteRequest) (*model.DeleteVpcRouteResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "DeleteVpcRoute", func() (_ *model.DeleteVpcRouteResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "DeleteVpcRoute", time.Now(), &err)
+		return m.api.DeleteVpcRoute(request)
+	})
+}
+
+func (m *metricsVpcAPI) ListRouteTables(request *model.ListRouteTablesRequest) (*model.ListRouteTablesResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "ListRouteTables", func() (_ *model.ListRouteTablesResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "ListRouteTables", time.Now(), &err)
+		return m.api.ListRouteTables(request)
+	})
+}
+
+func (m *metricsVpcAPI) ListSecurityGroups(request *model.ListSecurityGroupsRequest) (*model.ListSecurityGroupsResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "ListSecurityGroups", func() (_ *model.ListSecurityGroupsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "ListSecurityGroups", time.Now(), &err)
+		return m.api.ListSecurityGroups(request)
+	})
+}
+
+func (m *metricsVpcAPI) ListVpcsByTags(request *model.ListVpcsByTagsRequest) (*model.ListVpcsByTagsResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "ListVpcsByTags", func() (_ *model.ListVpcsByTagsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "ListVpcsByTags", time.Now(), &err)
+		return m.api.ListVpcsByTags(request)
+	})
+}
+
+func (m *metricsVpcAPI) ListSubnetsByTags(request *model.ListSubnetsByTagsRequest) (*model.ListSubnetsByTagsResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "ListSubnetsByTags", func() (_ *model.ListSubnetsByTagsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "ListSubnetsByTags", time.Now(), &err)
+		return m.api.ListSubnetsByTags(request)
+	})
+}
+
+func (m *metricsVpcAPI) BatchCreateVpcTags(request *model.BatchCreateVpcTagsRequest) (*model.BatchCreateVpcTagsResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "BatchCreateVpcTags", func() (_ *model.BatchCreateVpcTagsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "BatchCreateVpcTags", time.Now(), &err)
+		return m.api.BatchCreateVpcTags(request)
+	})
+}
+
+func (m *metricsVpcAPI) BatchCreateSubnetTags(request *model.BatchCreateSubnetTagsRequest) (*model.BatchCreateSubnetTagsResponse, error) {
+	return ratelimit.Call(m.policy, "vpc", "BatchCreateSubnetTags", func() (_ *model.BatchCreateSubnetTagsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpc", "BatchCreateSubnetTags", time.Now(), &err)
+		return m.api.BatchCreateSubnetTags(request)
+	})
+}
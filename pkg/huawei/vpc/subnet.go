@@ -3,12 +3,11 @@ package vpc
 import (
 	"github.com/cnrancher/cce-operator/pkg/huawei/common"
 	"github.com/cnrancher/cce-operator/pkg/utils"
-	vpc "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2"
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
 	"github.com/sirupsen/logrus"
 )
 
-func ShowSubnet(client *vpc.VpcClient, ID string) (*model.ShowSubnetResponse, error) {
+func ShowSubnet(client VpcAPI, ID string) (*model.ShowSubnetResponse, error) {
 	res, err := client.ShowSubnet(&model.ShowSubnetRequest{
 		SubnetId: ID,
 	})
@@ -18,7 +17,10 @@ func ShowSubnet(client *vpc.VpcClient, ID string) (*model.ShowSubnetResponse, er
 	return res, err
 }
 
-func CreateSubnet(client *vpc.VpcClient, name, vpcID, pDNS, sDNS string) (*model.CreateSubnetResponse, error) {
+// CreateSubnet creates the subnet in the VPC tagged with the owner if not nil.
+func CreateSubnet(
+	client VpcAPI, name, vpcID, pDNS, sDNS string, owner *common.Owner,
+) (*model.CreateSubnetResponse, error) {
 	request := &model.CreateSubnetRequest{
 		Body: &model.CreateSubnetRequestBody{
 			Subnet: &model.CreateSubnetOption{
@@ -30,6 +32,7 @@ func CreateSubnet(client *vpc.VpcClient, name, vpcID, pDNS, sDNS string) (*model
 				SecondaryDns: &sDNS,
 				DhcpEnable:   utils.Pointer(true),
 				Description:  &common.DefaultResourceDescription,
+				Tags:         createTags(owner),
 			},
 		},
 	}
@@ -41,7 +44,7 @@ func CreateSubnet(client *vpc.VpcClient, name, vpcID, pDNS, sDNS string) (*model
 }
 
 func DeleteSubnet(
-	client *vpc.VpcClient, vpcID string, subnetID string,
+	client VpcAPI, vpcID string, subnetID string,
 ) (*model.DeleteSubnetResponse, error) {
 	res, err := client.DeleteSubnet(&model.DeleteSubnetRequest{
 		VpcId:    vpcID,
@@ -52,3 +55,49 @@ func DeleteSubnet(
 	}
 	return res, err
 }
+
+// ListSubnetsByTag returns the subnets having the tag of the value.
+func ListSubnetsByTag(client VpcAPI, key, value string) ([]model.ListResourceResp, error) {
+	var resources []model.ListResourceResp
+	for offset := int32(0); ; {
+		request := &model.ListSubnetsByTagsRequest{
+			Body: &model.ListSubnetsByTagsRequestBody{
+				Action: model.GetListSubnetsByTagsRequestBodyActionEnum().FILTER,
+				Limit:  utils.Pointer(listPageSize),
+				Offset: utils.Pointer(offset),
+				Tags:   &[]model.ListTag{{Key: key, Values: []string{value}}},
+			},
+		}
+		res, err := client.ListSubnetsByTags(request)
+		if err != nil {
+			logrus.Debugf("ListSubnetsByTags failed: %v", utils.PrintObject(request))
+			return nil, err
+		}
+		if res.Resources == nil {
+			return resources, nil
+		}
+		resources = append(resources, *res.Resources...)
+		if int32(len(*res.Resources)) < listPageSize {
+			return resources, nil
+		}
+		offset += int32(len(*res.Resources))
+	}
+}
+
+// TagSubnet adds the tags to the subnet.
+func TagSubnet(client VpcAPI, id string, tags []common.Tag) error {
+	req := &model.BatchCreateSubnetTagsRequest{
+		SubnetId: id,
+		Body: &model.BatchCreateSubnetTagsRequestBody{
+			Action: model.GetBatchCreateSubnetTagsRequestBodyActionEnum().CREATE,
+		},
+	}
+	for _, tag := range tags {
+		req.Body.Tags = append(req.Body.Tags, model.ResourceTag{Key: tag.Key, Value: tag.Value})
+	}
+	_, err := client.BatchCreateSubnetTags(req)
+	if err != nil {
+		logrus.Debugf("BatchCreateSubnetTags failed: %v", utils.PrintObject(req))
+	}
+	return err
+}
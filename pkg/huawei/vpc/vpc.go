@@ -18,12 +18,12 @@ var (
 func NewVpcClient(c *common.ClientAuth) *vpc.VpcClient {
 	return vpc.NewVpcClient(
 		vpc.VpcClientBuilder().
-			WithRegion(region.ValueOf(c.Region)).
+			WithRegion(c.ServiceRegion(region.ValueOf)).
 			WithCredential(c.Credential).
 			Build())
 }
 
-func ShowVPC(client *vpc.VpcClient, ID string) (*model.ShowVpcResponse, error) {
+func ShowVPC(client VpcAPI, ID string) (*model.ShowVpcResponse, error) {
 	res, err := client.ShowVpc(&model.ShowVpcRequest{
 		VpcId: ID,
 	})
@@ -33,13 +33,31 @@ func ShowVPC(client *vpc.VpcClient, ID string) (*model.ShowVpcResponse, error) {
 	return res, err
 }
 
-func CreateVPC(client *vpc.VpcClient, name, cidr string) (*model.CreateVpcResponse, error) {
+// listPageSize is the number of the resources per page listed by tags.
+const listPageSize int32 = 1000
+
+// createTags returns the tags of the create options in the key*value format,
+// nil if owner is nil.
+func createTags(owner *common.Owner) *[]string {
+	if owner == nil {
+		return nil
+	}
+	tags := []string{}
+	for _, tag := range owner.Tags() {
+		tags = append(tags, tag.Key+"*"+tag.Value)
+	}
+	return &tags
+}
+
+// CreateVPC creates the VPC tagged with the owner if not nil.
+func CreateVPC(client VpcAPI, name, cidr string, owner *common.Owner) (*model.CreateVpcResponse, error) {
 	request := &model.CreateVpcRequest{
 		Body: &model.CreateVpcRequestBody{
 			Vpc: &model.CreateVpcOption{
 				Name:        &name,
 				Cidr:        &cidr,
 				Description: &common.DefaultResourceDescription,
+				Tags:        createTags(owner),
 			},
 		},
 	}
@@ -50,7 +68,7 @@ func CreateVPC(client *vpc.VpcClient, name, cidr string) (*model.CreateVpcRespon
 	return res, err
 }
 
-func DeleteVPC(client *vpc.VpcClient, ID string) (*model.DeleteVpcResponse, error) {
+func DeleteVPC(client VpcAPI, ID string) (*model.DeleteVpcResponse, error) {
 	res, err := client.DeleteVpc(&model.DeleteVpcRequest{
 		VpcId: ID,
 	})
@@ -60,8 +78,36 @@ func DeleteVPC(client *vpc.VpcClient, ID string) (*model.DeleteVpcResponse, erro
 	return res, err
 }
 
+// ListVPCsByTag returns the VPCs having the tag of the value.
+func ListVPCsByTag(client VpcAPI, key, value string) ([]model.ListResourceResp, error) {
+	var resources []model.ListResourceResp
+	for offset := int32(0); ; {
+		request := &model.ListVpcsByTagsRequest{
+			Body: &model.ListVpcsByTagsRequestBody{
+				Action: model.GetListVpcsByTagsRequestBodyActionEnum().FILTER,
+				Limit:  utils.Pointer(listPageSize),
+				Offset: utils.Pointer(offset),
+				Tags:   &[]model.ListTag{{Key: key, Values: []string{value}}},
+			},
+		}
+		res, err := client.ListVpcsByTags(request)
+		if err != nil {
+			logrus.Debugf("ListVpcsByTags failed: %v", utils.PrintObject(request))
+			return nil, err
+		}
+		if res.Resources == nil {
+			return resources, nil
+		}
+		resources = append(resources, *res.Resources...)
+		if int32(len(*res.Resources)) < listPageSize {
+			return resources, nil
+		}
+		offset += int32(len(*res.Resources))
+	}
+}
+
 func GetVpcRoutes(
-	client *vpc.VpcClient, vpcID string,
+	client VpcAPI, vpcID string,
 ) (*model.ListVpcRoutesResponse, error) {
 	res, err := client.ListVpcRoutes(&model.ListVpcRoutesRequest{
 		VpcId: &vpcID,
@@ -72,7 +118,7 @@ func GetVpcRoutes(
 	return res, err
 }
 
-func ShowVpcRoute(client *vpc.VpcClient, RouteID string) (*model.ShowVpcRouteResponse, error) {
+func ShowVpcRoute(client VpcAPI, RouteID string) (*model.ShowVpcRouteResponse, error) {
 	res, err := client.ShowVpcRoute(&model.ShowVpcRouteRequest{
 		RouteId: RouteID,
 	})
@@ -82,7 +128,7 @@ func ShowVpcRoute(client *vpc.VpcClient, RouteID string) (*model.ShowVpcRouteRes
 	return res, err
 }
 
-func DeleteVpcRoute(client *vpc.VpcClient, RouteID string) (*model.DeleteVpcRouteResponse, error) {
+func DeleteVpcRoute(client VpcAPI, RouteID string) (*model.DeleteVpcRouteResponse, error) {
 	res, err := client.DeleteVpcRoute(&model.DeleteVpcRouteRequest{
 		RouteId: RouteID,
 	})
@@ -93,7 +139,7 @@ func DeleteVpcRoute(client *vpc.VpcClient, RouteID string) (*model.DeleteVpcRout
 }
 
 func ListRouteTables(
-	client *vpc.VpcClient, RtID, VpcID, SubnetID string,
+	client VpcAPI, RtID, VpcID, SubnetID string,
 ) (*model.ListRouteTablesResponse, error) {
 	request := &model.ListRouteTablesRequest{
 		Id:       &RtID,
@@ -107,7 +153,7 @@ func ListRouteTables(
 	return res, err
 }
 
-func ListSecurityGroups(client *vpc.VpcClient, vpcID string) (*model.ListSecurityGroupsResponse, error) {
+func ListSecurityGroups(client VpcAPI, vpcID string) (*model.ListSecurityGroupsResponse, error) {
 	res, err := client.ListSecurityGroups(&model.ListSecurityGroupsRequest{
 		VpcId: &vpcID,
 	})
@@ -116,3 +162,21 @@ func ListSecurityGroups(client *vpc.VpcClient, vpcID string) (*model.ListSecurit
 	}
 	return res, err
 }
+
+// TagVPC adds the tags to the VPC.
+func TagVPC(client VpcAPI, id string, tags []common.Tag) error {
+	req := &model.BatchCreateVpcTagsRequest{
+		VpcId: id,
+		Body: &model.BatchCreateVpcTagsRequestBody{
+			Action: model.GetBatchCreateVpcTagsRequestBodyActionEnum().CREATE,
+		},
+	}
+	for _, tag := range tags {
+		req.Body.Tags = append(req.Body.Tags, model.ResourceTag{Key: tag.Key, Value: tag.Value})
+	}
+	_, err := client.BatchCreateVpcTags(req)
+	if err != nil {
+		logrus.Debugf("BatchCreateVpcTags failed: %v", utils.PrintObject(req))
+	}
+	return err
+}
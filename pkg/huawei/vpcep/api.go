@@ -0,0 +1,16 @@
+package vpcep
+
+import (
+	vpcep "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpcep/v1"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpcep/v1/model"
+)
+
+// VpcepAPI is the VPC Endpoint API used by the operator,
+// the *vpcep.VpcepClient of the Huawei Cloud SDK is the default implementation.
+type VpcepAPI interface {
+	ListEndpointService(request *model.ListEndpointServiceRequest) (*model.ListEndpointServiceResponse, error)
+	ListServiceDetails(request *model.ListServiceDetailsRequest) (*model.ListServiceDetailsResponse, error)
+	DeleteEndpointService(request *model.DeleteEndpointServiceRequest) (*model.DeleteEndpointServiceResponse, error)
+}
+
+var _ VpcepAPI = (*vpcep.VpcepClient)(nil)
@@ -0,0 +1,42 @@
+package vpcep
+
+import (
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei/ratelimit"
+	"github.com/cnrancher/cce-operator/pkg/metrics"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpcep/v1/model"
+)
+
+// metricsVpcepAPI records the request metrics of the VpcepAPI.
+type metricsVpcepAPI struct {
+	api    VpcepAPI
+	policy *ratelimit.Policy
+}
+
+// WithMetrics returns the VpcepAPI recording the request metrics of api, the
+// requests are rate limited and retried by policy if not nil.
+func WithMetrics(api VpcepAPI, policy *ratelimit.Policy) VpcepAPI {
+	return &metricsVpcepAPI{api: api, policy: policy}
+}
+
+func (m *metricsVpcepAPI) ListEndpointService(request *model.ListEndpointServiceRequest) (*model.ListEndpointServiceResponse, error) {
+	return ratelimit.Call(m.policy, "vpcep", "ListEndpointService", func() (_ *model.ListEndpointServiceResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpcep", "ListEndpointService", time.Now(), &err)
+		return m.api.ListEndpointService(request)
+	})
+}
+
+func (m *metricsVpcepAPI) ListServiceDetails(request *model.ListServiceDetailsRequest) (*model.ListServiceDetailsResponse, error) {
+	return ratelimit.Call(m.policy, "vpcep", "ListServiceDetails", func() (_ *model.ListServiceDetailsResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpcep", "ListServiceDetails", time.Now(), &err)
+		return m.api.ListServiceDetails(request)
+	})
+}
+
+func (m *metricsVpcepAPI) DeleteEndpointService(request *model.DeleteEndpointServiceRequest) (*model.DeleteEndpointServiceResponse, error) {
+	return ratelimit.Call(m.policy, "vpcep", "DeleteEndpointService", func() (_ *model.DeleteEndpointServiceResponse, err error) {
+		defer metrics.ObserveHuaweiAPI("vpcep", "DeleteEndpointService", time.Now(), &err)
+		return m.api.DeleteEndpointService(request)
+	})
+}
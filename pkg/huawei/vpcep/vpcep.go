@@ -11,13 +11,13 @@ import (
 func NewVpcepClient(c *common.ClientAuth) *vpcep.VpcepClient {
 	return vpcep.NewVpcepClient(
 		vpcep.VpcepClientBuilder().
-			WithRegion(region.ValueOf(c.Region)).
+			WithRegion(c.ServiceRegion(region.ValueOf)).
 			WithCredential(c.Credential).
 			Build())
 }
 
 func ListEndpointService(
-	client *vpcep.VpcepClient, svcID string,
+	client VpcepAPI, svcID string,
 ) (*model.ListEndpointServiceResponse, error) {
 	res, err := client.ListEndpointService(&model.ListEndpointServiceRequest{
 		Id: &svcID,
@@ -28,7 +28,7 @@ func ListEndpointService(
 	return res, err
 }
 
-func ListServiceDetails(client *vpcep.VpcepClient, svcID string) (*model.ListServiceDetailsResponse, error) {
+func ListServiceDetails(client VpcepAPI, svcID string) (*model.ListServiceDetailsResponse, error) {
 	res, err := client.ListServiceDetails(&model.ListServiceDetailsRequest{
 		VpcEndpointServiceId: svcID,
 	})
@@ -38,7 +38,7 @@ func ListServiceDetails(client *vpcep.VpcepClient, svcID string) (*model.ListSer
 	return res, err
 }
 
-func DeleteVpcepService(client *vpcep.VpcepClient, ID string) (*model.DeleteEndpointServiceResponse, error) {
+func DeleteVpcepService(client VpcepAPI, ID string) (*model.DeleteEndpointServiceResponse, error) {
 	res, err := client.DeleteEndpointService(&model.DeleteEndpointServiceRequest{
 		VpcEndpointServiceId: ID,
 	})
@@ -0,0 +1,127 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Default durations of the leader election, they are the same as the
+// defaults of the Rancher controllers.
+const (
+	DefaultLeaseDuration = 45 * time.Second
+	DefaultRenewDeadline = 30 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+// Config is the config of the Lease based leader election.
+type Config struct {
+	// Namespace and Name of the Lease object.
+	Namespace string
+	Name      string
+	// Identity of the current instance, the hostname is used if empty.
+	Identity string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// RunOrDie runs the leader election and calls the callback once the current
+// instance becomes the leader. It blocks until the context is done and exits
+// the process if the leadership is lost.
+func RunOrDie(ctx context.Context, client kubernetes.Interface, config Config, cb func(ctx context.Context)) {
+	if err := Run(ctx, client, config, cb, func() {
+		logrus.Fatalf("leader election lost for [%s/%s]", config.Namespace, config.Name)
+	}); err != nil {
+		logrus.Fatalf("failed to start leader election: %v", err)
+	}
+}
+
+// Run runs the leader election until the context is done, onLost is called
+// if the leadership is lost before the context is done.
+func Run(
+	ctx context.Context, client kubernetes.Interface, config Config, cb func(ctx context.Context), onLost func(),
+) error {
+	lec, err := newLeaderElectionConfig(ctx, client, config, cb, onLost)
+	if err != nil {
+		return err
+	}
+	le, err := leaderelection.NewLeaderElector(*lec)
+	if err != nil {
+		return fmt.Errorf("invalid leader election config: %w", err)
+	}
+	logrus.Infof("waiting for the leader election of [%s/%s] as [%s]",
+		config.Namespace, config.Name, lec.Lock.Identity())
+	le.Run(ctx)
+	return nil
+}
+
+func newLeaderElectionConfig(
+	ctx context.Context, client kubernetes.Interface, config Config, cb func(ctx context.Context), onLost func(),
+) (*leaderelection.LeaderElectionConfig, error) {
+	if config.Namespace == "" || config.Name == "" {
+		return nil, fmt.Errorf("namespace and name of the leader election lease are required")
+	}
+	identity := config.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname: %w", err)
+		}
+		identity = hostname
+	}
+	rl, err := resourcelock.New(resourcelock.LeasesResourceLock,
+		config.Namespace,
+		config.Name,
+		client.CoreV1(),
+		client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{
+			Identity: identity,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create leader lock: %w", err)
+	}
+
+	leaseDuration, renewDeadline, retryPeriod := config.LeaseDuration, config.RenewDeadline, config.RetryPeriod
+	if leaseDuration == 0 {
+		leaseDuration = DefaultLeaseDuration
+	}
+	if renewDeadline == 0 {
+		renewDeadline = DefaultRenewDeadline
+	}
+	if retryPeriod == 0 {
+		retryPeriod = DefaultRetryPeriod
+	}
+
+	return &leaderelection.LeaderElectionConfig{
+		Lock:          rl,
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logrus.Infof("became the leader of [%s/%s]", config.Namespace, config.Name)
+				cb(ctx)
+			},
+			OnStoppedLeading: func() {
+				select {
+				case <-ctx.Done():
+					// The election is stopped by the caller, the lease is
+					// released so another instance can take over at once.
+					logrus.Infof("leader election of [%s/%s] stopped", config.Namespace, config.Name)
+				default:
+					onLost()
+				}
+			},
+		},
+		ReleaseOnCancel: true,
+		Name:            config.Name,
+	}, nil
+}
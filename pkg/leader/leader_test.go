@@ -0,0 +1,84 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestConfig(identity string) Config {
+	return Config{
+		Namespace:     "cattle-system",
+		Name:          "cce-operator",
+		Identity:      identity,
+		LeaseDuration: 2 * time.Second,
+		RenewDeadline: time.Second,
+		RetryPeriod:   100 * time.Millisecond,
+	}
+}
+
+func Test_Run(t *testing.T) {
+	assert := assert.New(t)
+	client := fake.NewSimpleClientset()
+	lost := func() { t.Error("leadership should not be lost") }
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	leading1 := make(chan struct{})
+	stopped1 := make(chan struct{})
+	go func() {
+		assert.Nil(Run(ctx1, client, newTestConfig("instance-1"), func(context.Context) {
+			close(leading1)
+		}, lost))
+		close(stopped1)
+	}()
+	select {
+	case <-leading1:
+	case <-time.After(5 * time.Second):
+		t.Fatal("instance-1 should become the leader")
+	}
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	leading2 := make(chan struct{})
+	go func() {
+		assert.Nil(Run(ctx2, client, newTestConfig("instance-2"), func(context.Context) {
+			close(leading2)
+		}, lost))
+	}()
+	select {
+	case <-leading2:
+		t.Fatal("instance-2 should not become the leader while instance-1 is leading")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// The lease is released once the leader stops.
+	cancel1()
+	<-stopped1
+	select {
+	case <-leading2:
+	case <-time.After(5 * time.Second):
+		t.Fatal("instance-2 should become the leader after instance-1 stopped")
+	}
+	lease, err := client.CoordinationV1().Leases("cattle-system").Get(context.Background(), "cce-operator", metav1.GetOptions{})
+	if assert.Nil(err) {
+		assert.Equal("instance-2", *lease.Spec.HolderIdentity)
+	}
+}
+
+func Test_Run_InvalidConfig(t *testing.T) {
+	assert := assert.New(t)
+	client := fake.NewSimpleClientset()
+	cb := func(context.Context) {}
+
+	config := newTestConfig("instance-1")
+	config.RenewDeadline = config.LeaseDuration
+	assert.ErrorContains(Run(context.Background(), client, config, cb, nil), "renewDeadline")
+
+	config = newTestConfig("instance-1")
+	config.Namespace = ""
+	assert.ErrorContains(Run(context.Background(), client, config, cb, nil), "namespace")
+}
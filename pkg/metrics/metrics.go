@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cnrancher/cce-operator/pkg/huawei"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const namespace = "cce_operator"
+
+// Results of the reconciles and clean-up attempts.
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_total",
+		Help:      "Number of the CCEClusterConfig reconciles per phase handler and result.",
+	}, []string{"handler", "result"})
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of the CCEClusterConfig reconciles per phase handler.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"handler"})
+
+	huaweiAPIRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "huawei_api_requests_total",
+		Help:      "Number of the Huawei Cloud API requests per service and operation.",
+	}, []string{"service", "operation"})
+	huaweiAPIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "huawei_api_request_duration_seconds",
+		Help:      "Latency of the Huawei Cloud API requests per service and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+	huaweiAPIErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "huawei_api_errors_total",
+		Help:      "Number of the failed Huawei Cloud API requests per service, operation and error code.",
+	}, []string{"service", "operation", "error_code"})
+	huaweiAPIRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "huawei_api_retries_total",
+		Help:      "Number of the retries of the failed Huawei Cloud API requests per service and operation.",
+	}, []string{"service", "operation"})
+
+	cleanupAttemptsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cleanup_attempts_total",
+		Help:      "Number of the requests deleting the resources created for the clusters.",
+	}, []string{"resource", "result"})
+	orphanResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "orphan_resources",
+		Help:      "Number of the tagged resources whose CCEClusterConfig no longer exists, found by the last orphan scan.",
+	}, []string{"resource"})
+
+	clusterPhaseDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "clusters"),
+		"Number of the CCEClusterConfigs per phase.",
+		[]string{"phase"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		reconcileTotal,
+		reconcileDuration,
+		huaweiAPIRequestsTotal,
+		huaweiAPIRequestDuration,
+		huaweiAPIErrorsTotal,
+		huaweiAPIRetriesTotal,
+		cleanupAttemptsTotal,
+		orphanResources,
+	)
+}
+
+func result(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultSuccess
+}
+
+// ObserveReconcile records the result and duration of a phase handler.
+func ObserveReconcile(handler string, duration time.Duration, err error) {
+	reconcileTotal.WithLabelValues(handler, result(err)).Inc()
+	reconcileDuration.WithLabelValues(handler).Observe(duration.Seconds())
+}
+
+// ObserveHuaweiAPI records the Huawei Cloud API request started at start,
+// it is designed to be deferred with the pointer of the returned error.
+func ObserveHuaweiAPI(service, operation string, start time.Time, err *error) {
+	huaweiAPIRequestsTotal.WithLabelValues(service, operation).Inc()
+	huaweiAPIRequestDuration.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+	if err == nil || *err == nil {
+		return
+	}
+	code := "unknown"
+	if huawei.IsHuaweiError(*err) {
+		if hwerr, _ := huawei.NewHuaweiError(*err); hwerr.ErrorCode != "" {
+			code = hwerr.ErrorCode
+		}
+	}
+	huaweiAPIErrorsTotal.WithLabelValues(service, operation, code).Inc()
+}
+
+// ObserveHuaweiAPIRetry records a retry of the failed Huawei Cloud API request.
+func ObserveHuaweiAPIRetry(service, operation string) {
+	huaweiAPIRetriesTotal.WithLabelValues(service, operation).Inc()
+}
+
+// ObserveCleanup records a request deleting the cluster resource.
+func ObserveCleanup(resource string, err error) {
+	cleanupAttemptsTotal.WithLabelValues(resource, result(err)).Inc()
+}
+
+// SetOrphanResources records the number of the orphan resources of the kind
+// found by the last orphan scan.
+func SetOrphanResources(resource string, count int) {
+	orphanResources.WithLabelValues(resource).Set(float64(count))
+}
+
+// clusterPhaseCollector collects the number of clusters per phase
+// when the metrics are scraped.
+type clusterPhaseCollector struct {
+	count func() map[string]int
+}
+
+func (c *clusterPhaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clusterPhaseDesc
+}
+
+func (c *clusterPhaseCollector) Collect(ch chan<- prometheus.Metric) {
+	for phase, n := range c.count() {
+		ch <- prometheus.MustNewConstMetric(clusterPhaseDesc, prometheus.GaugeValue, float64(n), phase)
+	}
+}
+
+// RegisterClusterPhases registers the gauge of clusters per phase,
+// count returns the number of the clusters of each phase.
+func RegisterClusterPhases(count func() map[string]int) {
+	err := prometheus.Register(&clusterPhaseCollector{count: count})
+	if err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		logrus.Warnf("failed to register cluster phase metrics: %v", err)
+	}
+}
+
+// Serve serves the metrics on the address until the context is done.
+func Serve(ctx context.Context, address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		logrus.Infof("serving metrics on [%s]", address)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Errorf("failed to serve metrics: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ObserveHuaweiAPI(t *testing.T) {
+	assert := assert.New(t)
+
+	observe := func(err error) {
+		defer ObserveHuaweiAPI("vpc", "ShowVpc", time.Now(), &err)
+	}
+	observe(nil)
+	observe(&sdkerr.ServiceResponseError{
+		StatusCode: 404,
+		ErrorCode:  "VPC.0012",
+	})
+	observe(fmt.Errorf("connection refused"))
+
+	assert.Equal(3.0, testutil.ToFloat64(huaweiAPIRequestsTotal.WithLabelValues("vpc", "ShowVpc")))
+	assert.Equal(1.0, testutil.ToFloat64(huaweiAPIErrorsTotal.WithLabelValues("vpc", "ShowVpc", "VPC.0012")))
+	assert.Equal(1.0, testutil.ToFloat64(huaweiAPIErrorsTotal.WithLabelValues("vpc", "ShowVpc", "unknown")))
+}
+
+func Test_ObserveReconcile(t *testing.T) {
+	assert := assert.New(t)
+
+	ObserveReconcile("create", time.Second, nil)
+	ObserveReconcile("create", time.Second, fmt.Errorf("failed"))
+	ObserveCleanup("vpc", nil)
+	SetOrphanResources("vpc", 2)
+
+	assert.Equal(1.0, testutil.ToFloat64(reconcileTotal.WithLabelValues("create", resultSuccess)))
+	assert.Equal(1.0, testutil.ToFloat64(reconcileTotal.WithLabelValues("create", resultError)))
+	assert.Equal(1.0, testutil.ToFloat64(cleanupAttemptsTotal.WithLabelValues("vpc", resultSuccess)))
+	assert.Equal(2.0, testutil.ToFloat64(orphanResources.WithLabelValues("vpc")))
+}
+
+func Test_clusterPhaseCollector(t *testing.T) {
+	c := &clusterPhaseCollector{
+		count: func() map[string]int {
+			return map[string]int{"active": 2, "creating": 1}
+		},
+	}
+	expected := `
+# HELP cce_operator_clusters Number of the CCEClusterConfigs per phase.
+# TYPE cce_operator_clusters gauge
+cce_operator_clusters{phase="active"} 2
+cce_operator_clusters{phase="creating"} 1
+`
+	assert.Nil(t, testutil.CollectAndCompare(c, strings.NewReader(expected)))
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/cnrancher/cce-operator/pkg/controller"
+	"github.com/rancher/wrangler/v2/pkg/webhook"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// validate runs the validations of the controller synchronously when the
+// CCEClusterConfig is created or updated.
+func validate(resp *webhook.Response, req *webhook.Request) error {
+	config, err := decodeConfig(req.DecodeObject)
+	if err != nil {
+		return err
+	}
+
+	switch req.Operation {
+	case admissionv1.Create:
+		err = controller.ValidateCreate(config)
+	case admissionv1.Update:
+		old, decodeErr := decodeConfig(req.DecodeOldObject)
+		if decodeErr != nil {
+			return decodeErr
+		}
+		err = validateUpdate(old, config)
+	}
+	if err != nil {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: err.Error(),
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return nil
+	}
+	resp.Allowed = true
+	return nil
+}
+
+func validateUpdate(old, config *ccev1.CCEClusterConfig) error {
+	// Skip the validation if the spec is unchanged, such as updating the
+	// finalizers when the config is being deleted.
+	if config.DeletionTimestamp != nil || reflect.DeepEqual(old.Spec, config.Spec) {
+		return nil
+	}
+	if err := controller.ValidateImmutable(old, config); err != nil {
+		return err
+	}
+	if !config.Spec.Imported && config.Spec.ClusterID == "" {
+		// The cluster is not created yet.
+		return controller.ValidateCreate(config)
+	}
+	return controller.ValidateUpdate(config)
+}
+
+// mutate sets the default values of the CCEClusterConfig spec.
+func mutate(resp *webhook.Response, req *webhook.Request) error {
+	resp.Allowed = true
+	config, err := decodeConfig(req.DecodeObject)
+	if err != nil {
+		return err
+	}
+	if config.DeletionTimestamp != nil {
+		return nil
+	}
+
+	spec := config.Spec.DeepCopy()
+	controller.SetDefaults(spec)
+	if reflect.DeepEqual(spec, &config.Spec) {
+		return nil
+	}
+	patch, err := json.Marshal([]map[string]any{
+		{
+			"op":    "replace",
+			"path":  "/spec",
+			"value": spec,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	resp.Patch = patch
+	resp.PatchType = &patchType
+	return nil
+}
+
+func decodeConfig(decode func() (runtime.Object, error)) (*ccev1.CCEClusterConfig, error) {
+	obj, err := decode()
+	if err != nil {
+		return nil, err
+	}
+	config, ok := obj.(*ccev1.CCEClusterConfig)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+	return config, nil
+}
@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTestConfig() *ccev1.CCEClusterConfig {
+	return &ccev1.CCEClusterConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cce-test",
+			Namespace: "cattle-global-data",
+		},
+		Spec: ccev1.CCEClusterConfigSpec{
+			HuaweiCredentialSecret: "cattle-global-data:cc-test",
+			RegionID:               "cn-north-4",
+			Name:                   "cce-test",
+			Flavor:                 "cce.s1.small",
+			Version:                "v1.25",
+			KubernetesSvcIPRange:   "10.247.0.0/16",
+			NodePools: []ccev1.CCENodePool{
+				{
+					Name: "nodepool-1",
+					NodeTemplate: ccev1.CCENodeTemplate{
+						Flavor:          "c6.large.2",
+						AvailableZone:   "cn-north-4a",
+						OperatingSystem: "EulerOS 2.9",
+						SSHKey:          "ssh-key",
+						RootVolume:      ccev1.CCENodeVolume{Size: 50, Type: "SSD"},
+						DataVolumes:     []ccev1.CCENodeVolume{{Size: 100, Type: "SSD"}},
+					},
+					InitialNodeCount: 1,
+				},
+			},
+		},
+	}
+}
+
+func review(
+	t *testing.T, path string, operation admissionv1.Operation, config, old *ccev1.CCEClusterConfig,
+) *admissionv1.AdmissionResponse {
+	t.Helper()
+	raw := func(obj runtime.Object) runtime.RawExtension {
+		if obj == nil {
+			return runtime.RawExtension{}
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runtime.RawExtension{Raw: data}
+	}
+	body, err := json.Marshal(&admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:             "mock-uid",
+			RequestResource: &metav1.GroupVersionResource{Group: "cce.pandaria.io", Version: "v1", Resource: "cceclusterconfigs"},
+			Operation:       operation,
+			Object:          raw(config),
+			OldObject:       raw(old),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	NewHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d", rec.Code)
+	}
+	res := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(rec.Body).Decode(res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Response
+}
+
+func Test_validate(t *testing.T) {
+	assert := assert.New(t)
+
+	config := newTestConfig()
+	config.Spec.Type = "VirtualMachine"
+	res := review(t, ValidationPath, admissionv1.Create, config, nil)
+	assert.True(res.Allowed)
+	assert.Equal("mock-uid", string(res.UID))
+
+	config.Spec.NodePools[0].NodeTemplate.SSHKey = ""
+	res = review(t, ValidationPath, admissionv1.Create, config, nil)
+	assert.False(res.Allowed)
+	assert.Contains(res.Result.Message, "sshKey")
+
+	// Invalid spec is allowed if unchanged, such as removing finalizers.
+	old := config.DeepCopy()
+	config.Finalizers = nil
+	res = review(t, ValidationPath, admissionv1.Update, config, old)
+	assert.True(res.Allowed)
+
+	old = newTestConfig()
+	old.Spec.Type = "VirtualMachine"
+	old.Spec.ClusterID = "mock-cluster-id"
+	config = old.DeepCopy()
+	config.Spec.Version = "v1.27"
+	res = review(t, ValidationPath, admissionv1.Update, config, old)
+	assert.True(res.Allowed)
+
+	config.Spec.RegionID = "cn-east-3"
+	res = review(t, ValidationPath, admissionv1.Update, config, old)
+	assert.False(res.Allowed)
+	assert.Contains(res.Result.Message, "regionID")
+}
+
+func Test_mutate(t *testing.T) {
+	assert := assert.New(t)
+
+	config := newTestConfig()
+	res := review(t, MutationPath, admissionv1.Create, config, nil)
+	assert.True(res.Allowed)
+	if !assert.NotNil(res.PatchType) {
+		return
+	}
+	assert.Equal(admissionv1.PatchTypeJSONPatch, *res.PatchType)
+
+	original, _ := json.Marshal(config)
+	patch, err := jsonpatch.DecodePatch(res.Patch)
+	if !assert.Nil(err) {
+		return
+	}
+	patched, err := patch.Apply(original)
+	if !assert.Nil(err) {
+		return
+	}
+	result := &ccev1.CCEClusterConfig{}
+	assert.Nil(json.Unmarshal(patched, result))
+	assert.Equal("CCE", result.Spec.Category)
+	assert.Equal("VirtualMachine", result.Spec.Type)
+	assert.Equal("vpc-router", result.Spec.ContainerNetwork.Mode)
+	assert.Equal("iptables", result.Spec.KubeProxyMode)
+	assert.Equal("docker", result.Spec.NodePools[0].NodeTemplate.Runtime)
+
+	// No patch if defaults are already set.
+	res = review(t, MutationPath, admissionv1.Create, result, nil)
+	assert.True(res.Allowed)
+	assert.Nil(res.Patch)
+}
@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	ccev1 "github.com/cnrancher/cce-operator/pkg/apis/cce.pandaria.io/v1"
+	"github.com/rancher/wrangler/v2/pkg/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+// Paths of the admission webhooks, they should be the same as the
+// webhook configurations in the Helm chart.
+const (
+	ValidationPath = "/v1/webhook/validation/cceclusterconfigs"
+	MutationPath   = "/v1/webhook/mutation/cceclusterconfigs"
+)
+
+const (
+	certFile = "tls.crt"
+	keyFile  = "tls.key"
+)
+
+// NewHandler returns the HTTP handler serving the validating and mutating
+// admission webhooks of the CCEClusterConfig.
+func NewHandler() http.Handler {
+	validation := webhook.NewRouter()
+	validation.Resource("cceclusterconfigs").Type(&ccev1.CCEClusterConfig{}).HandleFunc(validate)
+	mutation := webhook.NewRouter()
+	mutation.Resource("cceclusterconfigs").Type(&ccev1.CCEClusterConfig{}).HandleFunc(mutate)
+
+	mux := http.NewServeMux()
+	mux.Handle(ValidationPath, validation)
+	mux.Handle(MutationPath, mutation)
+	return mux
+}
+
+// Serve serves the admission webhooks over HTTPS on the address until the
+// context is done, the serving certificate and key are loaded from the
+// tls.crt and tls.key files in the certDir and reloaded once changed.
+func Serve(ctx context.Context, address, certDir string) {
+	loader := &certLoader{
+		certFile: filepath.Join(certDir, certFile),
+		keyFile:  filepath.Join(certDir, keyFile),
+	}
+	server := &http.Server{
+		Addr:              address,
+		Handler:           NewHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: loader.getCertificate,
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		logrus.Infof("serving admission webhooks on [%s]", address)
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			logrus.Fatalf("failed to serve admission webhooks: %v", err)
+		}
+	}()
+}
+
+// certLoader loads the key pair from files, the key pair is reloaded if the
+// certificate file was modified, such as the mounted secret was updated.
+type certLoader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	modTime time.Time
+	cert    *tls.Certificate
+}
+
+func (l *certLoader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info, err := os.Stat(l.certFile)
+	if err != nil {
+		return nil, err
+	}
+	if l.cert != nil && info.ModTime().Equal(l.modTime) {
+		return l.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("loaded admission webhook certificate [%s]", l.certFile)
+	l.cert, l.modTime = &cert, info.ModTime()
+	return l.cert, nil
+}